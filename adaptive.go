@@ -0,0 +1,61 @@
+package lz4
+
+import (
+	"time"
+)
+
+// adaptiveLevels are the compression levels used by WithAdaptiveLevel, from
+// fastest to strongest, in the representation used by Writer.compressBlock.
+var adaptiveLevels = []int{-16, -4, 0, 3, 9}
+
+const (
+	// adaptiveDefault is the index in adaptiveLevels to start from.
+	adaptiveDefault = 2
+	// adaptiveWeight is the weight of the last block in the moving average.
+	adaptiveWeight = 0.25
+	// the level moves up when writing takes adaptiveUp times longer than
+	// compressing, and down when it takes less than adaptiveDown times as
+	// long
+	adaptiveUp   = 2
+	adaptiveDown = 0.5
+)
+
+// adaptiveLevel chooses the compression level of each block from the time
+// spent compressing and writing the previous blocks.
+type adaptiveLevel struct {
+	index int
+	// ratio is a moving average of the write time over the compression time
+	ratio float64
+}
+
+func newAdaptiveLevel() *adaptiveLevel {
+	return &adaptiveLevel{index: adaptiveDefault, ratio: 1}
+}
+
+func (a *adaptiveLevel) level() int {
+	return adaptiveLevels[a.index]
+}
+
+// update records the time spent compressing and writing a block.
+func (a *adaptiveLevel) update(compress, write time.Duration) {
+	if compress <= 0 {
+		compress = 1
+	}
+	ratio := float64(write) / float64(compress)
+	a.ratio = (1-adaptiveWeight)*a.ratio + adaptiveWeight*ratio
+
+	switch {
+	case a.ratio > adaptiveUp && a.index < len(adaptiveLevels)-1:
+		a.index++
+		a.ratio = 1
+	case a.ratio > adaptiveUp:
+		// already at the strongest level; do not let the average wind up,
+		// so it reacts as fast when things change
+		a.ratio = adaptiveUp
+	case a.ratio < adaptiveDown && a.index > 0:
+		a.index--
+		a.ratio = 1
+	case a.ratio < adaptiveDown:
+		a.ratio = adaptiveDown
+	}
+}
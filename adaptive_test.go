@@ -0,0 +1,72 @@
+package lz4
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+// slowWriter is a bytes.Buffer that sleeps on every write.
+type slowWriter struct {
+	bytes.Buffer
+	delay time.Duration
+}
+
+func (w *slowWriter) Write(p []byte) (int, error) {
+	time.Sleep(w.delay)
+	return w.Buffer.Write(p)
+}
+
+func TestAdaptiveLevel(t *testing.T) {
+	input, err := ioutil.ReadFile(sampleFilePath)
+	failOnError(t, "Failed reading sample", err)
+	for len(input) < 40*streamingBlockSize {
+		input = append(input, input...)
+	}
+
+	// a slow sink leaves time for stronger compression
+	slow := &slowWriter{delay: 5 * time.Millisecond}
+	w := NewWriter(slow, WithAdaptiveLevel())
+	_, err = w.Write(input[:10*streamingBlockSize])
+	failOnError(t, "Failed writing to compress object", err)
+	if w.adaptive.level() <= 0 {
+		t.Errorf("expected HC compression for a slow writer, got level %d", w.adaptive.level())
+	}
+
+	// a fast sink makes compression the bottleneck
+	fast := &slowWriter{}
+	fast.Grow(2 * len(input))
+	fast.Write(slow.Bytes())
+	w.underlyingWriter = fast
+	_, err = w.Write(input[10*streamingBlockSize:])
+	failOnError(t, "Failed writing to compress object", err)
+	if w.adaptive.level() > 0 {
+		t.Errorf("expected fast compression for a fast writer, got level %d", w.adaptive.level())
+	}
+	failOnError(t, "Failed closing writer", w.Close())
+
+	r := NewDecompressReader(&fast.Buffer)
+	out, err := ioutil.ReadAll(r)
+	failOnError(t, "Failed decompressing", err)
+	failOnError(t, "Failed closing reader", r.Close())
+	if !bytes.Equal(out, input) {
+		t.Fatalf("Decompressed output != input")
+	}
+}
+
+func TestAdaptiveLevelUpdate(t *testing.T) {
+	a := newAdaptiveLevel()
+	for i := 0; i < 100; i++ {
+		a.update(time.Millisecond, 10*time.Millisecond)
+	}
+	if a.level() != adaptiveLevels[len(adaptiveLevels)-1] {
+		t.Fatalf("expected strongest level, got %d", a.level())
+	}
+	for i := 0; i < 100; i++ {
+		a.update(10*time.Millisecond, time.Microsecond)
+	}
+	if a.level() != adaptiveLevels[0] {
+		t.Fatalf("expected fastest level, got %d", a.level())
+	}
+}
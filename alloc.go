@@ -0,0 +1,53 @@
+package lz4
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// Allocator returns a new slice of length size for the output of the *AllocHdr
+// functions. Its capacity may be larger.
+type Allocator func(size int) []byte
+
+// makeSlice is the default Allocator.
+func makeSlice(size int) []byte {
+	return make([]byte, size)
+}
+
+// AlignedAllocator returns an Allocator whose slices start at an address that is
+// a multiple of align, and whose capacity is rounded up to a multiple of align,
+// as required for direct I/O (O_DIRECT) with align set to the block size of
+// the device, typically 4096. The slice can then be extended to its capacity
+// to pad a write. align must be a power of two.
+func AlignedAllocator(align int) Allocator {
+	if align <= 0 || align&(align-1) != 0 {
+		panic(fmt.Sprintf("lz4: alignment %d is not a power of two", align))
+	}
+	return func(size int) []byte {
+		capacity := (size + align - 1) &^ (align - 1)
+		if capacity == 0 {
+			capacity = align
+		}
+		buf := make([]byte, capacity+align-1)
+		off := int(-uintptr(unsafe.Pointer(&buf[0])) & uintptr(align-1))
+		return buf[off : off+size : off+capacity]
+	}
+}
+
+// CompressAllocHdrWith is like CompressAllocHdr, but allocates the out slice
+// with alloc, so that it can be written with direct I/O without a copy when
+// alloc is an AlignedAllocator.
+func CompressAllocHdrWith(in []byte, alloc Allocator) (out []byte, err error) {
+	out = alloc(CompressBoundHdr(in))
+	count, err := CompressHdr(out, in)
+	if err != nil {
+		return out, err
+	}
+	return out[:count], nil
+}
+
+// UncompressAllocHdrWith is like UncompressAllocHdr, but allocates the result
+// with alloc if out is too small.
+func UncompressAllocHdrWith(out, in []byte, alloc Allocator) ([]byte, error) {
+	return uncompressAllocHdr(out, in, alloc)
+}
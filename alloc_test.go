@@ -0,0 +1,49 @@
+package lz4
+
+import (
+	"bytes"
+	"testing"
+	"unsafe"
+)
+
+func TestAlignedAllocator(t *testing.T) {
+	alloc := AlignedAllocator(4096)
+	for _, size := range []int{0, 1, 100, 4096, 4097, 10000} {
+		buf := alloc(size)
+		if len(buf) != size {
+			t.Errorf("size %d: got len %d", size, len(buf))
+		}
+		if cap(buf)%4096 != 0 || cap(buf) < size {
+			t.Errorf("size %d: got cap %d, want a multiple of 4096", size, cap(buf))
+		}
+		if addr := uintptr(unsafe.Pointer(&buf[:1][0])); addr%4096 != 0 {
+			t.Errorf("size %d: address %#x is not aligned", size, addr)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("no panic for an alignment that is not a power of two")
+		}
+	}()
+	AlignedAllocator(3000)
+}
+
+func TestCompressAllocHdrWith(t *testing.T) {
+	in := bytes.Repeat([]byte("aligned page "), 1000)
+	alloc := AlignedAllocator(512)
+	out, err := CompressAllocHdrWith(in, alloc)
+	failOnError(t, "Failed compressing", err)
+	if uintptr(unsafe.Pointer(&out[0]))%512 != 0 || cap(out)%512 != 0 {
+		t.Fatal("compressed output is not aligned")
+	}
+
+	dec, err := UncompressAllocHdrWith(nil, out, alloc)
+	failOnError(t, "Failed decompressing", err)
+	if uintptr(unsafe.Pointer(&dec[0]))%512 != 0 {
+		t.Fatal("decompressed output is not aligned")
+	}
+	if !bytes.Equal(dec, in) {
+		t.Fatal("decompressed output != input")
+	}
+}
@@ -0,0 +1,160 @@
+package lz4
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// truncater is implemented by *os.File.
+type truncater interface {
+	Truncate(size int64) error
+}
+
+// OpenAppend opens the block stream file at path and returns a Writer that
+// appends to it, as NewAppendWriter does. Closing the Writer also closes the
+// file.
+func OpenAppend(path string, reloadDict bool, opts ...Option) (*Writer, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	w, err := NewAppendWriter(f, reloadDict, opts...)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	w.closer = f
+	return w, nil
+}
+
+// NewAppendWriter returns a Writer that extends the block stream stored in
+// rws. The stream is scanned to find the end of its last complete block, and
+// writes continue from there. A partially written block at the end, as left by
+// a crashed writer, is removed if rws can be truncated, otherwise an error is
+// returned. Only streams with the default header width can be extended, so
+// WithHeaderWidth is rejected, as are streams written with it.
+//
+// If reloadDict is true, the stream is decompressed to recover the last block,
+// which is then used as history for the appended data. This improves the
+// compression of the first appended block at the cost of reading the whole
+// stream. Without it, the appended data is compressed without history, which
+// decoders handle the same way.
+func NewAppendWriter(rws io.ReadWriteSeeker, reloadDict bool, opts ...Option) (*Writer, error) {
+	o := newOptions(opts)
+	if err := checkDefaultWidth(o); err != nil {
+		return nil, err
+	}
+	end, err := rws.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	complete, err := lastCompleteBlockEnd(rws, end)
+	if err != nil {
+		return nil, err
+	}
+	if complete != end {
+		t, ok := rws.(truncater)
+		if !ok {
+			return nil, fmt.Errorf("incomplete block at offset %d", complete)
+		}
+		if err := t.Truncate(complete); err != nil {
+			return nil, err
+		}
+	}
+
+	var dict []byte
+	if reloadDict && complete > 0 {
+		if _, err := rws.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		dict, err = lastBlock(io.LimitReader(rws, complete))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := rws.Seek(complete, io.SeekStart); err != nil {
+		return nil, err
+	}
+	w := newWriter(rws, o)
+	if dict != nil {
+		w.loadDict(dict)
+	}
+	return w, nil
+}
+
+// lastCompleteBlockEnd walks the block headers of the stream in rs, which is
+// size bytes long, and returns the offset following its last complete block.
+func lastCompleteBlockEnd(rs io.ReadSeeker, size int64) (int64, error) {
+	var pos int64
+	for pos+blockHeaderSize <= size {
+		if _, err := rs.Seek(pos, io.SeekStart); err != nil {
+			return 0, err
+		}
+		var header [blockHeaderSize]byte
+		if _, err := io.ReadFull(rs, header[:]); err != nil {
+			return 0, err
+		}
+		h := binary.LittleEndian.Uint32(header[:])
+		blockSize := int64(h)
+		if h&controlFlag != 0 {
+			typ, length := parseControlHeader(h)
+			blockSize = int64(length)
+			if pos == 0 && typ == recordHeaderWidth {
+				if err := checkPreamble(rs, length); err != nil {
+					return 0, err
+				}
+			}
+		} else if blockSize > boundedHugeStreamingBlockSize {
+			return 0, fmt.Errorf("invalid block size %d at offset %d", blockSize, pos)
+		}
+		if pos+blockHeaderSize+blockSize > size {
+			break
+		}
+		pos += blockHeaderSize + blockSize
+	}
+	return pos, nil
+}
+
+// checkPreamble reads the payload of length bytes of the header width record
+// starting the stream in rs, and returns errDefaultWidthOnly unless it selects
+// the default width, since the records that follow are walked with 4-byte
+// headers.
+func checkPreamble(rs io.Reader, length int) error {
+	if length != headerWidthPayload {
+		return &corruptionError{errBadHeaderWidth}
+	}
+	var width [headerWidthPayload]byte
+	if _, err := io.ReadFull(rs, width[:]); err != nil {
+		// a truncated preamble is an incomplete record
+		return nil
+	}
+	if width[0] != blockHeaderSize {
+		return errDefaultWidthOnly
+	}
+	return nil
+}
+
+// lastBlock decompresses the stream in r and returns the uncompressed
+// content of its last block.
+func lastBlock(r io.Reader) ([]byte, error) {
+	dr := newDecompressReader(r, explicitOptions(), nil)
+	defer dr.Close()
+
+	block := make([]byte, hugeStreamingBlockSize)
+	var last []byte
+	for {
+		// block is large enough for any block, so each Read returns
+		// exactly one block
+		n, err := dr.Read(block)
+		if err == io.EOF {
+			return last, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		last = append(last[:0], block[:n]...)
+	}
+}
@@ -0,0 +1,79 @@
+package lz4
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenAppend(t *testing.T) {
+	input, err := ioutil.ReadFile(sampleFilePath)
+	failOnError(t, "Failed reading sample", err)
+	for len(input) < 3*streamingBlockSize {
+		input = append(input, input...)
+	}
+	split := len(input) / 3
+
+	for _, reloadDict := range []bool{false, true} {
+		path := filepath.Join(t.TempDir(), "append.lz4")
+		f, err := os.Create(path)
+		failOnError(t, "Failed creating file", err)
+		w := NewWriter(f)
+		_, err = w.Write(input[:split])
+		failOnError(t, "Failed writing to compress object", err)
+		failOnError(t, "Failed closing writer", w.Close())
+		failOnError(t, "Failed closing file", f.Close())
+
+		// simulate a crash in the middle of a block
+		f, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+		failOnError(t, "Failed opening file", err)
+		_, err = f.Write([]byte{0x10, 0x00, 0x00, 0x00, 0xaa})
+		failOnError(t, "Failed writing garbage", err)
+		failOnError(t, "Failed closing file", f.Close())
+
+		w, err = OpenAppend(path, reloadDict)
+		failOnError(t, "Failed opening for append", err)
+		_, err = w.Write(input[split:])
+		failOnError(t, "Failed writing to compress object", err)
+		failOnError(t, "Failed closing writer", w.Close())
+
+		compressed, err := ioutil.ReadFile(path)
+		failOnError(t, "Failed reading file", err)
+		r := NewDecompressReader(bytes.NewReader(compressed))
+		out, err := ioutil.ReadAll(r)
+		failOnError(t, "Failed decompressing", err)
+		failOnError(t, "Failed closing reader", r.Close())
+		if !bytes.Equal(out, input) {
+			t.Fatalf("reloadDict=%v: decompressed output != input (lengths: %d, %d)", reloadDict, len(out), len(input))
+		}
+	}
+}
+
+func TestOpenAppendHeaderWidth(t *testing.T) {
+	for _, width := range []int{HeaderWidth3, HeaderWidth8} {
+		var buf bytes.Buffer
+		w := NewWriter(&buf, WithHeaderWidth(width))
+		_, err := w.Write(plaintext0)
+		failOnError(t, "Failed writing", err)
+		failOnError(t, "Failed closing writer", w.Close())
+		path := filepath.Join(t.TempDir(), "append.lz4")
+		failOnError(t, "Failed writing file", ioutil.WriteFile(path, buf.Bytes(), 0644))
+
+		if _, err := OpenAppend(path, false); err != errDefaultWidthOnly {
+			t.Errorf("width %d: got %v, want errDefaultWidthOnly", width, err)
+		}
+		// the file is left untouched
+		compressed, err := ioutil.ReadFile(path)
+		failOnError(t, "Failed reading file", err)
+		if !bytes.Equal(compressed, buf.Bytes()) {
+			t.Errorf("width %d: file changed from %d to %d bytes", width, buf.Len(), len(compressed))
+		}
+		// nor can a default stream be extended with another width
+		failOnError(t, "Failed writing file", ioutil.WriteFile(path, compressStream(t, plaintext0), 0644))
+		if _, err := OpenAppend(path, false, WithHeaderWidth(width)); err != errDefaultWidthOnly {
+			t.Errorf("width %d: got %v appending with the option, want errDefaultWidthOnly", width, err)
+		}
+	}
+}
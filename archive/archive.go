@@ -0,0 +1,406 @@
+// Package archive implements a simple container of named entries, each stored
+// as an independent lz4 block stream with a seek index, so any entry can be
+// read, from any offset, without decompressing the others.
+//
+// An archive starts with the magic "LZ4A" and a version byte, followed by the
+// compressed entries. The directory follows the entries: a uvarint count, then
+// for each entry its name, mode, modification time, offset, compressed and
+// uncompressed sizes, and seek index, as written by lz4.Index.WriteTo. The
+// archive ends with the offset and size of the directory as little endian
+// uint64s, and the magic again.
+package archive
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"time"
+
+	lz4 "github.com/DataDog/golz4"
+)
+
+var magic = [4]byte{'L', 'Z', '4', 'A'}
+
+const (
+	version    = 1
+	headerSize = len(magic) + 1
+	footerSize = 8 + 8 + len(magic)
+
+	// blockSize is the size of the blocks of the entries, which makes
+	// every block boundary a valid index point.
+	blockSize = 64 * 1024
+	// indexSpan is the distance between index points in an entry.
+	indexSpan = lz4.DefaultIndexSpan
+	// maxDirectorySize bounds the directory accepted by NewReader, so a
+	// corrupted footer cannot cause a huge allocation.
+	maxDirectorySize = 1 << 30
+)
+
+var errBadArchive = errors.New("malformed archive")
+
+// Header describes an entry.
+type Header struct {
+	Name    string
+	Mode    fs.FileMode
+	ModTime time.Time
+}
+
+// Entry is an entry of an archive opened with NewReader.
+type Entry struct {
+	Header
+	// Size is the uncompressed size of the entry.
+	Size int64
+	// CompressedSize is the size of the entry in the archive.
+	CompressedSize int64
+
+	ra     io.ReaderAt
+	offset int64
+	index  *lz4.Index
+}
+
+// Open returns a reader decompressing the entry, which supports seeking. It
+// is the caller's responsibility to call Close when done.
+func (e *Entry) Open() (*lz4.SeekReader, error) {
+	return lz4.NewSeekReader(io.NewSectionReader(e.ra, e.offset, e.CompressedSize), e.index), nil
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Writer writes an archive.
+type Writer struct {
+	cw      *countingWriter
+	opts    []lz4.Option
+	entries []*Entry
+	cur     *entryWriter
+	closed  bool
+}
+
+// NewWriter returns a Writer writing an archive to w. opts configure the
+// compression of the entries. The block size is always 64 KiB, and the header
+// width the default, since entries are read with a seek index.
+func NewWriter(w io.Writer, opts ...lz4.Option) *Writer {
+	return &Writer{
+		cw:   &countingWriter{w: w},
+		opts: append(append([]lz4.Option(nil), opts...), lz4.WithBlockSize(blockSize), lz4.WithHeaderWidth(lz4.HeaderWidth4)),
+	}
+}
+
+// Create adds an entry with the given name and returns a writer for its
+// content, as CreateHeader does.
+func (w *Writer) Create(name string) (io.Writer, error) {
+	return w.CreateHeader(&Header{Name: name, Mode: 0o644})
+}
+
+// CreateHeader adds an entry described by h and returns a writer for its
+// content. The writer is valid until the next call to Create, CreateHeader or
+// Close, which completes the entry.
+func (w *Writer) CreateHeader(h *Header) (io.Writer, error) {
+	if w.closed {
+		return nil, errors.New("archive is closed")
+	}
+	if err := w.finishEntry(); err != nil {
+		return nil, err
+	}
+	if w.cw.n == 0 {
+		if _, err := w.cw.Write(append(magic[:], version)); err != nil {
+			return nil, err
+		}
+	}
+	e := &Entry{Header: *h, offset: w.cw.n}
+	w.entries = append(w.entries, e)
+	w.cur = newEntryWriter(w.cw, e, w.opts)
+	return w.cur, nil
+}
+
+func (w *Writer) finishEntry() error {
+	if w.cur == nil {
+		return nil
+	}
+	cur := w.cur
+	w.cur = nil
+	return cur.close()
+}
+
+// Close completes the last entry and writes the directory. It does not close
+// the underlying writer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if err := w.finishEntry(); err != nil {
+		return err
+	}
+	if w.cw.n == 0 {
+		if _, err := w.cw.Write(append(magic[:], version)); err != nil {
+			return err
+		}
+	}
+
+	var dir bytes.Buffer
+	var tmp [binary.MaxVarintLen64]byte
+	putUvarint := func(v uint64) {
+		dir.Write(tmp[:binary.PutUvarint(tmp[:], v)])
+	}
+	putUvarint(uint64(len(w.entries)))
+	for _, e := range w.entries {
+		putUvarint(uint64(len(e.Name)))
+		dir.WriteString(e.Name)
+		putUvarint(uint64(e.Mode))
+		var mtime int64
+		if !e.ModTime.IsZero() {
+			mtime = e.ModTime.UnixNano()
+		}
+		dir.Write(tmp[:binary.PutVarint(tmp[:], mtime)])
+		putUvarint(uint64(e.offset))
+		putUvarint(uint64(e.CompressedSize))
+		putUvarint(uint64(e.Size))
+		var idx bytes.Buffer
+		if _, err := e.index.WriteTo(&idx); err != nil {
+			return err
+		}
+		putUvarint(uint64(idx.Len()))
+		dir.Write(idx.Bytes())
+	}
+
+	var footer [footerSize]byte
+	binary.LittleEndian.PutUint64(footer[:], uint64(w.cw.n))
+	binary.LittleEndian.PutUint64(footer[8:], uint64(dir.Len()))
+	copy(footer[16:], magic[:])
+	if _, err := w.cw.Write(dir.Bytes()); err != nil {
+		return err
+	}
+	_, err := w.cw.Write(footer[:])
+	return err
+}
+
+// entryWriter compresses the content of an entry in blocks of blockSize,
+// recording index points as it goes.
+type entryWriter struct {
+	cw    *countingWriter
+	e     *Entry
+	w     *lz4.Writer
+	buf   []byte
+	prev  []byte
+	start int64
+
+	uncompressed int64
+	lastPoint    int64
+}
+
+func newEntryWriter(cw *countingWriter, e *Entry, opts []lz4.Option) *entryWriter {
+	return &entryWriter{
+		cw:        cw,
+		e:         e,
+		w:         lz4.NewWriter(cw, opts...),
+		buf:       make([]byte, 0, blockSize),
+		prev:      make([]byte, 0, blockSize),
+		start:     cw.n,
+		lastPoint: -indexSpan,
+	}
+}
+
+func (ew *entryWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := copy(ew.buf[len(ew.buf):cap(ew.buf)], p)
+		ew.buf = ew.buf[:len(ew.buf)+n]
+		p = p[n:]
+		written += n
+		if len(ew.buf) == cap(ew.buf) {
+			if err := ew.writeBlock(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// writeBlock compresses the buffered data as one block, adding an index point
+// before it if needed.
+func (ew *entryWriter) writeBlock() error {
+	if ew.e.index == nil {
+		ew.e.index = &lz4.Index{}
+	}
+	if ew.uncompressed-ew.lastPoint >= indexSpan {
+		ew.e.index.Points = append(ew.e.index.Points, lz4.IndexPoint{
+			CompressedOffset:   ew.cw.n - ew.start,
+			UncompressedOffset: ew.uncompressed,
+			// the blocks are compressed with the previous block as
+			// history
+			Window: append([]byte(nil), ew.prev...),
+		})
+		ew.lastPoint = ew.uncompressed
+	}
+	if err := ew.w.WriteBlock(ew.buf); err != nil {
+		return err
+	}
+	ew.uncompressed += int64(len(ew.buf))
+	ew.prev, ew.buf = ew.buf, ew.prev[:0]
+	return nil
+}
+
+func (ew *entryWriter) close() error {
+	if len(ew.buf) > 0 {
+		if err := ew.writeBlock(); err != nil {
+			ew.w.Close()
+			return err
+		}
+	}
+	if err := ew.w.Close(); err != nil {
+		return err
+	}
+	if ew.e.index == nil {
+		ew.e.index = &lz4.Index{}
+	}
+	ew.e.Size = ew.uncompressed
+	ew.e.CompressedSize = ew.cw.n - ew.start
+	ew.e.index.UncompressedSize = ew.e.Size
+	ew.e.index.CompressedSize = ew.e.CompressedSize
+	return nil
+}
+
+// Reader reads an archive.
+type Reader struct {
+	// Entries lists the entries of the archive, in the order they were
+	// written.
+	Entries []*Entry
+}
+
+// NewReader reads the directory of the archive of the given size in ra.
+func NewReader(ra io.ReaderAt, size int64) (*Reader, error) {
+	if size < int64(headerSize+footerSize) {
+		return nil, errBadArchive
+	}
+	var header [headerSize]byte
+	if _, err := ra.ReadAt(header[:], 0); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(header[:len(magic)], magic[:]) {
+		return nil, errBadArchive
+	}
+	if header[len(magic)] != version {
+		return nil, fmt.Errorf("unsupported archive version %d", header[len(magic)])
+	}
+
+	var footer [footerSize]byte
+	if _, err := ra.ReadAt(footer[:], size-int64(footerSize)); err != nil {
+		return nil, err
+	}
+	dirOffset := binary.LittleEndian.Uint64(footer[:])
+	dirSize := binary.LittleEndian.Uint64(footer[8:])
+	if !bytes.Equal(footer[16:], magic[:]) || dirSize > maxDirectorySize ||
+		dirSize > uint64(size-int64(footerSize)) || dirOffset != uint64(size-int64(footerSize))-dirSize ||
+		dirOffset < uint64(headerSize) {
+		return nil, errBadArchive
+	}
+	dir := make([]byte, dirSize)
+	if _, err := ra.ReadAt(dir, int64(dirOffset)); err != nil {
+		return nil, err
+	}
+
+	readUvarint := func() (uint64, error) {
+		v, n := binary.Uvarint(dir)
+		if n <= 0 {
+			return 0, errBadArchive
+		}
+		dir = dir[n:]
+		return v, nil
+	}
+	readBytes := func() ([]byte, error) {
+		n, err := readUvarint()
+		if err != nil {
+			return nil, err
+		}
+		if n > uint64(len(dir)) {
+			return nil, errBadArchive
+		}
+		b := dir[:n]
+		dir = dir[n:]
+		return b, nil
+	}
+
+	count, err := readUvarint()
+	if err != nil {
+		return nil, err
+	}
+	if count > uint64(len(dir)) {
+		return nil, errBadArchive
+	}
+	r := &Reader{Entries: make([]*Entry, 0, count)}
+	for i := uint64(0); i < count; i++ {
+		name, err := readBytes()
+		if err != nil {
+			return nil, err
+		}
+		mode, err := readUvarint()
+		if err != nil {
+			return nil, err
+		}
+		mtime, n := binary.Varint(dir)
+		if n <= 0 {
+			return nil, errBadArchive
+		}
+		dir = dir[n:]
+		var fields [3]uint64
+		for j := range fields {
+			if fields[j], err = readUvarint(); err != nil {
+				return nil, err
+			}
+		}
+		offset, compressedSize, uncompressedSize := fields[0], fields[1], fields[2]
+		if offset < uint64(headerSize) || offset > dirOffset || compressedSize > dirOffset-offset ||
+			uncompressedSize > math.MaxInt64 {
+			return nil, errBadArchive
+		}
+		idxBytes, err := readBytes()
+		if err != nil {
+			return nil, err
+		}
+		idx, err := lz4.ReadIndex(bytes.NewReader(idxBytes))
+		if err != nil {
+			return nil, err
+		}
+
+		e := &Entry{
+			Header: Header{
+				Name: string(name),
+				Mode: fs.FileMode(mode),
+			},
+			Size:           int64(uncompressedSize),
+			CompressedSize: int64(compressedSize),
+			ra:             ra,
+			offset:         int64(offset),
+			index:          idx,
+		}
+		if mtime != 0 {
+			e.ModTime = time.Unix(0, mtime)
+		}
+		r.Entries = append(r.Entries, e)
+	}
+	return r, nil
+}
+
+// Lookup returns the entry with the given name, or nil if there is none.
+func (r *Reader) Lookup(name string) *Entry {
+	for _, e := range r.Entries {
+		if e.Name == name {
+			return e
+		}
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package archive
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	lz4 "github.com/DataDog/golz4"
+)
+
+func testContent(n int) []byte {
+	rng := rand.New(rand.NewSource(int64(n)))
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = "abcdefgh"[rng.Intn(8)]
+	}
+	return b
+}
+
+func TestArchiveRoundTrip(t *testing.T) {
+	mtime := time.Unix(1600000000, 0)
+	contents := map[string][]byte{
+		"empty":  nil,
+		"small":  []byte("hello archive"),
+		"blocks": testContent(3*1024*1024 + 123),
+	}
+	names := []string{"empty", "small", "blocks"}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for _, name := range names {
+		ew, err := w.CreateHeader(&Header{Name: name, Mode: 0o600, ModTime: mtime})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ew.Write(contents[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Entries) != len(names) {
+		t.Fatalf("got %d entries, want %d", len(r.Entries), len(names))
+	}
+	for i, e := range r.Entries {
+		if e.Name != names[i] || e.Mode != 0o600 || !e.ModTime.Equal(mtime) {
+			t.Errorf("entry %d: got header %+v", i, e.Header)
+		}
+		if e.Size != int64(len(contents[e.Name])) {
+			t.Errorf("%s: got size %d, want %d", e.Name, e.Size, len(contents[e.Name]))
+		}
+		rc, err := e.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, contents[e.Name]) {
+			t.Errorf("%s: content mismatch", e.Name)
+		}
+	}
+}
+
+func TestArchiveHeaderWidth(t *testing.T) {
+	content := testContent(200 * 1024)
+	var buf bytes.Buffer
+	w := NewWriter(&buf, lz4.WithHeaderWidth(lz4.HeaderWidth3))
+	ew, err := w.Create("entry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ew.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, err := r.Entries[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	got, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Error("content mismatch")
+	}
+}
+
+func TestArchiveSeek(t *testing.T) {
+	content := testContent(5*1024*1024 + 17)
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if _, err := w.Create("first"); err != nil {
+		t.Fatal(err)
+	}
+	ew, err := w.Create("second")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ew.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := r.Lookup("second")
+	if e == nil {
+		t.Fatal("missing entry")
+	}
+	if len(e.index.Points) < 5 {
+		t.Fatalf("got %d index points, want at least 5", len(e.index.Points))
+	}
+	rc, err := e.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	for _, off := range []int64{4*1024*1024 + 999, 17, 1024*1024 - 1, int64(len(content)) - 5} {
+		if _, err := rc.Seek(off, io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		got := make([]byte, 5)
+		if _, err := io.ReadFull(rc, got); err != nil {
+			t.Fatalf("offset %d: %v", off, err)
+		}
+		if !bytes.Equal(got, content[off:off+5]) {
+			t.Errorf("offset %d: got %q, want %q", off, got, content[off:off+5])
+		}
+	}
+	if r.Lookup("missing") != nil {
+		t.Error("found a missing entry")
+	}
+}
+
+func TestNewReaderMalformed(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if _, err := w.Create("x"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	for _, b := range [][]byte{nil, []byte("LZ4A"), data[:len(data)-1], append([]byte("XXXX"), data[4:]...)} {
+		if _, err := NewReader(bytes.NewReader(b), int64(len(b))); err == nil {
+			t.Errorf("no error for %q", b)
+		}
+	}
+	empty := new(bytes.Buffer)
+	if err := NewWriter(empty).Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReader(bytes.NewReader(empty.Bytes()), int64(empty.Len()))
+	if err != nil || len(r.Entries) != 0 {
+		t.Errorf("empty archive: got %v, %v", r, err)
+	}
+}
+
+func TestNewReaderHugeSizes(t *testing.T) {
+	var idx bytes.Buffer
+	if _, err := (&lz4.Index{}).WriteTo(&idx); err != nil {
+		t.Fatal(err)
+	}
+	for _, sizes := range [][2]uint64{{math.MaxUint64, 0}, {0, math.MaxUint64}} {
+		// one entry at offset headerSize whose sizes overflow
+		// count, name length, name, mode and modification time
+		dir := []byte{1, 1, 'x', 0, 0}
+		var tmp [binary.MaxVarintLen64]byte
+		for _, v := range []uint64{uint64(headerSize), sizes[0], sizes[1], uint64(idx.Len())} {
+			dir = append(dir, tmp[:binary.PutUvarint(tmp[:], v)]...)
+		}
+		dir = append(dir, idx.Bytes()...)
+
+		archive := append(append(magic[:], version), dir...)
+		var footer [footerSize]byte
+		binary.LittleEndian.PutUint64(footer[:], uint64(headerSize))
+		binary.LittleEndian.PutUint64(footer[8:], uint64(len(dir)))
+		copy(footer[16:], magic[:])
+		archive = append(archive, footer[:]...)
+		if _, err := NewReader(bytes.NewReader(archive), int64(len(archive))); err != errBadArchive {
+			t.Errorf("sizes %d: got %v, want errBadArchive", sizes, err)
+		}
+	}
+}
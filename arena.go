@@ -0,0 +1,75 @@
+package lz4
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// ErrArenaFull is returned by Arena when there is not enough free space left
+// for the decompressed message.
+var ErrArenaFull = errors.New("arena full")
+
+// Arena decompresses many small messages into one caller-owned buffer, one
+// after the other, instead of allocating a slice for each, which reduces the
+// pressure on the garbage collector when decoding large numbers of messages.
+// The decompressed messages alias the buffer, and stay valid until Reset. An
+// Arena is not safe for concurrent use.
+type Arena struct {
+	buf []byte
+	off int
+}
+
+// NewArena returns an Arena using buf, which it never grows.
+func NewArena(buf []byte) *Arena {
+	return &Arena{buf: buf}
+}
+
+// Uncompress decompresses in, a block of size bytes once uncompressed, as
+// written by Compress, into the free space of a. It returns the offset of the
+// message in the buffer and the message itself.
+func (a *Arena) Uncompress(in []byte, size int) (offset int, out []byte, err error) {
+	if size < 0 || size > len(a.buf)-a.off {
+		return 0, nil, ErrArenaFull
+	}
+	out = a.buf[a.off : a.off+size : a.off+size]
+	n, err := Uncompress(out, in)
+	if err != nil {
+		return 0, nil, err
+	}
+	if n != size {
+		return 0, nil, errors.New("decompressed size does not match the expected size")
+	}
+	offset = a.off
+	a.off += size
+	return offset, out, nil
+}
+
+// UncompressHdr is like Uncompress for a message written by CompressHdr, whose
+// size is given by its length header.
+func (a *Arena) UncompressHdr(in []byte) (offset int, out []byte, err error) {
+	if len(in) < 4 {
+		return 0, nil, errTooShort
+	}
+	size := binary.LittleEndian.Uint32(in)
+	if uint64(size) > uint64(len(a.buf)-a.off) {
+		return 0, nil, ErrArenaFull
+	}
+	return a.Uncompress(in[4:], int(size))
+}
+
+// Len returns the number of bytes used in the buffer.
+func (a *Arena) Len() int {
+	return a.off
+}
+
+// Bytes returns the used part of the buffer, holding the messages
+// decompressed since the last Reset.
+func (a *Arena) Bytes() []byte {
+	return a.buf[:a.off]
+}
+
+// Reset makes the whole buffer available again. The messages returned so far
+// are overwritten by the next ones.
+func (a *Arena) Reset() {
+	a.off = 0
+}
@@ -0,0 +1,63 @@
+package lz4
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestArena(t *testing.T) {
+	var messages, compressed [][]byte
+	for i := 0; i < 10; i++ {
+		m := []byte(fmt.Sprintf("message %d: %s", i, bytes.Repeat([]byte("x"), i*10)))
+		c, err := CompressAllocHdr(m)
+		failOnError(t, "Failed compressing", err)
+		messages = append(messages, m)
+		compressed = append(compressed, c)
+	}
+
+	a := NewArena(make([]byte, 400))
+	var offsets []int
+	for i, c := range compressed {
+		offset, out, err := a.UncompressHdr(c)
+		if err == ErrArenaFull {
+			if i == 0 {
+				t.Fatal("arena full at the first message")
+			}
+			break
+		}
+		failOnError(t, "Failed decompressing", err)
+		if !bytes.Equal(out, messages[i]) {
+			t.Fatalf("message %d: decompressed output != input", i)
+		}
+		if cap(out) != len(out) {
+			t.Errorf("message %d: appending to it would overwrite the next one", i)
+		}
+		offsets = append(offsets, offset)
+	}
+	if len(offsets) == len(messages) {
+		t.Fatal("the arena never filled up")
+	}
+	// the earlier messages are intact
+	for i, offset := range offsets {
+		if got := a.Bytes()[offset : offset+len(messages[i])]; !bytes.Equal(got, messages[i]) {
+			t.Fatalf("message %d was overwritten", i)
+		}
+	}
+
+	a.Reset()
+	raw := make([]byte, CompressBound(messages[9]))
+	n, err := Compress(raw, messages[9])
+	failOnError(t, "Failed compressing", err)
+	offset, out, err := a.Uncompress(raw[:n], len(messages[9]))
+	failOnError(t, "Failed decompressing", err)
+	if offset != 0 || a.Len() != len(messages[9]) || !bytes.Equal(out, messages[9]) {
+		t.Fatalf("after Reset: got offset %d, len %d", offset, a.Len())
+	}
+	if _, _, err := a.Uncompress(raw[:n], len(messages[9])-1); err == nil {
+		t.Fatal("no error for a wrong size")
+	}
+	if a.Len() != len(messages[9]) {
+		t.Fatal("a failed decompression used space")
+	}
+}
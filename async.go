@@ -0,0 +1,121 @@
+package lz4
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// ErrQueueFull is returned by AsyncWriter.TryWrite when the queue is full.
+var ErrQueueFull = errors.New("write queue is full")
+
+type asyncItem struct {
+	data []byte
+	// done, if set, makes the background goroutine flush the Writer and
+	// close it
+	done chan struct{}
+}
+
+// AsyncWriter is a Writer that compresses and writes in a background
+// goroutine. Writes are copied into a bounded queue and return immediately,
+// unless the queue is full. Errors from the compression or the underlying
+// writer are returned by the next call to Write, TryWrite, Flush or Close.
+//
+// Write, TryWrite and Flush may be called concurrently, but not concurrently
+// with or after Close.
+type AsyncWriter struct {
+	w     *Writer
+	queue chan asyncItem
+	done  chan struct{}
+
+	mu  sync.Mutex
+	err error
+}
+
+// NewAsyncWriter creates a new AsyncWriter writing a compressed stream to w,
+// with room for queueLen pending writes. It is the caller's responsibility to
+// call Close when done.
+func NewAsyncWriter(w io.Writer, queueLen int, opts ...Option) *AsyncWriter {
+	a := &AsyncWriter{
+		w:     NewWriter(w, opts...),
+		queue: make(chan asyncItem, queueLen),
+		done:  make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+func (a *AsyncWriter) run() {
+	defer close(a.done)
+	for item := range a.queue {
+		if a.error() != nil {
+			// drop the data after an error
+			if item.done != nil {
+				close(item.done)
+			}
+			continue
+		}
+		var err error
+		if item.done != nil {
+			err = a.w.Flush()
+			close(item.done)
+		} else {
+			_, err = a.w.Write(item.data)
+		}
+		if err != nil {
+			a.mu.Lock()
+			a.err = err
+			a.mu.Unlock()
+		}
+	}
+}
+
+func (a *AsyncWriter) error() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.err
+}
+
+// Write queues a copy of p to be compressed, waiting while the queue is full.
+func (a *AsyncWriter) Write(p []byte) (int, error) {
+	if err := a.error(); err != nil {
+		return 0, err
+	}
+	a.queue <- asyncItem{data: append([]byte(nil), p...)}
+	return len(p), nil
+}
+
+// TryWrite queues a copy of p to be compressed, or returns ErrQueueFull
+// without waiting if the queue is full.
+func (a *AsyncWriter) TryWrite(p []byte) error {
+	if err := a.error(); err != nil {
+		return err
+	}
+	select {
+	case a.queue <- asyncItem{data: append([]byte(nil), p...)}:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Flush waits until the data queued before the call is compressed and written,
+// and flushes the underlying writer as Writer.Flush does.
+func (a *AsyncWriter) Flush() error {
+	done := make(chan struct{})
+	a.queue <- asyncItem{done: done}
+	<-done
+	return a.error()
+}
+
+// Close waits until all the queued data is written, then releases the
+// resources of the underlying Writer.
+func (a *AsyncWriter) Close() error {
+	close(a.queue)
+	<-a.done
+	err := a.error()
+	if cerr := a.w.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,86 @@
+package lz4
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"testing"
+)
+
+func TestAsyncWriter(t *testing.T) {
+	var compressed bytes.Buffer
+	a := NewAsyncWriter(&compressed, 4)
+	buf := append([]byte(nil), plaintext0...)
+	for i := 0; i < 100; i++ {
+		_, err := a.Write(buf)
+		failOnError(t, "Failed writing", err)
+		// the data was copied, so the buffer can be reused
+		for j := range buf {
+			buf[j] = 0
+		}
+		copy(buf, plaintext0)
+	}
+	failOnError(t, "Failed flushing", a.Flush())
+	flushed := compressed.Len()
+	if flushed == 0 {
+		t.Fatalf("nothing written after Flush")
+	}
+	failOnError(t, "Failed closing", a.Close())
+
+	out, err := ioutil.ReadAll(NewDecompressReader(&compressed))
+	failOnError(t, "Failed decompressing", err)
+	if !bytes.Equal(out, bytes.Repeat(plaintext0, 100)) {
+		t.Fatalf("Decompressed output != input")
+	}
+}
+
+type blockingWriter struct {
+	release chan struct{}
+}
+
+func (b *blockingWriter) Write(p []byte) (int, error) {
+	<-b.release
+	return len(p), nil
+}
+
+func TestAsyncWriterTryWrite(t *testing.T) {
+	bw := &blockingWriter{release: make(chan struct{})}
+	a := NewAsyncWriter(bw, 2)
+	full := false
+	for i := 0; i < 10 && !full; i++ {
+		err := a.TryWrite(plaintext0)
+		if err == ErrQueueFull {
+			full = true
+		} else {
+			failOnError(t, "Failed writing", err)
+		}
+	}
+	if !full {
+		t.Fatalf("queue never full")
+	}
+	close(bw.release)
+	failOnError(t, "Failed closing", a.Close())
+}
+
+type failingWriter struct{}
+
+var errFailingWriter = errors.New("write failed")
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errFailingWriter
+}
+
+func TestAsyncWriterError(t *testing.T) {
+	a := NewAsyncWriter(failingWriter{}, 2)
+	_, err := a.Write(plaintext0)
+	failOnError(t, "Failed writing", err)
+	if err := a.Flush(); !errors.Is(err, errFailingWriter) {
+		t.Fatalf("Flush returned %v", err)
+	}
+	if _, err := a.Write(plaintext0); !errors.Is(err, errFailingWriter) {
+		t.Fatalf("Write returned %v", err)
+	}
+	if err := a.Close(); !errors.Is(err, errFailingWriter) {
+		t.Fatalf("Close returned %v", err)
+	}
+}
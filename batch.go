@@ -0,0 +1,153 @@
+package lz4
+
+// #cgo pkg-config: liblz4
+// #include <lz4.h>
+//
+// // compress_batch compresses the n messages concatenated in src, of the
+// // given sizes, into dst, each at an offset of the sum of the bounds of the
+// // previous ones, and stores their compressed sizes in dstSizes. It returns
+// // the index of the first message that failed, or n.
+// static int compress_batch(const char* src, const int* srcSizes, char* dst, int* dstSizes, int n) {
+//	LZ4_stream_t state;
+//	LZ4_initStream(&state, sizeof(state));
+//	for (int i = 0; i < n; i++) {
+//		int bound = LZ4_compressBound(srcSizes[i]);
+//		// a fast reset avoids clearing the whole state for each message
+//		LZ4_resetStream_fast(&state);
+//		dstSizes[i] = LZ4_compress_fast_continue(&state, src, dst, srcSizes[i], bound, 1);
+//		if (dstSizes[i] <= 0) {
+//			return i;
+//		}
+//		src += srcSizes[i];
+//		dst += bound;
+//	}
+//	return n;
+// }
+//
+// // decompress_batch decompresses the n blocks concatenated in src, of the
+// // given sizes, into dst, one after the other, each of the size in
+// // dstSizes. It returns the index of the first block that failed, or n.
+// static int decompress_batch(const char* src, const int* srcSizes, char* dst, const int* dstSizes, int n) {
+//	for (int i = 0; i < n; i++) {
+//		if (LZ4_decompress_safe(src, dst, srcSizes[i], dstSizes[i]) != dstSizes[i]) {
+//			return i;
+//		}
+//		src += srcSizes[i];
+//		dst += dstSizes[i];
+//	}
+//	return n;
+// }
+import "C"
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// batchSize bounds the input of each call to C by the batch functions, and the
+// output of UncompressHdrBatch, which bounds the temporary memory they use.
+const batchSize = 1 << 20
+
+var errBatchLength = errors.New("dst and src have different lengths")
+
+// CompressHdrBatch compresses each message of src like CompressAllocHdr, and
+// stores the result in the corresponding element of dst, reusing its capacity
+// if large enough. The messages are compressed a batch at a time with a single
+// cgo call, so it is much faster than calling CompressHdr for each small
+// message. len(dst) must equal len(src).
+func CompressHdrBatch(dst, src [][]byte) error {
+	if len(dst) != len(src) {
+		return errBatchLength
+	}
+	var in, out []byte
+	var inSizes, outSizes []C.int
+	for start := 0; start < len(src); {
+		// gather a batch of messages into one buffer
+		in, inSizes = in[:0], inSizes[:0]
+		outLen := 0
+		end := start
+		for ; end < len(src) && (end == start || len(in)+len(src[end]) <= batchSize); end++ {
+			if len(src[end]) > maxInputSize {
+				return ErrInputTooLarge
+			}
+			in = append(in, src[end]...)
+			inSizes = append(inSizes, C.int(len(src[end])))
+			outLen += compressBound(len(src[end]))
+		}
+		if cap(out) < outLen {
+			out = make([]byte, outLen)
+		}
+		outSizes = append(outSizes[:0], make([]C.int, end-start)...)
+
+		n := end - start
+		if done := int(C.compress_batch(p(in), &inSizes[0], p(out[:outLen]), &outSizes[0], C.int(n))); done < n {
+			return fmt.Errorf("error compressing message %d", start+done)
+		}
+		off := 0
+		for i := 0; i < n; i++ {
+			size := int(outSizes[i])
+			msg := dst[start+i][:0]
+			msg = append(msg, 0, 0, 0, 0)
+			binary.LittleEndian.PutUint32(msg, uint32(inSizes[i]))
+			dst[start+i] = append(msg, out[off:off+size]...)
+			off += compressBound(int(inSizes[i]))
+		}
+		start = end
+	}
+	return nil
+}
+
+// UncompressHdrBatch decompresses each message of src, as written by
+// CompressHdr, and stores the result in the corresponding element of dst,
+// reusing its capacity if large enough. Like CompressHdrBatch, it makes a
+// single cgo call per batch. It is an error if a message does not decompress
+// to the size in its header. len(dst) must equal len(src).
+func UncompressHdrBatch(dst, src [][]byte) error {
+	if len(dst) != len(src) {
+		return errBatchLength
+	}
+	var in, out []byte
+	var inSizes, outSizes []C.int
+	for start := 0; start < len(src); {
+		in, inSizes, outSizes = in[:0], inSizes[:0], outSizes[:0]
+		outLen := 0
+		end := start
+		for ; end < len(src); end++ {
+			msg := src[end]
+			if len(msg) < 4 {
+				return fmt.Errorf("message %d: %w", end, errTooShort)
+			}
+			size := binary.LittleEndian.Uint32(msg)
+			if size > maxInputSize {
+				return fmt.Errorf("message %d: %w", end, ErrInputTooLarge)
+			}
+			if uint64(size) > maxUncompressedSize(len(msg)-4) {
+				return fmt.Errorf("message %d: %w", end, errHdrSize)
+			}
+			if end > start && (len(in)+len(msg) > batchSize || outLen+int(size) > batchSize) {
+				break
+			}
+			in = append(in, msg[4:]...)
+			inSizes = append(inSizes, C.int(len(msg)-4))
+			outSizes = append(outSizes, C.int(size))
+			outLen += int(size)
+		}
+		if cap(out) < outLen {
+			out = make([]byte, outLen)
+		}
+
+		n := end - start
+		if done := int(C.decompress_batch(p(in), &inSizes[0], p(out[:outLen]), &outSizes[0], C.int(n))); done < n {
+			return fmt.Errorf("message %d: Malformed compression stream", start+done)
+		}
+		off := 0
+		for i := 0; i < n; i++ {
+			size := int(outSizes[i])
+			dst[start+i] = append(dst[start+i][:0], out[off:off+size]...)
+			off += size
+		}
+		start = end
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package lz4
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestHdrBatch(t *testing.T) {
+	var src [][]byte
+	for i := 0; i < 20000; i++ {
+		src = append(src, []byte(fmt.Sprintf(`{"id":%d,"name":"user%d","tags":["a","b","c"],"pad":%q}`, i, i%100, bytes.Repeat([]byte("z"), i%150))))
+	}
+	src = append(src, nil, bytes.Repeat([]byte("large message "), batchSize/10))
+
+	dst := make([][]byte, len(src))
+	failOnError(t, "Failed compressing batch", CompressHdrBatch(dst, src))
+	for i := range src {
+		// the output can be decompressed one message at a time
+		got, err := UncompressAllocHdr(nil, dst[i])
+		failOnError(t, "Failed decompressing", err)
+		if !bytes.Equal(got, src[i]) {
+			t.Fatalf("message %d: decompressed output != input", i)
+		}
+	}
+
+	out := make([][]byte, len(dst))
+	out[0] = make([]byte, 0, 1000)
+	failOnError(t, "Failed decompressing batch", UncompressHdrBatch(out, dst))
+	for i := range src {
+		if !bytes.Equal(out[i], src[i]) {
+			t.Fatalf("message %d: decompressed output != input", i)
+		}
+	}
+	if cap(out[0]) != 1000 {
+		t.Error("the capacity of dst was not reused")
+	}
+
+	dst[5] = dst[5][:len(dst[5])-1]
+	if err := UncompressHdrBatch(out, dst); err == nil {
+		t.Fatal("no error for a truncated message")
+	}
+	dst[5] = []byte{0, 0, 0x10, 0, 0}
+	if err := UncompressHdrBatch(out, dst); err == nil {
+		t.Fatal("no error for a message larger than its compressed size allows")
+	}
+	if err := UncompressHdrBatch(out[:1], dst); err == nil {
+		t.Fatal("no error for different lengths")
+	}
+}
+
+func BenchmarkCompressHdrBatch(b *testing.B) {
+	src := make([][]byte, 1000)
+	for i := range src {
+		src[i] = []byte(fmt.Sprintf(`{"id":%d,"name":"user%d","tags":["a","b","c"],"status":"active","score":%d}`, i, i, i*7))
+	}
+	dst := make([][]byte, len(src))
+	b.Run("batch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			CompressHdrBatch(dst, src)
+		}
+	})
+	b.Run("single", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for j, s := range src {
+				dst[j], _ = CompressAllocHdr(s)
+			}
+		}
+	})
+}
@@ -0,0 +1,66 @@
+package lz4
+
+import (
+	"encoding/binary"
+)
+
+// Blob holds data compressed in the length header format used by CompressHdr.
+// It implements encoding.BinaryMarshaler and encoding.BinaryUnmarshaler, so
+// compressed fields can be embedded in structs serialized with encoding/gob or
+// similar packages. The zero value is an empty Blob.
+type Blob struct {
+	data []byte
+}
+
+// NewBlob compresses p into a new Blob.
+func NewBlob(p []byte) (Blob, error) {
+	data, err := CompressAllocHdr(p)
+	if err != nil {
+		return Blob{}, err
+	}
+	return Blob{data: data}, nil
+}
+
+// Bytes decompresses the content of the Blob.
+func (b Blob) Bytes() ([]byte, error) {
+	if len(b.data) == 0 {
+		return nil, nil
+	}
+	if uint64(b.Len()) > maxUncompressedSize(len(b.data)-4) {
+		return nil, errHdrSize
+	}
+	return UncompressAllocHdr(nil, b.data)
+}
+
+// Len returns the uncompressed size of the Blob, read from its header.
+func (b Blob) Len() int {
+	if len(b.data) < 4 {
+		return 0
+	}
+	return int(binary.LittleEndian.Uint32(b.data))
+}
+
+// CompressedLen returns the size of the compressed data including its header.
+func (b Blob) CompressedLen() int {
+	return len(b.data)
+}
+
+// MarshalBinary returns the compressed data, including its length header. It
+// can be decompressed with UncompressAllocHdr.
+func (b Blob) MarshalBinary() ([]byte, error) {
+	return b.data, nil
+}
+
+// UnmarshalBinary sets the content of b to a copy of data, which must be in
+// the format produced by CompressHdr. The data is only decompressed by Bytes.
+func (b *Blob) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 {
+		b.data = nil
+		return nil
+	}
+	if len(data) < 4 {
+		return errTooShort
+	}
+	b.data = append([]byte(nil), data...)
+	return nil
+}
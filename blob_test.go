@@ -0,0 +1,57 @@
+package lz4
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io/ioutil"
+	"testing"
+)
+
+func TestBlobGob(t *testing.T) {
+	input, err := ioutil.ReadFile(sampleFilePath)
+	failOnError(t, "Failed reading sample", err)
+
+	type record struct {
+		Name    string
+		Payload Blob
+		Empty   Blob
+	}
+	payload, err := NewBlob(input)
+	failOnError(t, "Failed creating blob", err)
+	if payload.Len() != len(input) || payload.CompressedLen() >= len(input) {
+		t.Fatalf("unexpected blob sizes %d, %d", payload.Len(), payload.CompressedLen())
+	}
+
+	var encoded bytes.Buffer
+	err = gob.NewEncoder(&encoded).Encode(record{Name: "sample", Payload: payload})
+	failOnError(t, "Failed encoding", err)
+
+	var decoded record
+	err = gob.NewDecoder(&encoded).Decode(&decoded)
+	failOnError(t, "Failed decoding", err)
+	out, err := decoded.Payload.Bytes()
+	failOnError(t, "Failed decompressing blob", err)
+	if decoded.Name != "sample" || !bytes.Equal(out, input) {
+		t.Fatalf("decoded record does not match")
+	}
+	empty, err := decoded.Empty.Bytes()
+	failOnError(t, "Failed decompressing empty blob", err)
+	if len(empty) != 0 {
+		t.Fatalf("expected empty blob, got %q", empty)
+	}
+}
+
+func TestBlobUnmarshalShort(t *testing.T) {
+	var b Blob
+	if err := b.UnmarshalBinary([]byte{1, 2}); err != errTooShort {
+		t.Fatalf("expected errTooShort, got %v", err)
+	}
+}
+
+func TestBlobHugeLength(t *testing.T) {
+	var b Blob
+	failOnError(t, "Failed unmarshaling", b.UnmarshalBinary([]byte{0xff, 0xff, 0xff, 0x7f, 0x10}))
+	if _, err := b.Bytes(); err != errHdrSize {
+		t.Fatalf("expected errHdrSize, got %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package lz4
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestWriteBlockReadBlock(t *testing.T) {
+	messages := [][]byte{plaintext0, {}, []byte("short"), bytes.Repeat([]byte("x"), streamingBlockSize)}
+
+	var compressed bytes.Buffer
+	w := NewWriter(&compressed)
+	for _, m := range messages {
+		failOnError(t, "Failed writing block", w.WriteBlock(m))
+	}
+	if err := w.WriteBlock(make([]byte, streamingBlockSize+1)); err == nil {
+		t.Fatalf("expected an error for an oversized block")
+	}
+	failOnError(t, "Failed closing writer", w.Close())
+
+	r := NewDecompressReader(&compressed).(*DecompressReader)
+	defer r.Close()
+	for i, m := range messages {
+		block, err := r.ReadBlock()
+		failOnError(t, "Failed reading block", err)
+		if !bytes.Equal(block, m) {
+			t.Fatalf("block %d: got %d bytes, want %d", i, len(block), len(m))
+		}
+	}
+	if _, err := r.ReadBlock(); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestReadBlockAfterRead(t *testing.T) {
+	var compressed bytes.Buffer
+	w := NewWriter(&compressed)
+	failOnError(t, "Failed writing block", w.WriteBlock(plaintext0))
+	failOnError(t, "Failed writing block", w.WriteBlock([]byte("second")))
+	failOnError(t, "Failed closing writer", w.Close())
+
+	r := NewDecompressReader(&compressed).(*DecompressReader)
+	defer r.Close()
+	head := make([]byte, 10)
+	_, err := r.Read(head)
+	failOnError(t, "Failed reading", err)
+	rest, err := r.ReadBlock()
+	failOnError(t, "Failed reading block", err)
+	if !bytes.Equal(append(head, rest...), plaintext0) {
+		t.Fatalf("first block != input")
+	}
+	block, err := r.ReadBlock()
+	failOnError(t, "Failed reading block", err)
+	if string(block) != "second" {
+		t.Fatalf("got %q", block)
+	}
+	if r.UncompressedBytesRead() != int64(len(plaintext0)+len("second")) {
+		t.Fatalf("UncompressedBytesRead = %d", r.UncompressedBytesRead())
+	}
+}
@@ -0,0 +1,43 @@
+package lz4
+
+// BlockHash describes a block written by a Writer created with
+// WithBlockHashes.
+type BlockHash struct {
+	// UncompressedOffset is the offset of the block in the uncompressed
+	// data, and Size its uncompressed size.
+	UncompressedOffset int64
+	Size               int
+	// CompressedOffset is the offset in the compressed stream of the
+	// records of the block, including any control records preceding it.
+	CompressedOffset int64
+	// Sum is the checksum of the uncompressed data of the block.
+	Sum uint64
+}
+
+// WithBlockHashes makes a Writer call fn with the checksum of the uncompressed
+// data of each block once it is written, so that a deduplication index can be
+// built while compressing instead of in a separate pass over the data. The
+// checksum is XXH64, or the one set with WithChecksum. Blocks are cut at the
+// block size, so identical data only gives identical blocks when aligned,
+// as with WriteBlock.
+func WithBlockHashes(fn func(BlockHash)) Option {
+	return func(o *options) {
+		o.blockHash = fn
+	}
+}
+
+// reportBlockHash calls the function of WithBlockHashes for src, the block
+// just written.
+func (w *Writer) reportBlockHash(src []byte) {
+	if w.blockHasher == nil {
+		w.blockHasher = w.opts.newChecksummer(XXH64)
+	}
+	w.blockHasher.Reset()
+	w.blockHasher.Write(src)
+	w.opts.blockHash(BlockHash{
+		UncompressedOffset: w.uncompressedWritten,
+		Size:               len(src),
+		CompressedOffset:   w.recordStart,
+		Sum:                w.blockHasher.Sum64(),
+	})
+}
@@ -0,0 +1,67 @@
+package lz4
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/DataDog/golz4/xxhash"
+)
+
+func TestBlockHashes(t *testing.T) {
+	chunk := bytes.Repeat(plaintext0, 200)
+	other := append([]byte(nil), chunk...)
+	other[0]++
+	var hashes []BlockHash
+	var buf bytes.Buffer
+	w := NewWriter(&buf, WithBlockChecksum(), WithBlockHashes(func(h BlockHash) {
+		hashes = append(hashes, h)
+	}))
+	for _, block := range [][]byte{chunk, other, chunk} {
+		failOnError(t, "Failed writing block", w.WriteBlock(block))
+	}
+	failOnError(t, "Failed closing", w.Close())
+
+	if len(hashes) != 3 {
+		t.Fatalf("got %d hashes, want 3", len(hashes))
+	}
+	if hashes[0].Sum != hashes[2].Sum || hashes[0].Sum == hashes[1].Sum {
+		t.Errorf("sums %x, %x, %x do not identify the blocks", hashes[0].Sum, hashes[1].Sum, hashes[2].Sum)
+	}
+	if want := xxhash.Sum64(chunk); hashes[0].Sum != want {
+		t.Errorf("got sum %x, want XXH64 %x", hashes[0].Sum, want)
+	}
+	var offset int64
+	for i, h := range hashes {
+		if h.UncompressedOffset != offset || h.Size != len(chunk) {
+			t.Errorf("block %d: got offset %d and size %d, want %d and %d", i, h.UncompressedOffset, h.Size, offset, len(chunk))
+		}
+		offset += int64(h.Size)
+	}
+
+	// the records of the block start with its checksum
+	header, err := parseHeader(buf.Bytes()[hashes[2].CompressedOffset:], HeaderWidth4)
+	failOnError(t, "Failed parsing header", err)
+	if typ, _ := parseControlHeader(header); header&controlFlag == 0 || typ != recordBlockChecksum {
+		t.Errorf("compressed offset %d does not start the records of the block", hashes[2].CompressedOffset)
+	}
+
+	out, err := ioutil.ReadAll(NewDecompressReader(&buf, WithBlockChecksum()))
+	failOnError(t, "Failed decompressing", err)
+	if !bytes.Equal(out, bytes.Join([][]byte{chunk, other, chunk}, nil)) {
+		t.Fatalf("Decompressed output != input")
+	}
+}
+
+func TestBlockHashesChecksum(t *testing.T) {
+	var sum uint64
+	w := NewWriter(ioutil.Discard, WithChecksum(XXH32), WithBlockHashes(func(h BlockHash) {
+		sum = h.Sum
+	}))
+	_, err := w.Write(plaintext0)
+	failOnError(t, "Failed writing", err)
+	failOnError(t, "Failed closing", w.Close())
+	if want := uint64(xxhash.Sum32(plaintext0)); sum != want {
+		t.Errorf("got sum %x, want XXH32 %x", sum, want)
+	}
+}
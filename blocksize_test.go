@@ -0,0 +1,63 @@
+package lz4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"testing"
+)
+
+func TestWithLargeBlocks(t *testing.T) {
+	input, err := ioutil.ReadFile(sampleFilePath)
+	failOnError(t, "Failed reading sample", err)
+	for len(input) < 3*LargeBlockSize/2 {
+		input = append(input, input...)
+	}
+
+	var compressed bytes.Buffer
+	w := NewWriter(&compressed, WithLargeBlocks(), WithVerify())
+	_, err = w.Write(input)
+	failOnError(t, "Failed writing to compress object", err)
+	failOnError(t, "Failed closing writer", w.Close())
+
+	blocks := 0
+	for b := compressed.Bytes(); len(b) > 0; blocks++ {
+		b = b[blockHeaderSize+binary.LittleEndian.Uint32(b):]
+	}
+	if want := (len(input) + LargeBlockSize - 1) / LargeBlockSize; blocks != want {
+		t.Errorf("got %d blocks, want %d", blocks, want)
+	}
+
+	r := NewDecompressReader(&compressed)
+	out, err := ioutil.ReadAll(r)
+	failOnError(t, "Failed decompressing", err)
+	failOnError(t, "Failed closing reader", r.Close())
+	if !bytes.Equal(out, input) {
+		t.Fatalf("Decompressed output != input")
+	}
+}
+
+func TestWithBlockSizeSmall(t *testing.T) {
+	input, err := ioutil.ReadFile(sampleFilePath)
+	failOnError(t, "Failed reading sample", err)
+
+	var compressed bytes.Buffer
+	w := NewWriter(&compressed, WithBlockSize(1000))
+	_, err = w.Write(input)
+	failOnError(t, "Failed writing to compress object", err)
+	state, err := w.MarshalState()
+	failOnError(t, "Failed marshaling state", err)
+	failOnError(t, "Failed closing writer", w.Close())
+
+	w, err = NewWriterFromState(&compressed, state, WithBlockSize(1000))
+	failOnError(t, "Failed restoring state", err)
+	_, err = w.Write(input)
+	failOnError(t, "Failed writing to compress object", err)
+	failOnError(t, "Failed closing writer", w.Close())
+
+	out, err := ioutil.ReadAll(NewDecompressReader(&compressed, WithLowMemory()))
+	failOnError(t, "Failed decompressing", err)
+	if !bytes.Equal(out, append(append([]byte(nil), input...), input...)) {
+		t.Fatalf("Decompressed output != input")
+	}
+}
@@ -0,0 +1,103 @@
+package lz4
+
+// #cgo pkg-config: liblz4
+// #include <lz4hc.h>
+import "C"
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrMemoryBudgetExceeded is returned by a Writer, CompressReader or
+// DecompressReader whose buffers would exceed the budget set with
+// SetMemoryBudget.
+var ErrMemoryBudgetExceeded = errors.New("C memory budget exceeded")
+
+// cMemory accounts for the memory allocated with C.malloc and liblz4, which
+// the Go runtime does not see.
+var cMemory struct {
+	mu     sync.Mutex
+	budget int64
+	used   int64
+}
+
+// SetMemoryBudget limits the C memory held by the Writers, CompressReaders
+// and DecompressReaders of the program to bytes in total, or removes the limit
+// if bytes is 0, the default. A Writer uses about twice its block size, a
+// CompressReader about 15 MiB and a DecompressReader about 15 MiB, or under
+// 200 KiB in low-memory mode. The constructors cannot return an error, so
+// those exceeding the budget allocate nothing, and the first Read, ReadBlock
+// or write returns ErrMemoryBudgetExceeded instead, as does Close for a
+// Writer. The memory is released by Close. A Writer that cannot fit the state
+// of HC compression in the budget compresses with fast compression instead.
+// Readers using a buffer from NewDecompressReaderBuffer, frame readers and
+// writers, and the one-shot functions are not accounted for.
+func SetMemoryBudget(bytes int64) {
+	cMemory.mu.Lock()
+	cMemory.budget = bytes
+	cMemory.mu.Unlock()
+}
+
+// MemoryInUse returns the C memory held within the budget of
+// SetMemoryBudget, which is accounted for even without a budget.
+func MemoryInUse() int64 {
+	cMemory.mu.Lock()
+	defer cMemory.mu.Unlock()
+	return cMemory.used
+}
+
+// reserveMemory accounts for n bytes about to be allocated, unless they
+// exceed the budget.
+func reserveMemory(n int64) bool {
+	cMemory.mu.Lock()
+	defer cMemory.mu.Unlock()
+	if cMemory.budget > 0 && cMemory.used+n > cMemory.budget {
+		return false
+	}
+	cMemory.used += n
+	return true
+}
+
+// releaseMemory accounts for n bytes freed.
+func releaseMemory(n int64) {
+	cMemory.mu.Lock()
+	cMemory.used -= n
+	cMemory.mu.Unlock()
+}
+
+var (
+	stateSize   = int64(C.LZ4_sizeofState())
+	stateHCSize = int64(C.LZ4_sizeofStateHC())
+)
+
+// failBudget is the next function of the block engine of a reader that could
+// not allocate its buffers.
+func failBudget() ([]byte, error) {
+	return nil, ErrMemoryBudgetExceeded
+}
+
+// reserveHC accounts for the HC state of w, which is allocated when first
+// needed.
+func (w *Writer) reserveHC() bool {
+	if !reserveMemory(stateHCSize) {
+		return false
+	}
+	w.reserved += stateHCSize
+	return true
+}
+
+// decompressMemory returns the C memory used by a DecompressReader with the
+// options o, allocating its own buffers.
+func decompressMemory(o options) int64 {
+	switch {
+	case o.independentBlocks && o.lowMemory:
+		blockSize := lowMemoryBlockSize(o)
+		return int64(blockSize + compressBound(blockSize))
+	case o.independentBlocks && !o.readAhead:
+		return hugeStreamingBlockSize + boundedHugeStreamingBlockSize
+	case o.lowMemory:
+		return int64(lowMemorySize(o))
+	}
+	return 2*hugeStreamingBlockSize + boundedHugeStreamingBlockSize
+}
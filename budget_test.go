@@ -0,0 +1,60 @@
+package lz4
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func TestMemoryBudget(t *testing.T) {
+	base := MemoryInUse()
+	w := NewWriter(ioutil.Discard)
+	if used := MemoryInUse() - base; used < 2*streamingBlockSize {
+		t.Fatalf("a Writer accounts for %d bytes", used)
+	}
+	failOnError(t, "Failed closing", w.Close())
+	if used := MemoryInUse() - base; used != 0 {
+		t.Fatalf("%d bytes still accounted for after Close", used)
+	}
+
+	compressed, err := CompressBytesToStream(plaintext0)
+	failOnError(t, "Failed compressing", err)
+	SetMemoryBudget(base + 1<<20)
+	defer SetMemoryBudget(0)
+
+	// a low-memory reader fits in the budget, but not a second Writer
+	r := NewDecompressReader(bytes.NewReader(compressed), WithLowMemory())
+	w = NewWriter(ioutil.Discard, WithLevel(9))
+	if _, err := w.Write(plaintext0); err != nil {
+		t.Fatalf("got %v writing within the budget", err)
+	}
+	var buf bytes.Buffer
+	w2 := NewWriter(&buf, WithBlockSize(MaxBlockSize))
+	if _, err := w2.Write(plaintext0); err != ErrMemoryBudgetExceeded {
+		t.Errorf("got %v writing past the budget", err)
+	}
+	if err := w2.Flush(); err != ErrMemoryBudgetExceeded {
+		t.Errorf("got %v flushing past the budget", err)
+	}
+	if err := w2.Close(); err != ErrMemoryBudgetExceeded || buf.Len() != 0 {
+		t.Errorf("got %v closing past the budget, with %d bytes written", err, buf.Len())
+	}
+	for _, rc := range []io.ReadCloser{NewDecompressReader(bytes.NewReader(compressed)), NewCompressReader(bytes.NewReader(plaintext0))} {
+		if _, err := rc.Read(make([]byte, 10)); err != ErrMemoryBudgetExceeded {
+			t.Errorf("got %v reading past the budget", err)
+		}
+		failOnError(t, "Failed closing", rc.Close())
+	}
+
+	out, err := ioutil.ReadAll(r)
+	failOnError(t, "Failed decompressing", err)
+	if !bytes.Equal(out, plaintext0) {
+		t.Fatal("decompressed output != input")
+	}
+	failOnError(t, "Failed closing", r.Close())
+	failOnError(t, "Failed closing", w.Close())
+	if used := MemoryInUse() - base; used != 0 {
+		t.Fatalf("%d bytes still accounted for after Close", used)
+	}
+}
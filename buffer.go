@@ -0,0 +1,62 @@
+package lz4
+
+import (
+	"bytes"
+	"io"
+)
+
+// Buffer compresses the data written to it into an in-memory block stream,
+// in the format produced by Writer. The zero value is an empty Buffer ready to
+// use. It is the caller's responsibility to call Close when done, to free the
+// resources of the underlying Writer.
+type Buffer struct {
+	buf bytes.Buffer
+	w   *Writer
+}
+
+// Write compresses p and appends it to the buffer.
+func (b *Buffer) Write(p []byte) (int, error) {
+	if b.w == nil {
+		b.w = newWriter(&b.buf, explicitOptions())
+	}
+	return b.w.Write(p)
+}
+
+// Bytes returns the compressed stream written so far. The slice is only valid
+// until the next call to Write or Reset.
+func (b *Buffer) Bytes() []byte {
+	return b.buf.Bytes()
+}
+
+// Len returns the size of the compressed stream written so far.
+func (b *Buffer) Len() int {
+	return b.buf.Len()
+}
+
+// Decompress writes the uncompressed content of the buffer to dst and returns
+// the number of bytes written. The buffer is left unchanged.
+func (b *Buffer) Decompress(dst io.Writer) (int64, error) {
+	r := newDecompressReader(bytes.NewReader(b.buf.Bytes()), explicitOptions(), nil)
+	n, err := io.Copy(dst, r)
+	if cerr := r.Close(); err == nil {
+		err = cerr
+	}
+	return n, err
+}
+
+// Reset empties the buffer and starts a new compressed stream.
+func (b *Buffer) Reset() {
+	b.Close()
+	b.buf.Reset()
+}
+
+// Close releases the resources of the underlying Writer. The compressed data
+// remains available through Bytes, and further writes start a new stream.
+func (b *Buffer) Close() error {
+	if b.w == nil {
+		return nil
+	}
+	err := b.w.Close()
+	b.w = nil
+	return err
+}
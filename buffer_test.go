@@ -0,0 +1,55 @@
+package lz4
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestBuffer(t *testing.T) {
+	input, err := ioutil.ReadFile(sampleFilePath)
+	failOnError(t, "Failed reading sample", err)
+
+	var b Buffer
+	defer b.Close()
+	for i := 0; i < len(input); i += 1000 {
+		end := i + 1000
+		if end > len(input) {
+			end = len(input)
+		}
+		_, err := b.Write(input[i:end])
+		failOnError(t, "Failed writing to buffer", err)
+	}
+	if b.Len() == 0 || b.Len() >= len(input) {
+		t.Fatalf("unexpected compressed length %d for %d bytes", b.Len(), len(input))
+	}
+
+	var out bytes.Buffer
+	n, err := b.Decompress(&out)
+	failOnError(t, "Failed decompressing buffer", err)
+	if n != int64(len(input)) || !bytes.Equal(out.Bytes(), input) {
+		t.Fatalf("Decompressed output != input (lengths: %d, %d)", n, len(input))
+	}
+
+	// the compressed stream can be read by the streaming API
+	r := NewDecompressReader(bytes.NewReader(b.Bytes()))
+	decompressed, err := ioutil.ReadAll(r)
+	failOnError(t, "Failed decompressing", err)
+	failOnError(t, "Failed closing reader", r.Close())
+	if !bytes.Equal(decompressed, input) {
+		t.Fatalf("Decompressed output != input")
+	}
+
+	b.Reset()
+	if b.Len() != 0 {
+		t.Fatalf("expected empty buffer after Reset, got %d bytes", b.Len())
+	}
+	_, err = b.Write(plaintext0)
+	failOnError(t, "Failed writing to buffer", err)
+	out.Reset()
+	_, err = b.Decompress(&out)
+	failOnError(t, "Failed decompressing buffer", err)
+	if !bytes.Equal(out.Bytes(), plaintext0) {
+		t.Fatalf("Decompressed output != input after Reset: %q", out.Bytes())
+	}
+}
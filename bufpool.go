@@ -0,0 +1,84 @@
+package lz4
+
+import (
+	"container/list"
+	"sync"
+)
+
+// WithMaxRetainedBytes bounds the output buffers a CompressorPool keeps
+// between jobs to about n bytes in total. Once a job returns its buffer past
+// the limit, the least recently used buffers are released, so a burst of
+// large inputs does not leave the pool holding its peak memory. Buffers in
+// use by running jobs do not count. Zero or less, the default, keeps every
+// buffer. The option is ignored by the other types.
+func WithMaxRetainedBytes(n int64) Option {
+	return func(o *options) {
+		o.maxRetained = n
+	}
+}
+
+// bufferPool keeps the buffers returned to it, most recently used first,
+// trimming the least recently used once they exceed max bytes.
+type bufferPool struct {
+	mu       sync.Mutex
+	max      int64
+	retained int64
+	free     list.List
+}
+
+// get returns a buffer of size bytes, reusing the most recently used buffer
+// large enough.
+func (bp *bufferPool) get(size int) []byte {
+	bp.mu.Lock()
+	for e := bp.free.Front(); e != nil; e = e.Next() {
+		if buf := e.Value.([]byte); cap(buf) >= size {
+			bp.free.Remove(e)
+			bp.retained -= int64(cap(buf))
+			bp.mu.Unlock()
+			return buf[:size]
+		}
+	}
+	bp.mu.Unlock()
+	return make([]byte, size)
+}
+
+// put returns buf to the pool, trimming it to max bytes.
+func (bp *bufferPool) put(buf []byte) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	bp.free.PushFront(buf)
+	bp.retained += int64(cap(buf))
+	if bp.max > 0 {
+		bp.trim(bp.max)
+	}
+}
+
+// trim releases the least recently used buffers until at most max bytes are
+// retained. It must be called with mu held.
+func (bp *bufferPool) trim(max int64) {
+	for bp.retained > max {
+		e := bp.free.Back()
+		bp.retained -= int64(cap(bp.free.Remove(e).([]byte)))
+	}
+}
+
+// size returns the number of bytes retained.
+func (bp *bufferPool) size() int64 {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	return bp.retained
+}
+
+// Trim releases every buffer the pool keeps between jobs, as after a burst of
+// traffic, leaving buffers in use by running jobs alone. Later jobs allocate
+// new buffers as needed.
+func (cp *CompressorPool) Trim() {
+	cp.buffers.mu.Lock()
+	defer cp.buffers.mu.Unlock()
+	cp.buffers.trim(0)
+}
+
+// RetainedBytes returns the size of the buffers the pool keeps between jobs.
+func (cp *CompressorPool) RetainedBytes() int64 {
+	return cp.buffers.size()
+}
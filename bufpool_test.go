@@ -0,0 +1,56 @@
+package lz4
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBufferPoolLRU(t *testing.T) {
+	bp := bufferPool{max: 300}
+	a, b, c := bp.get(100), bp.get(100), bp.get(150)
+	bp.put(a)
+	bp.put(b)
+	if got := bp.size(); got != 200 {
+		t.Fatalf("retained %d bytes instead of 200", got)
+	}
+	// a is the least recently used, and goes first
+	bp.put(c)
+	if got := bp.size(); got != 250 {
+		t.Fatalf("retained %d bytes instead of 250", got)
+	}
+	if buf := bp.get(100); &buf[0] != &c[0] {
+		t.Error("get did not reuse the most recently used buffer")
+	}
+	if buf := bp.get(100); &buf[0] != &b[0] {
+		t.Error("get did not reuse the remaining buffer")
+	}
+	if got := bp.size(); got != 0 {
+		t.Errorf("retained %d bytes instead of 0", got)
+	}
+}
+
+func TestCompressorPoolMaxRetained(t *testing.T) {
+	const max = 64 << 10
+	cp := NewCompressorPool(2, WithMaxRetainedBytes(max))
+	defer cp.Close()
+	for _, size := range []int{1 << 20, 100, 10 << 10, 2 << 20, 1000} {
+		in := bytes.Repeat([]byte("spiky "), size/6)
+		out, err := cp.Submit(in)
+		failOnError(t, "Failed compressing", err)
+		dec, err := UncompressAllocHdr(nil, out)
+		failOnError(t, "Failed decompressing", err)
+		if !bytes.Equal(dec, in) {
+			t.Fatalf("%d bytes: decompressed output != input", size)
+		}
+		if got := cp.RetainedBytes(); got > max {
+			t.Errorf("%d bytes: pool retains %d bytes, more than %d", size, got, max)
+		}
+	}
+	if cp.RetainedBytes() == 0 {
+		t.Error("pool retains no buffer after small inputs")
+	}
+	cp.Trim()
+	if got := cp.RetainedBytes(); got != 0 {
+		t.Errorf("pool retains %d bytes after Trim", got)
+	}
+}
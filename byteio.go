@@ -0,0 +1,67 @@
+package lz4
+
+import "io"
+
+// WriteByte writes c to w, making Writer an io.ByteWriter, as used by
+// encoders writing varints. The bytes are buffered, and compressed as a block
+// once a block is full, or before any other data is written to w, on Flush,
+// MarshalState, ResetState or Close.
+func (w *Writer) WriteByte(c byte) error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.byteBuf == nil {
+		w.byteBuf = make([]byte, 0, w.blockSize)
+	}
+	w.byteBuf = append(w.byteBuf, c)
+	if len(w.byteBuf) == w.blockSize {
+		return w.flushBytes()
+	}
+	return nil
+}
+
+// flushBytes compresses the bytes buffered by WriteByte, if any.
+func (w *Writer) flushBytes() error {
+	if len(w.byteBuf) == 0 {
+		return nil
+	}
+	// the buffer is emptied first, since writeFrame may reset the state
+	b := w.byteBuf
+	w.byteBuf = w.byteBuf[:0]
+	_, err := w.writeFrame(b, false)
+	return err
+}
+
+// ReadByte reads and returns the next decompressed byte, making
+// DecompressReader an io.ByteReader, as used by binary.ReadUvarint, without
+// wrapping it in a bufio.Reader, since the decoded block is already buffered.
+func (r *DecompressReader) ReadByte() (byte, error) {
+	return r.out.readByte()
+}
+
+// ReadByte reads and returns the next decompressed byte, making FrameReader
+// an io.ByteReader.
+func (r *FrameReader) ReadByte() (byte, error) {
+	if r.oneByte == nil {
+		r.oneByte = make([]byte, 1)
+	}
+	n, err := io.ReadFull(r, r.oneByte)
+	if n == 0 {
+		return 0, err
+	}
+	return r.oneByte[0], nil
+}
+
+// readByte returns the next byte of the output of e.
+func (e *blockEngine) readByte() (byte, error) {
+	for len(e.pending) == 0 {
+		block, err := e.nextBlock()
+		if err != nil {
+			return 0, err
+		}
+		e.pending = block
+	}
+	c := e.pending[0]
+	e.pending = e.pending[1:]
+	return c, nil
+}
@@ -0,0 +1,71 @@
+package lz4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+func TestByteIO(t *testing.T) {
+	values := []uint64{0, 1, 127, 128, 300, 1 << 20, 1<<63 + 5}
+	var buf bytes.Buffer
+	w := NewWriter(&buf, WithBlockSize(1000), WithBlockChecksum())
+	var tmp [binary.MaxVarintLen64]byte
+	var plain []byte
+	for i := 0; i < 1000; i++ {
+		v := values[i%len(values)]
+		for _, c := range tmp[:binary.PutUvarint(tmp[:], v)] {
+			failOnError(t, "Failed writing byte", w.WriteByte(c))
+			plain = append(plain, c)
+		}
+		if i%300 == 0 {
+			// mixed with other writes
+			_, err := w.Write([]byte{0})
+			failOnError(t, "Failed writing", err)
+			plain = append(plain, 0)
+		}
+	}
+	failOnError(t, "Failed closing", w.Close())
+	if got := w.UncompressedBytesWritten(); got != int64(len(plain)) {
+		t.Fatalf("%d bytes written, want %d", got, len(plain))
+	}
+
+	r := NewDecompressReader(bytes.NewReader(buf.Bytes()), WithBlockChecksum()).(*DecompressReader)
+	defer r.Close()
+	for i := 0; i < 1000; i++ {
+		v, err := binary.ReadUvarint(r)
+		failOnError(t, "Failed reading varint", err)
+		if want := values[i%len(values)]; v != want {
+			t.Fatalf("value %d: got %d, want %d", i, v, want)
+		}
+		if i%300 == 0 {
+			if c, err := r.ReadByte(); c != 0 || err != nil {
+				t.Fatalf("value %d: got byte %d, %v", i, c, err)
+			}
+		}
+	}
+	if _, err := r.ReadByte(); err != io.EOF {
+		t.Errorf("got error %v at the end", err)
+	}
+
+	var frame bytes.Buffer
+	fw := NewFrameWriter(&frame)
+	_, err := fw.Write(plain)
+	failOnError(t, "Failed writing frame", err)
+	failOnError(t, "Failed closing frame", fw.Close())
+	fr := NewFrameReader(&frame, WithEarlyEOF())
+	defer fr.Close()
+	var got []byte
+	for {
+		c, err := fr.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		failOnError(t, "Failed reading frame byte", err)
+		got = append(got, c)
+	}
+	if !bytes.Equal(got, plain) {
+		t.Error("bytes read from the frame != bytes written")
+	}
+}
@@ -0,0 +1,35 @@
+package lz4
+
+// #cgo pkg-config: liblz4
+// #include <lz4.h>
+import "C"
+
+import "fmt"
+
+// libraryVersion is the version of the liblz4 linked, such as "1.9.4".
+var libraryVersion = C.GoString(C.LZ4_versionString())
+
+// CError is the error returned when a function of liblz4 fails, possibly
+// wrapped. It gives access with errors.As to the name of the function and its
+// raw return code, as needed to report a problem against a given version of
+// liblz4.
+type CError struct {
+	// Func is the name of the liblz4 function that failed, such as
+	// "LZ4_decompress_safe".
+	Func string
+	// Code is the value returned by Func. Errors of the LZ4F functions are
+	// negative, the opposite of their LZ4F_errorCodes value.
+	Code int
+	// Version is the version of liblz4 that returned the error.
+	Version string
+
+	msg string
+}
+
+func newCError(fn string, code int, msg string) *CError {
+	return &CError{Func: fn, Code: code, Version: libraryVersion, msg: msg}
+}
+
+func (e *CError) Error() string {
+	return fmt.Sprintf("%s (%s returned %d, liblz4 %s)", e.msg, e.Func, e.Code, e.Version)
+}
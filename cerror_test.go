@@ -0,0 +1,44 @@
+package lz4
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestCError(t *testing.T) {
+	check := func(name string, err error, fn string, negative bool) {
+		t.Helper()
+		var ce *CError
+		if !errors.As(err, &ce) {
+			t.Errorf("%s: got error %v, not a CError", name, err)
+			return
+		}
+		if ce.Func != fn || (ce.Code < 0) != negative || ce.Version == "" {
+			t.Errorf("%s: got %+v, want function %s", name, ce, fn)
+		}
+		if !strings.Contains(err.Error(), fn) {
+			t.Errorf("%s: function missing from message %q", name, err)
+		}
+	}
+
+	_, err := Uncompress(make([]byte, 10), []byte{0xff, 0xff, 0xff})
+	check("Uncompress", err, "LZ4_decompress_safe", true)
+	_, err = Compress(make([]byte, 2), bytes.Repeat([]byte("x"), 100))
+	check("Compress", err, "LZ4_compress_default", false)
+	_, err = CompressHCLevel(make([]byte, 2), bytes.Repeat([]byte("x"), 100), 9)
+	check("CompressHCLevel", err, "LZ4_compress_HC", false)
+
+	stream := compressStream(t, bytes.Repeat([]byte("corrupt me "), 1000))
+	stream[len(stream)-10] ^= 0xff
+	stream[len(stream)-20] ^= 0xff
+	_, err = io.ReadAll(NewDecompressReader(bytes.NewReader(stream)))
+	check("DecompressReader", err, "LZ4_decompress_safe_continue", true)
+
+	frame := compressFrame(t, bytes.Repeat([]byte("corrupt me "), 1000))
+	frame[len(frame)/2] ^= 0xff
+	_, err = io.ReadAll(NewFrameReader(bytes.NewReader(frame)))
+	check("FrameReader", err, "LZ4F_decompress", true)
+}
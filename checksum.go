@@ -0,0 +1,83 @@
+package lz4
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash"
+	"io"
+
+	"github.com/DataDog/golz4/xxhash"
+)
+
+// Checksummer is a streaming checksum used by WithTrailer and
+// WithBlockChecksum. Checksums of up to 32 bits are returned zero-extended by
+// Sum64.
+type Checksummer interface {
+	io.Writer
+	// Reset starts a new checksum.
+	Reset()
+	// Sum64 returns the checksum of the data written since the last Reset.
+	Sum64() uint64
+}
+
+// XXH32 returns a Checksummer computing XXH32, the checksum of the lz4 frame
+// format.
+func XXH32() Checksummer {
+	return NewHash32Checksummer(xxhash.New32())
+}
+
+// XXH64 returns a Checksummer computing XXH64.
+func XXH64() Checksummer {
+	return xxhash.New64()
+}
+
+// NewHash32Checksummer returns a Checksummer computing h, for example CRC-32C
+// with crc32.New(crc32.MakeTable(crc32.Castagnoli)).
+func NewHash32Checksummer(h hash.Hash32) Checksummer {
+	return hash32Checksummer{h}
+}
+
+type hash32Checksummer struct {
+	hash.Hash32
+}
+
+func (c hash32Checksummer) Sum64() uint64 {
+	return uint64(c.Sum32())
+}
+
+// ErrChecksumMismatch is returned by a reader created with WithBlockChecksum
+// when a block does not match its checksum.
+var ErrChecksumMismatch = errors.New("block checksum mismatch")
+
+// blockChecksumPayloadSize is the size of the payload of a block checksum
+// record, the checksum of the next block as a little endian uint64.
+const blockChecksumPayloadSize = 8
+
+// writeBlockChecksum writes a block checksum record for src, the data of the
+// next block.
+func (w *Writer) writeBlockChecksum(src []byte) error {
+	w.blockChecksum.Reset()
+	w.blockChecksum.Write(src)
+	var record [blockHeaderSize + blockChecksumPayloadSize]byte
+	binary.LittleEndian.PutUint32(record[:], controlHeader(recordBlockChecksum, blockChecksumPayloadSize))
+	binary.LittleEndian.PutUint64(record[blockHeaderSize:], w.blockChecksum.Sum64())
+	if err := w.writeRecord(record[:]); err != nil {
+		return err
+	}
+	w.compressedWritten += int64(len(record))
+	return nil
+}
+
+// checkBlock verifies block against the checksum record preceding it, if any.
+func (r *DecompressReader) checkBlock(block []byte) error {
+	if !r.hasBlockSum {
+		return nil
+	}
+	r.hasBlockSum = false
+	r.blockChecksum.Reset()
+	r.blockChecksum.Write(block)
+	if r.blockChecksum.Sum64() != r.blockSum {
+		return &corruptionError{ErrChecksumMismatch}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package lz4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io/ioutil"
+	"testing"
+)
+
+func TestBlockChecksum(t *testing.T) {
+	input, err := ioutil.ReadFile(sampleFilePath)
+	failOnError(t, "Failed reading sample", err)
+	crc32c := func() Checksummer {
+		return NewHash32Checksummer(crc32.New(crc32.MakeTable(crc32.Castagnoli)))
+	}
+
+	for _, opts := range [][]Option{
+		{WithBlockChecksum()},
+		{WithBlockChecksum(), WithChecksum(crc32c)},
+		{WithBlockChecksum(), WithTrailer(), WithChecksum(XXH64)},
+	} {
+		var compressed bytes.Buffer
+		w := NewWriter(&compressed, opts...)
+		_, err := w.Write(input)
+		failOnError(t, "Failed writing to compress object", err)
+		failOnError(t, "Failed closing writer", w.Close())
+
+		for _, ropts := range [][]Option{nil, opts} {
+			out, err := ioutil.ReadAll(NewDecompressReader(bytes.NewReader(compressed.Bytes()), ropts...))
+			failOnError(t, "Failed decompressing", err)
+			if !bytes.Equal(out, input) {
+				t.Fatalf("Decompressed output != input")
+			}
+		}
+	}
+}
+
+func TestBlockChecksumMismatch(t *testing.T) {
+	var compressed bytes.Buffer
+	w := NewWriter(&compressed, WithBlockChecksum())
+	_, err := w.Write(plaintext0)
+	failOnError(t, "Failed writing to compress object", err)
+	failOnError(t, "Failed closing writer", w.Close())
+
+	// change a literal of the first sequence, which still decompresses
+	b := append([]byte(nil), compressed.Bytes()...)
+	b[blockHeaderSize+blockChecksumPayloadSize+blockHeaderSize+2] ^= 1
+	_, err = ioutil.ReadAll(NewDecompressReader(bytes.NewReader(b), WithBlockChecksum()))
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected ErrChecksumMismatch, got %v", err)
+	}
+
+	// a different checksum does not match either
+	_, err = ioutil.ReadAll(NewDecompressReader(bytes.NewReader(compressed.Bytes()), WithBlockChecksum(), WithChecksum(XXH64)))
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("expected ErrChecksumMismatch, got %v", err)
+	}
+}
+
+func TestXXH32FrameChecksum(t *testing.T) {
+	var compressed bytes.Buffer
+	w := NewFrameWriter(&compressed)
+	_, err := w.Write(plaintext0)
+	failOnError(t, "Failed writing to compress object", err)
+	failOnError(t, "Failed closing writer", w.Close())
+
+	// the frame ends with the XXH32 of its content
+	b := compressed.Bytes()
+	c := XXH32()
+	c.Write(plaintext0)
+	if got := binary.LittleEndian.Uint32(b[len(b)-4:]); uint64(got) != c.Sum64() {
+		t.Fatalf("frame checksum %#x, XXH32 %#x", got, c.Sum64())
+	}
+}
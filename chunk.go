@@ -0,0 +1,160 @@
+package lz4
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// ChunkWriter compresses its input into a block stream and cuts the stream
+// into chunks of a fixed size on record boundaries, passing each completed
+// chunk to a callback. The chunks can be stored separately, for example as the
+// parts of a multipart upload, and concatenated to get the stream back.
+//
+// With padding, every chunk, including the last one, is filled up to exactly
+// the chunk size with padding records, which readers skip. Without it, chunks
+// are at most the chunk size.
+type ChunkWriter struct {
+	w         *Writer
+	chunkSize int
+	padded    bool
+	onChunk   func(chunk []byte) error
+
+	chunk  []byte
+	record []byte
+}
+
+// NewChunkWriter creates a new ChunkWriter calling onChunk with chunks of
+// chunkSize bytes. The chunk passed to onChunk is only valid during the call.
+// An error returned by onChunk is returned by the Write or Close call that
+// completed the chunk. chunkSize must be large enough for the largest block,
+// which is slightly more than 64 KiB by default; sizes of a few MiB are
+// typical. opts configure the underlying Writer. It is the caller's
+// responsibility to call Close when done, which passes the last chunk to
+// onChunk.
+func NewChunkWriter(chunkSize int, padded bool, onChunk func(chunk []byte) error, opts ...Option) *ChunkWriter {
+	c := &ChunkWriter{
+		chunkSize: chunkSize,
+		padded:    padded,
+		onChunk:   onChunk,
+		chunk:     make([]byte, 0, chunkSize),
+	}
+	o := newOptions(opts)
+	c.w = newWriter(chunkRecords{c}, o)
+	if err := checkDefaultWidth(o); err != nil {
+		// records are cut on 4-byte headers
+		c.w.err = err
+	}
+	return c
+}
+
+// Write compresses p.
+func (c *ChunkWriter) Write(p []byte) (int, error) {
+	return c.w.Write(p)
+}
+
+// Close ends the stream, passes the last chunk to onChunk and releases the
+// resources of the underlying Writer.
+func (c *ChunkWriter) Close() error {
+	if err := c.w.Close(); err != nil {
+		return err
+	}
+	if len(c.chunk) == 0 {
+		return nil
+	}
+	return c.finishChunk()
+}
+
+// chunkRecords receives the stream written by the Writer of a ChunkWriter.
+type chunkRecords struct {
+	c *ChunkWriter
+}
+
+// Write reassembles the records of the stream, which the Writer may write in
+// several pieces.
+func (cr chunkRecords) Write(p []byte) (int, error) {
+	c := cr.c
+	c.record = append(c.record, p...)
+	for len(c.record) >= blockHeaderSize {
+		size := recordSize(binary.LittleEndian.Uint32(c.record))
+		if len(c.record) < size {
+			break
+		}
+		if err := c.addRecord(c.record[:size]); err != nil {
+			return 0, err
+		}
+		c.record = c.record[:copy(c.record, c.record[size:])]
+	}
+	return len(p), nil
+}
+
+// recordSize returns the size of the record with the given header, including
+// the header.
+func recordSize(header uint32) int {
+	if header&controlFlag != 0 {
+		_, length := parseControlHeader(header)
+		return blockHeaderSize + length
+	}
+	return blockHeaderSize + int(header)
+}
+
+// addRecord adds record to the current chunk, starting a new chunk first if it
+// does not fit.
+func (c *ChunkWriter) addRecord(record []byte) error {
+	if len(record) > c.chunkSize {
+		return fmt.Errorf("record of %d bytes larger than chunk size", len(record))
+	}
+	// with padding, the space left must be 0 or fit a padding record, which
+	// a full chunk always leaves
+	if !c.fits(len(c.chunk), len(record)) && len(c.chunk) > 0 {
+		if err := c.finishChunk(); err != nil {
+			return err
+		}
+	}
+	if !c.fits(0, len(record)) {
+		return fmt.Errorf("record of %d bytes leaves less than a header of padding in a chunk of %d bytes",
+			len(record), c.chunkSize)
+	}
+	c.chunk = append(c.chunk, record...)
+	if len(c.chunk) == c.chunkSize {
+		return c.finishChunk()
+	}
+	return nil
+}
+
+// fits reports whether a record of size bytes can be added to a chunk of used
+// bytes.
+func (c *ChunkWriter) fits(used, size int) bool {
+	left := c.chunkSize - used - size
+	return left == 0 || left >= blockHeaderSize || (!c.padded && left > 0)
+}
+
+// finishChunk pads the current chunk if needed and passes it to onChunk.
+func (c *ChunkWriter) finishChunk() error {
+	if c.padded {
+		c.chunk = appendPadding(c.chunk, c.chunkSize-len(c.chunk), blockHeaderSize)
+	}
+	err := c.onChunk(c.chunk)
+	c.chunk = c.chunk[:0]
+	return err
+}
+
+// appendPadding appends n bytes of padding records with headers of width bytes
+// to b. n must be 0 or at least width.
+func appendPadding(b []byte, n, width int) []byte {
+	for n > 0 {
+		// keep each record small enough for readers, and the rest large
+		// enough for a header
+		piece := n
+		if piece > width+maxControlLength {
+			piece = width + maxControlLength
+			if n-piece < width {
+				piece -= width
+			}
+		}
+		var header [maxHeaderWidth]byte
+		b = append(b, header[:putHeader(header[:], controlHeader(recordPadding, piece-width), width)]...)
+		b = append(b, make([]byte, piece-width)...)
+		n -= piece
+	}
+	return b
+}
@@ -0,0 +1,102 @@
+package lz4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+)
+
+func TestChunkWriter(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	input := make([]byte, 1<<20)
+	for i := range input {
+		input[i] = 'a' + byte(rng.Intn(4))
+	}
+
+	const chunkSize = 200 * 1024
+	for _, padded := range []bool{false, true} {
+		var chunks [][]byte
+		c := NewChunkWriter(chunkSize, padded, func(chunk []byte) error {
+			chunks = append(chunks, append([]byte(nil), chunk...))
+			return nil
+		}, WithTrailer())
+		for i := 0; i < len(input); i += 10000 {
+			_, err := c.Write(input[i:min(i+10000, len(input))])
+			failOnError(t, "Failed writing", err)
+		}
+		failOnError(t, "Failed closing", c.Close())
+
+		var stream []byte
+		for i, chunk := range chunks {
+			if len(chunk) > chunkSize || (padded && len(chunk) != chunkSize) {
+				t.Fatalf("padded %v: chunk %d is %d bytes", padded, i, len(chunk))
+			}
+			// chunks start on a record boundary
+			s := NewBlockScanner(bytes.NewReader(chunk))
+			for s.Scan() {
+			}
+			failOnError(t, "Failed scanning chunk", s.Err())
+			stream = append(stream, chunk...)
+		}
+		out, err := ioutil.ReadAll(NewDecompressReader(bytes.NewReader(stream), WithTrailer()))
+		failOnError(t, "Failed decompressing", err)
+		if !bytes.Equal(out, input) {
+			t.Fatalf("padded %v: decompressed output != input", padded)
+		}
+	}
+}
+
+func TestAppendPadding(t *testing.T) {
+	for _, n := range []int{4, 5, blockHeaderSize + maxControlLength, blockHeaderSize + maxControlLength + 1,
+		blockHeaderSize + maxControlLength + 4, 3*maxControlLength + 2} {
+		for _, width := range []int{HeaderWidth3, HeaderWidth4, HeaderWidth8} {
+			if n < width {
+				continue
+			}
+			b := appendPadding(nil, n, width)
+			if len(b) != n {
+				t.Fatalf("padding of %d bytes with width %d is %d bytes", n, width, len(b))
+			}
+			if width != blockHeaderSize {
+				preamble := make([]byte, blockHeaderSize, blockHeaderSize+headerWidthPayload)
+				binary.LittleEndian.PutUint32(preamble, controlHeader(recordHeaderWidth, headerWidthPayload))
+				b = append(append(preamble, byte(width)), b...)
+			}
+			out, err := ioutil.ReadAll(NewDecompressReader(bytes.NewReader(b)))
+			if err != nil || len(out) != 0 {
+				t.Fatalf("padding of %d bytes with width %d: got %d bytes, %v", n, width, len(out), err)
+			}
+		}
+	}
+}
+
+func TestChunkWriterRecordSize(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	input := make([]byte, 40)
+	rng.Read(input)
+	// the record of the incompressible input is about 49 bytes
+	for chunkSize := 40; chunkSize <= 60; chunkSize++ {
+		var stream []byte
+		c := NewChunkWriter(chunkSize, true, func(chunk []byte) error {
+			if len(chunk) != chunkSize {
+				t.Errorf("chunk size %d: got a chunk of %d bytes", chunkSize, len(chunk))
+			}
+			stream = append(stream, chunk...)
+			return nil
+		})
+		_, err := c.Write(input)
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			continue
+		}
+		out, err := ioutil.ReadAll(NewDecompressReader(bytes.NewReader(stream)))
+		failOnError(t, "Failed decompressing", err)
+		if !bytes.Equal(out, input) {
+			t.Fatalf("chunk size %d: decompressed output != input", chunkSize)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+// Command golz4 works with the lz4 block streams of package lz4.
+//
+// Usage:
+//
+//	golz4 inspect [-v] [file]
+//
+// The inspect subcommand walks a block stream, read from file or the standard
+// input, decoding every block and verifying the checksums, and prints a
+// summary, with the size and ratio of each block if -v is given. If the stream
+// is damaged, it prints the offset of the first corrupt record and exits with
+// status 1.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	lz4 "github.com/DataDog/golz4"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: golz4 inspect [-v] [file]")
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "inspect":
+		os.Exit(inspect(os.Args[2:]))
+	default:
+		usage()
+	}
+}
+
+func inspect(args []string) int {
+	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
+	verbose := fs.Bool("v", false, "print every block")
+	fs.Usage = usage
+	fs.Parse(args)
+
+	var r io.Reader = os.Stdin
+	switch fs.NArg() {
+	case 0:
+	case 1:
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer f.Close()
+		r = f
+	default:
+		usage()
+	}
+
+	report, err := lz4.Inspect(r)
+	if *verbose {
+		for i, b := range report.Blocks {
+			fmt.Printf("block %d at %d: %d -> %d bytes, ratio %.2f\n", i, b.Offset, b.CompressedSize, b.UncompressedSize, b.Ratio())
+		}
+	}
+	fmt.Printf("%d blocks, %d -> %d bytes", len(report.Blocks), report.CompressedSize, report.UncompressedSize)
+	if report.CompressedSize > 0 {
+		fmt.Printf(", ratio %.2f", report.Ratio())
+	}
+	fmt.Println()
+	fmt.Printf("block checksums: %v, trailer: %v\n", report.BlockChecksums, report.Trailer)
+	if err != nil {
+		fmt.Printf("error at offset %d: %v\n", report.ErrOffset, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,53 @@
+package lz4
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+func TestCompressFrom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	words := []string{"stable ", "input ", "memory ", "mapped ", "file "}
+	var plain []byte
+	for len(plain) < 1<<20 {
+		plain = append(plain, words[rng.Intn(len(words))]...)
+	}
+
+	for _, tc := range []struct {
+		name string
+		opts []Option
+	}{
+		{"default", nil},
+		{"small blocks", []Option{WithBlockSize(10000), WithVerify()}},
+		{"hc", []Option{WithLevel(9), WithBlockSize(20000)}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var compressed bytes.Buffer
+			w := NewWriter(&compressed, tc.opts...)
+			// contiguous calls, with short last blocks, mixed with copies
+			parts := []int{70000, 5000, 200000, 1}
+			off := 0
+			for i, n := range parts {
+				var err error
+				if i == 2 {
+					_, err = w.Write(plain[off : off+n])
+				} else {
+					_, err = w.CompressFrom(plain[off : off+n])
+				}
+				failOnError(t, "Failed writing", err)
+				off += n
+			}
+			_, err := w.CompressFrom(plain[off:])
+			failOnError(t, "Failed writing", err)
+			failOnError(t, "Failed closing", w.Close())
+
+			got, err := io.ReadAll(NewDecompressReader(&compressed))
+			failOnError(t, "Failed decompressing", err)
+			if !bytes.Equal(got, plain) {
+				t.Fatal("decompressed content does not match")
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package lz4
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+)
+
+// stressCase is a one-shot function checked by StressConcurrentUse: it
+// processes in, shared by all goroutines, and returns its result, which must
+// be the same on every call.
+type stressCase struct {
+	name string
+	run  func(in []byte) ([]byte, error)
+}
+
+var stressCases = []stressCase{
+	{"Compress", func(in []byte) ([]byte, error) {
+		out := make([]byte, CompressBound(in))
+		n, err := Compress(out, in)
+		return out[:n], err
+	}},
+	{"CompressFast", func(in []byte) ([]byte, error) {
+		out := make([]byte, CompressBound(in))
+		n, err := CompressFast(out, in, 4)
+		return out[:n], err
+	}},
+	{"CompressHCLevel", func(in []byte) ([]byte, error) {
+		out := make([]byte, CompressBound(in))
+		n, err := CompressHCLevel(out, in, 9)
+		return out[:n], err
+	}},
+	{"CompressOrStore", func(in []byte) ([]byte, error) {
+		out := make([]byte, len(in))
+		res, err := CompressOrStore(out, in)
+		return out[:res.CompressedSize], err
+	}},
+	{"CompressAllocHdr/UncompressAllocHdr", func(in []byte) ([]byte, error) {
+		c, err := CompressAllocHdr(in)
+		if err != nil {
+			return nil, err
+		}
+		return UncompressAllocHdr(nil, c)
+	}},
+	{"CompressAllocHdr16/UncompressAllocHdr16", func(in []byte) ([]byte, error) {
+		if len(in) > MaxHdr16Size {
+			in = in[:MaxHdr16Size]
+		}
+		c, err := CompressAllocHdr16(in)
+		if err != nil {
+			return nil, err
+		}
+		return UncompressAllocHdr16(nil, c)
+	}},
+	{"CompressHdr/DecodeUntrustedBlockHdr", func(in []byte) ([]byte, error) {
+		c, err := CompressAllocHdr(in)
+		if err != nil {
+			return nil, err
+		}
+		return DecodeUntrustedBlockHdr(c, Limits{MaxSize: len(in)})
+	}},
+	{"CompressBytesToStream/DecompressAll", func(in []byte) ([]byte, error) {
+		c, err := CompressBytesToStream(in)
+		if err != nil {
+			return nil, err
+		}
+		return DecompressAll(c, len(in))
+	}},
+}
+
+// StressConcurrentUse runs the one-shot functions of the package from the
+// given number of goroutines at once, each calling every function rounds
+// times on the same input, and returns an error if any call fails or returns
+// a different result than when called alone. It is meant for tests checking
+// the concurrency contract of the package, described in the package
+// documentation, under the race detector (go test -race) on a given build and
+// version of liblz4. input defaults to a compressible sample if empty.
+func StressConcurrentUse(goroutines, rounds int, input []byte) error {
+	if len(input) == 0 {
+		input = bytes.Repeat([]byte("concurrent use of the one-shot functions "), 4000)
+	}
+	want := make([][]byte, len(stressCases))
+	for i, c := range stressCases {
+		out, err := c.run(input)
+		if err != nil {
+			return fmt.Errorf("%s: %w", c.name, err)
+		}
+		want[i] = out
+	}
+
+	errs := make(chan error, goroutines)
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for round := 0; round < rounds; round++ {
+				// goroutines start from different functions, so that
+				// different functions run at the same time
+				for k := range stressCases {
+					i := (g + k) % len(stressCases)
+					c := stressCases[i]
+					out, err := c.run(input)
+					if err != nil {
+						errs <- fmt.Errorf("%s: %w", c.name, err)
+						return
+					}
+					if !bytes.Equal(out, want[i]) {
+						errs <- fmt.Errorf("%s: result differs when called concurrently", c.name)
+						return
+					}
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	return <-errs
+}
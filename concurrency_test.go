@@ -0,0 +1,18 @@
+package lz4
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestStressConcurrentUse(t *testing.T) {
+	rounds := 20
+	if testing.Short() {
+		rounds = 2
+	}
+	failOnError(t, "Failed with the default input", StressConcurrentUse(8, rounds, nil))
+
+	input, err := ioutil.ReadFile(sampleFilePath)
+	failOnError(t, "Failed reading sample", err)
+	failOnError(t, "Failed with the sample", StressConcurrentUse(4, 2, input))
+}
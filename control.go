@@ -0,0 +1,148 @@
+package lz4
+
+// control.go contains the control records that can be interleaved with
+// compressed blocks in a block stream. A control record starts with a 4-byte
+// little endian header like a block, with the high bit set. Since compressed
+// blocks are always smaller than 2 GiB, streams without control records are
+// unchanged. Bits 24 to 30 of the header hold the record type and bits 0 to 23
+// the length of the payload that follows.
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const (
+	controlFlag       = 1 << 31
+	controlTypeShift  = 24
+	controlTypeMask   = 0x7f
+	controlLengthMask = 1<<controlTypeShift - 1
+
+	// maxControlLength bounds the payload accepted by readers, so a
+	// corrupted header cannot cause a huge allocation.
+	maxControlLength = 64 * 1024
+)
+
+// Control record types. Readers skip records of unknown types.
+const (
+	// recordSync resets the compression history. Its payload is syncMagic
+	// followed by the uncompressed offset of the next block as a little
+	// endian uint64.
+	recordSync = 1
+
+	// recordMetadata holds a BlockMetadata for the next block. Its payload
+	// is the flags followed by the data.
+	recordMetadata = 2
+
+	// recordTrailer holds the length and XXH64 of the uncompressed data
+	// since the previous trailer or the start of the stream.
+	recordTrailer = 3
+
+	// recordBlockChecksum holds the checksum of the uncompressed data of the
+	// next block.
+	recordBlockChecksum = 4
+
+	// recordPadding fills space, for example up to a chunk boundary. Its
+	// payload is ignored.
+	recordPadding = 5
+
+	// recordDictionary resets the compression history to a dictionary of a
+	// DictionaryStore. Its payload is the dictionary ID as a little endian
+	// uint32.
+	recordDictionary = 6
+
+	// recordHeaderWidth, defined in headerwidth.go, starts streams whose
+	// headers have another width.
+)
+
+var syncMagic = [8]byte{0x89, 'L', 'Z', '4', 'S', 'Y', 'N', 'C'}
+
+const syncRecordSize = blockHeaderSize + len(syncMagic) + 8
+
+func controlHeader(typ, length int) uint32 {
+	return controlFlag | uint32(typ)<<controlTypeShift | uint32(length)
+}
+
+func parseControlHeader(header uint32) (typ, length int) {
+	return int(header>>controlTypeShift) & controlTypeMask, int(header & controlLengthMask)
+}
+
+// appendSyncRecord appends a sync record for uncompressed offset offset to b.
+func appendSyncRecord(b []byte, offset int64) []byte {
+	var record [syncRecordSize]byte
+	binary.LittleEndian.PutUint32(record[:], controlHeader(recordSync, syncRecordSize-blockHeaderSize))
+	copy(record[blockHeaderSize:], syncMagic[:])
+	binary.LittleEndian.PutUint64(record[blockHeaderSize+len(syncMagic):], uint64(offset))
+	return append(b, record[:]...)
+}
+
+var (
+	errBadSync     = errors.New("malformed sync marker")
+	errBadMetadata = errors.New("malformed block metadata")
+)
+
+// parseSyncPayload returns the uncompressed offset stored in a sync payload.
+func parseSyncPayload(payload []byte) (int64, error) {
+	if len(payload) != syncRecordSize-blockHeaderSize || !bytes.Equal(payload[:len(syncMagic)], syncMagic[:]) {
+		return 0, errBadSync
+	}
+	return int64(binary.LittleEndian.Uint64(payload[len(syncMagic):])), nil
+}
+
+// SyncPoint describes a sync marker found in a block stream.
+type SyncPoint struct {
+	// Skipped is the number of bytes discarded before the marker.
+	Skipped int64
+	// UncompressedOffset is the offset in the uncompressed data of the data
+	// following the marker.
+	UncompressedOffset int64
+}
+
+// NextSyncPoint scans r for the next sync marker, as written by a Writer
+// created with WithSyncInterval. It returns the marker found and a reader
+// returning the stream from that marker on, which can be passed to
+// NewDecompressReader. If r ends before a marker is found, NextSyncPoint
+// returns io.EOF.
+func NextSyncPoint(r io.Reader) (SyncPoint, io.Reader, error) {
+	br := bufio.NewReader(r)
+	skipped, err := skipToSync(br, blockHeaderSize)
+	if err != nil {
+		return SyncPoint{Skipped: skipped}, nil, err
+	}
+	record, err := br.Peek(syncRecordSize)
+	if err != nil {
+		return SyncPoint{Skipped: skipped}, nil, err
+	}
+	offset, err := parseSyncPayload(record[blockHeaderSize:])
+	if err != nil {
+		return SyncPoint{Skipped: skipped}, nil, err
+	}
+	return SyncPoint{Skipped: skipped, UncompressedOffset: offset}, br, nil
+}
+
+// skipToSync discards data from br up to the next sync record, whose header
+// has the given width, and returns the number of bytes discarded.
+func skipToSync(br *bufio.Reader, width int) (int64, error) {
+	var header [maxHeaderWidth]byte
+	n := putHeader(header[:], controlHeader(recordSync, syncRecordSize-blockHeaderSize), width)
+	pattern := append(header[:n:n], syncMagic[:]...)
+
+	var skipped int64
+	for {
+		buf, err := br.Peek(br.Size())
+		if i := bytes.Index(buf, pattern); i >= 0 {
+			n, _ := br.Discard(i)
+			return skipped + int64(n), nil
+		}
+		if err != nil && err != bufio.ErrBufferFull {
+			n, _ := br.Discard(len(buf))
+			return skipped + int64(n), err
+		}
+		// keep the end of the buffer, which may hold the start of a marker
+		n, _ := br.Discard(len(buf) - len(pattern) + 1)
+		skipped += int64(n)
+	}
+}
@@ -0,0 +1,111 @@
+package lz4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func TestSyncPoints(t *testing.T) {
+	input, err := ioutil.ReadFile(sampleFilePath)
+	failOnError(t, "Failed reading sample", err)
+	for len(input) < 8*streamingBlockSize {
+		input = append(input, input...)
+	}
+
+	var compressed bytes.Buffer
+	w := NewWriter(&compressed, WithSyncInterval(2*streamingBlockSize))
+	_, err = w.Write(input)
+	failOnError(t, "Failed writing to compress object", err)
+	failOnError(t, "Failed closing writer", w.Close())
+
+	// the whole stream decodes as usual
+	r := NewDecompressReader(bytes.NewReader(compressed.Bytes()))
+	out, err := ioutil.ReadAll(r)
+	failOnError(t, "Failed decompressing", err)
+	failOnError(t, "Failed closing reader", r.Close())
+	if !bytes.Equal(out, input) {
+		t.Fatalf("Decompressed output != input (lengths: %d, %d)", len(out), len(input))
+	}
+
+	// resume from the middle of the stream, as after an interrupted download
+	resumeAt := int64(compressed.Len() / 2)
+	sp, rest, err := NextSyncPoint(bytes.NewReader(compressed.Bytes()[resumeAt:]))
+	failOnError(t, "Failed finding sync point", err)
+	if sp.UncompressedOffset == 0 || sp.UncompressedOffset%(2*streamingBlockSize) != 0 {
+		t.Fatalf("unexpected sync point offset %d", sp.UncompressedOffset)
+	}
+	r = NewDecompressReader(rest)
+	out, err = ioutil.ReadAll(r)
+	failOnError(t, "Failed decompressing", err)
+	failOnError(t, "Failed closing reader", r.Close())
+	if !bytes.Equal(out, input[sp.UncompressedOffset:]) {
+		t.Fatalf("Decompressed output after sync point %d does not match input", sp.UncompressedOffset)
+	}
+}
+
+func TestNextSyncPointEOF(t *testing.T) {
+	var compressed bytes.Buffer
+	w := NewWriter(&compressed)
+	_, err := w.Write(plaintext0)
+	failOnError(t, "Failed writing to compress object", err)
+	failOnError(t, "Failed closing writer", w.Close())
+
+	sp, _, err := NextSyncPoint(&compressed)
+	if err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+	if sp.Skipped == 0 {
+		t.Fatalf("expected skipped bytes")
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	input, err := ioutil.ReadFile(sampleFilePath)
+	failOnError(t, "Failed reading sample", err)
+	for len(input) < 8*streamingBlockSize {
+		input = append(input, input...)
+	}
+	input = input[:8*streamingBlockSize]
+
+	var compressed bytes.Buffer
+	w := NewWriter(&compressed, WithSyncInterval(2*streamingBlockSize))
+	_, err = w.Write(input)
+	failOnError(t, "Failed writing to compress object", err)
+	failOnError(t, "Failed closing writer", w.Close())
+
+	// corrupt the header of the second block
+	data := compressed.Bytes()
+	secondBlock := blockHeaderSize + int(binary.LittleEndian.Uint32(data))
+	binary.LittleEndian.PutUint32(data[secondBlock:], 0x7fffffff)
+
+	var skipped []SkippedRange
+	r := NewDecompressReader(bytes.NewReader(data), WithRecovery(func(sr SkippedRange) {
+		skipped = append(skipped, sr)
+	}))
+	out, err := ioutil.ReadAll(r)
+	failOnError(t, "Failed decompressing", err)
+	failOnError(t, "Failed closing reader", r.Close())
+
+	if len(skipped) != 1 {
+		t.Fatalf("expected one skipped range, got %v", skipped)
+	}
+	sr := skipped[0]
+	if sr.Offset != int64(secondBlock) || sr.UncompressedOffset != streamingBlockSize || sr.Err == nil {
+		t.Fatalf("unexpected skipped range %+v", sr)
+	}
+	expected := append(append([]byte(nil), input[:streamingBlockSize]...), input[2*streamingBlockSize:]...)
+	if !bytes.Equal(out, expected) {
+		t.Fatalf("Decompressed output does not match input with the corrupt blocks removed")
+	}
+
+	// without recovery the corruption is an error
+	r = NewDecompressReader(bytes.NewReader(data))
+	_, err = ioutil.ReadAll(r)
+	if err == nil {
+		t.Fatalf("expected an error without recovery")
+	}
+	failOnError(t, "Failed closing reader", r.Close())
+}
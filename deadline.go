@@ -0,0 +1,88 @@
+package lz4
+
+import (
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+// readDeadliner is implemented by net.Conn and *os.File.
+type readDeadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
+// WithReadTimeout makes each call to Read or ReadBlock of a DecompressReader
+// or FrameReader set a read deadline of d from now on the underlying reader,
+// if it has a SetReadDeadline method, as net.Conn does, so that a stalled
+// peer cannot block a worker forever. Reading then fails with the timeout
+// error of the underlying reader, such as os.ErrDeadlineExceeded. Like any
+// error of the underlying reader, it is final, since part of a block may have
+// been consumed. ReadByte, ReadRune and ReadString set the deadline only when
+// they decode a new block. With WithReadAhead, the deadline is set instead before each
+// read or block decoded in the background, so the reader fails if the peer
+// stalls even while nothing is read from it. The option is ignored for
+// readers without deadlines.
+func WithReadTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.readTimeout = d
+	}
+}
+
+// readTimeout sets the read deadlines of the underlying reader of a
+// decompressing reader.
+type readTimeout struct {
+	r       readDeadliner
+	timeout time.Duration
+}
+
+func newReadTimeout(r io.Reader, o options) readTimeout {
+	d, _ := r.(readDeadliner)
+	return readTimeout{r: d, timeout: o.readTimeout}
+}
+
+// arm sets the deadline for a call to Read, if enabled.
+func (t readTimeout) arm() error {
+	if t.r == nil || t.timeout <= 0 {
+		return nil
+	}
+	err := t.r.SetReadDeadline(time.Now().Add(t.timeout))
+	if errors.Is(err, os.ErrNoDeadline) {
+		return nil
+	}
+	return err
+}
+
+func (t readTimeout) set(deadline time.Time) error {
+	if t.r == nil {
+		return os.ErrNoDeadline
+	}
+	return t.r.SetReadDeadline(deadline)
+}
+
+// nextArmed is the next function of the block engine of r. It arms the
+// deadline before decoding a block, unless the Read or ReadBlock call in
+// progress did, so that ReadByte, ReadRune and ReadString only arm it when
+// they need a new block rather than for every call.
+func (r *DecompressReader) nextArmed() ([]byte, error) {
+	if !r.armed {
+		if err := r.timeout.arm(); err != nil {
+			return nil, err
+		}
+	}
+	return r.nextBlock()
+}
+
+// SetReadDeadline sets the read deadline of the underlying reader, which must
+// have a SetReadDeadline method, as net.Conn does; it returns os.ErrNoDeadline
+// otherwise. Reading past the deadline fails for good, as with
+// WithReadTimeout, which overrides the deadline on each Read.
+func (r *DecompressReader) SetReadDeadline(t time.Time) error {
+	return r.timeout.set(t)
+}
+
+// SetReadDeadline sets the read deadline of the underlying reader, as
+// DecompressReader.SetReadDeadline does.
+func (r *FrameReader) SetReadDeadline(t time.Time) error {
+	return r.timeout.set(t)
+}
@@ -0,0 +1,102 @@
+package lz4
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestWithReadTimeout(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	_, err := w.Write(plaintext0)
+	failOnError(t, "Failed writing", err)
+	failOnError(t, "Failed closing", w.Close())
+	block := buf.Bytes()
+	frameStream := compressFrame(t, plaintext0)
+
+	for i, frame := range []bool{false, true, false, true} {
+		opts := []Option{WithReadTimeout(20 * time.Millisecond)}
+		if i >= 2 {
+			// the deadline is armed in the background
+			opts = append(opts, WithReadAhead())
+		}
+		stream := block
+		client, server := net.Pipe()
+		var r interface {
+			Read([]byte) (int, error)
+			Close() error
+		}
+		if frame {
+			stream = frameStream
+			r = NewFrameReader(client, opts...)
+		} else {
+			r = NewDecompressReader(client, opts...)
+		}
+		// the peer sends part of the stream and stalls
+		go server.Write(stream[:len(stream)/2])
+		start := time.Now()
+		// the data received may be returned before the timeout
+		var err error
+		for err == nil {
+			_, err = r.Read(make([]byte, len(plaintext0)))
+		}
+		if !errors.Is(err, os.ErrDeadlineExceeded) {
+			t.Errorf("case %d: got %v, want a timeout", i, err)
+		}
+		if d := time.Since(start); d > 5*time.Second {
+			t.Errorf("case %d: Read returned after %v", i, d)
+		}
+		r.Close()
+		client.Close()
+		server.Close()
+	}
+}
+
+func TestSetReadDeadline(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	r := NewDecompressReader(client).(*DecompressReader)
+	failOnError(t, "Failed setting the deadline", r.SetReadDeadline(time.Now().Add(10*time.Millisecond)))
+	if _, err := r.Read(make([]byte, 10)); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("got %v, want a timeout", err)
+	}
+	r.Close()
+
+	r = NewDecompressReader(bytes.NewReader(nil)).(*DecompressReader)
+	if err := r.SetReadDeadline(time.Now()); err != os.ErrNoDeadline {
+		t.Errorf("got %v for a reader without deadlines, want os.ErrNoDeadline", err)
+	}
+	r.Close()
+}
+
+// deadlineCounter counts the deadlines set on a reader.
+type deadlineCounter struct {
+	*bytes.Reader
+	n int
+}
+
+func (d *deadlineCounter) SetReadDeadline(time.Time) error {
+	d.n++
+	return nil
+}
+
+func TestReadByteDeadlines(t *testing.T) {
+	input := bytes.Repeat(plaintext0, 50000)
+	src := &deadlineCounter{Reader: bytes.NewReader(compressStream(t, input))}
+	r := NewDecompressReader(src, WithReadTimeout(time.Minute)).(*DecompressReader)
+	defer r.Close()
+	for i := range input {
+		c, err := r.ReadByte()
+		failOnError(t, "Failed reading byte", err)
+		if c != input[i] {
+			t.Fatalf("byte %d differs", i)
+		}
+	}
+	if blocks := len(input)/streamingBlockSize + 1; src.n > blocks+1 {
+		t.Errorf("set %d deadlines for %d blocks", src.n, blocks)
+	}
+}
@@ -0,0 +1,61 @@
+package lz4
+
+import (
+	"errors"
+	"io"
+)
+
+// Summary describes a block stream decompressed by DecompressTo.
+type Summary struct {
+	// BytesWritten is the number of uncompressed bytes written.
+	BytesWritten int64
+	// CompressedBytes is the number of bytes of the stream decoded,
+	// including headers and control records.
+	CompressedBytes int64
+	// Blocks is the number of blocks decoded.
+	Blocks int
+	// BlockChecksums is set if the blocks have checksums, which were
+	// verified.
+	BlockChecksums bool
+	// Trailer is set if the stream ends with a trailer, which marks the end
+	// of the stream and was verified against all the data written.
+	Trailer bool
+}
+
+// DecompressTo decompresses the block stream read from src into dst, verifying
+// the block checksums and trailer, if any, as Inspect does, and returns a
+// summary of the stream, such as to check a backup in one call. A stream
+// without a trailer is not an error, but Trailer is not set, since it may have
+// been truncated at a block boundary. On error, the summary describes the
+// stream up to the failing record, and BytesWritten the data written to dst.
+func DecompressTo(dst io.Writer, src io.Reader) (Summary, error) {
+	dr := newDecompressReader(src, explicitOptions(WithBlockChecksum(), WithTrailer()), nil)
+	defer dr.Close()
+
+	var s Summary
+	var err error
+	for {
+		var block []byte
+		block, err = dr.ReadBlock()
+		if err != nil {
+			break
+		}
+		s.Blocks++
+		var n int
+		n, err = dst.Write(block)
+		s.BytesWritten += int64(n)
+		if err != nil {
+			break
+		}
+	}
+	s.CompressedBytes = dr.CompressedBytesRead()
+	s.BlockChecksums = dr.sumSeen
+	switch {
+	case err == io.EOF:
+		s.Trailer = true
+		return s, nil
+	case errors.Is(err, ErrMissingTrailer):
+		return s, nil
+	}
+	return s, err
+}
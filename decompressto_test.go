@@ -0,0 +1,51 @@
+package lz4
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecompressTo(t *testing.T) {
+	input := bytes.Repeat([]byte("verify this backup "), 10000)
+	for _, tc := range []struct {
+		name string
+		opts []Option
+		want Summary
+	}{
+		{"plain", nil, Summary{Blocks: 3}},
+		{"checksums", []Option{WithBlockChecksum(), WithTrailer()}, Summary{Blocks: 3, BlockChecksums: true, Trailer: true}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewWriter(&buf, tc.opts...)
+			_, err := w.Write(input)
+			failOnError(t, "Failed writing", err)
+			failOnError(t, "Failed closing", w.Close())
+			stream := buf.Bytes()
+
+			var out bytes.Buffer
+			s, err := DecompressTo(&out, bytes.NewReader(stream))
+			failOnError(t, "Failed decompressing", err)
+			tc.want.BytesWritten = int64(len(input))
+			tc.want.CompressedBytes = int64(len(stream))
+			if s != tc.want {
+				t.Errorf("got summary %+v, want %+v", s, tc.want)
+			}
+			if !bytes.Equal(out.Bytes(), input) {
+				t.Error("decompressed output != input")
+			}
+
+			// a corrupted byte in the last block
+			corrupt := append([]byte(nil), stream...)
+			corrupt[len(corrupt)-30] ^= 0x55
+			out.Reset()
+			s, err = DecompressTo(&out, bytes.NewReader(corrupt))
+			if err == nil {
+				t.Fatal("no error for a corrupt stream")
+			}
+			if s.Trailer || s.BytesWritten != int64(out.Len()) {
+				t.Errorf("got summary %+v after writing %d bytes", s, out.Len())
+			}
+		})
+	}
+}
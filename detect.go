@@ -0,0 +1,109 @@
+package lz4
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// ErrUnknownFormat is returned by DetectFormat and NewAutoReader for data in
+// none of the supported formats.
+var ErrUnknownFormat = errors.New("unknown lz4 format")
+
+// detectSize is the amount of input NewAutoReader looks at, which holds the
+// first block of a stream written by Writer with the default block size.
+const detectSize = blockHeaderSize + boundedStreamingBlockSize
+
+// DetectFormat returns the format of the data starting with prefix. The
+// formats with a magic number are recognized from their first bytes. The
+// others start with a size, and are told apart by decoding their first block,
+// which needs prefix to hold it, or all the data for FormatBlockHdr. If the
+// first block of a block stream is not in prefix, the format is assumed from
+// the size alone.
+func DetectFormat(prefix []byte) (Format, error) {
+	if len(prefix) >= len(javaMagic) && string(prefix[:len(javaMagic)]) == javaMagic {
+		return FormatJavaBlock, nil
+	}
+	if len(prefix) < blockHeaderSize {
+		return 0, ErrUnknownFormat
+	}
+	header := binary.LittleEndian.Uint32(prefix)
+	switch {
+	case header == frameMagic, header&0xfffffff0 == 0x184D2A50:
+		return FormatFrame, nil
+	case header == legacyMagic:
+		return FormatLegacy, nil
+	case header&controlFlag != 0:
+		if t := header >> controlTypeShift & controlTypeMask; t >= recordSync && t <= recordDictionary &&
+			header&controlLengthMask <= maxControlLength {
+			return FormatCustomStream, nil
+		}
+	}
+	if isHadoopPrefix(prefix) {
+		return FormatHadoopBlock, nil
+	}
+	if header&controlFlag != 0 {
+		return 0, ErrUnknownFormat
+	}
+	size := int(header)
+	rest := prefix[blockHeaderSize:]
+	if size <= MaxCompressedBlockSize && size <= len(rest) {
+		if _, err := Uncompress(make([]byte, MaxBlockSize), rest[:size]); err == nil {
+			return FormatCustomStream, nil
+		}
+	}
+	if size <= len(rest)*maxBlockRatio {
+		if n, err := Uncompress(make([]byte, size), rest); err == nil && n == size {
+			return FormatBlockHdr, nil
+		}
+	}
+	if size > len(rest) && size <= MaxCompressedBlockSize {
+		return FormatCustomStream, nil
+	}
+	return 0, ErrUnknownFormat
+}
+
+// isHadoopPrefix reports whether prefix starts with a Hadoop chunk, whose first
+// block decodes if it is in prefix.
+func isHadoopPrefix(prefix []byte) bool {
+	if len(prefix) < 8 {
+		return false
+	}
+	size := int(binary.BigEndian.Uint32(prefix))
+	compressed := int(binary.BigEndian.Uint32(prefix[4:]))
+	if size == 0 || size > maxHadoopChunkSize || compressed == 0 || compressed > compressBound(size) ||
+		size > compressed*maxBlockRatio {
+		return false
+	}
+	if block := prefix[8:]; compressed <= len(block) {
+		n, err := Uncompress(make([]byte, size), block[:compressed])
+		return err == nil && n > 0
+	}
+	return true
+}
+
+// NewAutoReader returns a reader decompressing r in the format detected by
+// DetectFormat from its first bytes, along with that format. The FormatFrame
+// and FormatCustomStream readers are created with opts.
+func NewAutoReader(r io.Reader, opts ...Option) (io.ReadCloser, Format, error) {
+	br := bufio.NewReaderSize(r, detectSize)
+	prefix, err := br.Peek(detectSize)
+	if err != nil && err != io.EOF {
+		return nil, 0, err
+	}
+	f, err := DetectFormat(prefix)
+	if err != nil {
+		return nil, 0, err
+	}
+	var rc io.ReadCloser
+	switch f {
+	case FormatCustomStream:
+		rc = NewDecompressReader(br, opts...)
+	case FormatFrame:
+		rc = NewFrameReader(br, opts...)
+	default:
+		rc, err = newFormatReader(br, f)
+	}
+	return rc, f, err
+}
@@ -0,0 +1,66 @@
+package lz4
+
+// #cgo pkg-config: liblz4
+// #include <stdlib.h>
+// #include <lz4.h>
+import "C"
+
+import (
+	"unsafe"
+)
+
+// CompressWithPrevious compresses current into out, using previous as a
+// dictionary, and returns the number of bytes written to out. It suits
+// periodic snapshots of a state, where consecutive versions are nearly
+// identical: the unchanged parts of current are stored as references to
+// previous. Only the last 64 KiB of previous are used. len(out) should be at
+// least CompressBound(current). The result can only be decompressed with
+// UncompressWithPrevious and the same previous.
+func CompressWithPrevious(out, current, previous []byte) (int, error) {
+	return compressWithDict(out, current, previous)
+}
+
+// UncompressWithPrevious uncompresses in, as produced by CompressWithPrevious
+// with the same previous, into out and returns the number of bytes written to
+// out. len(out) should be the uncompressed size.
+func UncompressWithPrevious(out, in, previous []byte) (int, error) {
+	return uncompressWithDict(out, in, previous)
+}
+
+// compressWithDict compresses in into out, using dict as history, and returns
+// the compressed size. Only the last 64 KiB of dict are used.
+func compressWithDict(out, in, dict []byte) (int, error) {
+	if len(dict) > streamingBlockSize {
+		dict = dict[len(dict)-streamingBlockSize:]
+	}
+	stream := C.LZ4_createStream()
+	defer C.LZ4_freeStream(stream)
+	// the stream keeps a pointer to the dictionary, so it must live in C
+	// memory
+	var cdict unsafe.Pointer
+	if len(dict) > 0 {
+		cdict = C.malloc(C.size_t(len(dict)))
+		defer C.free(cdict)
+		copy(unsafe.Slice((*byte)(cdict), len(dict)), dict)
+	}
+	C.LZ4_loadDict(stream, (*C.char)(cdict), clen(dict))
+
+	n := int(C.LZ4_compress_fast_continue(stream, p(in), p(out), clen(in), clen(out), 1))
+	if n <= 0 {
+		return 0, newCError("LZ4_compress_fast_continue", n, "Insufficient space for compression")
+	}
+	return n, nil
+}
+
+// uncompressWithDict uncompresses in into out, using dict as the history it
+// was compressed with, and returns the uncompressed size.
+func uncompressWithDict(out, in, dict []byte) (int, error) {
+	if len(dict) > streamingBlockSize {
+		dict = dict[len(dict)-streamingBlockSize:]
+	}
+	n := int(C.LZ4_decompress_safe_usingDict(p(in), p(out), clen(in), clen(out), p(dict), clen(dict)))
+	if n < 0 {
+		return 0, newCError("LZ4_decompress_safe_usingDict", n, "Malformed compression stream")
+	}
+	return n, nil
+}
@@ -0,0 +1,49 @@
+package lz4
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestCompressWithPrevious(t *testing.T) {
+	previous, err := ioutil.ReadFile(sampleFilePath)
+	failOnError(t, "Failed reading sample", err)
+	if len(previous) > streamingBlockSize {
+		previous = previous[:streamingBlockSize]
+	}
+	current := append([]byte(nil), previous...)
+	copy(current[len(current)/2:], "a small change in the snapshot")
+
+	out := make([]byte, CompressBound(current))
+	n, err := CompressWithPrevious(out, current, previous)
+	failOnError(t, "Failed compressing", err)
+	plain, err := Compress(make([]byte, CompressBound(current)), current)
+	failOnError(t, "Failed compressing", err)
+	if n*10 > plain {
+		t.Errorf("delta is %d bytes, plain compression %d bytes", n, plain)
+	}
+
+	decompressed := make([]byte, len(current))
+	m, err := UncompressWithPrevious(decompressed, out[:n], previous)
+	failOnError(t, "Failed decompressing", err)
+	if !bytes.Equal(decompressed[:m], current) {
+		t.Fatalf("Decompressed output != input")
+	}
+
+	if _, err := UncompressWithPrevious(decompressed, out[:n], nil); err == nil && bytes.Equal(decompressed, current) {
+		t.Fatalf("decompressed without the previous snapshot")
+	}
+}
+
+func TestCompressWithPreviousEmpty(t *testing.T) {
+	out := make([]byte, CompressBound(plaintext0))
+	n, err := CompressWithPrevious(out, plaintext0, nil)
+	failOnError(t, "Failed compressing", err)
+	decompressed := make([]byte, len(plaintext0))
+	m, err := UncompressWithPrevious(decompressed, out[:n], nil)
+	failOnError(t, "Failed decompressing", err)
+	if !bytes.Equal(decompressed[:m], plaintext0) {
+		t.Fatalf("Decompressed output != input")
+	}
+}
@@ -0,0 +1,141 @@
+package lz4
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrUnknownDictionary is returned by a DecompressReader or FrameReader for a stream
+// compressed with a dictionary that is not registered in its DictionaryStore.
+var ErrUnknownDictionary = errors.New("unknown dictionary")
+
+// DictionaryStore holds versions of a dictionary keyed by ID, for long-running
+// services that replace their dictionary over time. Writers created with
+// WithDictionaryStore compress with the current dictionary and record its ID
+// in the stream, and readers look the ID up, so streams written with an older
+// dictionary can still be read as long as it stays registered. A
+// DictionaryStore is safe for concurrent use.
+type DictionaryStore struct {
+	mu         sync.RWMutex
+	dicts      map[uint32][]byte
+	current    uint32
+	hasCurrent bool
+}
+
+// NewDictionaryStore returns an empty DictionaryStore.
+func NewDictionaryStore() *DictionaryStore {
+	return &DictionaryStore{dicts: make(map[uint32][]byte)}
+}
+
+// Register adds dict to s as version id. Only the last 64 KiB of dict are
+// kept, since lz4 never references anything further back. A version cannot be
+// changed once registered, since streams may refer to it: registering an ID
+// again with different content is an error.
+func (s *DictionaryStore) Register(id uint32, dict []byte) error {
+	if len(dict) > streamingBlockSize {
+		dict = dict[len(dict)-streamingBlockSize:]
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if old, ok := s.dicts[id]; ok {
+		if string(old) != string(dict) {
+			return fmt.Errorf("dictionary %d already registered", id)
+		}
+		return nil
+	}
+	s.dicts[id] = append([]byte(nil), dict...)
+	return nil
+}
+
+// SetCurrent makes the registered version id the dictionary used by Writers
+// created from now on. Existing Writers keep the dictionary they started with.
+func (s *DictionaryStore) SetCurrent(id uint32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.dicts[id]; !ok {
+		return fmt.Errorf("%w %d", ErrUnknownDictionary, id)
+	}
+	s.current = id
+	s.hasCurrent = true
+	return nil
+}
+
+// Current returns the ID and content of the current dictionary. ok is false if
+// SetCurrent has not been called, or the current version was removed.
+func (s *DictionaryStore) Current() (id uint32, dict []byte, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if !s.hasCurrent {
+		return 0, nil, false
+	}
+	return s.current, s.dicts[s.current], true
+}
+
+// Lookup returns the content of version id.
+func (s *DictionaryStore) Lookup(id uint32) ([]byte, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	dict, ok := s.dicts[id]
+	return dict, ok
+}
+
+// Remove retires version id, once no stream needing it remains to be read.
+// Writers no longer use a dictionary if id was the current one.
+func (s *DictionaryStore) Remove(id uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.dicts, id)
+	if s.hasCurrent && s.current == id {
+		s.hasCurrent = false
+	}
+}
+
+// WithDictionaryStore makes a Writer compress with the current dictionary of
+// s, if any, and a DecompressReader decompress with the dictionaries of s. The
+// Writer records the ID of its dictionary at the start of the stream and after
+// each sync marker, and a DecompressReader returns an error matching
+// ErrUnknownDictionary if the ID is not registered in s, or if it was created
+// without this option. FrameWriter and FrameReader use the dictionary ID of
+// the frame header instead.
+func WithDictionaryStore(s *DictionaryStore) Option {
+	return func(o *options) {
+		o.dictionaries = s
+	}
+}
+
+const dictionaryPayloadSize = 4
+
+// writeDictionary writes a dictionary record for the dictionary of w and
+// loads it as the history of the next block.
+func (w *Writer) writeDictionary() error {
+	var record [blockHeaderSize + dictionaryPayloadSize]byte
+	binary.LittleEndian.PutUint32(record[:], controlHeader(recordDictionary, dictionaryPayloadSize))
+	binary.LittleEndian.PutUint32(record[blockHeaderSize:], w.dictID)
+	if err := w.writeRecord(record[:]); err != nil {
+		return err
+	}
+	w.compressedWritten += int64(len(record))
+	w.loadDict(w.dict)
+	w.dictPending = false
+	return nil
+}
+
+// readDictionary applies the payload of a dictionary record.
+func (r *DecompressReader) readDictionary(payload []byte) error {
+	if len(payload) != dictionaryPayloadSize {
+		return &corruptionError{errors.New("malformed dictionary record")}
+	}
+	id := binary.LittleEndian.Uint32(payload)
+	var dict []byte
+	ok := false
+	if r.opts.dictionaries != nil {
+		dict, ok = r.opts.dictionaries.Lookup(id)
+	}
+	if !ok {
+		return fmt.Errorf("%w %d", ErrUnknownDictionary, id)
+	}
+	r.dictID = id
+	return r.loadHistory(dict)
+}
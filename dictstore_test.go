@@ -0,0 +1,73 @@
+package lz4
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func TestDictionaryStore(t *testing.T) {
+	store := NewDictionaryStore()
+	v1 := bytes.Repeat([]byte(`{"user":"alice","action":"login","status":"ok"}`), 20)
+	v2 := bytes.Repeat([]byte(`{"host":"web-1","level":"info","msg":"request"}`), 20)
+	failOnError(t, "Failed registering", store.Register(1, v1))
+	failOnError(t, "Failed registering", store.Register(2, v2))
+	if err := store.Register(1, v2); err == nil {
+		t.Fatal("version 1 replaced")
+	}
+	if err := store.SetCurrent(3); !errors.Is(err, ErrUnknownDictionary) {
+		t.Fatalf("SetCurrent of an unknown version: got %v", err)
+	}
+
+	compress := func(data []byte, opts ...Option) []byte {
+		var buf bytes.Buffer
+		w := NewWriter(&buf, opts...)
+		_, err := w.Write(data)
+		failOnError(t, "Failed writing", err)
+		failOnError(t, "Failed closing", w.Close())
+		return buf.Bytes()
+	}
+	msg := []byte(`{"user":"bob","action":"login","status":"ok"}`)
+
+	failOnError(t, "Failed setting version", store.SetCurrent(1))
+	old := compress(msg, WithDictionaryStore(store))
+	plain := compress(msg)
+	if len(old) >= len(plain) {
+		t.Errorf("dictionary did not help: %d bytes, %d without", len(old), len(plain))
+	}
+	// writers move to the new version, readers still decode the old one
+	failOnError(t, "Failed setting version", store.SetCurrent(2))
+	logs := bytes.Repeat([]byte(`{"host":"web-2","level":"warn","msg":"request"}`), 3000)
+	recent := compress(logs, WithDictionaryStore(store), WithSyncInterval(64*1024))
+
+	for _, tc := range []struct {
+		compressed, want []byte
+		id               uint32
+	}{{old, msg, 1}, {recent, logs, 2}} {
+		r := NewDecompressReader(bytes.NewReader(tc.compressed), WithDictionaryStore(store)).(*DecompressReader)
+		got, err := ioutil.ReadAll(r)
+		failOnError(t, "Failed reading", err)
+		if !bytes.Equal(got, tc.want) {
+			t.Fatalf("dictionary %d: decompressed output != input", tc.id)
+		}
+		if id := r.Info().DictID; id != tc.id {
+			t.Errorf("got DictID %d, want %d", id, tc.id)
+		}
+		r.Close()
+	}
+
+	// a version that was retired, or a reader without the store
+	store.Remove(1)
+	if _, _, ok := store.Current(); !ok {
+		t.Fatal("removing an old version cleared the current one")
+	}
+	for _, opts := range [][]Option{{WithDictionaryStore(store)}, nil} {
+		r := NewDecompressReader(bytes.NewReader(old), opts...)
+		if _, err := io.Copy(ioutil.Discard, r); !errors.Is(err, ErrUnknownDictionary) {
+			t.Errorf("got %v, want ErrUnknownDictionary", err)
+		}
+		r.Close()
+	}
+}
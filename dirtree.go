@@ -0,0 +1,270 @@
+package lz4
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// CompressDir writes the tree rooted at dir to dst as a tar archive compressed
+// into a block stream, which NewDecompressReader or ExtractDir can read. opts
+// configure the compression; they are not suitable for the options adding
+// records covering the whole stream, such as WithTrailer.
+//
+// Files are compressed in parallel: each entry of the archive is compressed
+// into an independent block stream, held in memory until it is its turn to be
+// written, and the streams are concatenated, which is itself a valid block
+// stream.
+func CompressDir(dst io.Writer, dir string, opts ...Option) error {
+	if err := checkDefaultWidth(explicitOptions(opts...)); err != nil {
+		return err
+	}
+	type dirEntry struct {
+		path, name string
+		d          fs.DirEntry
+	}
+	entries := make(chan dirEntry)
+	stop := make(chan struct{})
+	walkErr := make(chan error, 1)
+	go func() {
+		defer close(entries)
+		walkErr <- filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			name, err := filepath.Rel(dir, p)
+			if err != nil {
+				return err
+			}
+			if name == "." {
+				return nil
+			}
+			select {
+			case entries <- dirEntry{p, filepath.ToSlash(name), d}:
+				return nil
+			case <-stop:
+				return errors.New("aborted")
+			}
+		})
+	}()
+
+	next := func() (func() chunkResult, error) {
+		e, ok := <-entries
+		if !ok {
+			return nil, nil
+		}
+		return func() chunkResult {
+			data, err := compressDirEntry(e.path, e.name, e.d, opts)
+			return chunkResult{data: data, err: err}
+		}, nil
+	}
+	err := runOrdered(runtime.GOMAXPROCS(0), next, func(res chunkResult) error {
+		_, err := dst.Write(res.data)
+		return err
+	})
+	close(stop)
+	if werr := <-walkErr; err == nil {
+		err = werr
+	}
+	if err != nil {
+		return err
+	}
+
+	// the end of the tar archive
+	var end bytes.Buffer
+	if err := tar.NewWriter(&end).Close(); err != nil {
+		return err
+	}
+	return compressDirPiece(dst, end.Bytes(), nil, opts)
+}
+
+// compressDirEntry returns the tar entry for the file p, compressed into an
+// independent block stream.
+func compressDirEntry(p, name string, d fs.DirEntry, opts []Option) ([]byte, error) {
+	fi, err := d.Info()
+	if err != nil {
+		return nil, err
+	}
+	var link string
+	if fi.Mode()&fs.ModeSymlink != 0 {
+		if link, err = os.Readlink(p); err != nil {
+			return nil, err
+		}
+	}
+	hdr, err := tar.FileInfoHeader(fi, link)
+	if err != nil {
+		return nil, err
+	}
+	hdr.Name = name
+	if fi.IsDir() {
+		hdr.Name += "/"
+	}
+
+	var entry bytes.Buffer
+	tw := tar.NewWriter(&entry)
+	if err := tw.WriteHeader(hdr); err != nil {
+		return nil, err
+	}
+	var content *io.LimitedReader
+	if fi.Mode().IsRegular() {
+		f, err := os.Open(p)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		content = &io.LimitedReader{R: f, N: hdr.Size}
+	}
+
+	var out bytes.Buffer
+	if err := compressDirPiece(&out, entry.Bytes(), content, opts); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// compressDirPiece compresses header followed by content, padded to the tar
+// block size, into an independent block stream written to dst.
+func compressDirPiece(dst io.Writer, header []byte, content *io.LimitedReader, opts []Option) error {
+	w := newWriter(dst, explicitOptions(opts...))
+	_, err := w.Write(header)
+	if err == nil && content != nil {
+		var n int64
+		n, err = io.Copy(w, content)
+		if err == nil && content.N != 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		if err == nil && n%512 != 0 {
+			_, err = w.Write(make([]byte, 512-n%512))
+		}
+	}
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// ExtractDir extracts the compressed tar archive read from src, as written by
+// CompressDir, into dir, creating it if needed. Entries that would be
+// extracted outside of dir are rejected: names that are absolute or escape
+// dir, symlinks whose target is absolute or escapes dir, and entries under a
+// symlink, which are never followed.
+func ExtractDir(src io.Reader, dir string) error {
+	r := newDecompressReader(src, explicitOptions(), nil)
+	defer r.Close()
+	tr := tar.NewReader(r)
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	type dirTime struct {
+		path string
+		hdr  *tar.Header
+	}
+	var dirs []dirTime
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		name := path.Clean(hdr.Name)
+		if path.IsAbs(name) || escapesDir(name) {
+			return fmt.Errorf("invalid entry name %q", hdr.Name)
+		}
+		if err := checkNoSymlink(dir, name); err != nil {
+			return err
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		mode := fs.FileMode(hdr.Mode).Perm()
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+			// the permissions and modification time of directories are
+			// set last, as extracting their content changes them
+			dirs = append(dirs, dirTime{target, hdr})
+			continue
+		case tar.TypeReg:
+			if err := extractFile(target, mode, tr); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			link := hdr.Linkname
+			if path.IsAbs(link) || filepath.IsAbs(link) || escapesDir(path.Join(path.Dir(name), link)) {
+				return fmt.Errorf("invalid symlink target %q for %q", link, hdr.Name)
+			}
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+			continue
+		default:
+			return fmt.Errorf("unsupported entry type %q for %q", hdr.Typeflag, hdr.Name)
+		}
+		if err := os.Chtimes(target, hdr.ModTime, hdr.ModTime); err != nil {
+			return err
+		}
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		d := dirs[i]
+		if err := os.Chmod(d.path, fs.FileMode(d.hdr.Mode).Perm()); err != nil {
+			return err
+		}
+		if err := os.Chtimes(d.path, d.hdr.ModTime, d.hdr.ModTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// escapesDir reports whether the clean relative path name is outside of the
+// directory it is relative to.
+func escapesDir(name string) bool {
+	return name == ".." || strings.HasPrefix(name, "../")
+}
+
+// checkNoSymlink returns an error if an existing component of the path name,
+// relative to dir, is a symlink, which extracting name would follow.
+func checkNoSymlink(dir, name string) error {
+	p := dir
+	for _, c := range strings.Split(name, "/") {
+		p = filepath.Join(p, c)
+		fi, err := os.Lstat(p)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if fi.Mode()&fs.ModeSymlink != 0 {
+			return fmt.Errorf("entry %q would be extracted through a symlink", name)
+		}
+	}
+	return nil
+}
+
+func extractFile(target string, mode fs.FileMode, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,132 @@
+package lz4
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompressDirRoundTrip(t *testing.T) {
+	src := t.TempDir()
+	files := map[string]string{
+		"a.txt":         "alpha",
+		"sub/b.txt":     strings.Repeat("bravo ", 100000),
+		"sub/deep/c.md": "",
+	}
+	mtime := time.Unix(1500000000, 0)
+	for name, content := range files {
+		p := filepath.Join(src, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o640); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(p, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("a.txt", filepath.Join(src, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := CompressDir(&buf, src); err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(t.TempDir(), "out")
+	if err := ExtractDir(bytes.NewReader(buf.Bytes()), dst); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, content := range files {
+		p := filepath.Join(dst, filepath.FromSlash(name))
+		got, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != content {
+			t.Errorf("%s: content mismatch", name)
+		}
+		fi, err := os.Stat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode().Perm() != 0o640 || !fi.ModTime().Equal(mtime) {
+			t.Errorf("%s: got mode %v and mtime %v", name, fi.Mode(), fi.ModTime())
+		}
+	}
+	if link, err := os.Readlink(filepath.Join(dst, "link")); err != nil || link != "a.txt" {
+		t.Errorf("got link %q, %v", link, err)
+	}
+}
+
+func TestExtractDirRejectsEscapingNames(t *testing.T) {
+	for _, name := range []string{"../evil", "/abs", "a/../../evil"} {
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		tw := tar.NewWriter(w)
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: 1, Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte("x"))
+		tw.Close()
+		w.Close()
+
+		dst := t.TempDir()
+		if err := ExtractDir(&buf, filepath.Join(dst, "out")); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+		if _, err := os.Stat(filepath.Join(dst, "evil")); err == nil {
+			t.Errorf("%s: extracted outside of the directory", name)
+		}
+	}
+}
+
+func TestExtractDirRejectsSymlinkEscapes(t *testing.T) {
+	type entry struct {
+		name, link string
+	}
+	for _, entries := range [][]entry{
+		{{"a", "/outside"}, {"a/pwned", ""}},
+		{{"a", "../outside"}, {"a/pwned", ""}},
+		{{"sub/", ""}, {"sub/l", ".."}, {"sub/m", "l/../.."}, {"sub/m/pwned", ""}},
+		{{"a", "."}, {"a/pwned", ""}},
+	} {
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		tw := tar.NewWriter(w)
+		for _, e := range entries {
+			hdr := &tar.Header{Name: e.name, Mode: 0o644, Typeflag: tar.TypeReg, Size: 1}
+			switch {
+			case e.link != "":
+				hdr.Typeflag, hdr.Linkname, hdr.Size = tar.TypeSymlink, e.link, 0
+			case strings.HasSuffix(e.name, "/"):
+				hdr.Typeflag, hdr.Mode, hdr.Size = tar.TypeDir, 0o755, 0
+			}
+			if err := tw.WriteHeader(hdr); err != nil {
+				t.Fatal(err)
+			}
+			if hdr.Size > 0 {
+				tw.Write([]byte("x"))
+			}
+		}
+		tw.Close()
+		w.Close()
+
+		root := t.TempDir()
+		dst := filepath.Join(root, "out", "dir")
+		if err := ExtractDir(&buf, dst); err == nil {
+			t.Errorf("%v: no error", entries)
+		}
+		for _, p := range []string{filepath.Join(root, "out", "pwned"), filepath.Join(root, "pwned")} {
+			if _, err := os.Stat(p); err == nil {
+				t.Errorf("%v: extracted outside of the directory", entries)
+			}
+		}
+	}
+}
@@ -1,5 +1,62 @@
 // Package lz4 implements compression using lz4.c and lz4hc.c
 //
+// All the streaming readers of the package, DecompressReader, CompressReader,
+// FrameReader and SeekReader, follow the same contract for Read:
+//
+//   - Read returns data from at most one block, so it may return less than
+//     len(dst) before the end of the stream, unless WithFillBuffer is given.
+//   - Read never returns data along with an error: an error met after some
+//     data was read is returned by the next call. WithEarlyEOF makes io.EOF
+//     an exception, returned along with the last data.
+//   - io.EOF is returned only when the stream ends cleanly, at the end of a
+//     block or frame, and io.ErrUnexpectedEOF when it ends in the middle of
+//     one. Other errors of the underlying reader are returned unchanged, or
+//     wrapped so that errors.Is matches them.
+//   - Errors are final, except io.EOF: the next Read reads the underlying
+//     reader again, so a stream that is still being written can be followed.
+//
+// # Concurrency
+//
+// The one-shot functions working on byte slices, such as Compress,
+// CompressFast, CompressHC, Uncompress and their Hdr, Hdr16 and Result
+// variants, as well as CompressBytesToStream, DecompressAll and the untrusted
+// decoders, keep no state between calls: each call uses its own lz4 state. They
+// are safe for concurrent use without locking, including on the same input,
+// which they only read, as long as concurrent calls do not share an output
+// slice. StressConcurrentUse checks this contract, for example under the race
+// detector. The streaming types are not safe for concurrent use unless their
+// documentation says so, as for SharedWriter, AsyncWriter, CompressorPool and
+// DictionaryStore.
+//
+// # Block stream format
+//
+// The block stream written by Writer and CompressReader is a sequence of
+// records, each starting with a header of BlockHeaderSize bytes holding a
+// little endian uint32:
+//
+//   - If the high bit of the header is clear, the header is the size of the
+//     compressed block that follows, at most MaxCompressedBlockSize. The
+//     block is in the LZ4 block format, decodes to at most MaxBlockSize
+//     bytes, and may reference the uncompressed data of the previous block,
+//     up to StreamBlockSize bytes back, as with
+//     LZ4_decompress_safe_continue.
+//   - If the high bit is set, the record is a control record: bits 24 to 30
+//     of the header hold its type, and bits 0 to 23 the length of the
+//     payload that follows, at most 64 KiB. Readers skip control records of
+//     unknown types. Type 1 is a sync marker, after which blocks do not
+//     reference the data before it; type 2 holds block metadata; type 3 a
+//     trailer; type 4 a block checksum; type 5 padding; and type 6 the ID of
+//     a dictionary of a DictionaryStore, which replaces the history.
+//
+// A Writer created with WithHeaderWidth starts the stream with a control
+// record of type 7, whose 1-byte payload is the width of the headers of the
+// following records, 3 or 8 bytes instead of BlockHeaderSize. Their high bit
+// is the control flag; HeaderWidth3 and HeaderWidth8 describe their layout.
+//
+// A stream ends after any record, and the concatenation of streams is a
+// stream. Streams that use none of the options adding control records are
+// compatible with the original implementation of the package.
+//
 // Copyright (c) 2016 Datadog
 // Copyright (c) 2013 CloudFlare, Inc.
 package lz4
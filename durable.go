@@ -0,0 +1,44 @@
+package lz4
+
+import "fmt"
+
+// WriteError is returned by a Writer when the underlying writer fails. The
+// first CompressedOffset bytes written by the Writer hold complete blocks,
+// which decode to the first Offset bytes given to it; the bytes written after
+// them may hold part of a block and must be discarded. To resume after a
+// transient failure, truncate the output there, reopen it with NewAppendWriter,
+// and write the input again from Offset. The offsets are counted like
+// CompressedBytesWritten and UncompressedBytesWritten.
+//
+// The error is final: every later write returns it, and Close returns it
+// after freeing the resources of the Writer, without writing a trailer.
+type WriteError struct {
+	Offset           int64
+	CompressedOffset int64
+	Err              error
+}
+
+func (e *WriteError) Error() string {
+	return fmt.Sprintf("lz4: write failed after %d uncompressed bytes: %v", e.Offset, e.Err)
+}
+
+// Unwrap returns the error of the underlying writer.
+func (e *WriteError) Unwrap() error {
+	return e.Err
+}
+
+// UncompressedBytesWritten returns the number of uncompressed bytes whose
+// blocks were completely written to the underlying io.Writer.
+func (w *Writer) UncompressedBytesWritten() int64 {
+	return w.uncompressedWritten
+}
+
+// writeUnderlying writes b, part of the records starting at w.recordStart, to
+// the underlying writer. An error fails w.
+func (w *Writer) writeUnderlying(b []byte) error {
+	if _, err := w.underlyingWriter.Write(b); err != nil {
+		w.err = &WriteError{Offset: w.uncompressedWritten, CompressedOffset: w.recordStart, Err: err}
+		return w.err
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package lz4
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// limitedWriter accepts up to n bytes, writing part of the call that
+// exceeds them before failing.
+type limitedWriter struct {
+	w io.Writer
+	n int
+}
+
+var errLimitedWriter = errors.New("limit reached")
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	if len(p) > l.n {
+		n, _ := l.w.Write(p[:l.n])
+		l.n = 0
+		return n, errLimitedWriter
+	}
+	l.n -= len(p)
+	return l.w.Write(p)
+}
+
+func TestWriteError(t *testing.T) {
+	input, err := ioutil.ReadFile(sampleFilePath)
+	failOnError(t, "Failed reading sample", err)
+	for len(input) < 4*streamingBlockSize {
+		input = append(input, input...)
+	}
+	path := filepath.Join(t.TempDir(), "resume.lz4")
+	f, err := os.Create(path)
+	failOnError(t, "Failed creating file", err)
+
+	// fail in the middle of the third block
+	probe := NewWriter(ioutil.Discard, WithBlockChecksum())
+	_, err = probe.Write(input[:2*streamingBlockSize])
+	failOnError(t, "Failed writing", err)
+	complete := probe.CompressedBytesWritten()
+	limit := int(complete) + 100
+	failOnError(t, "Failed closing", probe.Close())
+
+	w := NewWriter(&limitedWriter{w: f, n: limit}, WithBlockChecksum())
+	n, err := w.Write(input)
+	var werr *WriteError
+	if !errors.As(err, &werr) || !errors.Is(err, errLimitedWriter) {
+		t.Fatalf("got %v, want a WriteError", err)
+	}
+	if n != 2*streamingBlockSize || werr.Offset != int64(n) || w.UncompressedBytesWritten() != int64(n) ||
+		werr.CompressedOffset != complete {
+		t.Fatalf("got %d bytes written and %+v", n, werr)
+	}
+	if _, err := w.Write(input[n:]); err != werr {
+		t.Fatalf("got %v writing after the failure, want the same error", err)
+	}
+	if err := w.Close(); err != werr {
+		t.Fatalf("got %v closing, want the same error", err)
+	}
+
+	// resume from the offsets of the error
+	failOnError(t, "Failed truncating", f.Truncate(werr.CompressedOffset))
+	w, err = NewAppendWriter(f, true, WithBlockChecksum())
+	failOnError(t, "Failed opening for append", err)
+	_, err = w.Write(input[werr.Offset:])
+	failOnError(t, "Failed writing", err)
+	failOnError(t, "Failed closing", w.Close())
+	failOnError(t, "Failed closing file", f.Close())
+
+	compressed, err := ioutil.ReadFile(path)
+	failOnError(t, "Failed reading file", err)
+	out, err := ioutil.ReadAll(NewDecompressReader(bytes.NewReader(compressed), WithBlockChecksum()))
+	failOnError(t, "Failed decompressing", err)
+	if !bytes.Equal(out, input) {
+		t.Fatalf("decompressed %d bytes, want %d", len(out), len(input))
+	}
+}
@@ -0,0 +1,121 @@
+package lz4
+
+import "io"
+
+// blockEngine implements Read for the readers producing their output one block
+// at a time: the compressed blocks of a CompressReader, or the decompressed
+// blocks of a DecompressReader, so they share the same buffering and error
+// semantics, described in the package documentation.
+type blockEngine struct {
+	// next produces the next block of output, which must stay valid until
+	// the following call
+	next func() ([]byte, error)
+	// pending is the unread rest of the last block
+	pending []byte
+	// err is the error to return by the next call: an error met after
+	// some data was returned, or any error but io.EOF, which is final
+	err error
+	// fill makes read fill dst rather than return after the first block
+	fill bool
+	// earlyEOF makes read return io.EOF along with the last data
+	earlyEOF bool
+}
+
+// read copies the pending output to dst, producing more blocks if there is
+// none, or if fill is set and dst is not full yet. An error met after some
+// data was copied is returned by the next call. Errors are final, except
+// io.EOF: the next call tries to produce a block again, so a growing stream
+// can be followed.
+func (e *blockEngine) read(dst []byte) (int, error) {
+	if len(dst) == 0 {
+		return 0, nil
+	}
+	n := copy(dst, e.pending)
+	e.pending = e.pending[n:]
+	if n > 0 && (!e.fill || n == len(dst)) {
+		return e.done(n)
+	}
+	for {
+		block, err := e.nextBlock()
+		if err != nil {
+			if n > 0 {
+				if err == io.EOF && e.earlyEOF {
+					return n, io.EOF
+				}
+				e.err = err
+				return n, nil
+			}
+			return 0, err
+		}
+		m := copy(dst[n:], block)
+		e.pending = block[m:]
+		n += m
+		// empty blocks are skipped, so that data or an error is returned
+		if n > 0 && (!e.fill || n == len(dst)) {
+			return e.done(n)
+		}
+	}
+}
+
+// done returns n, along with io.EOF if earlyEOF is set and the stream ends
+// after the data returned. It produces the next block to find out, which
+// blocks until it is available.
+func (e *blockEngine) done(n int) (int, error) {
+	if !e.earlyEOF || len(e.pending) > 0 || e.err != nil {
+		return n, nil
+	}
+	block, err := e.nextBlock()
+	if err == io.EOF {
+		return n, io.EOF
+	}
+	// any other error was kept by nextBlock for the next call
+	e.pending = block
+	return n, nil
+}
+
+// readBlock returns the pending output, or the next block if there is none,
+// and consumes it.
+func (e *blockEngine) readBlock() ([]byte, error) {
+	if len(e.pending) > 0 {
+		block := e.pending
+		e.pending = nil
+		return block, nil
+	}
+	return e.nextBlock()
+}
+
+// prime produces the next block, if there is no pending output, without
+// consuming it.
+func (e *blockEngine) prime() error {
+	if len(e.pending) > 0 {
+		return nil
+	}
+	block, err := e.nextBlock()
+	if err != nil {
+		e.err = err
+		return err
+	}
+	e.pending = block
+	return nil
+}
+
+// nextBlock returns the pending error, if any, or the next block.
+func (e *blockEngine) nextBlock() ([]byte, error) {
+	if err := e.err; err != nil {
+		if err == io.EOF {
+			e.err = nil
+		}
+		return nil, err
+	}
+	block, err := e.next()
+	if err != nil && err != io.EOF {
+		e.err = err
+	}
+	return block, err
+}
+
+// reset discards the pending output and any deferred error.
+func (e *blockEngine) reset() {
+	e.pending = nil
+	e.err = nil
+}
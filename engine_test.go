@@ -0,0 +1,135 @@
+package lz4
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+)
+
+func newTestEngine(blocks []string, end error) *blockEngine {
+	e := &blockEngine{}
+	e.next = func() ([]byte, error) {
+		if len(blocks) == 0 {
+			return nil, end
+		}
+		b := blocks[0]
+		blocks = blocks[1:]
+		return []byte(b), nil
+	}
+	return e
+}
+
+func TestBlockEngineRead(t *testing.T) {
+	e := newTestEngine([]string{"hello", "", "world"}, io.EOF)
+	buf := make([]byte, 3)
+	var got []string
+	for {
+		n, err := e.read(buf)
+		if err == io.EOF {
+			break
+		}
+		failOnError(t, "Failed reading", err)
+		got = append(got, string(buf[:n]))
+	}
+	// the empty block is skipped
+	want := []string{"hel", "lo", "wor", "ld"}
+	if len(got) != len(want) {
+		t.Fatalf("got reads %q, want %q", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("got reads %q, want %q", got, want)
+		}
+	}
+}
+
+func TestBlockEngineFillDefersError(t *testing.T) {
+	errBroken := errors.New("broken")
+	e := newTestEngine([]string{"abc", "de"}, errBroken)
+	e.fill = true
+	buf := make([]byte, 10)
+	n, err := e.read(buf)
+	if n != 5 || err != nil || string(buf[:n]) != "abcde" {
+		t.Fatalf("got %q, %v", buf[:n], err)
+	}
+	for i := 0; i < 2; i++ {
+		if n, err := e.read(buf); n != 0 || err != errBroken {
+			t.Fatalf("got %d, %v, want the deferred error", n, err)
+		}
+	}
+	e.reset()
+	if _, err := e.read(buf); err != errBroken {
+		t.Fatalf("got %v after reset, want a new call to next", err)
+	}
+}
+
+func TestBlockEnginePrimeAndReadBlock(t *testing.T) {
+	e := newTestEngine([]string{"first", "second"}, io.EOF)
+	failOnError(t, "Failed priming", e.prime())
+	failOnError(t, "Failed priming twice", e.prime())
+	buf := make([]byte, 2)
+	if n, _ := e.read(buf); string(buf[:n]) != "fi" {
+		t.Fatalf("got %q after priming", buf[:n])
+	}
+	for _, want := range []string{"rst", "second"} {
+		b, err := e.readBlock()
+		if err != nil || string(b) != want {
+			t.Fatalf("got %q, %v, want %q", b, err, want)
+		}
+	}
+	if err := e.prime(); err != io.EOF {
+		t.Fatalf("got %v priming at the end, want io.EOF", err)
+	}
+}
+
+func TestEmptyWrites(t *testing.T) {
+	messages := [][]byte{[]byte("first"), {}, nil, []byte("last")}
+	for _, empty := range []bool{false, true} {
+		var buf bytes.Buffer
+		var opts []Option
+		if empty {
+			opts = append(opts, WithEmptyWrites())
+		}
+		w := NewWriter(&buf, opts...)
+		for _, m := range messages {
+			_, err := w.Write(m)
+			failOnError(t, "Failed writing", err)
+		}
+		failOnError(t, "Failed closing", w.Close())
+		stream := buf.Bytes()
+
+		r := NewDecompressReader(bytes.NewReader(stream)).(*DecompressReader)
+		var got []string
+		for {
+			block, err := r.ReadBlock()
+			if err == io.EOF {
+				break
+			}
+			failOnError(t, "Failed reading block", err)
+			got = append(got, string(block))
+		}
+		want := []string{"first", "last"}
+		if empty {
+			want = []string{"first", "", "", "last"}
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) || len(got) != len(want) {
+			t.Errorf("empty writes %v: got blocks %q, want %q", empty, got, want)
+		}
+
+		// Read skips the empty blocks
+		r = NewDecompressReader(bytes.NewReader(stream)).(*DecompressReader)
+		p := make([]byte, 100)
+		for _, want := range []string{"first", "last"} {
+			n, err := r.Read(p)
+			failOnError(t, "Failed reading", err)
+			if string(p[:n]) != want {
+				t.Errorf("empty writes %v: got %q, want %q", empty, p[:n], want)
+			}
+		}
+		if _, err := r.Read(p); err != io.EOF {
+			t.Errorf("empty writes %v: got %v, want io.EOF", empty, err)
+		}
+	}
+}
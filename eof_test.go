@@ -0,0 +1,143 @@
+package lz4
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+	"testing/iotest"
+)
+
+func TestReadersTruncation(t *testing.T) {
+	var stream bytes.Buffer
+	w := NewWriter(&stream)
+	_, err := w.Write(plaintext0)
+	failOnError(t, "Failed writing", err)
+	failOnError(t, "Failed closing", w.Close())
+	var frame bytes.Buffer
+	fw := NewFrameWriter(&frame)
+	_, err = fw.Write(plaintext0)
+	failOnError(t, "Failed writing", err)
+	failOnError(t, "Failed closing", fw.Close())
+
+	for _, tc := range []struct {
+		name      string
+		data      []byte
+		newReader func(io.Reader) io.ReadCloser
+	}{
+		{"DecompressReader", stream.Bytes(), func(r io.Reader) io.ReadCloser { return NewDecompressReader(r) }},
+		{"FrameReader", frame.Bytes(), func(r io.Reader) io.ReadCloser { return NewFrameReader(r) }},
+	} {
+		for _, cut := range []int{1, 4, 5, len(tc.data) - 1} {
+			r := tc.newReader(bytes.NewReader(tc.data[:cut]))
+			if _, err := ioutil.ReadAll(r); err != io.ErrUnexpectedEOF {
+				t.Errorf("%s cut at %d: got %v, want io.ErrUnexpectedEOF", tc.name, cut, err)
+			}
+			r.Close()
+		}
+		r := tc.newReader(bytes.NewReader(tc.data))
+		got, err := ioutil.ReadAll(r)
+		if err != nil || !bytes.Equal(got, plaintext0) {
+			t.Errorf("%s: got %q, %v", tc.name, got, err)
+		}
+		r.Close()
+	}
+}
+
+func TestReadersFollowGrowingStream(t *testing.T) {
+	// a bytes.Buffer returns io.EOF when empty, and can be written to again
+	var src bytes.Buffer
+	w := NewWriter(&src)
+	r := NewDecompressReader(&src)
+	defer r.Close()
+	buf := make([]byte, 100)
+	for i := 0; i < 3; i++ {
+		if _, err := r.Read(buf); err != io.EOF {
+			t.Fatalf("got %v before writing, want io.EOF", err)
+		}
+		failOnError(t, "Failed writing", w.WriteBlock(plaintext0))
+		n, err := r.Read(buf)
+		if err != nil || !bytes.Equal(buf[:n], plaintext0) {
+			t.Fatalf("got %q, %v after writing", buf[:n], err)
+		}
+	}
+	failOnError(t, "Failed closing", w.Close())
+}
+
+func TestReadersErrorsAreFinal(t *testing.T) {
+	errBroken := errors.New("broken")
+	r := NewDecompressReader(iotest.ErrReader(errBroken))
+	defer r.Close()
+	for i := 0; i < 2; i++ {
+		if _, err := r.Read(make([]byte, 10)); err != errBroken {
+			t.Fatalf("got %v, want the underlying error", err)
+		}
+	}
+
+	cr := NewCompressReader(iotest.ErrReader(errBroken))
+	defer cr.Close()
+	if _, err := cr.Read(make([]byte, 10)); !errors.Is(err, errBroken) {
+		t.Fatalf("got %v, want an error wrapping the underlying error", err)
+	}
+}
+
+func TestReadersEarlyEOF(t *testing.T) {
+	input := bytes.Repeat([]byte("early end of file "), 10000)
+	var stream, frame bytes.Buffer
+	w := NewWriter(&stream)
+	_, err := w.Write(input)
+	failOnError(t, "Failed writing", err)
+	failOnError(t, "Failed closing", w.Close())
+	fw := NewFrameWriter(&frame)
+	_, err = fw.Write(input)
+	failOnError(t, "Failed writing", err)
+	failOnError(t, "Failed closing", fw.Close())
+	compressed, err := ioutil.ReadAll(NewCompressReader(bytes.NewReader(input)))
+	failOnError(t, "Failed compressing", err)
+
+	for _, tc := range []struct {
+		name string
+		open func(...Option) io.Reader
+		want []byte
+	}{
+		{"DecompressReader", func(opts ...Option) io.Reader {
+			return NewDecompressReader(bytes.NewReader(stream.Bytes()), opts...)
+		}, input},
+		{"FrameReader", func(opts ...Option) io.Reader {
+			return NewFrameReader(bytes.NewReader(frame.Bytes()), opts...)
+		}, input},
+		{"CompressReader", func(opts ...Option) io.Reader {
+			return NewCompressReader(bytes.NewReader(input), opts...)
+		}, compressed},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := iotest.TestReader(tc.open(WithEarlyEOF()), tc.want); err != nil {
+				t.Fatal(err)
+			}
+			for _, opts := range [][]Option{{WithEarlyEOF()}, {WithEarlyEOF(), WithFillBuffer()}} {
+				r := tc.open(opts...)
+				buf := make([]byte, 2*len(input))
+				total := 0
+				for {
+					n, err := r.Read(buf[total:])
+					total += n
+					if err == io.EOF {
+						if n == 0 {
+							t.Fatal("io.EOF returned without the last data")
+						}
+						break
+					}
+					failOnError(t, "Failed reading", err)
+				}
+				if !bytes.Equal(buf[:total], tc.want) {
+					t.Fatal("output != expected")
+				}
+			}
+			// without the option, io.EOF comes alone
+			r := tc.open()
+			_, err := r.Read(make([]byte, 2*len(input)))
+			failOnError(t, "Failed reading", err)
+		})
+	}
+}
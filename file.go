@@ -0,0 +1,105 @@
+package lz4
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// CompressFile compresses the file src into a block stream in the new file
+// dst, configured by opts. See convertFile for how dst is written.
+func CompressFile(dst, src string, opts ...Option) error {
+	return convertFile(dst, src, opts, func(w io.Writer, r io.Reader) error {
+		zw := NewWriter(w, opts...)
+		_, err := io.Copy(zw, r)
+		if cerr := zw.Close(); err == nil {
+			err = cerr
+		}
+		return err
+	})
+}
+
+// DecompressFile decompresses the block stream in the file src into the new
+// file dst, configured by opts. See convertFile for how dst is written.
+func DecompressFile(dst, src string, opts ...Option) error {
+	return convertFile(dst, src, opts, func(w io.Writer, r io.Reader) error {
+		zr := NewDecompressReader(r, opts...)
+		_, err := io.Copy(w, zr)
+		if cerr := zr.Close(); err == nil {
+			err = cerr
+		}
+		return err
+	})
+}
+
+// convertFile writes the output of convert for the content of src to dst. The
+// output is written to a temporary file in the directory of dst, which gets
+// the permissions and modification time of src, then linked to dst, so dst
+// never holds partial output and an existing file is never replaced: it is an
+// error if dst exists. With WithFileSync, the file and the directory are
+// synced before returning.
+func convertFile(dst, src string, opts []Option, convert func(io.Writer, io.Reader) error) error {
+	o := newOptions(opts)
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	fi, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Lstat(dst); err == nil {
+		return &os.PathError{Op: "create", Path: dst, Err: os.ErrExist}
+	}
+
+	dir := filepath.Dir(dst)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(dst)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// closing again is harmless, and the link to dst, if any, keeps
+		// the content
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+
+	if err := tmp.Chmod(fi.Mode().Perm()); err != nil {
+		return err
+	}
+	if err := convert(tmp, in); err != nil {
+		return err
+	}
+	if o.fileSync {
+		if err := tmp.Sync(); err != nil {
+			return err
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chtimes(tmp.Name(), fi.ModTime(), fi.ModTime()); err != nil {
+		return err
+	}
+	// unlike a rename, a link fails if dst was created in the meantime
+	if err := os.Link(tmp.Name(), dst); err != nil {
+		return err
+	}
+	if o.fileSync {
+		return syncDir(dir)
+	}
+	return nil
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if cerr := d.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
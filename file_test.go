@@ -0,0 +1,75 @@
+package lz4
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCompressFileRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	mtime := time.Unix(1400000000, 0)
+	if err := os.WriteFile(src, plaintext0, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(src, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	compressed := filepath.Join(dir, "src.lz4")
+	failOnError(t, "Failed compressing file", CompressFile(compressed, src, WithFileSync()))
+	out := filepath.Join(dir, "out")
+	failOnError(t, "Failed decompressing file", DecompressFile(out, compressed))
+
+	got, err := os.ReadFile(out)
+	failOnError(t, "Failed reading output", err)
+	if !bytes.Equal(got, plaintext0) {
+		t.Fatal("decompressed content does not match")
+	}
+	for _, p := range []string{compressed, out} {
+		fi, err := os.Stat(p)
+		failOnError(t, "Failed stating output", err)
+		if fi.Mode().Perm() != 0o600 || !fi.ModTime().Equal(mtime) {
+			t.Errorf("%s: got mode %v and mtime %v", p, fi.Mode(), fi.ModTime())
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	failOnError(t, "Failed listing directory", err)
+	if len(entries) != 3 {
+		t.Errorf("got %d files, want 3: temporary files left behind", len(entries))
+	}
+}
+
+func TestCompressFileExistingOutput(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	dst := filepath.Join(dir, "dst")
+	failOnError(t, "Failed writing file", os.WriteFile(src, plaintext0, 0o644))
+	failOnError(t, "Failed writing file", os.WriteFile(dst, []byte("keep"), 0o644))
+
+	if err := CompressFile(dst, src); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("got error %v, want os.ErrExist", err)
+	}
+	got, _ := os.ReadFile(dst)
+	if string(got) != "keep" {
+		t.Error("existing file was modified")
+	}
+}
+
+func TestDecompressFileCorrupt(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	failOnError(t, "Failed writing file", os.WriteFile(src, []byte("not a block stream at all"), 0o644))
+	dst := filepath.Join(dir, "dst")
+	if err := DecompressFile(dst, src); err == nil {
+		t.Fatal("no error for a corrupt stream")
+	}
+	if _, err := os.Stat(dst); !os.IsNotExist(err) {
+		t.Error("output written for a corrupt stream")
+	}
+}
@@ -0,0 +1,65 @@
+package lz4
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func TestWithFillBuffer(t *testing.T) {
+	input, err := ioutil.ReadFile(sampleFilePath)
+	failOnError(t, "Failed reading sample", err)
+	for len(input) < 5*streamingBlockSize {
+		input = append(input, input...)
+	}
+	input = input[:5*streamingBlockSize+100]
+	compressed, err := CompressBytesToStream(input)
+	failOnError(t, "Failed compressing", err)
+
+	r := NewDecompressReader(bytes.NewReader(compressed), WithFillBuffer())
+	defer r.Close()
+	buf := make([]byte, 2*streamingBlockSize+10)
+	var out []byte
+	var sizes []int
+	for {
+		n, err := r.Read(buf)
+		out = append(out, buf[:n]...)
+		if err == io.EOF {
+			break
+		}
+		failOnError(t, "Failed reading", err)
+		sizes = append(sizes, n)
+	}
+	if !bytes.Equal(out, input) {
+		t.Fatalf("Decompressed output != input")
+	}
+	want := []int{len(buf), len(buf), len(input) - 2*len(buf)}
+	if len(sizes) != len(want) {
+		t.Fatalf("read sizes %v, want %v", sizes, want)
+	}
+	for i := range want {
+		if sizes[i] != want[i] {
+			t.Fatalf("read sizes %v, want %v", sizes, want)
+		}
+	}
+}
+
+func TestWithFillBufferError(t *testing.T) {
+	input := bytes.Repeat([]byte("fill "), streamingBlockSize)
+	compressed, err := CompressBytesToStream(input)
+	failOnError(t, "Failed compressing", err)
+	// truncate in the last block
+	compressed = compressed[:len(compressed)-3]
+
+	r := NewDecompressReader(bytes.NewReader(compressed), WithFillBuffer())
+	defer r.Close()
+	buf := make([]byte, len(input))
+	n, err := r.Read(buf)
+	if n == 0 || err != nil {
+		t.Fatalf("first Read = %d, %v", n, err)
+	}
+	if _, err := r.Read(buf); err != io.ErrUnexpectedEOF {
+		t.Fatalf("expected io.ErrUnexpectedEOF, got %v", err)
+	}
+}
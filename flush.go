@@ -0,0 +1,34 @@
+package lz4
+
+// flusher is implemented by http.ResponseWriter values that support
+// http.Flusher.
+type flusher interface {
+	Flush()
+}
+
+// errFlusher is implemented by buffered writers such as *bufio.Writer.
+type errFlusher interface {
+	Flush() error
+}
+
+// Flush flushes the underlying writer if it implements http.Flusher or has a
+// Flush method returning an error, like *bufio.Writer. The Writer itself only
+// buffers the bytes written by WriteByte, which Flush compresses first, since
+// each Write is compressed and written before it returns, so Flush makes the
+// data written so far reach an HTTP client or file promptly, for example in
+// server-sent events.
+func (w *Writer) Flush() error {
+	if w.err != nil {
+		return w.err
+	}
+	if err := w.flushBytes(); err != nil {
+		return err
+	}
+	switch f := w.underlyingWriter.(type) {
+	case errFlusher:
+		return f.Flush()
+	case flusher:
+		f.Flush()
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package lz4
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFlushResponseWriter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := NewWriter(rec)
+	defer w.Close()
+	_, err := w.Write(plaintext0)
+	failOnError(t, "Failed writing to compress object", err)
+	failOnError(t, "Failed flushing", w.Flush())
+	if !rec.Flushed {
+		t.Fatalf("ResponseWriter was not flushed")
+	}
+
+	out, err := ioutil.ReadAll(NewDecompressReader(rec.Body))
+	failOnError(t, "Failed decompressing", err)
+	if !bytes.Equal(out, plaintext0) {
+		t.Fatalf("Decompressed output != input")
+	}
+}
+
+func TestFlushBufioWriter(t *testing.T) {
+	var compressed bytes.Buffer
+	bw := bufio.NewWriter(&compressed)
+	w := NewWriter(bw)
+	defer w.Close()
+	_, err := w.Write(plaintext0)
+	failOnError(t, "Failed writing to compress object", err)
+	if compressed.Len() != 0 {
+		t.Fatalf("data reached the buffer before Flush")
+	}
+	failOnError(t, "Failed flushing", w.Flush())
+	if int64(compressed.Len()) != w.CompressedBytesWritten() {
+		t.Fatalf("got %d bytes after Flush, want %d", compressed.Len(), w.CompressedBytesWritten())
+	}
+}
@@ -0,0 +1,161 @@
+package lz4
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// Format identifies an lz4 data format.
+type Format int
+
+const (
+	// FormatCustomStream is the block stream written by Writer and
+	// CompressReader.
+	FormatCustomStream Format = iota
+	// FormatFrame is the standard LZ4 frame format, written by FrameWriter
+	// and the lz4 command line tool.
+	FormatFrame
+	// FormatBlockHdr is a single block preceded by its 4-byte little endian
+	// uncompressed size, as written by CompressHdr.
+	FormatBlockHdr
+	// FormatLegacy is the legacy frame format of the lz4 command line
+	// tool, written with lz4 -l.
+	FormatLegacy
+	// FormatHadoopBlock is the format of the Lz4Codec of Hadoop.
+	FormatHadoopBlock
+	// FormatJavaBlock is the format of LZ4BlockOutputStream from lz4-java.
+	FormatJavaBlock
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatCustomStream:
+		return "CustomStream"
+	case FormatFrame:
+		return "Frame"
+	case FormatBlockHdr:
+		return "BlockHdr"
+	case FormatLegacy:
+		return "Legacy"
+	case FormatHadoopBlock:
+		return "HadoopBlock"
+	case FormatJavaBlock:
+		return "JavaBlock"
+	}
+	return fmt.Sprintf("Format(%d)", int(f))
+}
+
+// Capabilities describes the features of a format.
+type Capabilities struct {
+	// Seeking is set if the format can be read from an arbitrary offset of
+	// the uncompressed data, as with NewSeekReader.
+	Seeking bool
+	// Checksums is set if the format can hold checksums of its data.
+	Checksums bool
+	// Dictionaries is set if the format can be compressed with a dictionary
+	// identified in the data.
+	Dictionaries bool
+}
+
+// Capabilities returns the features supported by f in this package.
+func (f Format) Capabilities() Capabilities {
+	switch f {
+	case FormatCustomStream:
+		return Capabilities{Seeking: true, Checksums: true, Dictionaries: true}
+	case FormatFrame:
+		return Capabilities{Checksums: true, Dictionaries: true}
+	case FormatJavaBlock:
+		return Capabilities{Checksums: true}
+	}
+	return Capabilities{}
+}
+
+// Transcode decompresses src, in format from, and compresses the data again
+// into dst in format to. The streaming formats are converted block by block
+// with bounded memory, but FormatBlockHdr holds all the data in a single
+// block, so reading or writing it keeps the whole data in memory.
+func Transcode(dst io.Writer, src io.Reader, from, to Format) error {
+	r, err := newFormatReader(src, from)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	w, err := newFormatWriter(dst, to)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, streamingBlockSize)
+	if _, err := io.CopyBuffer(w, r, buf); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// newFormatReader returns a reader decompressing r, in format f.
+func newFormatReader(r io.Reader, f Format) (io.ReadCloser, error) {
+	switch f {
+	case FormatCustomStream:
+		return newDecompressReader(r, explicitOptions(), nil), nil
+	case FormatFrame:
+		return newFrameReader(r, explicitOptions()), nil
+	case FormatBlockHdr:
+		in, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		out, err := UncompressAllocHdr(nil, in)
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(bytes.NewReader(out)), nil
+	case FormatLegacy:
+		return newLegacyReader(r), nil
+	case FormatHadoopBlock:
+		return newHadoopReader(r), nil
+	case FormatJavaBlock:
+		return newJavaReader(r), nil
+	}
+	return nil, fmt.Errorf("unsupported format %s", f)
+}
+
+// newFormatWriter returns a writer compressing to w in format f.
+func newFormatWriter(w io.Writer, f Format) (io.WriteCloser, error) {
+	switch f {
+	case FormatCustomStream:
+		return newWriter(w, explicitOptions()), nil
+	case FormatFrame:
+		return newFrameWriter(w, explicitOptions()), nil
+	case FormatBlockHdr:
+		return &hdrWriter{w: w}, nil
+	case FormatLegacy:
+		return newLegacyWriter(w), nil
+	case FormatHadoopBlock:
+		return newHadoopWriter(w), nil
+	case FormatJavaBlock:
+		return newJavaWriter(w), nil
+	}
+	return nil, fmt.Errorf("unsupported format %s", f)
+}
+
+// hdrWriter buffers the data written to it and writes it as a single
+// CompressHdr block on Close.
+type hdrWriter struct {
+	w   io.Writer
+	buf bytes.Buffer
+}
+
+func (h *hdrWriter) Write(p []byte) (int, error) {
+	return h.buf.Write(p)
+}
+
+func (h *hdrWriter) Close() error {
+	out, err := CompressAllocHdr(h.buf.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = h.w.Write(out)
+	return err
+}
@@ -0,0 +1,117 @@
+package lz4
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os/exec"
+	"testing"
+)
+
+func TestTranscode(t *testing.T) {
+	input, err := ioutil.ReadFile(sampleFilePath)
+	failOnError(t, "Failed reading sample", err)
+
+	compressed, err := CompressBytesToStream(input)
+	failOnError(t, "Failed compressing", err)
+
+	// go through every format and back to the custom stream
+	data := compressed
+	from := FormatCustomStream
+	for _, to := range []Format{FormatFrame, FormatBlockHdr, FormatFrame, FormatCustomStream} {
+		var out bytes.Buffer
+		failOnError(t, "Failed transcoding to "+to.String(), Transcode(&out, bytes.NewReader(data), from, to))
+		data, from = out.Bytes(), to
+	}
+
+	out, err := DecompressStreamToBytes(data, len(input))
+	failOnError(t, "Failed decompressing", err)
+	if !bytes.Equal(out, input) {
+		t.Fatalf("Decompressed output != input")
+	}
+}
+
+func TestTranscodeUnsupported(t *testing.T) {
+	var out bytes.Buffer
+	if err := Transcode(&out, bytes.NewReader(nil), Format(42), FormatFrame); err == nil {
+		t.Fatalf("expected an error")
+	}
+}
+
+func TestTranscodeFormats(t *testing.T) {
+	input, err := ioutil.ReadFile(sampleFilePath)
+	failOnError(t, "Failed reading sample", err)
+	for len(input) < 600<<10 {
+		input = append(input, input...)
+	}
+	compressed, err := CompressBytesToStream(input)
+	failOnError(t, "Failed compressing", err)
+
+	for _, f := range []Format{FormatLegacy, FormatHadoopBlock, FormatJavaBlock, FormatFrame, FormatBlockHdr, FormatCustomStream} {
+		var out bytes.Buffer
+		failOnError(t, "Failed transcoding to "+f.String(), Transcode(&out, bytes.NewReader(compressed), FormatCustomStream, f))
+		data := out.Bytes()
+		if got, err := DetectFormat(data); err != nil || got != f {
+			t.Errorf("detected %v, %v for %v", got, err, f)
+		}
+		r, got, err := NewAutoReader(bytes.NewReader(data))
+		failOnError(t, "Failed creating reader for "+f.String(), err)
+		decompressed, err := ioutil.ReadAll(r)
+		failOnError(t, "Failed decompressing "+f.String(), err)
+		r.Close()
+		if got != f || !bytes.Equal(decompressed, input) {
+			t.Errorf("%v: got format %v and %d bytes, want %d", f, got, len(decompressed), len(input))
+		}
+		// a truncated stream is an error
+		if f != FormatBlockHdr {
+			r, _ := newFormatReader(bytes.NewReader(data[:len(data)-1]), f)
+			if _, err := ioutil.ReadAll(r); err == nil {
+				t.Errorf("%v: no error for a truncated stream", f)
+			}
+		}
+	}
+	if _, err := DetectFormat([]byte("not lz4 at all")); err != ErrUnknownFormat {
+		t.Errorf("got %v for unknown data", err)
+	}
+}
+
+func TestFormatCapabilities(t *testing.T) {
+	if c := FormatCustomStream.Capabilities(); !c.Seeking || !c.Checksums || !c.Dictionaries {
+		t.Errorf("got %+v for the block stream", c)
+	}
+	if c := FormatBlockHdr.Capabilities(); c.Seeking || c.Checksums || c.Dictionaries {
+		t.Errorf("got %+v for a single block", c)
+	}
+	if c := FormatJavaBlock.Capabilities(); !c.Checksums || c.Dictionaries {
+		t.Errorf("got %+v for lz4-java", c)
+	}
+}
+
+func TestLegacyCommandLine(t *testing.T) {
+	lz4, err := exec.LookPath("lz4")
+	if err != nil {
+		t.Skip("lz4 command not found")
+	}
+	input := bytes.Repeat(plaintext0, 100)
+	cmd := exec.Command(lz4, "-l", "-c")
+	cmd.Stdin = bytes.NewReader(input)
+	legacy, err := cmd.Output()
+	failOnError(t, "Failed running lz4", err)
+	out, err := ioutil.ReadAll(newLegacyReader(bytes.NewReader(legacy)))
+	failOnError(t, "Failed decompressing", err)
+	if !bytes.Equal(out, input) {
+		t.Fatal("decompressed output != input")
+	}
+
+	var buf bytes.Buffer
+	w := newLegacyWriter(&buf)
+	_, err = w.Write(input)
+	failOnError(t, "Failed writing", err)
+	failOnError(t, "Failed closing", w.Close())
+	cmd = exec.Command(lz4, "-d", "-c")
+	cmd.Stdin = &buf
+	out, err = cmd.Output()
+	failOnError(t, "Failed running lz4", err)
+	if !bytes.Equal(out, input) {
+		t.Fatal("lz4 decompressed output != input")
+	}
+}
@@ -0,0 +1,389 @@
+package lz4
+
+// #cgo pkg-config: liblz4
+// #include <lz4frame.h>
+import "C"
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"unsafe"
+)
+
+// frame.go contains a Writer and a reader for the standard LZ4 frame format,
+// as produced by the lz4 command line tool, using the LZ4F API of liblz4.
+
+// frameChunkSize is the amount of data passed to liblz4 per call, which bounds
+// the size of the output buffers.
+const frameChunkSize = 64 * 1024
+
+// frameError returns the error for the result code of the LZ4F function fn,
+// if any.
+func frameError(fn string, code C.size_t) error {
+	if C.LZ4F_isError(code) == 0 {
+		return nil
+	}
+	return newCError(fn, int(int64(code)), C.GoString(C.LZ4F_getErrorName(code)))
+}
+
+// FrameWriter is an io.WriteCloser that compresses its input into an LZ4 frame.
+// The frame is complete once Close has been called.
+type FrameWriter struct {
+	ctx              *C.LZ4F_cctx
+	prefs            C.LZ4F_preferences_t
+	buf              []byte
+	underlyingWriter io.Writer
+	closer           io.Closer
+	started          bool
+	// enc encodes the frame instead of liblz4 if it uses a dictionary
+	enc *frameEncoder
+	// counter counts the output to pad it to a multiple of padding
+	counter *countingWriter
+	padding int
+}
+
+// NewFrameWriter creates a new FrameWriter writing an LZ4 frame to w. By
+// default, the frame uses linked 64 KiB blocks and a content checksum, like
+// the lz4 command line tool. The frame header can be configured with options:
+//
+//   - WithBlockSize selects the smallest frame block size of 64 KiB, 256 KiB,
+//     1 MiB or 4 MiB that holds the given size.
+//   - WithIndependentBlocks compresses blocks independently.
+//   - WithContentChecksum(false) omits the content checksum.
+//   - WithBlockChecksum adds an XXH32 checksum to each block; WithChecksum
+//     does not apply to frames.
+//   - WithLevel sets the compression level as in liblz4: levels below 3 use
+//     fast compression, with negative levels as acceleration, and levels
+//     from 3 to 12 use HC compression.
+//   - WithFavorDecSpeed favors decompression speed at high HC levels.
+//   - WithDictionaryStore compresses with the current dictionary of the
+//     store, if any, recording its ID in the frame header. FrameReader and
+//     the lz4 command line tool, given the dictionary with -D, can decode
+//     the frame. WithFavorDecSpeed does not apply to it.
+//
+// It is the caller's responsibility to call Close on the FrameWriter when
+// done, to write the end of the frame and free the lz4 context.
+func NewFrameWriter(w io.Writer, opts ...Option) *FrameWriter {
+	return newFrameWriter(w, newOptions(opts))
+}
+
+// newFrameWriter creates a new FrameWriter with the options o.
+func newFrameWriter(w io.Writer, o options) *FrameWriter {
+	fw := &FrameWriter{
+		underlyingWriter: w,
+		closer:           underlyingCloser(w, o),
+	}
+	if o.padding >= 2 {
+		fw.counter = &countingWriter{w: w}
+		fw.underlyingWriter = fw.counter
+		fw.padding = o.padding
+	}
+	fi := &fw.prefs.frameInfo
+	fi.blockSizeID = frameBlockSizeID(o.blockSize)
+	if o.independentBlocks {
+		fi.blockMode = C.LZ4F_blockIndependent
+	}
+	if !o.noContentChecksum {
+		fi.contentChecksumFlag = C.LZ4F_contentChecksumEnabled
+	}
+	if o.blockChecksum {
+		fi.blockChecksumFlag = C.LZ4F_blockChecksumEnabled
+	}
+	fw.prefs.compressionLevel = C.int(o.level)
+	if o.favorDecSpeed {
+		fw.prefs.favorDecSpeed = 1
+	}
+	if o.dictionaries != nil {
+		if id, dict, ok := o.dictionaries.Current(); ok {
+			fw.enc = newFrameEncoder(fw.underlyingWriter, o, id, dict)
+		}
+	}
+	C.LZ4F_createCompressionContext(&fw.ctx, C.LZ4F_VERSION)
+	fw.buf = make([]byte, C.LZ4F_compressBound(frameChunkSize, &fw.prefs))
+	return fw
+}
+
+// frameBlockSizeID returns the ID of the smallest frame block size holding
+// size, or the default for 0.
+func frameBlockSizeID(size int) C.LZ4F_blockSizeID_t {
+	switch {
+	case size <= 64<<10:
+		return C.LZ4F_max64KB
+	case size <= 256<<10:
+		return C.LZ4F_max256KB
+	case size <= 1<<20:
+		return C.LZ4F_max1MB
+	}
+	return C.LZ4F_max4MB
+}
+
+// begin writes the frame header, if not done yet.
+func (w *FrameWriter) begin() error {
+	if w.started {
+		return nil
+	}
+	n := C.LZ4F_compressBegin(w.ctx, unsafe.Pointer(&w.buf[0]), C.size_t(len(w.buf)), &w.prefs)
+	if err := frameError("LZ4F_compressBegin", n); err != nil {
+		return err
+	}
+	if _, err := w.underlyingWriter.Write(w.buf[:n]); err != nil {
+		return err
+	}
+	w.started = true
+	return nil
+}
+
+// Write compresses src into the frame.
+func (w *FrameWriter) Write(src []byte) (int, error) {
+	if w.ctx == nil {
+		return 0, errors.New("writer is closed")
+	}
+	if w.enc != nil {
+		return w.enc.write(src)
+	}
+	if err := w.begin(); err != nil {
+		return 0, err
+	}
+	written := 0
+	for written < len(src) {
+		chunk := src[written:min(written+frameChunkSize, len(src))]
+		n := C.LZ4F_compressUpdate(w.ctx, unsafe.Pointer(&w.buf[0]), C.size_t(len(w.buf)),
+			unsafe.Pointer(&chunk[0]), C.size_t(len(chunk)), nil)
+		if err := frameError("LZ4F_compressUpdate", n); err != nil {
+			return written, err
+		}
+		if n > 0 {
+			if _, err := w.underlyingWriter.Write(w.buf[:n]); err != nil {
+				return written, err
+			}
+		}
+		written += len(chunk)
+	}
+	return written, nil
+}
+
+// Close writes the end of the frame, followed by the padding of WithPadding,
+// and releases the lz4 context.
+func (w *FrameWriter) Close() error {
+	if w.ctx == nil {
+		return nil
+	}
+	defer func() {
+		C.LZ4F_freeCompressionContext(w.ctx)
+		w.ctx = nil
+		if w.enc != nil {
+			w.enc.free()
+		}
+	}()
+	var err error
+	if w.enc != nil {
+		err = w.enc.end()
+	} else if err = w.begin(); err == nil {
+		n := C.LZ4F_compressEnd(w.ctx, unsafe.Pointer(&w.buf[0]), C.size_t(len(w.buf)), nil)
+		err = frameError("LZ4F_compressEnd", n)
+		if err == nil {
+			_, err = w.underlyingWriter.Write(w.buf[:n])
+		}
+	}
+	if err == nil {
+		err = w.writePadding()
+	}
+	if w.closer != nil {
+		if cerr := w.closer.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// FrameReader is an io.ReadCloser that decompresses LZ4 frames. Concatenated
+// frames are decompressed one after the other, and skippable frames are
+// ignored.
+type FrameReader struct {
+	ctx              *C.LZ4F_dctx
+	buf              []byte
+	src              []byte
+	underlyingReader io.Reader
+	closer           io.Closer
+	// inFrame is set between the start and the end of a frame
+	inFrame   bool
+	err       error
+	readAhead *readAhead
+	timeout   readTimeout
+	info      *StreamInfo
+	// frame describes the current or last frame, and frameLoaded is set
+	// once the header of the current frame is decoded
+	frame       *FrameInfo
+	frameLoaded bool
+	earlyEOF    bool
+	// dictFrame decodes the current frame instead of liblz4 if it uses a
+	// dictionary, which is looked up in dictionaries. fail is the error
+	// ending such a frame, which is final.
+	dictionaries *DictionaryStore
+	dictFrame    *frameDecoder
+	fail         error
+	// oneByte is the buffer of ReadByte, apart from r since it is passed
+	// to liblz4
+	oneByte []byte
+}
+
+// NewFrameReader creates a new FrameReader reading LZ4 frames from r. Frames
+// compressed with a dictionary are decoded with the dictionary registered
+// under their ID in the DictionaryStore given with WithDictionaryStore;
+// reading one fails with an error matching ErrUnknownDictionary if there is
+// none. It is the caller's responsibility to call Close on the FrameReader
+// when done, to free the lz4 context.
+func NewFrameReader(r io.Reader, opts ...Option) *FrameReader {
+	return newFrameReader(r, newOptions(opts))
+}
+
+// newFrameReader creates a new FrameReader with the options o.
+func newFrameReader(r io.Reader, o options) *FrameReader {
+	fr := &FrameReader{
+		buf:              make([]byte, frameChunkSize),
+		underlyingReader: r,
+		closer:           underlyingCloser(r, o),
+		earlyEOF:         o.earlyEOF,
+		dictionaries:     o.dictionaries,
+		timeout:          newReadTimeout(r, o),
+	}
+	if o.readAhead {
+		fr.readAhead = newReadAhead(r, fr.timeout)
+		fr.underlyingReader = fr.readAhead
+		fr.timeout.timeout = 0
+	}
+	C.LZ4F_createDecompressionContext(&fr.ctx, C.LZ4F_VERSION)
+	return fr
+}
+
+// Read decompresses data from the underlying reader into dst.
+func (r *FrameReader) Read(dst []byte) (int, error) {
+	if r.ctx == nil {
+		return 0, errors.New("reader is closed")
+	}
+	if len(dst) == 0 {
+		return 0, nil
+	}
+	if err := r.timeout.arm(); err != nil {
+		return 0, err
+	}
+	for {
+		n, err := r.step(dst)
+		if err != nil {
+			return 0, err
+		}
+		if n > 0 {
+			if r.earlyEOF && r.atEOF() {
+				return n, io.EOF
+			}
+			return n, nil
+		}
+	}
+}
+
+// step decodes input into dst, reading more input if needed, and returns the
+// number of bytes written to dst, which may be 0.
+func (r *FrameReader) step(dst []byte) (int, error) {
+	if r.fail != nil {
+		return 0, r.fail
+	}
+	if r.dictFrame == nil && !r.inFrame {
+		if err := r.startFrame(); err != nil {
+			return 0, err
+		}
+	}
+	if r.dictFrame != nil {
+		return r.readDictFrame(dst)
+	}
+	if len(r.src) == 0 {
+		return 0, r.fill()
+	}
+	if !r.frameLoaded {
+		// decode the header of a frame on its own, so it is known even if
+		// the whole frame is decoded by the next call
+		dst = nil
+	}
+	return r.decompress(dst)
+}
+
+// atEOF reports whether the stream ends cleanly after the data decoded so
+// far, reading more input to find out if needed.
+func (r *FrameReader) atEOF() bool {
+	if d := r.dictFrame; d != nil && len(d.pending) == 0 {
+		// end the frame if the end mark follows
+		if b, err := r.peek(blockHeaderSize); err != nil || binary.LittleEndian.Uint32(b) != 0 || r.endDictFrame() != nil {
+			return false
+		}
+	}
+	if r.inFrame || len(r.src) > 0 {
+		return false
+	}
+	if r.err == nil {
+		var n int
+		n, r.err = r.underlyingReader.Read(r.buf)
+		r.src = r.buf[:n]
+	}
+	if len(r.src) == 0 && r.err == io.EOF {
+		// like fill, io.EOF is not final
+		r.err = nil
+		return true
+	}
+	return false
+}
+
+// decompress decodes input from r.src into dst, and returns the number of
+// bytes written to dst.
+func (r *FrameReader) decompress(dst []byte) (int, error) {
+	var dstPtr unsafe.Pointer
+	if len(dst) > 0 {
+		dstPtr = unsafe.Pointer(&dst[0])
+	}
+	dstSize := C.size_t(len(dst))
+	srcSize := C.size_t(len(r.src))
+	hint := C.LZ4F_decompress(r.ctx, dstPtr, &dstSize, unsafe.Pointer(&r.src[0]), &srcSize, nil)
+	if err := frameError("LZ4F_decompress", hint); err != nil {
+		return 0, err
+	}
+	r.src = r.src[srcSize:]
+	r.inFrame = hint != 0
+	r.loadInfo()
+	if !r.inFrame {
+		r.frameLoaded = false
+	}
+	return int(dstSize), nil
+}
+
+// fill reads more input into r.src, or returns the error of the underlying
+// reader: io.ErrUnexpectedEOF for an end in the middle of a frame. io.EOF is
+// not final, so a growing stream can be followed.
+func (r *FrameReader) fill() error {
+	if err := r.err; err != nil {
+		if err == io.EOF {
+			if r.inFrame {
+				return io.ErrUnexpectedEOF
+			}
+			r.err = nil
+		}
+		return err
+	}
+	var n int
+	n, r.err = r.underlyingReader.Read(r.buf)
+	r.src = r.buf[:n]
+	return nil
+}
+
+// Close releases the lz4 context.
+func (r *FrameReader) Close() error {
+	if r.ctx != nil {
+		C.LZ4F_freeDecompressionContext(r.ctx)
+		r.ctx = nil
+		if r.readAhead != nil {
+			r.readAhead.close()
+		}
+		if r.closer != nil {
+			return r.closer.Close()
+		}
+	}
+	return nil
+}
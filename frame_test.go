@@ -0,0 +1,129 @@
+package lz4
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"testing"
+)
+
+func TestFrameWriterReader(t *testing.T) {
+	input, err := ioutil.ReadFile(sampleFilePath)
+	failOnError(t, "Failed reading sample", err)
+	for len(input) < 4*frameChunkSize {
+		input = append(input, input...)
+	}
+
+	var compressed bytes.Buffer
+	w := NewFrameWriter(&compressed)
+	_, err = w.Write(input[:1000])
+	failOnError(t, "Failed writing to compress object", err)
+	_, err = w.Write(input[1000:])
+	failOnError(t, "Failed writing to compress object", err)
+	failOnError(t, "Failed closing writer", w.Close())
+	failOnError(t, "Failed closing writer twice", w.Close())
+
+	// concatenated frames are read one after the other
+	stream := append(compressed.Bytes(), compressed.Bytes()...)
+	r := NewFrameReader(bytes.NewReader(stream))
+	out, err := ioutil.ReadAll(r)
+	failOnError(t, "Failed decompressing", err)
+	failOnError(t, "Failed closing reader", r.Close())
+	if !bytes.Equal(out, append(append([]byte(nil), input...), input...)) {
+		t.Fatalf("Decompressed output != input")
+	}
+
+	r = NewFrameReader(bytes.NewReader(compressed.Bytes()[:compressed.Len()-1]))
+	defer r.Close()
+	if _, err := ioutil.ReadAll(r); err != io.ErrUnexpectedEOF {
+		t.Fatalf("expected io.ErrUnexpectedEOF, got %v", err)
+	}
+}
+
+func TestFrameEmpty(t *testing.T) {
+	var compressed bytes.Buffer
+	w := NewFrameWriter(&compressed)
+	failOnError(t, "Failed closing writer", w.Close())
+	r := NewFrameReader(&compressed)
+	defer r.Close()
+	out, err := ioutil.ReadAll(r)
+	failOnError(t, "Failed decompressing", err)
+	if len(out) != 0 {
+		t.Fatalf("got %d bytes", len(out))
+	}
+}
+
+func TestFrameCommandLine(t *testing.T) {
+	lz4, err := exec.LookPath("lz4")
+	if err != nil {
+		t.Skip("lz4 command not found")
+	}
+	var compressed bytes.Buffer
+	w := NewFrameWriter(&compressed)
+	_, err = w.Write(plaintext0)
+	failOnError(t, "Failed writing to compress object", err)
+	failOnError(t, "Failed closing writer", w.Close())
+
+	cmd := exec.Command(lz4, "-d", "-c")
+	cmd.Stdin = &compressed
+	out, err := cmd.Output()
+	failOnError(t, "Failed running lz4", err)
+	if !bytes.Equal(out, plaintext0) {
+		t.Fatalf("Decompressed output != input")
+	}
+}
+
+func TestFrameWriterOptions(t *testing.T) {
+	input, err := ioutil.ReadFile(sampleFilePath)
+	failOnError(t, "Failed reading sample", err)
+	for len(input) < 2<<20 {
+		input = append(input, input...)
+	}
+
+	for _, tc := range []struct {
+		name string
+		opts []Option
+		want StreamInfo
+	}{
+		{"default", nil, StreamInfo{BlockSize: 64 << 10, ContentChecksum: true}},
+		{"1MB independent", []Option{WithBlockSize(300 << 10), WithBlockSize(1 << 20), WithIndependentBlocks()},
+			StreamInfo{BlockSize: 1 << 20, IndependentBlocks: true, ContentChecksum: true}},
+		{"block checksums only", []Option{WithBlockChecksum(), WithContentChecksum(false), WithLargeBlocks()},
+			StreamInfo{BlockSize: 4 << 20, BlockChecksum: true}},
+		{"HC", []Option{WithLevel(12), WithFavorDecSpeed(), WithBlockSize(256 << 10)},
+			StreamInfo{BlockSize: 256 << 10, ContentChecksum: true}},
+		{"fast", []Option{WithLevel(-8)}, StreamInfo{BlockSize: 64 << 10, ContentChecksum: true}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var compressed bytes.Buffer
+			w := NewFrameWriter(&compressed, tc.opts...)
+			_, err := w.Write(input)
+			failOnError(t, "Failed writing", err)
+			failOnError(t, "Failed closing writer", w.Close())
+
+			r := NewFrameReader(bytes.NewReader(compressed.Bytes()))
+			defer r.Close()
+			out, err := ioutil.ReadAll(r)
+			failOnError(t, "Failed decompressing", err)
+			if !bytes.Equal(out, input) {
+				t.Fatal("Decompressed output != input")
+			}
+			tc.want.Format = FormatFrame
+			tc.want.ContentSize = -1
+			if got := *r.Info(); got != tc.want {
+				t.Errorf("got %+v, want %+v", got, tc.want)
+			}
+
+			if _, err := exec.LookPath("lz4"); err == nil {
+				cmd := exec.Command("lz4", "-d", "-c")
+				cmd.Stdin = bytes.NewReader(compressed.Bytes())
+				out, err := cmd.Output()
+				failOnError(t, "lz4 failed", err)
+				if !bytes.Equal(out, input) {
+					t.Fatal("lz4 output != input")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package lz4
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/DataDog/golz4/xxhash"
+)
+
+// NewFrameBodyReader creates a FrameReader decoding the body of an LZ4 frame
+// whose header was stripped, such as by a proxy: r starts with the first block
+// of the frame, and fi gives the parameters the header would have recorded.
+// The body runs up to the end mark and the content checksum, if fi has one,
+// and any frames following it in r are decoded as by NewFrameReader. This is
+// an expert mode: parameters that do not match the ones the frame was
+// compressed with make reads fail, or may return corrupt data if the frame
+// has no checksums.
+//
+// fi.BlockSizeID selects the block size; if it is 0, it is derived from
+// fi.BlockSize, and the default of 64 KiB is used if both are 0. A non-zero
+// fi.DictID requires WithDictionaryStore, as for frames with that ID.
+// NewFrameBodyReader returns an error if fi cannot be a frame header.
+func NewFrameBodyReader(r io.Reader, fi FrameInfo, opts ...Option) (*FrameReader, error) {
+	header, err := appendFrameHeader(nil, fi)
+	if err != nil {
+		return nil, err
+	}
+	fr := NewFrameReader(r, opts...)
+	if len(fr.buf) < len(header) {
+		fr.buf = make([]byte, len(header))
+	}
+	// the header is decoded before any input is read
+	fr.src = fr.buf[:copy(fr.buf, header)]
+	return fr, nil
+}
+
+// appendFrameHeader appends the frame header described by fi to b.
+func appendFrameHeader(b []byte, fi FrameInfo) ([]byte, error) {
+	id := fi.BlockSizeID
+	switch {
+	case id == 0 && fi.BlockSize > 0:
+		if fi.BlockSize > 4<<20 {
+			return nil, fmt.Errorf("invalid frame block size %d", fi.BlockSize)
+		}
+		id = int(frameBlockSizeID(fi.BlockSize))
+	case id == 0:
+		id = int(frameBlockSizeID(streamingBlockSize))
+	case id < 4 || id > 7:
+		return nil, fmt.Errorf("invalid frame block size ID %d", id)
+	}
+
+	flags := byte(frameFlagVersion)
+	if fi.IndependentBlocks {
+		flags |= frameFlagIndependent
+	}
+	if fi.BlockChecksum {
+		flags |= frameFlagBlockChecksum
+	}
+	if fi.HasContentSize {
+		flags |= frameFlagContentSize
+	}
+	if fi.ContentChecksum {
+		flags |= frameFlagContentChecksum
+	}
+	if fi.DictID != 0 {
+		flags |= frameFlagDictID
+	}
+
+	var field [8]byte
+	binary.LittleEndian.PutUint32(field[:], frameMagic)
+	b = append(b, field[:4]...)
+	descriptor := len(b)
+	b = append(b, flags, byte(id)<<4)
+	if fi.HasContentSize {
+		binary.LittleEndian.PutUint64(field[:], fi.ContentSize)
+		b = append(b, field[:]...)
+	}
+	if fi.DictID != 0 {
+		binary.LittleEndian.PutUint32(field[:], fi.DictID)
+		b = append(b, field[:4]...)
+	}
+	return append(b, byte(xxhash.Sum32(b[descriptor:])>>8)), nil
+}
@@ -0,0 +1,66 @@
+package lz4
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestFrameBodyReader(t *testing.T) {
+	store := NewDictionaryStore()
+	failOnError(t, "Failed registering", store.Register(3, []byte("some dictionary content")))
+	failOnError(t, "Failed setting version", store.SetCurrent(3))
+
+	input := bytes.Repeat(plaintext0, 300)
+	for _, opts := range [][]Option{
+		nil,
+		{WithBlockChecksum(), WithIndependentBlocks()},
+		{WithContentChecksum(false), WithBlockSize(1 << 20)},
+		{WithDictionaryStore(store)},
+	} {
+		var buf bytes.Buffer
+		w := NewFrameWriter(&buf, opts...)
+		_, err := w.Write(input)
+		failOnError(t, "Failed writing", err)
+		failOnError(t, "Failed closing", w.Close())
+
+		fr := NewFrameReader(bytes.NewReader(buf.Bytes()), opts...)
+		fi, err := fr.FrameInfo()
+		failOnError(t, "Failed reading frame info", err)
+		failOnError(t, "Failed closing reader", fr.Close())
+
+		header, err := appendFrameHeader(nil, fi)
+		failOnError(t, "Failed building header", err)
+		if !bytes.Equal(header, buf.Bytes()[:len(header)]) {
+			t.Fatalf("%+v: header % x, want % x", fi, header, buf.Bytes()[:len(header)])
+		}
+
+		body := buf.Bytes()[len(header):]
+		br, err := NewFrameBodyReader(bytes.NewReader(body), fi, opts...)
+		failOnError(t, "Failed creating reader", err)
+		out, err := ioutil.ReadAll(br)
+		failOnError(t, "Failed decompressing body", err)
+		failOnError(t, "Failed closing body reader", br.Close())
+		if !bytes.Equal(out, input) {
+			t.Fatalf("%+v: decompressed output != input", fi)
+		}
+	}
+}
+
+func TestFrameBodyReaderMismatch(t *testing.T) {
+	compressed := compressFrame(t, bytes.Repeat(plaintext0, 100))
+	// the default frame has a content checksum, which the reader then
+	// takes for a block
+	br, err := NewFrameBodyReader(bytes.NewReader(compressed[7:]), FrameInfo{BlockSizeID: 4})
+	failOnError(t, "Failed creating reader", err)
+	defer br.Close()
+	if _, err := ioutil.ReadAll(br); err == nil {
+		t.Errorf("no error decoding with the wrong parameters")
+	}
+
+	for _, fi := range []FrameInfo{{BlockSizeID: 3}, {BlockSizeID: 8}, {BlockSize: 5 << 20}} {
+		if _, err := NewFrameBodyReader(bytes.NewReader(nil), fi); err == nil {
+			t.Errorf("no error for %+v", fi)
+		}
+	}
+}
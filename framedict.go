@@ -0,0 +1,443 @@
+package lz4
+
+// #cgo pkg-config: liblz4
+// #include <stdlib.h>
+// #include <lz4hc.h>
+// #include <lz4frame.h>
+import "C"
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"unsafe"
+
+	"github.com/DataDog/golz4/xxhash"
+)
+
+// framedict.go encodes and decodes LZ4 frames compressed with a dictionary.
+// The LZ4F dictionary functions are not part of the stable API of liblz4, so
+// these frames are handled with the block API instead.
+
+const (
+	frameMagic = 0x184D2204
+
+	frameFlagVersion         = 1 << 6
+	frameFlagIndependent     = 1 << 5
+	frameFlagBlockChecksum   = 1 << 4
+	frameFlagContentSize     = 1 << 3
+	frameFlagContentChecksum = 1 << 2
+	frameFlagDictID          = 1 << 0
+
+	// frameUncompressedBit is set in the size of a block stored uncompressed
+	frameUncompressedBit = 1 << 31
+)
+
+// frameEncoder writes a frame compressed with a dictionary, in blocks of
+// blockSize buffered in C memory, since the lz4 stream references them.
+type frameEncoder struct {
+	w           io.Writer
+	header      []byte
+	started     bool
+	blockSize   int
+	independent bool
+	checksum    bool
+	content     hash.Hash32
+	level       int
+
+	in       unsafe.Pointer
+	buffered int
+	dict     unsafe.Pointer
+	dictSize int
+	history  unsafe.Pointer
+	linked   bool
+	stream   *C.LZ4_stream_t
+	hcStream *C.LZ4_streamHC_t
+	out      []byte
+}
+
+// newFrameEncoder returns a frameEncoder for a frame with the options o and
+// the dictionary dict, version id of a DictionaryStore.
+func newFrameEncoder(w io.Writer, o options, id uint32, dict []byte) *frameEncoder {
+	blockSizeID := frameBlockSizeID(o.blockSize)
+	e := &frameEncoder{
+		w:           w,
+		blockSize:   frameBlockSize(blockSizeID),
+		independent: o.independentBlocks,
+		checksum:    o.blockChecksum,
+		level:       o.level,
+	}
+	flags := byte(frameFlagVersion | frameFlagDictID)
+	if e.independent {
+		flags |= frameFlagIndependent
+	}
+	if e.checksum {
+		flags |= frameFlagBlockChecksum
+	}
+	if !o.noContentChecksum {
+		flags |= frameFlagContentChecksum
+		e.content = xxhash.New32()
+	}
+	header := make([]byte, 4, 11)
+	binary.LittleEndian.PutUint32(header, frameMagic)
+	header = append(header, flags, byte(blockSizeID)<<4, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(header[6:], id)
+	e.header = append(header, byte(xxhash.Sum32(header[4:])>>8))
+
+	e.in = C.malloc(C.size_t(e.blockSize))
+	e.dict = C.CBytes(dict)
+	e.dictSize = len(dict)
+	e.history = C.malloc(C.size_t(streamingBlockSize))
+	if e.level >= 3 {
+		e.hcStream = C.LZ4_createStreamHC()
+	} else {
+		e.stream = C.LZ4_createStream()
+	}
+	e.out = make([]byte, blockHeaderSize+compressBound(e.blockSize)+4)
+	return e
+}
+
+// begin writes the frame header, if not done yet.
+func (e *frameEncoder) begin() error {
+	if e.started {
+		return nil
+	}
+	if _, err := e.w.Write(e.header); err != nil {
+		return err
+	}
+	e.started = true
+	return nil
+}
+
+// write buffers src, writing each block once full.
+func (e *frameEncoder) write(src []byte) (int, error) {
+	if err := e.begin(); err != nil {
+		return 0, err
+	}
+	in := unsafe.Slice((*byte)(e.in), e.blockSize)
+	written := 0
+	for written < len(src) {
+		n := copy(in[e.buffered:], src[written:])
+		e.buffered += n
+		written += n
+		if e.buffered == e.blockSize {
+			if err := e.writeBlock(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// writeBlock compresses and writes the buffered block.
+func (e *frameEncoder) writeBlock() error {
+	src := unsafe.Slice((*byte)(e.in), e.buffered)
+	e.buffered = 0
+	if e.content != nil {
+		e.content.Write(src)
+	}
+	dst := e.out[blockHeaderSize:]
+	n := e.compress(src, dst[:compressBound(len(src))])
+	if n <= 0 {
+		fn := "LZ4_compress_fast_continue"
+		if e.hcStream != nil {
+			fn = "LZ4_compress_HC_continue"
+		}
+		return newCError(fn, n, "error compressing")
+	}
+	size := uint32(n)
+	if n >= len(src) {
+		// the block is stored as is, which the history takes into account
+		n = copy(dst, src)
+		size = uint32(n) | frameUncompressedBit
+	}
+	binary.LittleEndian.PutUint32(e.out, size)
+	block := e.out[:blockHeaderSize+n]
+	if e.checksum {
+		block = block[:len(block)+4]
+		binary.LittleEndian.PutUint32(block[blockHeaderSize+n:], xxhash.Sum32(dst[:n]))
+	}
+	_, err := e.w.Write(block)
+	return err
+}
+
+// compress compresses src, the input buffer, into dst with the dictionary, and
+// for linked blocks the previous blocks, as history.
+func (e *frameEncoder) compress(src, dst []byte) int {
+	var n int
+	if e.hcStream != nil {
+		if !e.linked {
+			C.LZ4_resetStreamHC_fast(e.hcStream, C.int(e.level))
+			C.LZ4_loadDictHC(e.hcStream, (*C.char)(e.dict), C.int(e.dictSize))
+		}
+		n = int(C.LZ4_compress_HC_continue(e.hcStream, p(src), p(dst), clen(src), clen(dst)))
+		if !e.independent {
+			// the input buffer is overwritten by the next block
+			C.LZ4_saveDictHC(e.hcStream, (*C.char)(e.history), C.int(streamingBlockSize))
+			e.linked = true
+		}
+		return n
+	}
+	if !e.linked {
+		C.LZ4_loadDict(e.stream, (*C.char)(e.dict), C.int(e.dictSize))
+	}
+	acceleration := 1
+	if e.level < 0 {
+		acceleration = -e.level + 1
+	}
+	n = int(C.LZ4_compress_fast_continue(e.stream, p(src), p(dst), clen(src), clen(dst), C.int(acceleration)))
+	if !e.independent {
+		C.LZ4_saveDict(e.stream, (*C.char)(e.history), C.int(streamingBlockSize))
+		e.linked = true
+	}
+	return n
+}
+
+// end writes the last block, the end mark and the content checksum.
+func (e *frameEncoder) end() error {
+	if err := e.begin(); err != nil {
+		return err
+	}
+	if e.buffered > 0 {
+		if err := e.writeBlock(); err != nil {
+			return err
+		}
+	}
+	trailer := make([]byte, 4, 8)
+	if e.content != nil {
+		trailer = trailer[:8]
+		binary.LittleEndian.PutUint32(trailer[4:], e.content.Sum32())
+	}
+	_, err := e.w.Write(trailer)
+	return err
+}
+
+// free releases the C memory of e.
+func (e *frameEncoder) free() {
+	if e.hcStream != nil {
+		C.LZ4_freeStreamHC(e.hcStream)
+	}
+	if e.stream != nil {
+		C.LZ4_freeStream(e.stream)
+	}
+	C.free(e.in)
+	C.free(e.dict)
+	C.free(e.history)
+}
+
+// frameDecoder holds the state of a FrameReader within a frame compressed
+// with a dictionary.
+type frameDecoder struct {
+	info    FrameInfo
+	dict    []byte
+	history []byte
+	content hash.Hash32
+	decoded uint64
+	out     []byte
+	pending []byte
+}
+
+// startFrame checks whether the frame starting at r.src was compressed with a
+// dictionary, and if so reads its header and starts decoding it with a
+// frameDecoder instead of liblz4.
+func (r *FrameReader) startFrame() error {
+	b, err := r.peek(5)
+	if err != nil {
+		return err
+	}
+	if binary.LittleEndian.Uint32(b) != frameMagic || b[4]&frameFlagDictID == 0 {
+		return nil
+	}
+	flags := b[4]
+	size := 11
+	if flags&frameFlagContentSize != 0 {
+		size += 8
+	}
+	r.inFrame = true
+	header, err := r.peek(size)
+	if err != nil {
+		return err
+	}
+	if flags&0xc0 != frameFlagVersion || flags&0x02 != 0 || header[5]&0x8f != 0 ||
+		byte(xxhash.Sum32(header[4:size-1])>>8) != header[size-1] {
+		return r.frameFailed(errors.New("ERROR_frameHeader_incorrect"))
+	}
+	blockSizeID := C.LZ4F_blockSizeID_t(header[5] >> 4)
+	if blockSizeID < 4 {
+		return r.frameFailed(errors.New("ERROR_maxBlockSize_invalid"))
+	}
+	fi := FrameInfo{
+		BlockSizeID:       int(blockSizeID),
+		BlockSize:         frameBlockSize(blockSizeID),
+		IndependentBlocks: flags&frameFlagIndependent != 0,
+		ContentChecksum:   flags&frameFlagContentChecksum != 0,
+		BlockChecksum:     flags&frameFlagBlockChecksum != 0,
+		HasContentSize:    flags&frameFlagContentSize != 0,
+	}
+	if fi.HasContentSize {
+		fi.ContentSize = binary.LittleEndian.Uint64(header[6:])
+	}
+	fi.DictID = binary.LittleEndian.Uint32(header[size-5:])
+	r.src = r.src[size:]
+	r.setFrame(fi)
+
+	var dict []byte
+	var ok bool
+	if r.dictionaries != nil {
+		dict, ok = r.dictionaries.Lookup(fi.DictID)
+	}
+	if !ok {
+		return r.frameFailed(fmt.Errorf("%w %d", ErrUnknownDictionary, fi.DictID))
+	}
+	d := &frameDecoder{
+		info:    fi,
+		dict:    dict,
+		history: append(make([]byte, 0, streamingBlockSize), dict...),
+		out:     make([]byte, fi.BlockSize),
+	}
+	if fi.ContentChecksum {
+		d.content = xxhash.New32()
+	}
+	r.dictFrame = d
+	return nil
+}
+
+// readDictFrame copies decoded data of the current frame into dst, decoding
+// the next block if needed. It ends the frame at its end mark.
+func (r *FrameReader) readDictFrame(dst []byte) (int, error) {
+	d := r.dictFrame
+	if len(d.pending) == 0 {
+		if err := r.decodeDictBlock(); err != nil {
+			return 0, err
+		}
+		if r.dictFrame == nil {
+			return 0, nil
+		}
+	}
+	n := copy(dst, d.pending)
+	d.pending = d.pending[n:]
+	return n, nil
+}
+
+// decodeDictBlock decodes the next block of the current frame into
+// d.pending, or reads the end of the frame.
+func (r *FrameReader) decodeDictBlock() error {
+	d := r.dictFrame
+	b, err := r.peek(blockHeaderSize)
+	if err != nil {
+		return err
+	}
+	size := binary.LittleEndian.Uint32(b)
+	if size == 0 {
+		return r.endDictFrame()
+	}
+	compressed := size&frameUncompressedBit == 0
+	size &^= frameUncompressedBit
+	if int(size) > d.info.BlockSize {
+		return r.frameFailed(errors.New("ERROR_maxBlockSize_invalid"))
+	}
+	total := blockHeaderSize + int(size)
+	if d.info.BlockChecksum {
+		total += 4
+	}
+	b, err = r.peek(total)
+	if err != nil {
+		return err
+	}
+	data := b[blockHeaderSize : blockHeaderSize+size]
+	if d.info.BlockChecksum && xxhash.Sum32(data) != binary.LittleEndian.Uint32(b[total-4:]) {
+		return r.frameFailed(errors.New("ERROR_blockChecksum_invalid"))
+	}
+	var out []byte
+	if compressed {
+		n := int(C.LZ4_decompress_safe_usingDict(p(data), p(d.out), clen(data), clen(d.out), p(d.history), clen(d.history)))
+		if n < 0 {
+			return r.frameFailed(newCError("LZ4_decompress_safe_usingDict", n, "ERROR_decompressionFailed"))
+		}
+		out = d.out[:n]
+	} else {
+		out = d.out[:copy(d.out, data)]
+	}
+	r.src = r.src[total:]
+	if !d.info.IndependentBlocks {
+		d.history = appendHistory(d.history, out)
+	}
+	if d.content != nil {
+		d.content.Write(out)
+	}
+	d.decoded += uint64(len(out))
+	d.pending = out
+	return nil
+}
+
+// endDictFrame reads the end mark and content checksum of the current frame,
+// and returns to decoding with liblz4.
+func (r *FrameReader) endDictFrame() error {
+	d := r.dictFrame
+	size := blockHeaderSize
+	if d.info.ContentChecksum {
+		size += 4
+	}
+	b, err := r.peek(size)
+	if err != nil {
+		return err
+	}
+	if d.info.HasContentSize && d.decoded != d.info.ContentSize {
+		return r.frameFailed(errors.New("ERROR_frameSize_wrong"))
+	}
+	if d.content != nil && d.content.Sum32() != binary.LittleEndian.Uint32(b[blockHeaderSize:]) {
+		return r.frameFailed(errors.New("ERROR_contentChecksum_invalid"))
+	}
+	r.src = r.src[size:]
+	r.dictFrame = nil
+	r.inFrame = false
+	r.frameLoaded = false
+	return nil
+}
+
+// frameFailed makes err, an error decoding a frame compressed with a
+// dictionary, final.
+func (r *FrameReader) frameFailed(err error) error {
+	r.fail = err
+	return err
+}
+
+// appendHistory appends block to history, keeping the last 64 KiB, as far back
+// as blocks can reference.
+func appendHistory(history, block []byte) []byte {
+	if len(block) >= streamingBlockSize {
+		return append(history[:0], block[len(block)-streamingBlockSize:]...)
+	}
+	if keep := streamingBlockSize - len(block); len(history) > keep {
+		copy(history, history[len(history)-keep:])
+		history = history[:keep]
+	}
+	return append(history, block...)
+}
+
+// peek returns the next n bytes of input without consuming them, reading more
+// input as needed. Input that ends before n bytes, or in the middle of a
+// frame, gives io.ErrUnexpectedEOF. As in fill, io.EOF is not final.
+func (r *FrameReader) peek(n int) ([]byte, error) {
+	for len(r.src) < n {
+		if err := r.err; err != nil {
+			if err == io.EOF {
+				r.err = nil
+				if len(r.src) > 0 || r.inFrame {
+					err = io.ErrUnexpectedEOF
+				}
+			}
+			return nil, err
+		}
+		if len(r.buf) < n {
+			r.buf = make([]byte, n)
+		}
+		m := copy(r.buf, r.src)
+		var k int
+		k, r.err = r.underlyingReader.Read(r.buf[m:])
+		r.src = r.buf[:m+k]
+	}
+	return r.src[:n], nil
+}
@@ -0,0 +1,127 @@
+package lz4
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestFrameDictionary(t *testing.T) {
+	store := NewDictionaryStore()
+	dict := bytes.Repeat([]byte(`{"host":"web-1","level":"info","msg":"request served"}`), 100)
+	failOnError(t, "Failed registering", store.Register(7, dict))
+	failOnError(t, "Failed setting version", store.SetCurrent(7))
+	var input []byte
+	for i := 0; len(input) < 300<<10; i++ {
+		input = append(input, fmt.Sprintf(`{"host":"web-%d","level":"warn","msg":"request %d"}`, i%5, i)...)
+	}
+
+	for _, opts := range [][]Option{
+		nil,
+		{WithIndependentBlocks()},
+		{WithBlockChecksum(), WithContentChecksum(false)},
+		{WithLevel(9)},
+		{WithLevel(9), WithIndependentBlocks(), WithBlockSize(256 << 10)},
+	} {
+		var buf bytes.Buffer
+		w := NewFrameWriter(&buf, append(opts, WithDictionaryStore(store))...)
+		_, err := w.Write(input)
+		failOnError(t, "Failed writing", err)
+		failOnError(t, "Failed closing", w.Close())
+		// a frame without a dictionary follows
+		w = NewFrameWriter(&buf)
+		_, err = w.Write(plaintext0)
+		failOnError(t, "Failed writing", err)
+		failOnError(t, "Failed closing", w.Close())
+
+		r := NewFrameReader(bytes.NewReader(buf.Bytes()), WithDictionaryStore(store))
+		fi, err := r.FrameInfo()
+		failOnError(t, "Failed reading the frame header", err)
+		if fi.DictID != 7 || r.Info().DictID != 7 {
+			t.Errorf("got frame info %+v", fi)
+		}
+		out, err := ioutil.ReadAll(r)
+		failOnError(t, "Failed decompressing", err)
+		failOnError(t, "Failed closing", r.Close())
+		if !bytes.Equal(out, append(append([]byte(nil), input...), plaintext0...)) {
+			t.Fatalf("%d options: decompressed output != input", len(opts))
+		}
+	}
+
+	var buf bytes.Buffer
+	w := NewFrameWriter(&buf, WithDictionaryStore(store))
+	_, err := w.Write(input)
+	failOnError(t, "Failed writing", err)
+	failOnError(t, "Failed closing", w.Close())
+	if len(buf.Bytes()) >= len(compressFrame(t, input)) {
+		t.Errorf("dictionary did not help")
+	}
+
+	// the frame needs its dictionary
+	for _, opts := range [][]Option{{WithDictionaryStore(NewDictionaryStore())}, nil} {
+		r := NewFrameReader(bytes.NewReader(buf.Bytes()), opts...)
+		if _, err := io.Copy(ioutil.Discard, r); !errors.Is(err, ErrUnknownDictionary) {
+			t.Errorf("got %v, want ErrUnknownDictionary", err)
+		}
+		r.Close()
+	}
+
+	lz4, err := exec.LookPath("lz4")
+	if err != nil {
+		t.Skip("lz4 command not found")
+	}
+	path := filepath.Join(t.TempDir(), "dict")
+	failOnError(t, "Failed writing dictionary", ioutil.WriteFile(path, dict, 0o600))
+	cmd := exec.Command(lz4, "-d", "-c", "-D", path)
+	cmd.Stdin = bytes.NewReader(buf.Bytes())
+	out, err := cmd.Output()
+	failOnError(t, "Failed running lz4", err)
+	if !bytes.Equal(out, input) {
+		t.Fatalf("lz4 decompressed output != input")
+	}
+}
+
+func compressFrame(t *testing.T, input []byte) []byte {
+	var buf bytes.Buffer
+	w := NewFrameWriter(&buf)
+	_, err := w.Write(input)
+	failOnError(t, "Failed writing", err)
+	failOnError(t, "Failed closing", w.Close())
+	return buf.Bytes()
+}
+
+func TestFrameDictionaryCorrupt(t *testing.T) {
+	store := NewDictionaryStore()
+	failOnError(t, "Failed registering", store.Register(1, []byte("some dictionary content")))
+	failOnError(t, "Failed setting version", store.SetCurrent(1))
+	var buf bytes.Buffer
+	w := NewFrameWriter(&buf, WithDictionaryStore(store))
+	_, err := w.Write(plaintext0)
+	failOnError(t, "Failed writing", err)
+	failOnError(t, "Failed closing", w.Close())
+	frame := buf.Bytes()
+
+	for _, tc := range []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"truncated", frame[:len(frame)-5], io.ErrUnexpectedEOF},
+		{"checksum", append(frame[:len(frame)-1:len(frame)-1], frame[len(frame)-1]^1), nil},
+	} {
+		r := NewFrameReader(bytes.NewReader(tc.data), WithDictionaryStore(store))
+		_, err := io.Copy(ioutil.Discard, r)
+		if err == nil || (tc.want != nil && err != tc.want) {
+			t.Errorf("%s: got %v", tc.name, err)
+		}
+		if _, again := r.Read(make([]byte, 10)); again != err {
+			t.Errorf("%s: got %v reading again, want %v", tc.name, again, err)
+		}
+		r.Close()
+	}
+}
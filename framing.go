@@ -0,0 +1,152 @@
+package lz4
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrFramingMismatch is returned by FramingProfile.Check when the peer uses a
+// different framing.
+var ErrFramingMismatch = errors.New("framing profile mismatch")
+
+// framingVersion prefixes the descriptors of FramingProfile, so that the
+// descriptor can evolve.
+const framingVersion = "lz4s1"
+
+// FramingProfile describes the framing of a block stream, to be agreed upon
+// by the producer and the consumer of a stream: the producer creates its
+// Writer with WithFramingProfile, sends the descriptor returned by String to
+// the consumer, which checks it against its own profile with Check, and
+// creates its DecompressReader with the same option.
+type FramingProfile struct {
+	// BlockSize is the maximum uncompressed size of the blocks, or 0 for
+	// the default of 64 KiB.
+	BlockSize int
+	// HeaderWidth is the size in bytes of the block headers, which are
+	// little endian, or 0 for the default of 4, as with WithHeaderWidth.
+	HeaderWidth int
+	// BlockChecksums precedes each block with its checksum, as with
+	// WithBlockChecksum.
+	BlockChecksums bool
+	// Trailer ends the stream with a trailer, as with WithTrailer. Readers
+	// then require it.
+	Trailer bool
+	// SyncInterval emits sync markers, as with WithSyncInterval, or none
+	// if 0.
+	SyncInterval int64
+}
+
+// normalize returns p with its defaults filled in.
+func (p FramingProfile) normalize() FramingProfile {
+	if p.BlockSize == 0 {
+		p.BlockSize = streamingBlockSize
+	}
+	if p.HeaderWidth == 0 {
+		p.HeaderWidth = blockHeaderSize
+	}
+	return p
+}
+
+// Validate returns an error if p cannot be used, for a block size out of
+// range or an unsupported header width.
+func (p FramingProfile) Validate() error {
+	p = p.normalize()
+	switch {
+	case p.BlockSize < 0 || p.BlockSize > MaxBlockSize:
+		return fmt.Errorf("invalid framing block size %d", p.BlockSize)
+	case p.HeaderWidth != HeaderWidth3 && p.HeaderWidth != HeaderWidth4 && p.HeaderWidth != HeaderWidth8:
+		return fmt.Errorf("unsupported framing header width %d", p.HeaderWidth)
+	case p.SyncInterval < 0:
+		return fmt.Errorf("invalid framing sync interval %d", p.SyncInterval)
+	}
+	return nil
+}
+
+// String returns the descriptor of p, such as
+// "lz4s1,bs=65536,hw=4,le,bc,tr,sync=1048576", which ParseFramingProfile
+// parses back. Equivalent profiles have the same descriptor.
+func (p FramingProfile) String() string {
+	p = p.normalize()
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s,bs=%d,hw=%d,le", framingVersion, p.BlockSize, p.HeaderWidth)
+	if p.BlockChecksums {
+		b.WriteString(",bc")
+	}
+	if p.Trailer {
+		b.WriteString(",tr")
+	}
+	if p.SyncInterval > 0 {
+		fmt.Fprintf(&b, ",sync=%d", p.SyncInterval)
+	}
+	return b.String()
+}
+
+// ParseFramingProfile parses a descriptor returned by FramingProfile.String,
+// and validates the profile.
+func ParseFramingProfile(desc string) (FramingProfile, error) {
+	fields := strings.Split(desc, ",")
+	if fields[0] != framingVersion {
+		return FramingProfile{}, fmt.Errorf("unknown framing descriptor %q", desc)
+	}
+	var p FramingProfile
+	for _, field := range fields[1:] {
+		key, value := field, ""
+		if i := strings.IndexByte(field, '='); i >= 0 {
+			key, value = field[:i], field[i+1:]
+		}
+		var err error
+		switch key {
+		case "bs":
+			p.BlockSize, err = strconv.Atoi(value)
+		case "hw":
+			p.HeaderWidth, err = strconv.Atoi(value)
+		case "le":
+		case "bc":
+			p.BlockChecksums = true
+		case "tr":
+			p.Trailer = true
+		case "sync":
+			p.SyncInterval, err = strconv.ParseInt(value, 10, 64)
+		default:
+			err = errors.New("unknown field")
+		}
+		if err != nil {
+			return FramingProfile{}, fmt.Errorf("invalid framing descriptor field %q: %v", field, err)
+		}
+	}
+	if err := p.Validate(); err != nil {
+		return FramingProfile{}, err
+	}
+	return p, nil
+}
+
+// Check parses desc, the descriptor of the profile of a peer, and returns an
+// error matching ErrFramingMismatch if it differs from p, or the error of
+// ParseFramingProfile.
+func (p FramingProfile) Check(desc string) error {
+	peer, err := ParseFramingProfile(desc)
+	if err != nil {
+		return err
+	}
+	if mine := p.String(); peer.String() != mine {
+		return fmt.Errorf("%w: %s, expected %s", ErrFramingMismatch, desc, mine)
+	}
+	return nil
+}
+
+// WithFramingProfile applies the framing of p to a Writer or
+// DecompressReader, which should be valid, as checked by Validate. Options
+// passed after it override its choices.
+func WithFramingProfile(p FramingProfile) Option {
+	return func(o *options) {
+		if p.BlockSize > 0 {
+			WithBlockSize(p.BlockSize)(o)
+		}
+		o.blockChecksum = p.BlockChecksums
+		o.trailer = p.Trailer
+		o.syncInterval = p.SyncInterval
+		WithHeaderWidth(p.HeaderWidth)(o)
+	}
+}
@@ -0,0 +1,56 @@
+package lz4
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestFramingProfile(t *testing.T) {
+	producer := FramingProfile{BlockSize: 10000, BlockChecksums: true, Trailer: true, SyncInterval: 50000}
+	desc := producer.String()
+	if want := "lz4s1,bs=10000,hw=4,le,bc,tr,sync=50000"; desc != want {
+		t.Fatalf("got descriptor %q, want %q", desc, want)
+	}
+	consumer, err := ParseFramingProfile(desc)
+	failOnError(t, "Failed parsing descriptor", err)
+	if consumer.String() != desc || consumer.BlockSize != producer.BlockSize {
+		t.Fatalf("parsed %+v, want %+v", consumer, producer)
+	}
+	failOnError(t, "Failed checking descriptor", consumer.Check(desc))
+	if err := (FramingProfile{}).Check(desc); !errors.Is(err, ErrFramingMismatch) {
+		t.Errorf("got error %v for a different profile", err)
+	}
+	if err := (FramingProfile{}).Check(FramingProfile{BlockSize: streamingBlockSize, HeaderWidth: 4}.String()); err != nil {
+		t.Errorf("defaults do not match: %v", err)
+	}
+	for _, bad := range []string{"", "lz4s2,bs=1", "lz4s1,bs=x", "lz4s1,hw=5", "lz4s1,be", "lz4s1,sync=-1"} {
+		if _, err := ParseFramingProfile(bad); err == nil {
+			t.Errorf("no error parsing %q", bad)
+		}
+	}
+
+	input := bytes.Repeat([]byte("framing drift "), 20000)
+	var buf bytes.Buffer
+	w := NewWriter(&buf, WithFramingProfile(producer))
+	_, err = w.Write(input)
+	failOnError(t, "Failed writing", err)
+	failOnError(t, "Failed closing", w.Close())
+	report, err := Inspect(bytes.NewReader(buf.Bytes()))
+	failOnError(t, "Failed inspecting", err)
+	if len(report.Blocks) != (len(input)+9999)/10000 || !report.BlockChecksums || !report.Trailer {
+		t.Errorf("stream written with %d blocks, checksums %v, trailer %v", len(report.Blocks), report.BlockChecksums, report.Trailer)
+	}
+	out, err := io.ReadAll(NewDecompressReader(bytes.NewReader(buf.Bytes()), WithFramingProfile(consumer)))
+	failOnError(t, "Failed reading", err)
+	if !bytes.Equal(out, input) {
+		t.Error("decompressed output != input")
+	}
+
+	// a consumer requiring a trailer rejects a stream without one
+	_, err = io.ReadAll(NewDecompressReader(bytes.NewReader(compressStream(t, input)), WithFramingProfile(consumer)))
+	if !errors.Is(err, ErrMissingTrailer) {
+		t.Errorf("got error %v reading a stream without trailer", err)
+	}
+}
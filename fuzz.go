@@ -0,0 +1,26 @@
+//go:build gofuzz
+// +build gofuzz
+
+package lz4
+
+// fuzz.go holds the targets for go-fuzz, built with its gofuzz tag, for
+// example with go-fuzz -func FuzzBlockHdr.
+
+// fuzzLimits keeps the memory used by each input small.
+var fuzzLimits = Limits{MaxSize: 1 << 20}
+
+// FuzzBlockHdr decodes data as a block with a length header.
+func FuzzBlockHdr(data []byte) int {
+	if _, err := DecodeUntrustedBlockHdr(data, fuzzLimits); err != nil {
+		return 0
+	}
+	return 1
+}
+
+// FuzzStream decodes data as a block stream.
+func FuzzStream(data []byte) int {
+	if _, err := DecodeUntrustedStream(data, fuzzLimits); err != nil {
+		return 0
+	}
+	return 1
+}
@@ -0,0 +1,146 @@
+package lz4
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// hadoop.go reads and writes the format of the Lz4Codec of Hadoop: chunks of
+// input, each preceded by its big endian uncompressed size, and made of one
+// or more blocks preceded by their big endian compressed size.
+
+const (
+	// hadoopChunkSize is the largest chunk written by Hadoop with its
+	// default buffer of 256 KiB, which leaves room for the compression
+	// overhead.
+	hadoopChunkSize = 256<<10 - (256<<10/255 + 16)
+	// maxHadoopChunkSize bounds the chunks read, since Hadoop can be
+	// configured with larger buffers.
+	maxHadoopChunkSize = 64 << 20
+)
+
+// hadoopReader decompresses the Hadoop format.
+type hadoopReader struct {
+	r      io.Reader
+	out    blockEngine
+	header [4]byte
+	in     []byte
+	buf    []byte
+}
+
+func newHadoopReader(r io.Reader) *hadoopReader {
+	hr := &hadoopReader{r: r}
+	hr.out.next = hr.nextChunk
+	return hr
+}
+
+func (r *hadoopReader) Read(dst []byte) (int, error) {
+	return r.out.read(dst)
+}
+
+func (r *hadoopReader) Close() error {
+	return nil
+}
+
+// readSize reads a big endian size.
+func (r *hadoopReader) readSize() (int, error) {
+	if _, err := io.ReadFull(r.r, r.header[:]); err != nil {
+		return 0, err
+	}
+	return int(binary.BigEndian.Uint32(r.header[:])), nil
+}
+
+// nextChunk decodes the blocks of the next chunk.
+func (r *hadoopReader) nextChunk() ([]byte, error) {
+	size, err := r.readSize()
+	if err != nil {
+		return nil, err
+	}
+	if size > maxHadoopChunkSize {
+		return nil, fmt.Errorf("hadoop chunk of %d bytes too large", size)
+	}
+	if cap(r.buf) < size {
+		r.buf = make([]byte, size)
+	}
+	out := r.buf[:size]
+	for pos := 0; pos < size; {
+		n, err := r.readSize()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		if n > compressBound(size-pos) {
+			return nil, fmt.Errorf("hadoop block of %d bytes too large", n)
+		}
+		if cap(r.in) < n {
+			r.in = make([]byte, n)
+		}
+		in := r.in[:n]
+		if _, err := io.ReadFull(r.r, in); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		m, err := Uncompress(out[pos:], in)
+		if err != nil {
+			return nil, err
+		}
+		pos += m
+	}
+	return out, nil
+}
+
+// hadoopWriter compresses its input into the Hadoop format, buffering each
+// chunk, which it writes as a single block.
+type hadoopWriter struct {
+	w   io.Writer
+	buf []byte
+	out []byte
+}
+
+func newHadoopWriter(w io.Writer) *hadoopWriter {
+	return &hadoopWriter{w: w}
+}
+
+func (w *hadoopWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), hadoopChunkSize-len(w.buf))
+		w.buf = append(w.buf, p[:n]...)
+		p = p[n:]
+		written += n
+		if len(w.buf) == hadoopChunkSize {
+			if err := w.flush(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// flush writes the buffered chunk, if any.
+func (w *hadoopWriter) flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	if w.out == nil {
+		w.out = make([]byte, 8+compressBound(hadoopChunkSize))
+	}
+	n, err := Compress(w.out[8:], w.buf)
+	if err != nil {
+		return err
+	}
+	binary.BigEndian.PutUint32(w.out, uint32(len(w.buf)))
+	binary.BigEndian.PutUint32(w.out[4:], uint32(n))
+	w.buf = w.buf[:0]
+	_, err = w.w.Write(w.out[:8+n])
+	return err
+}
+
+func (w *hadoopWriter) Close() error {
+	return w.flush()
+}
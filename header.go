@@ -30,15 +30,39 @@ func CompressHdr(out, in []byte) (count int, err error) {
 // can be more convenient to use if you are in a situation where you cannot
 // reuse buffers.
 func CompressAllocHdr(in []byte) (out []byte, err error) {
+	return CompressAllocHdrWith(in, makeSlice)
+}
+
+// CompressFastHdr is like CompressHdr, but compresses with CompressFast at the
+// given acceleration.
+func CompressFastHdr(out, in []byte, acceleration int) (count int, err error) {
+	count, err = CompressFast(out[4:], in, acceleration)
+	binary.LittleEndian.PutUint32(out, uint32(len(in)))
+	return count + 4, err
+}
+
+// CompressFastAllocHdr is like CompressAllocHdr, but compresses with
+// CompressFast at the given acceleration.
+func CompressFastAllocHdr(in []byte, acceleration int) (out []byte, err error) {
 	out = make([]byte, CompressBoundHdr(in))
-	count, err := CompressHdr(out, in)
+	count, err := CompressFastHdr(out, in, acceleration)
 	if err != nil {
 		return out, err
 	}
 	return out[:count], nil
 }
 
-var errTooShort = errors.New("input too short to contain a length header")
+var (
+	errTooShort = errors.New("input too short to contain a length header")
+	errHdrSize  = errors.New("length header larger than the compressed data allows")
+)
+
+// maxUncompressedSize returns the largest size n bytes of compressed data can
+// decompress to, since LZ4 expands each byte to at most 255 bytes. Larger
+// sizes in a length header are corrupt and must not size a buffer.
+func maxUncompressedSize(n int) uint64 {
+	return uint64(n)*255 + 16
+}
 
 // UncompressHdr uncompresses in into out.  Out must have enough space allocated
 // for the uncompressed message.
@@ -56,12 +80,16 @@ func UncompressHdr(out, in []byte) error {
 // necessary for the result message, which CloudFlare's implementation doesn't
 // have.
 func UncompressAllocHdr(out, in []byte) ([]byte, error) {
+	return uncompressAllocHdr(out, in, makeSlice)
+}
+
+func uncompressAllocHdr(out, in []byte, alloc Allocator) ([]byte, error) {
 	if len(in) < 4 {
 		return out, errTooShort
 	}
 	origlen := binary.LittleEndian.Uint32(in)
 	if origlen > uint32(len(out)) {
-		out = make([]byte, origlen)
+		out = alloc(int(origlen))
 	}
 	_, err := Uncompress(out, in[4:])
 	return out, err
@@ -0,0 +1,92 @@
+package lz4
+
+// header16.go contains variants of the routines of header.go with a 2-byte
+// length header, for messages under 64 KiB.
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// MaxHdr16Size is the largest input the Hdr16 routines can compress.
+const MaxHdr16Size = 1<<16 - 1
+
+var (
+	errTooLargeHdr16 = errors.New("input too large for a 2-byte length header")
+	errTooShortHdr16 = errors.New("input too short to contain a 2-byte length header")
+	errSizeHdr16     = errors.New("decompressed size does not match the length header")
+)
+
+// CompressBoundHdr16 returns the upper bound of the size of the compressed
+// in, plus space for a 2-byte length header.
+func CompressBoundHdr16(in []byte) int {
+	return CompressBound(in) + 2
+}
+
+// CompressHdr16 is like CompressHdr, but the length header is a 2-byte little
+// endian integer, so in must not be larger than MaxHdr16Size.
+func CompressHdr16(out, in []byte) (count int, err error) {
+	if len(in) > MaxHdr16Size {
+		return 0, errTooLargeHdr16
+	}
+	if len(out) < 2 {
+		return 0, errors.New("Insufficient space for compression")
+	}
+	count, err = Compress(out[2:], in)
+	binary.LittleEndian.PutUint16(out, uint16(len(in)))
+	return count + 2, err
+}
+
+// CompressAllocHdr16 is like CompressHdr16, but allocates the out slice
+// itself.
+func CompressAllocHdr16(in []byte) (out []byte, err error) {
+	if len(in) > MaxHdr16Size {
+		return nil, errTooLargeHdr16
+	}
+	out = make([]byte, CompressBoundHdr16(in))
+	count, err := CompressHdr16(out, in)
+	if err != nil {
+		return out, err
+	}
+	return out[:count], nil
+}
+
+// UncompressHdr16 uncompresses in, as written by CompressHdr16, into out,
+// which must be large enough for the uncompressed message. It is an error if
+// the message does not decompress to the size in its header.
+func UncompressHdr16(out, in []byte) error {
+	_, err := uncompressHdr16(out, in)
+	return err
+}
+
+// UncompressAllocHdr16 is like UncompressAllocHdr for messages written by
+// CompressHdr16: out is used if it is large enough, otherwise a new slice is
+// allocated. The returned slice has exactly the size in the header.
+func UncompressAllocHdr16(out, in []byte) ([]byte, error) {
+	if len(in) < 2 {
+		return out, errTooShortHdr16
+	}
+	origlen := int(binary.LittleEndian.Uint16(in))
+	if origlen > len(out) {
+		out = make([]byte, origlen)
+	}
+	return uncompressHdr16(out[:origlen], in)
+}
+
+func uncompressHdr16(out, in []byte) ([]byte, error) {
+	if len(in) < 2 {
+		return out, errTooShortHdr16
+	}
+	origlen := int(binary.LittleEndian.Uint16(in))
+	if origlen > len(out) {
+		return out, errors.New("output too small for the uncompressed message")
+	}
+	n, err := Uncompress(out[:origlen], in[2:])
+	if err != nil {
+		return out, err
+	}
+	if n != origlen {
+		return out, errSizeHdr16
+	}
+	return out[:origlen], nil
+}
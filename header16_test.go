@@ -0,0 +1,55 @@
+package lz4
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCompressHdr16RoundTrip(t *testing.T) {
+	for _, size := range []int{0, 1, 1000, MaxHdr16Size} {
+		input := bytes.Repeat([]byte{'a', 'b', 'c'}, size/3+1)[:size]
+		compressed, err := CompressAllocHdr16(input)
+		failOnError(t, "Failed compressing", err)
+
+		out, err := UncompressAllocHdr16(nil, compressed)
+		failOnError(t, "Failed decompressing", err)
+		if !bytes.Equal(out, input) {
+			t.Fatalf("size %d: uncompressed != input", size)
+		}
+
+		buf := make([]byte, size+10)
+		failOnError(t, "Failed decompressing", UncompressHdr16(buf, compressed))
+		if !bytes.Equal(buf[:size], input) {
+			t.Fatalf("size %d: uncompressed != input", size)
+		}
+	}
+}
+
+func TestCompressHdr16Validation(t *testing.T) {
+	if _, err := CompressAllocHdr16(make([]byte, MaxHdr16Size+1)); err != errTooLargeHdr16 {
+		t.Fatalf("got %v for an oversized input", err)
+	}
+	if _, err := CompressHdr16(make([]byte, 100), make([]byte, MaxHdr16Size+1)); err != errTooLargeHdr16 {
+		t.Fatalf("got %v for an oversized input", err)
+	}
+	if _, err := UncompressAllocHdr16(nil, []byte{1}); err != errTooShortHdr16 {
+		t.Fatalf("got %v for a short input", err)
+	}
+
+	compressed, err := CompressAllocHdr16(plaintext0)
+	failOnError(t, "Failed compressing", err)
+	if err := UncompressHdr16(make([]byte, len(plaintext0)-1), compressed); err == nil {
+		t.Fatal("no error for a short output")
+	}
+	// a header claiming fewer bytes than the message holds
+	bad := append([]byte(nil), compressed...)
+	bad[0]--
+	if _, err := UncompressAllocHdr16(nil, bad); err == nil {
+		t.Fatal("no error for a wrong length header")
+	}
+	// a header claiming more bytes than the message holds
+	bad[0] += 2
+	if _, err := UncompressAllocHdr16(nil, bad); err != errSizeHdr16 {
+		t.Fatalf("got %v for a wrong length header", err)
+	}
+}
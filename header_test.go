@@ -128,6 +128,34 @@ func TestCompressAllocHdr(t *testing.T) {
 	}
 }
 
+func TestCompressFastHdr(t *testing.T) {
+	input, err := ioutil.ReadFile(sampleFilePath)
+	failOnError(t, "Failed reading sample", err)
+
+	var sizes []int
+	for _, acceleration := range []int{0, 1, 8, 64} {
+		compressed, err := CompressFastAllocHdr(input, acceleration)
+		failOnError(t, "Failed compressing", err)
+		sizes = append(sizes, len(compressed))
+
+		uncompressed, err := UncompressAllocHdr(nil, compressed)
+		failOnError(t, "Failed decompressing", err)
+		if !bytes.Equal(uncompressed, input) {
+			t.Fatalf("acceleration %d: uncompressed != input", acceleration)
+		}
+	}
+	if sizes[0] != sizes[1] || sizes[3] <= sizes[1] {
+		t.Errorf("unexpected compressed sizes for increasing accelerations: %v", sizes)
+	}
+
+	out := make([]byte, CompressBoundHdr(input))
+	n, err := CompressFastHdr(out, input, 8)
+	failOnError(t, "Failed compressing", err)
+	if n != sizes[2] {
+		t.Errorf("CompressFastHdr wrote %d bytes, CompressFastAllocHdr %d", n, sizes[2])
+	}
+}
+
 // test python interoperability
 
 // pymod returns whether or not a python module is importable.  For checking
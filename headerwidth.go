@@ -0,0 +1,181 @@
+package lz4
+
+// headerwidth.go lets the records of a block stream start with 3-byte or
+// 8-byte headers instead of the default 4-byte ones, for systems expecting
+// them. A stream with another width starts with a preamble, a header width
+// record in the default format, after which every header has the new width.
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Header widths accepted by WithHeaderWidth.
+const (
+	// HeaderWidth3 uses 3-byte headers. Bit 23 is the control flag; blocks
+	// store their size in bits 0 to 22, and control records their type in
+	// bits 17 to 22 and the length of their payload in bits 0 to 16.
+	HeaderWidth3 = 3
+	// HeaderWidth4 uses the default 4-byte headers.
+	HeaderWidth4 = blockHeaderSize
+	// HeaderWidth8 uses 8-byte headers. Bit 63 is the control flag; blocks
+	// store their size in the low bits, and control records have the
+	// layout of the default header in bits 0 to 30.
+	HeaderWidth8 = 8
+
+	maxHeaderWidth = HeaderWidth8
+
+	// recordHeaderWidth is the preamble of streams with another header
+	// width. Its payload is the width as one byte.
+	recordHeaderWidth  = 7
+	headerWidthPayload = 1
+
+	width3Flag       = 1 << 23
+	width3TypeShift  = 17
+	width3TypeMask   = 0x3f
+	width3LengthMask = 1<<width3TypeShift - 1
+)
+
+var (
+	errBadHeaderWidth = errors.New("malformed header width record")
+	// errDefaultWidthOnly is returned by the functions that only support
+	// the default header width.
+	errDefaultWidthOnly = errors.New("only the default header width is supported")
+)
+
+// WithHeaderWidth makes a Writer start the records of the stream with headers
+// of width bytes, one of HeaderWidth3, HeaderWidth4 and HeaderWidth8. Other
+// widths are ignored. The width is recorded in a preamble at the start of the
+// stream, from which DecompressReader learns it, so the option has no effect
+// on readers. Unlike streams with the default width, such streams cannot be
+// concatenated, since the preamble of the second stream would be read with
+// the width of the first. ChunkWriter, CompressParallel, CompressDir,
+// BuildIndex, SeekReader, DecompressParallel, ScanBlocks, OpenAppend and
+// NewAppendWriter return an error for other widths, the archive package always
+// writes the default width, and NextSyncPoint does not find their sync
+// markers, although WithRecovery resumes at them.
+func WithHeaderWidth(width int) Option {
+	return func(o *options) {
+		switch width {
+		case HeaderWidth3, HeaderWidth4, HeaderWidth8:
+			o.headerWidth = width
+		}
+	}
+}
+
+// writeRecord writes record, which starts with a header in the default
+// format, converting the header to the width of w. The first record is
+// preceded by the preamble. compressedWritten is adjusted for the difference,
+// so callers count the record with its default header.
+func (w *Writer) writeRecord(record []byte) error {
+	if w.headerWidth == blockHeaderSize {
+		return w.writeUnderlying(record)
+	}
+	if err := w.writePreamble(); err != nil {
+		return err
+	}
+	var header [maxHeaderWidth]byte
+	n := putHeader(header[:], binary.LittleEndian.Uint32(record), w.headerWidth)
+	if err := w.writeUnderlying(header[:n]); err != nil {
+		return err
+	}
+	if len(record) > blockHeaderSize {
+		if err := w.writeUnderlying(record[blockHeaderSize:]); err != nil {
+			return err
+		}
+	}
+	w.compressedWritten += int64(n - blockHeaderSize)
+	return nil
+}
+
+// writePreamble writes the header width record starting the stream, if not
+// done yet.
+func (w *Writer) writePreamble() error {
+	if !w.preamblePending {
+		return nil
+	}
+	var preamble [blockHeaderSize + headerWidthPayload]byte
+	binary.LittleEndian.PutUint32(preamble[:], controlHeader(recordHeaderWidth, headerWidthPayload))
+	preamble[blockHeaderSize] = byte(w.headerWidth)
+	if err := w.writeUnderlying(preamble[:]); err != nil {
+		return err
+	}
+	w.preamblePending = false
+	w.compressedWritten += int64(len(preamble))
+	return nil
+}
+
+// putHeader stores the default format header h in b with the given width and
+// returns the width.
+func putHeader(b []byte, h uint32, width int) int {
+	switch width {
+	case HeaderWidth3:
+		v := h
+		if h&controlFlag != 0 {
+			typ, length := parseControlHeader(h)
+			v = width3Flag | uint32(typ)<<width3TypeShift | uint32(length)
+		}
+		b[0], b[1], b[2] = byte(v), byte(v>>8), byte(v>>16)
+	case HeaderWidth8:
+		v := uint64(h)
+		if h&controlFlag != 0 {
+			v = 1<<63 | uint64(h&^controlFlag)
+		}
+		binary.LittleEndian.PutUint64(b, v)
+	default:
+		binary.LittleEndian.PutUint32(b, h)
+	}
+	return width
+}
+
+// parseHeader returns the header of the given width in b in the default
+// format.
+func parseHeader(b []byte, width int) (uint32, error) {
+	switch width {
+	case HeaderWidth3:
+		v := uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+		if v&width3Flag == 0 {
+			return v, nil
+		}
+		return controlHeader(int(v>>width3TypeShift)&width3TypeMask, int(v&width3LengthMask)), nil
+	case HeaderWidth8:
+		v := binary.LittleEndian.Uint64(b)
+		if v&(1<<63) != 0 {
+			v &^= 1 << 63
+			if v >= controlFlag {
+				return 0, &corruptionError{errors.New("invalid control record header")}
+			}
+			return controlFlag | uint32(v), nil
+		}
+		if v >= controlFlag {
+			return 0, &corruptionError{errors.New("invalid block size")}
+		}
+		return uint32(v), nil
+	}
+	return binary.LittleEndian.Uint32(b), nil
+}
+
+// checkDefaultWidth returns errDefaultWidthOnly if o selects another header
+// width than the default.
+func checkDefaultWidth(o options) error {
+	if o.headerWidth != 0 && o.headerWidth != blockHeaderSize {
+		return errDefaultWidthOnly
+	}
+	return nil
+}
+
+// readHeaderWidth applies the payload of a header width record.
+func (r *DecompressReader) readHeaderWidth(payload []byte) error {
+	if len(payload) != headerWidthPayload {
+		return &corruptionError{errBadHeaderWidth}
+	}
+	switch width := int(payload[0]); width {
+	case HeaderWidth3, HeaderWidth4, HeaderWidth8:
+		if r.defaultWidthOnly && width != blockHeaderSize {
+			return errDefaultWidthOnly
+		}
+		r.headerWidth = width
+		return nil
+	}
+	return &corruptionError{errBadHeaderWidth}
+}
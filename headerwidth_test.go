@@ -0,0 +1,151 @@
+package lz4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"testing"
+)
+
+func TestHeaderWidth(t *testing.T) {
+	input := bytes.Repeat([]byte("header width "), 30000)
+	for _, width := range []int{HeaderWidth3, HeaderWidth4, HeaderWidth8} {
+		var buf bytes.Buffer
+		w := NewWriter(&buf, WithHeaderWidth(width), WithSyncInterval(100000),
+			WithBlockChecksum(), WithTrailer())
+		failOnError(t, "Failed setting metadata", w.SetBlockMetadata(BlockMetadata{Data: make([]byte, MaxMetadataLength)}))
+		_, err := w.Write(input)
+		failOnError(t, "Failed writing", err)
+		failOnError(t, "Failed closing", w.Close())
+		if w.CompressedBytesWritten() != int64(buf.Len()) {
+			t.Errorf("width %d: CompressedBytesWritten = %d, want %d", width, w.CompressedBytesWritten(), buf.Len())
+		}
+
+		stream := buf.Bytes()
+		if width != HeaderWidth4 {
+			preamble := binary.LittleEndian.Uint32(stream)
+			if typ, length := parseControlHeader(preamble); preamble&controlFlag == 0 ||
+				typ != recordHeaderWidth || length != 1 || stream[4] != byte(width) {
+				t.Fatalf("width %d: bad preamble % x", width, stream[:5])
+			}
+			stream = stream[5:]
+		}
+		// the first record is the metadata of the first block
+		header, err := parseHeader(stream, width)
+		failOnError(t, "Failed parsing header", err)
+		if typ, length := parseControlHeader(header); header&controlFlag == 0 ||
+			typ != recordMetadata || length != 1+MaxMetadataLength {
+			t.Errorf("width %d: first header %#x, want metadata", width, header)
+		}
+
+		var metadata int
+		r := NewDecompressReader(bytes.NewReader(buf.Bytes()), WithBlockChecksum(), WithTrailer(),
+			WithMetadata(func(m BlockMetadata) { metadata++ }))
+		out, err := ioutil.ReadAll(r)
+		failOnError(t, "Failed decompressing", err)
+		failOnError(t, "Failed closing reader", r.Close())
+		if !bytes.Equal(out, input) {
+			t.Fatalf("width %d: decompressed output != input", width)
+		}
+		if metadata != 1 {
+			t.Errorf("width %d: got %d metadata records, want 1", width, metadata)
+		}
+
+		report, err := Inspect(bytes.NewReader(buf.Bytes()))
+		failOnError(t, "Failed inspecting", err)
+		if report.CompressedSize != int64(buf.Len()) {
+			t.Errorf("width %d: inspected %d compressed bytes, want %d", width, report.CompressedSize, buf.Len())
+		}
+	}
+}
+
+func TestHeaderWidthInvalid(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, WithHeaderWidth(5))
+	_, err := w.Write(plaintext0)
+	failOnError(t, "Failed writing", err)
+	failOnError(t, "Failed closing", w.Close())
+	if size := binary.LittleEndian.Uint32(buf.Bytes()); size&controlFlag != 0 {
+		t.Errorf("invalid width wrote a preamble")
+	}
+
+	// block sizes of 8-byte headers must fit the default header
+	var header [HeaderWidth8]byte
+	binary.LittleEndian.PutUint64(header[:], 1<<40)
+	if _, err := parseHeader(header[:], HeaderWidth8); err == nil {
+		t.Errorf("no error for a huge block size")
+	}
+	stream := []byte{0x01, 0x00, 0x00, 0x87, 0x05}
+	if _, err := ioutil.ReadAll(NewDecompressReader(bytes.NewReader(stream))); err == nil {
+		t.Errorf("no error for an unsupported width")
+	}
+}
+
+func TestHeaderWidthUnsupported(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, WithHeaderWidth(HeaderWidth3))
+	_, err := w.Write(plaintext0)
+	failOnError(t, "Failed writing", err)
+	failOnError(t, "Failed closing", w.Close())
+
+	if _, err := BuildIndex(bytes.NewReader(buf.Bytes()), 0); err == nil {
+		t.Errorf("BuildIndex: no error")
+	}
+	sr := NewSeekReader(bytes.NewReader(buf.Bytes()), &Index{})
+	if _, err := ioutil.ReadAll(sr); err == nil {
+		t.Errorf("SeekReader: no error")
+	}
+	sr.Close()
+	if _, err := CompressParallel(ioutil.Discard, bytes.NewReader(plaintext0), 0, WithHeaderWidth(HeaderWidth8)); err == nil {
+		t.Errorf("CompressParallel: no error")
+	}
+	c := NewChunkWriter(1<<20, false, func([]byte) error { return nil }, WithHeaderWidth(HeaderWidth8))
+	if _, err := c.Write(plaintext0); err == nil {
+		t.Errorf("ChunkWriter: no error")
+	}
+	if err := c.Close(); err == nil {
+		t.Errorf("ChunkWriter: no error closing")
+	}
+}
+
+func TestHeaderWidthRecovery(t *testing.T) {
+	input, err := ioutil.ReadFile(sampleFilePath)
+	failOnError(t, "Failed reading sample", err)
+	for len(input) < 8*streamingBlockSize {
+		input = append(input, input...)
+	}
+	input = input[:8*streamingBlockSize]
+
+	for _, width := range []int{HeaderWidth3, HeaderWidth8} {
+		var compressed bytes.Buffer
+		w := NewWriter(&compressed, WithHeaderWidth(width), WithSyncInterval(2*streamingBlockSize))
+		_, err = w.Write(input)
+		failOnError(t, "Failed writing", err)
+		failOnError(t, "Failed closing", w.Close())
+
+		// corrupt the header of the second block, after the preamble
+		data := compressed.Bytes()
+		first := blockHeaderSize + headerWidthPayload
+		header, err := parseHeader(data[first:], width)
+		failOnError(t, "Failed parsing header", err)
+		second := first + width + int(header)
+		for i := 0; i < width; i++ {
+			data[second+i] = 0x7f
+		}
+
+		var skipped []SkippedRange
+		r := NewDecompressReader(bytes.NewReader(data), WithRecovery(func(sr SkippedRange) {
+			skipped = append(skipped, sr)
+		}))
+		out, err := ioutil.ReadAll(r)
+		failOnError(t, "Failed decompressing", err)
+		failOnError(t, "Failed closing reader", r.Close())
+		if len(skipped) != 1 || skipped[0].Offset != int64(second) {
+			t.Fatalf("width %d: unexpected skipped ranges %+v", width, skipped)
+		}
+		expected := append(append([]byte(nil), input[:streamingBlockSize]...), input[2*streamingBlockSize:]...)
+		if !bytes.Equal(out, expected) {
+			t.Fatalf("width %d: decompressed output does not match input with the corrupt blocks removed", width)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+// Package httplz4 compresses HTTP response bodies with lz4.
+//
+// Bodies are written as an lz4 block stream, as produced by lz4.NewWriter, and
+// can be read with lz4.NewDecompressReader.
+package httplz4
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	lz4 "github.com/DataDog/golz4"
+)
+
+// Encoding is the Content-Encoding set on compressed responses.
+const Encoding = "x-lz4-stream"
+
+// ResponseWriter is an http.ResponseWriter compressing the body written to it.
+// It implements http.Flusher, http.Hijacker and io.ReaderFrom only if the
+// wrapped http.ResponseWriter does, so type assertions on it give the same
+// results as on the wrapped value. Close must be called when the response is
+// complete.
+type ResponseWriter interface {
+	http.ResponseWriter
+	io.Closer
+}
+
+// NewResponseWriter returns a ResponseWriter compressing the body written to
+// it and writing it to w. It sets the Content-Encoding header and removes any
+// Content-Length header when the response header is written. Responses that
+// already have a Content-Encoding, or whose status does not allow a body, are
+// written uncompressed.
+func NewResponseWriter(w http.ResponseWriter, opts ...lz4.Option) ResponseWriter {
+	rw := &responseWriter{ResponseWriter: w, opts: opts}
+	_, f := w.(http.Flusher)
+	_, h := w.(http.Hijacker)
+	_, rf := w.(io.ReaderFrom)
+	switch {
+	case f && h && rf:
+		return struct {
+			*responseWriter
+			flushWriter
+			hijackWriter
+			readFromWriter
+		}{rw, flushWriter{rw}, hijackWriter{rw}, readFromWriter{rw}}
+	case f && h:
+		return struct {
+			*responseWriter
+			flushWriter
+			hijackWriter
+		}{rw, flushWriter{rw}, hijackWriter{rw}}
+	case f && rf:
+		return struct {
+			*responseWriter
+			flushWriter
+			readFromWriter
+		}{rw, flushWriter{rw}, readFromWriter{rw}}
+	case h && rf:
+		return struct {
+			*responseWriter
+			hijackWriter
+			readFromWriter
+		}{rw, hijackWriter{rw}, readFromWriter{rw}}
+	case f:
+		return struct {
+			*responseWriter
+			flushWriter
+		}{rw, flushWriter{rw}}
+	case h:
+		return struct {
+			*responseWriter
+			hijackWriter
+		}{rw, hijackWriter{rw}}
+	case rf:
+		return struct {
+			*responseWriter
+			readFromWriter
+		}{rw, readFromWriter{rw}}
+	}
+	return rw
+}
+
+// Handler returns an http.Handler compressing the responses of h for requests
+// accepting Encoding. Responses to HEAD requests are not compressed.
+func Handler(h http.Handler, opts ...lz4.Option) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if r.Method == http.MethodHead || !acceptsEncoding(r) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		rw := NewResponseWriter(w, opts...)
+		defer rw.Close()
+		h.ServeHTTP(rw, r)
+	})
+}
+
+// acceptsEncoding reports whether the Accept-Encoding header of r lists
+// Encoding.
+func acceptsEncoding(r *http.Request) bool {
+	for _, v := range r.Header.Values("Accept-Encoding") {
+		for _, coding := range strings.Split(v, ",") {
+			if i := strings.IndexByte(coding, ';'); i >= 0 {
+				coding = coding[:i]
+			}
+			if strings.TrimSpace(coding) == Encoding {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type responseWriter struct {
+	http.ResponseWriter
+	opts        []lz4.Option
+	lw          *lz4.Writer
+	wroteHeader bool
+	hijacked    bool
+	passthrough bool
+}
+
+// bodyAllowed reports whether a response with the given status may have a
+// body.
+func bodyAllowed(statusCode int) bool {
+	switch {
+	case statusCode >= 100 && statusCode <= 199:
+		return false
+	case statusCode == http.StatusNoContent, statusCode == http.StatusNotModified:
+		return false
+	}
+	return true
+}
+
+func (w *responseWriter) WriteHeader(statusCode int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" || !bodyAllowed(statusCode) {
+		w.passthrough = true
+		w.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	h.Del("Content-Length")
+	h.Set("Content-Encoding", Encoding)
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	if w.hijacked {
+		return 0, http.ErrHijacked
+	}
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(p)
+	}
+	if w.lw == nil {
+		w.lw = lz4.NewWriter(w.ResponseWriter, w.opts...)
+	}
+	return w.lw.Write(p)
+}
+
+// Close releases the compressor. The body needs no trailer, so Close does not
+// write anything.
+func (w *responseWriter) Close() error {
+	if w.lw == nil {
+		return nil
+	}
+	return w.lw.Close()
+}
+
+type flushWriter struct {
+	w *responseWriter
+}
+
+// Flush sends the data compressed so far to the client.
+func (f flushWriter) Flush() {
+	if !f.w.wroteHeader {
+		f.w.WriteHeader(http.StatusOK)
+	}
+	if f.w.lw != nil {
+		f.w.lw.Flush()
+		return
+	}
+	f.w.ResponseWriter.(http.Flusher).Flush()
+}
+
+type hijackWriter struct {
+	w *responseWriter
+}
+
+// Hijack takes over the connection, as for the wrapped http.ResponseWriter.
+// Data written to the connection is not compressed.
+func (h hijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := h.w.ResponseWriter.(http.Hijacker).Hijack()
+	if err == nil {
+		h.w.hijacked = true
+	}
+	return conn, rw, err
+}
+
+type readFromWriter struct {
+	w *responseWriter
+}
+
+// ReadFrom compresses the data read from r into the response. The data is
+// read in large chunks, which compress better than small writes.
+func (rf readFromWriter) ReadFrom(r io.Reader) (int64, error) {
+	if rf.w.hijacked {
+		return 0, http.ErrHijacked
+	}
+	buf := make([]byte, 256*1024)
+	// hide ReadFrom from io.CopyBuffer, which would call it again
+	return io.CopyBuffer(struct{ io.Writer }{rf.w}, r, buf)
+}
@@ -0,0 +1,170 @@
+package httplz4
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	lz4 "github.com/DataDog/golz4"
+)
+
+var body = bytes.Repeat([]byte("hello, compressed world\n"), 10000)
+
+func decompress(t *testing.T, r io.Reader) []byte {
+	dr := lz4.NewDecompressReader(r)
+	defer dr.Close()
+	out, err := ioutil.ReadAll(dr)
+	if err != nil {
+		t.Fatalf("Failed decompressing: %s", err)
+	}
+	return out
+}
+
+func TestHandler(t *testing.T) {
+	h := Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1")
+		w.Write(body[:100])
+		io.Copy(w, bytes.NewReader(body[100:]))
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip, "+Encoding+";q=1")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if got := rec.Header().Get("Content-Encoding"); got != Encoding {
+		t.Fatalf("Content-Encoding = %q", got)
+	}
+	if got := rec.Header().Get("Content-Length"); got != "" {
+		t.Fatalf("Content-Length = %q", got)
+	}
+	if out := decompress(t, rec.Body); !bytes.Equal(out, body) {
+		t.Fatalf("Decompressed output != input")
+	}
+
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Header().Get("Content-Encoding") != "" || !bytes.Equal(rec.Body.Bytes(), body) {
+		t.Fatalf("response compressed without Accept-Encoding")
+	}
+}
+
+func TestHandlerPassthrough(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		method   string
+		status   int
+		encoding string
+		body     []byte
+	}{
+		{"encoded", "GET", http.StatusOK, "gzip", body},
+		{"no content", "GET", http.StatusNoContent, "", nil},
+		{"not modified", "GET", http.StatusNotModified, "", nil},
+		{"head", "HEAD", http.StatusOK, "", nil},
+	} {
+		h := Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if c.encoding != "" {
+				w.Header().Set("Content-Encoding", c.encoding)
+			}
+			w.Header().Set("Content-Length", "42")
+			w.WriteHeader(c.status)
+			w.Write(c.body)
+		}))
+
+		req := httptest.NewRequest(c.method, "/", nil)
+		req.Header.Set("Accept-Encoding", Encoding)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if got := rec.Header().Get("Content-Encoding"); got != c.encoding {
+			t.Fatalf("%s: Content-Encoding = %q, expected %q", c.name, got, c.encoding)
+		}
+		if got := rec.Header().Get("Content-Length"); got != "42" {
+			t.Fatalf("%s: Content-Length = %q", c.name, got)
+		}
+		if !bytes.Equal(rec.Body.Bytes(), c.body) {
+			t.Fatalf("%s: body was modified", c.name)
+		}
+	}
+}
+
+type plainWriter struct {
+	http.ResponseWriter
+}
+
+type hijackableRecorder struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+}
+
+func (h *hijackableRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h.hijacked = true
+	return nil, nil, nil
+}
+
+func TestResponseWriterInterfaces(t *testing.T) {
+	rec := httptest.NewRecorder()
+	for _, c := range []struct {
+		w                 http.ResponseWriter
+		flush, hijack, rf bool
+	}{
+		{rec, true, false, false},
+		{plainWriter{rec}, false, false, false},
+		{&hijackableRecorder{ResponseRecorder: rec}, true, true, false},
+		{struct {
+			http.ResponseWriter
+			io.ReaderFrom
+		}{rec, nil}, false, false, true},
+	} {
+		rw := NewResponseWriter(c.w)
+		_, flush := rw.(http.Flusher)
+		_, hijack := rw.(http.Hijacker)
+		_, rf := rw.(io.ReaderFrom)
+		if flush != c.flush || hijack != c.hijack || rf != c.rf {
+			t.Errorf("%T: got Flusher %v Hijacker %v ReaderFrom %v", c.w, flush, hijack, rf)
+		}
+		rw.Close()
+	}
+}
+
+func TestResponseWriterFlushAndHijack(t *testing.T) {
+	rec := &hijackableRecorder{ResponseRecorder: httptest.NewRecorder()}
+	rw := NewResponseWriter(rec)
+	defer rw.Close()
+	rw.Write([]byte("event: ping\n\n"))
+	rw.(http.Flusher).Flush()
+	if !rec.Flushed {
+		t.Fatalf("response was not flushed")
+	}
+	if out := decompress(t, bytes.NewReader(rec.Body.Bytes())); string(out) != "event: ping\n\n" {
+		t.Fatalf("got %q", out)
+	}
+
+	if _, _, err := rw.(http.Hijacker).Hijack(); err != nil || !rec.hijacked {
+		t.Fatalf("Hijack not passed through: %v", err)
+	}
+	if _, err := rw.Write([]byte("x")); err != http.ErrHijacked {
+		t.Fatalf("Write after Hijack returned %v", err)
+	}
+}
+
+func TestResponseWriterReadFrom(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rw := NewResponseWriter(struct {
+		http.ResponseWriter
+		io.ReaderFrom
+	}{rec, rec.Body})
+	n, err := rw.(io.ReaderFrom).ReadFrom(strings.NewReader(string(body)))
+	if err != nil || n != int64(len(body)) {
+		t.Fatalf("ReadFrom = %d, %v", n, err)
+	}
+	rw.Close()
+	if out := decompress(t, rec.Body); !bytes.Equal(out, body) {
+		t.Fatalf("Decompressed output != input")
+	}
+}
@@ -0,0 +1,285 @@
+package lz4
+
+// index.go builds seek indexes for existing block streams, as produced by
+// Writer or CompressReader. The index is stored separately from the
+// compressed data (a "sidecar" file), so files that cannot be rewritten can
+// still be read starting at arbitrary uncompressed offsets.
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// DefaultIndexSpan is the distance in uncompressed bytes between index points
+// used by BuildIndex when span is not positive.
+const DefaultIndexSpan = 1024 * 1024
+
+var indexMagic = [4]byte{'L', 'Z', '4', 'X'}
+
+const indexVersion = 1
+
+// IndexPoint is a position in a block stream where decoding can start.
+type IndexPoint struct {
+	// CompressedOffset is the offset of the block header in the compressed
+	// stream.
+	CompressedOffset int64
+	// UncompressedOffset is the offset of the first byte of the block in the
+	// uncompressed data.
+	UncompressedOffset int64
+	// Window holds up to 64 KiB of uncompressed data preceding the block. It
+	// is needed to decode the block, which may refer to earlier data.
+	Window []byte
+}
+
+// Index maps uncompressed offsets to positions in a compressed block stream.
+type Index struct {
+	Points           []IndexPoint
+	CompressedSize   int64
+	UncompressedSize int64
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// BuildIndex scans the block stream in r and returns an index with a point
+// at the start of the first block beginning at least span uncompressed bytes
+// after the previous point. If span is not positive, DefaultIndexSpan is used.
+// The whole stream is decompressed to build the index.
+func BuildIndex(r io.Reader, span int64) (*Index, error) {
+	if span <= 0 {
+		span = DefaultIndexSpan
+	}
+
+	cr := &countingReader{r: r}
+	dr := newDecompressReader(cr, explicitOptions(), nil)
+	dr.defaultWidthOnly = true
+	defer dr.Close()
+
+	idx := &Index{}
+	block := make([]byte, hugeStreamingBlockSize)
+	window := make([]byte, 0, streamingBlockSize)
+	var uncompressed int64
+	lastPoint := -span
+	for {
+		compressedOffset := cr.n
+		// block is large enough for any block, so each Read consumes
+		// exactly one block from the underlying reader
+		n, err := dr.Read(block)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("block at offset %d: %s", compressedOffset, err)
+		}
+
+		if uncompressed-lastPoint >= span {
+			idx.Points = append(idx.Points, IndexPoint{
+				CompressedOffset:   compressedOffset,
+				UncompressedOffset: uncompressed,
+				Window:             append([]byte(nil), window...),
+			})
+			lastPoint = uncompressed
+		}
+
+		uncompressed += int64(n)
+		window = append(window, block[:n]...)
+		if len(window) > streamingBlockSize {
+			window = window[:copy(window, window[len(window)-streamingBlockSize:])]
+		}
+	}
+
+	idx.CompressedSize = cr.n
+	idx.UncompressedSize = uncompressed
+	return idx, nil
+}
+
+// WriteTo writes the index to w in a compact binary form that can be read
+// back with ReadIndex. Windows are stored compressed.
+func (idx *Index) WriteTo(w io.Writer) (int64, error) {
+	var tmp [binary.MaxVarintLen64]byte
+	buf := make([]byte, 0, 64)
+	putUvarint := func(v uint64) {
+		buf = append(buf, tmp[:binary.PutUvarint(tmp[:], v)]...)
+	}
+
+	buf = append(buf, indexMagic[:]...)
+	buf = append(buf, indexVersion)
+	putUvarint(uint64(idx.CompressedSize))
+	putUvarint(uint64(idx.UncompressedSize))
+	putUvarint(uint64(len(idx.Points)))
+	for _, pt := range idx.Points {
+		window, err := CompressAllocHdr(pt.Window)
+		if err != nil {
+			return 0, err
+		}
+		putUvarint(uint64(pt.CompressedOffset))
+		putUvarint(uint64(pt.UncompressedOffset))
+		putUvarint(uint64(len(window)))
+		buf = append(buf, window...)
+	}
+	n, err := w.Write(buf)
+	return int64(n), err
+}
+
+var errBadIndex = errors.New("malformed index")
+
+// ReadIndex reads an index written by Index.WriteTo.
+func ReadIndex(r io.Reader) (*Index, error) {
+	br := bufio.NewReader(r)
+	var magic [5]byte
+	if _, err := io.ReadFull(br, magic[:]); err != nil {
+		return nil, err
+	}
+	if [4]byte{magic[0], magic[1], magic[2], magic[3]} != indexMagic || magic[4] != indexVersion {
+		return nil, errBadIndex
+	}
+
+	var fields [3]uint64
+	for i := range fields {
+		v, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, errBadIndex
+		}
+		fields[i] = v
+	}
+	idx := &Index{
+		CompressedSize:   int64(fields[0]),
+		UncompressedSize: int64(fields[1]),
+	}
+	count := fields[2]
+	for i := uint64(0); i < count; i++ {
+		var pt [3]uint64
+		for j := range pt {
+			v, err := binary.ReadUvarint(br)
+			if err != nil {
+				return nil, errBadIndex
+			}
+			pt[j] = v
+		}
+		if pt[2] > boundedStreamingBlockSize+4 {
+			return nil, errBadIndex
+		}
+		compressed := make([]byte, pt[2])
+		if _, err := io.ReadFull(br, compressed); err != nil {
+			return nil, errBadIndex
+		}
+		// check the size of the window before allocating it
+		if len(compressed) < 4 || binary.LittleEndian.Uint32(compressed) > streamingBlockSize {
+			return nil, errBadIndex
+		}
+		window, err := UncompressAllocHdr(nil, compressed)
+		if err != nil {
+			return nil, errBadIndex
+		}
+		idx.Points = append(idx.Points, IndexPoint{
+			CompressedOffset:   int64(pt[0]),
+			UncompressedOffset: int64(pt[1]),
+			Window:             window,
+		})
+	}
+	return idx, nil
+}
+
+// SeekReader decompresses a block stream and supports seeking within the
+// uncompressed data using an Index.
+type SeekReader struct {
+	rs      io.ReadSeeker
+	idx     *Index
+	dr      *DecompressReader
+	pos     int64
+	seeking bool
+}
+
+// NewSeekReader returns a SeekReader that reads the block stream from rs using
+// idx to find where to start decoding after a call to Seek. It is the caller's
+// responsibility to call Close on the SeekReader when done.
+func NewSeekReader(rs io.ReadSeeker, idx *Index) *SeekReader {
+	dr := newDecompressReader(rs, explicitOptions(), nil)
+	dr.defaultWidthOnly = true
+	return &SeekReader{
+		rs:      rs,
+		idx:     idx,
+		dr:      dr,
+		seeking: true,
+	}
+}
+
+// Read decompresses data into dst, starting at the current offset.
+func (r *SeekReader) Read(dst []byte) (int, error) {
+	if r.seeking {
+		if err := r.seek(); err != nil {
+			return 0, err
+		}
+		r.seeking = false
+	}
+	n, err := r.dr.Read(dst)
+	r.pos += int64(n)
+	return n, err
+}
+
+// seek positions the decoder at the index point preceding r.pos and discards
+// the data up to r.pos.
+func (r *SeekReader) seek() error {
+	points := r.idx.Points
+	i := sort.Search(len(points), func(i int) bool {
+		return points[i].UncompressedOffset > r.pos
+	}) - 1
+
+	var pt IndexPoint
+	if i >= 0 {
+		pt = points[i]
+	}
+	if _, err := r.rs.Seek(pt.CompressedOffset, io.SeekStart); err != nil {
+		return err
+	}
+	if err := r.dr.resetWithDict(r.rs, pt.Window); err != nil {
+		return err
+	}
+	_, err := io.CopyN(io.Discard, r.dr, r.pos-pt.UncompressedOffset)
+	if err == io.EOF {
+		// seeking past the end is allowed; the next Read returns EOF
+		err = nil
+	}
+	return err
+}
+
+// Seek sets the offset in the uncompressed data for the next Read. The
+// decompression work is deferred until the next Read.
+func (r *SeekReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.pos
+	case io.SeekEnd:
+		offset += r.idx.UncompressedSize
+	default:
+		return r.pos, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return r.pos, errors.New("negative position")
+	}
+	if offset != r.pos || r.seeking {
+		r.pos = offset
+		r.seeking = true
+	}
+	return offset, nil
+}
+
+// Close releases all the resources occupied by r. It does not close the
+// underlying io.ReadSeeker.
+func (r *SeekReader) Close() error {
+	return r.dr.Close()
+}
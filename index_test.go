@@ -0,0 +1,110 @@
+package lz4
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"runtime"
+	"testing"
+)
+
+func TestSeekReader(t *testing.T) {
+	input, err := ioutil.ReadFile(sampleFilePath)
+	failOnError(t, "Failed reading sample", err)
+	for len(input) < 5*streamingBlockSize {
+		input = append(input, input...)
+	}
+
+	var compressed bytes.Buffer
+	w := NewWriter(&compressed)
+	_, err = w.Write(input)
+	failOnError(t, "Failed writing to compress object", err)
+	failOnError(t, "Failed closing writer", w.Close())
+
+	idx, err := BuildIndex(bytes.NewReader(compressed.Bytes()), 100000)
+	failOnError(t, "Failed building index", err)
+	if idx.UncompressedSize != int64(len(input)) {
+		t.Fatalf("UncompressedSize = %d, expected %d", idx.UncompressedSize, len(input))
+	}
+	if idx.CompressedSize != int64(compressed.Len()) {
+		t.Fatalf("CompressedSize = %d, expected %d", idx.CompressedSize, compressed.Len())
+	}
+	if len(idx.Points) < 2 {
+		t.Fatalf("expected several index points, got %d", len(idx.Points))
+	}
+
+	// round trip the sidecar
+	var sidecar bytes.Buffer
+	_, err = idx.WriteTo(&sidecar)
+	failOnError(t, "Failed writing index", err)
+	idx, err = ReadIndex(&sidecar)
+	failOnError(t, "Failed reading index", err)
+
+	r := NewSeekReader(bytes.NewReader(compressed.Bytes()), idx)
+	defer r.Close()
+
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		off := rng.Int63n(int64(len(input)))
+		_, err := r.Seek(off, io.SeekStart)
+		failOnError(t, "Failed seeking", err)
+
+		want := input[off:]
+		if len(want) > 1000 {
+			want = want[:1000]
+		}
+		got := make([]byte, len(want))
+		_, err = io.ReadFull(r, got)
+		failOnError(t, "Failed reading after seek", err)
+		if !bytes.Equal(got, want) {
+			t.Fatalf("wrong data after seeking to %d", off)
+		}
+	}
+
+	// querying the position must not restart decompression
+	_, err = r.Seek(int64(len(input)/2), io.SeekStart)
+	failOnError(t, "Failed seeking", err)
+	_, err = io.ReadFull(r, make([]byte, 10))
+	failOnError(t, "Failed reading after seek", err)
+	pos, err := r.Seek(0, io.SeekCurrent)
+	failOnError(t, "Failed seeking", err)
+	if r.seeking {
+		t.Fatalf("Seek(0, io.SeekCurrent) at %d scheduled a seek", pos)
+	}
+	next := make([]byte, 100)
+	_, err = io.ReadFull(r, next)
+	failOnError(t, "Failed reading after Seek(0, io.SeekCurrent)", err)
+	if !bytes.Equal(next, input[pos:pos+100]) {
+		t.Fatalf("wrong data after Seek(0, io.SeekCurrent) at %d", pos)
+	}
+
+	_, err = r.Seek(-10, io.SeekEnd)
+	failOnError(t, "Failed seeking", err)
+	rest, err := ioutil.ReadAll(r)
+	failOnError(t, "Failed reading to end", err)
+	if !bytes.Equal(rest, input[len(input)-10:]) {
+		t.Fatalf("wrong data at end: %q", rest)
+	}
+}
+
+func TestReadIndexBadData(t *testing.T) {
+	_, err := ReadIndex(bytes.NewReader([]byte("LZ4Y\x01")))
+	if err != errBadIndex {
+		t.Fatalf("expected errBadIndex, got %v", err)
+	}
+}
+
+func TestReadIndexHugeWindow(t *testing.T) {
+	// one point whose window claims 4 GiB of uncompressed data
+	data := []byte("LZ4X\x01\x00\x00\x01\x00\x00\x05\xff\xff\xff\xff\x00")
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if _, err := ReadIndex(bytes.NewReader(data)); err != errBadIndex {
+		t.Fatalf("expected errBadIndex, got %v", err)
+	}
+	runtime.ReadMemStats(&after)
+	if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+		t.Errorf("allocated %d bytes for a malformed window", n)
+	}
+}
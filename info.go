@@ -0,0 +1,167 @@
+package lz4
+
+// #cgo pkg-config: liblz4
+// #include <lz4frame.h>
+import "C"
+
+import "errors"
+
+// StreamInfo describes a compressed stream, as parsed from its header, so a
+// service can reject streams with unacceptable parameters before decoding
+// them. The block stream has no header: its info reflects the records
+// preceding its first block.
+type StreamInfo struct {
+	Format Format
+	// BlockSize is the maximum uncompressed size of a block, as given by
+	// the frame header, or the size of the first block of a block stream.
+	BlockSize int
+	// IndependentBlocks is set if each block can be decoded without the
+	// previous ones.
+	IndependentBlocks bool
+	ContentChecksum   bool
+	BlockChecksum     bool
+	// ContentSize is the uncompressed size recorded in the header, or -1 if
+	// there is none.
+	ContentSize int64
+	// DictID identifies the dictionary the stream was compressed with, or
+	// is 0 if there is none.
+	DictID uint32
+}
+
+// Prime decodes the first block of the stream, without consuming it, so Info
+// can describe the stream before anything is read. It returns the error the
+// first Read would return, if any.
+func (r *DecompressReader) Prime() error {
+	if r.Info() != nil {
+		return nil
+	}
+	return r.out.prime()
+}
+
+// Info returns the description of the stream, or nil if no block was decoded
+// yet by Read, ReadBlock or Prime.
+func (r *DecompressReader) Info() *StreamInfo {
+	if r.readAhead != nil {
+		return r.readAhead.last.info
+	}
+	return r.info
+}
+
+// Prime reads the header of the first frame, so Info can describe the stream
+// before anything is read. It returns the error the first Read would return
+// for a malformed or truncated header, and io.EOF for an empty stream.
+func (r *FrameReader) Prime() error {
+	if r.ctx == nil {
+		return errors.New("reader is closed")
+	}
+	for r.info == nil {
+		// consume the header without producing any output
+		if _, err := r.step(nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Info returns the description of the first frame, or nil if its header was
+// not read yet by Read or Prime.
+func (r *FrameReader) Info() *StreamInfo {
+	return r.info
+}
+
+// FrameInfo describes the header of an LZ4 frame.
+type FrameInfo struct {
+	// BlockSizeID is the block size ID of the header, from 4 for 64 KiB
+	// blocks to 7 for 4 MiB blocks.
+	BlockSizeID int
+	// BlockSize is the maximum uncompressed size of a block.
+	BlockSize int
+	// IndependentBlocks is set if each block can be decoded without the
+	// previous ones.
+	IndependentBlocks bool
+	ContentChecksum   bool
+	BlockChecksum     bool
+	// HasContentSize is set if the header records the uncompressed size of
+	// the frame, ContentSize.
+	HasContentSize bool
+	ContentSize    uint64
+	// DictID identifies the dictionary the frame was compressed with, or is
+	// 0 if there is none.
+	DictID uint32
+}
+
+// FrameInfo returns the description of the frame being decoded, or of the last
+// frame decoded if the reader is between frames, so that each frame of a
+// stream can be checked against a policy. If no frame header was decoded yet,
+// it reads the header of the first frame, returning the same errors as Prime.
+// Skippable frames are not described.
+func (r *FrameReader) FrameInfo() (FrameInfo, error) {
+	if r.frame == nil {
+		if err := r.Prime(); err != nil {
+			return FrameInfo{}, err
+		}
+	}
+	return *r.frame, nil
+}
+
+// loadInfo sets r.frame from the header of the current frame, if it was
+// decoded and r.frame is not up to date yet, and r.info from the first frame.
+// Skippable frames are ignored.
+func (r *FrameReader) loadInfo() {
+	if r.frameLoaded {
+		return
+	}
+	var fi C.LZ4F_frameInfo_t
+	var srcSize C.size_t
+	if C.LZ4F_isError(C.LZ4F_getFrameInfo(r.ctx, &fi, nil, &srcSize)) != 0 ||
+		fi.frameType == C.LZ4F_skippableFrame {
+		return
+	}
+	r.setFrame(FrameInfo{
+		BlockSizeID:       int(fi.blockSizeID),
+		BlockSize:         frameBlockSize(fi.blockSizeID),
+		IndependentBlocks: fi.blockMode == C.LZ4F_blockIndependent,
+		ContentChecksum:   fi.contentChecksumFlag == C.LZ4F_contentChecksumEnabled,
+		BlockChecksum:     fi.blockChecksumFlag == C.LZ4F_blockChecksumEnabled,
+		HasContentSize:    fi.contentSize != 0,
+		ContentSize:       uint64(fi.contentSize),
+		DictID:            uint32(fi.dictID),
+	})
+}
+
+// setFrame sets r.frame to fi, the header of the current frame, and r.info
+// from the first frame.
+func (r *FrameReader) setFrame(fi FrameInfo) {
+	r.frameLoaded = true
+	r.frame = &fi
+	if r.info != nil {
+		return
+	}
+	info := &StreamInfo{
+		Format:            FormatFrame,
+		BlockSize:         r.frame.BlockSize,
+		IndependentBlocks: r.frame.IndependentBlocks,
+		ContentChecksum:   r.frame.ContentChecksum,
+		BlockChecksum:     r.frame.BlockChecksum,
+		ContentSize:       -1,
+		DictID:            r.frame.DictID,
+	}
+	if r.frame.HasContentSize {
+		info.ContentSize = int64(r.frame.ContentSize)
+	}
+	r.info = info
+}
+
+// frameBlockSize returns the maximum block size for the block size ID of a
+// frame header.
+func frameBlockSize(id C.LZ4F_blockSizeID_t) int {
+	switch id {
+	case C.LZ4F_max256KB:
+		return 256 << 10
+	case C.LZ4F_max1MB:
+		return 1 << 20
+	case C.LZ4F_max4MB:
+		return 4 << 20
+	}
+	return 64 << 10
+}
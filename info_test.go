@@ -0,0 +1,133 @@
+package lz4
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func TestDecompressReaderInfo(t *testing.T) {
+	var compressed bytes.Buffer
+	w := NewWriter(&compressed, WithBlockChecksum())
+	_, err := w.Write(plaintext0)
+	failOnError(t, "Failed writing", err)
+	failOnError(t, "Failed closing", w.Close())
+
+	r := NewDecompressReader(&compressed, WithBlockChecksum()).(*DecompressReader)
+	defer r.Close()
+	if r.Info() != nil {
+		t.Fatal("info available before decoding")
+	}
+	failOnError(t, "Failed priming", r.Prime())
+	info := r.Info()
+	want := StreamInfo{Format: FormatCustomStream, BlockSize: len(plaintext0), BlockChecksum: true, ContentSize: -1}
+	if info == nil || *info != want {
+		t.Fatalf("got info %+v, want %+v", info, want)
+	}
+	got, err := io.ReadAll(r)
+	failOnError(t, "Failed reading", err)
+	if !bytes.Equal(got, plaintext0) {
+		t.Fatal("priming lost data")
+	}
+
+	empty := NewDecompressReader(bytes.NewReader(nil)).(*DecompressReader)
+	if err := empty.Prime(); err != io.EOF {
+		t.Fatalf("got error %v for an empty stream, want io.EOF", err)
+	}
+	if n, err := empty.Read(make([]byte, 10)); n != 0 || err != io.EOF {
+		t.Fatalf("got %d, %v after priming an empty stream", n, err)
+	}
+}
+
+func TestFrameReaderInfo(t *testing.T) {
+	var compressed bytes.Buffer
+	w := NewFrameWriter(&compressed)
+	_, err := w.Write(plaintext0)
+	failOnError(t, "Failed writing", err)
+	failOnError(t, "Failed closing", w.Close())
+
+	r := NewFrameReader(iotest.OneByteReader(bytes.NewReader(compressed.Bytes())))
+	defer r.Close()
+	failOnError(t, "Failed priming", r.Prime())
+	info := r.Info()
+	want := StreamInfo{Format: FormatFrame, BlockSize: 64 << 10, ContentChecksum: true, ContentSize: -1}
+	if info == nil || *info != want {
+		t.Fatalf("got info %+v, want %+v", info, want)
+	}
+	got, err := io.ReadAll(r)
+	failOnError(t, "Failed reading", err)
+	if !bytes.Equal(got, plaintext0) {
+		t.Fatal("priming lost data")
+	}
+
+	// Read also fills in the info
+	r2 := NewFrameReader(bytes.NewReader(compressed.Bytes()))
+	defer r2.Close()
+	_, err = r2.Read(make([]byte, 1))
+	failOnError(t, "Failed reading", err)
+	if info := r2.Info(); info == nil || *info != want {
+		t.Fatalf("got info %+v after Read, want %+v", info, want)
+	}
+
+	truncated := NewFrameReader(bytes.NewReader(compressed.Bytes()[:3]))
+	defer truncated.Close()
+	if err := truncated.Prime(); err == nil {
+		t.Fatal("no error for a truncated header")
+	}
+}
+
+func TestFrameReaderFrameInfo(t *testing.T) {
+	frame := func(data []byte, opts ...Option) []byte {
+		var buf bytes.Buffer
+		w := NewFrameWriter(&buf, opts...)
+		_, err := w.Write(data)
+		failOnError(t, "Failed writing", err)
+		failOnError(t, "Failed closing", w.Close())
+		return buf.Bytes()
+	}
+	first := frame([]byte("first"))
+	second := frame([]byte("second"), WithBlockSize(256<<10), WithIndependentBlocks(), WithBlockChecksum(), WithContentChecksum(false))
+	// a skippable frame between the two is not described
+	skippable := []byte{0x50, 0x2a, 0x4d, 0x18, 2, 0, 0, 0, 'x', 'x'}
+	stream := append(append(append([]byte(nil), first...), skippable...), second...)
+
+	r := NewFrameReader(bytes.NewReader(stream))
+	defer r.Close()
+	fi, err := r.FrameInfo()
+	failOnError(t, "Failed reading frame info", err)
+	want := FrameInfo{BlockSizeID: 4, BlockSize: 64 << 10, ContentChecksum: true}
+	if fi != want {
+		t.Fatalf("first frame: got %+v, want %+v", fi, want)
+	}
+
+	buf := make([]byte, 100)
+	n, err := r.Read(buf)
+	failOnError(t, "Failed reading", err)
+	if string(buf[:n]) != "first" {
+		t.Fatalf("got %q", buf[:n])
+	}
+	n, err = r.Read(buf)
+	failOnError(t, "Failed reading", err)
+	if string(buf[:n]) != "second" {
+		t.Fatalf("got %q", buf[:n])
+	}
+	fi, err = r.FrameInfo()
+	failOnError(t, "Failed reading frame info", err)
+	want = FrameInfo{BlockSizeID: 5, BlockSize: 256 << 10, IndependentBlocks: true, BlockChecksum: true}
+	if fi != want {
+		t.Fatalf("second frame: got %+v, want %+v", fi, want)
+	}
+	if _, err := r.Read(buf); err != io.EOF {
+		t.Fatalf("got %v, want io.EOF", err)
+	}
+	if info := r.Info(); info.BlockSize != 64<<10 || !info.ContentChecksum {
+		t.Errorf("Info does not describe the first frame: %+v", info)
+	}
+
+	empty := NewFrameReader(bytes.NewReader(nil))
+	defer empty.Close()
+	if _, err := empty.FrameInfo(); err != io.EOF {
+		t.Errorf("empty stream: got %v, want io.EOF", err)
+	}
+}
@@ -0,0 +1,112 @@
+package lz4
+
+import (
+	"errors"
+	"io"
+)
+
+// Report describes a block stream, as walked by Inspect.
+type Report struct {
+	// Blocks describes the blocks decoded successfully.
+	Blocks []BlockReport
+	// CompressedSize is the number of bytes of the stream decoded
+	// successfully, including headers and control records.
+	CompressedSize int64
+	// UncompressedSize is the total size of the decoded blocks.
+	UncompressedSize int64
+	// BlockChecksums is set if the blocks have checksums, which were
+	// verified.
+	BlockChecksums bool
+	// Trailer is set if the stream ends with a trailer, which was verified.
+	Trailer bool
+	// ErrOffset is the offset in the stream of the record where decoding
+	// failed, or -1 if the whole stream was decoded.
+	ErrOffset int64
+}
+
+// BlockReport describes a block of a stream.
+type BlockReport struct {
+	// Index is the number of blocks decoded before this one.
+	Index int64
+	// Offset is the offset of the block header in the stream.
+	Offset int64
+	// CompressedSize is the size of the compressed block, without its
+	// header.
+	CompressedSize int
+	// UncompressedSize is the size of the decoded block.
+	UncompressedSize int
+}
+
+// Ratio returns the compression ratio of the block, its uncompressed size
+// divided by its compressed size.
+func (b BlockReport) Ratio() float64 {
+	return float64(b.UncompressedSize) / float64(b.CompressedSize)
+}
+
+// Ratio returns the compression ratio of the stream decoded so far.
+func (r *Report) Ratio() float64 {
+	return float64(r.UncompressedSize) / float64(r.CompressedSize)
+}
+
+// Inspect walks the block stream read from r without returning its data,
+// decoding every block and verifying the block checksums and trailer, if any,
+// to diagnose a damaged stream. opts configure the reader, for example with
+// WithChecksum for streams using another checksum than the default ones. The
+// report describes the stream up to the first error, which is returned along
+// with the offset of the record that caused it in ErrOffset.
+func Inspect(r io.Reader, opts ...Option) (*Report, error) {
+	opts = append([]Option{WithBlockChecksum(), WithTrailer()}, opts...)
+	dr := newDecompressReader(r, explicitOptions(opts...), nil)
+	defer dr.Close()
+
+	report := &Report{ErrOffset: -1}
+	reportBlock := dr.opts.blockReport
+	dr.opts.blockReport = func(b BlockReport) {
+		report.Blocks = append(report.Blocks, b)
+		if reportBlock != nil {
+			reportBlock(b)
+		}
+	}
+	var err error
+	for err == nil {
+		_, err = dr.ReadBlock()
+	}
+	report.CompressedSize = dr.CompressedBytesRead()
+	report.UncompressedSize = dr.UncompressedBytesRead()
+	report.BlockChecksums = dr.sumSeen
+	switch {
+	case err == io.EOF:
+		report.Trailer = true
+		return report, nil
+	case errors.Is(err, ErrMissingTrailer):
+		return report, nil
+	}
+	report.ErrOffset = report.CompressedSize
+	return report, err
+}
+
+// WithBlockReports makes a DecompressReader call fn with the description of
+// each block as it is decoded, before its data is returned by Read, for
+// example to show the compression ratio live during a long restore. In
+// recovery mode, the index counts the blocks decoded, not those skipped.
+func WithBlockReports(fn func(BlockReport)) Option {
+	return func(o *options) {
+		o.blockReport = fn
+	}
+}
+
+// reportBlock calls the function of WithBlockReports, if any, for the block
+// of compressedSize bytes decoded into size bytes, whose header starts at
+// offset.
+func (r *DecompressReader) reportBlock(offset int64, compressedSize, size int) {
+	index := r.blocksDecoded
+	r.blocksDecoded++
+	if r.opts.blockReport != nil {
+		r.opts.blockReport(BlockReport{
+			Index:            index,
+			Offset:           offset,
+			CompressedSize:   compressedSize,
+			UncompressedSize: size,
+		})
+	}
+}
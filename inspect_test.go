@@ -0,0 +1,94 @@
+package lz4
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestInspect(t *testing.T) {
+	input := bytes.Repeat([]byte("inspect this stream "), 10000)
+	var buf bytes.Buffer
+	w := NewWriter(&buf, WithBlockChecksum(), WithTrailer())
+	_, err := w.Write(input)
+	failOnError(t, "Failed writing", err)
+	failOnError(t, "Failed closing", w.Close())
+	stream := buf.Bytes()
+
+	report, err := Inspect(bytes.NewReader(stream))
+	failOnError(t, "Failed inspecting", err)
+	if len(report.Blocks) != 4 || report.UncompressedSize != int64(len(input)) ||
+		report.CompressedSize != int64(len(stream)) || !report.BlockChecksums || !report.Trailer || report.ErrOffset != -1 {
+		t.Fatalf("got report %+v", report)
+	}
+	for _, b := range report.Blocks {
+		if b.Ratio() < 10 {
+			t.Errorf("block at %d: ratio %.1f", b.Offset, b.Ratio())
+		}
+	}
+
+	// a corrupted byte in the third block
+	third := report.Blocks[2]
+	corrupt := append([]byte(nil), stream...)
+	corrupt[third.Offset+blockHeaderSize+int64(third.CompressedSize)/2] ^= 0x55
+	report, err = Inspect(bytes.NewReader(corrupt))
+	if err == nil {
+		t.Fatal("no error for a corrupt block")
+	}
+	if len(report.Blocks) != 2 || report.ErrOffset != third.Offset {
+		t.Errorf("got %d blocks and error offset %d, want 2 and %d", len(report.Blocks), report.ErrOffset, third.Offset)
+	}
+
+	// a stream without checksums nor trailer
+	var plain bytes.Buffer
+	w = NewWriter(&plain)
+	_, err = w.Write(input)
+	failOnError(t, "Failed writing", err)
+	failOnError(t, "Failed closing", w.Close())
+	report, err = Inspect(bytes.NewReader(plain.Bytes()))
+	failOnError(t, "Failed inspecting", err)
+	if report.BlockChecksums || report.Trailer || len(report.Blocks) != 4 {
+		t.Fatalf("got report %+v", report)
+	}
+
+	report, err = Inspect(bytes.NewReader(plain.Bytes()[:plain.Len()-1]))
+	if err != io.ErrUnexpectedEOF || len(report.Blocks) != 3 || report.ErrOffset != report.CompressedSize {
+		t.Fatalf("truncated stream: got %v, report %+v", err, report)
+	}
+}
+
+func TestBlockReports(t *testing.T) {
+	input := bytes.Repeat([]byte("restore progress "), 20000)
+	var buf bytes.Buffer
+	w := NewWriter(&buf, WithBlockChecksum())
+	_, err := w.Write(input)
+	failOnError(t, "Failed writing", err)
+	failOnError(t, "Failed closing", w.Close())
+
+	var reports []BlockReport
+	r := NewDecompressReader(bytes.NewReader(buf.Bytes()), WithBlockReports(func(b BlockReport) {
+		reports = append(reports, b)
+	}))
+	out, err := io.ReadAll(r)
+	failOnError(t, "Failed reading", err)
+	failOnError(t, "Failed closing", r.Close())
+	if !bytes.Equal(out, input) {
+		t.Fatal("decompressed output != input")
+	}
+
+	inspected, err := Inspect(bytes.NewReader(buf.Bytes()))
+	failOnError(t, "Failed inspecting", err)
+	if len(reports) != len(inspected.Blocks) || len(reports) != (len(input)+streamingBlockSize-1)/streamingBlockSize {
+		t.Fatalf("got %d reports, %d blocks inspected", len(reports), len(inspected.Blocks))
+	}
+	total := 0
+	for i, b := range reports {
+		if b != inspected.Blocks[i] || b.Index != int64(i) {
+			t.Errorf("block %d: got %+v, inspected %+v", i, b, inspected.Blocks[i])
+		}
+		total += b.UncompressedSize
+	}
+	if total != len(input) {
+		t.Errorf("reports cover %d bytes of %d", total, len(input))
+	}
+}
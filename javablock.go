@@ -0,0 +1,199 @@
+package lz4
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/DataDog/golz4/xxhash"
+)
+
+// javablock.go reads and writes the format of LZ4BlockOutputStream from
+// lz4-java: blocks preceded by a 21-byte header holding a magic string, the
+// compression method, the compressed and uncompressed sizes, and a checksum of
+// the uncompressed data, followed by an empty block marking the end.
+
+const (
+	javaMagic      = "LZ4Block"
+	javaHeaderSize = len(javaMagic) + 13
+	javaMethodRaw  = 0x10
+	javaMethodLZ4  = 0x20
+	// javaLevelBase is the log2 of the smallest block size, which the level
+	// in the header of a block is relative to.
+	javaLevelBase = 10
+	// javaBlockSize is the default block size of LZ4BlockOutputStream.
+	javaBlockSize = 64 << 10
+	// javaMaxBlockSize is the largest block size it accepts.
+	javaMaxBlockSize = 32 << 20
+	javaSeed         = 0x9747b28c
+)
+
+var errJavaMagic = errors.New("missing lz4-java block magic string")
+
+// javaChecksum returns the checksum of a block, as computed by the checksum
+// of lz4-java, which keeps the 28 low bits of XXH32.
+func javaChecksum(b []byte) uint32 {
+	h := xxhash.NewWithSeed32(javaSeed)
+	h.Write(b)
+	return h.Sum32() & 0xFFFFFFF
+}
+
+// javaReader decompresses the lz4-java block format. Concatenated streams are
+// decompressed one after the other.
+type javaReader struct {
+	r      io.Reader
+	out    blockEngine
+	header [javaHeaderSize]byte
+	// ended is set after the end block of a stream
+	ended bool
+	in    []byte
+	buf   []byte
+}
+
+func newJavaReader(r io.Reader) *javaReader {
+	jr := &javaReader{r: r}
+	jr.out.next = jr.nextBlock
+	return jr
+}
+
+func (r *javaReader) Read(dst []byte) (int, error) {
+	return r.out.read(dst)
+}
+
+func (r *javaReader) Close() error {
+	return nil
+}
+
+// nextBlock decodes the next block, verifying its checksum.
+func (r *javaReader) nextBlock() ([]byte, error) {
+	for {
+		if _, err := io.ReadFull(r.r, r.header[:]); err != nil {
+			if err == io.EOF && !r.ended {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		r.ended = false
+		if string(r.header[:len(javaMagic)]) != javaMagic {
+			return nil, errJavaMagic
+		}
+		token := r.header[len(javaMagic)]
+		method := token & 0xf0
+		maxSize := 1 << (javaLevelBase + token&0x0f)
+		compressed := int(int32(binary.LittleEndian.Uint32(r.header[9:])))
+		size := int(int32(binary.LittleEndian.Uint32(r.header[13:])))
+		sum := binary.LittleEndian.Uint32(r.header[17:])
+		if method != javaMethodRaw && method != javaMethodLZ4 || maxSize > javaMaxBlockSize ||
+			size < 0 || size > maxSize || compressed < 0 || compressed > compressBound(maxSize) ||
+			method == javaMethodRaw && compressed != size {
+			return nil, fmt.Errorf("invalid lz4-java block header %x", r.header[len(javaMagic):])
+		}
+		if size == 0 {
+			if compressed != 0 || sum != 0 {
+				return nil, fmt.Errorf("invalid lz4-java end block")
+			}
+			r.ended = true
+			continue
+		}
+		if cap(r.in) < compressed {
+			r.in = make([]byte, compressed)
+		}
+		in := r.in[:compressed]
+		if _, err := io.ReadFull(r.r, in); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		if cap(r.buf) < size {
+			r.buf = make([]byte, size)
+		}
+		out := r.buf[:size]
+		if method == javaMethodRaw {
+			copy(out, in)
+		} else if n, err := Uncompress(out, in); err != nil {
+			return nil, err
+		} else if n != size {
+			return nil, fmt.Errorf("lz4-java block decoded to %d bytes instead of %d", n, size)
+		}
+		if javaChecksum(out) != sum {
+			return nil, fmt.Errorf("%w: lz4-java block", ErrChecksumMismatch)
+		}
+		return out, nil
+	}
+}
+
+// javaWriter compresses its input into the lz4-java block format, buffering
+// each block of javaBlockSize. The stream is complete once Close has been
+// called.
+type javaWriter struct {
+	w   io.Writer
+	buf []byte
+	out []byte
+}
+
+func newJavaWriter(w io.Writer) *javaWriter {
+	return &javaWriter{
+		w:   w,
+		buf: make([]byte, 0, javaBlockSize),
+		out: make([]byte, javaHeaderSize+compressBound(javaBlockSize)),
+	}
+}
+
+func (w *javaWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), javaBlockSize-len(w.buf))
+		w.buf = append(w.buf, p[:n]...)
+		p = p[n:]
+		written += n
+		if len(w.buf) == javaBlockSize {
+			if err := w.flush(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// flush writes the buffered block, which is the end block if empty.
+func (w *javaWriter) flush() error {
+	method := byte(javaMethodLZ4)
+	n := 0
+	if len(w.buf) > 0 {
+		var err error
+		n, err = Compress(w.out[javaHeaderSize:], w.buf)
+		if err != nil {
+			return err
+		}
+	}
+	if n >= len(w.buf) {
+		method = javaMethodRaw
+		n = copy(w.out[javaHeaderSize:], w.buf)
+	}
+	// the level of the default block size
+	const level = 16 - javaLevelBase
+	copy(w.out, javaMagic)
+	w.out[len(javaMagic)] = method | level
+	binary.LittleEndian.PutUint32(w.out[9:], uint32(n))
+	binary.LittleEndian.PutUint32(w.out[13:], uint32(len(w.buf)))
+	sum := uint32(0)
+	if len(w.buf) > 0 {
+		sum = javaChecksum(w.buf)
+	}
+	binary.LittleEndian.PutUint32(w.out[17:], sum)
+	w.buf = w.buf[:0]
+	_, err := w.w.Write(w.out[:javaHeaderSize+n])
+	return err
+}
+
+// Close writes the last block, if any, and the end block.
+func (w *javaWriter) Close() error {
+	if len(w.buf) > 0 {
+		if err := w.flush(); err != nil {
+			return err
+		}
+	}
+	return w.flush()
+}
@@ -0,0 +1,99 @@
+package lz4
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// large.go contains one-shot helpers for inputs of any size, split into
+// blocks small enough for lz4.
+
+// largeChunkSize is the amount of input compressed as one block by
+// CompressLarge.
+const largeChunkSize = 1 << 30
+
+// largeHeaderSize is the size of the header of each block written by
+// CompressLarge: the uncompressed and compressed sizes as little endian
+// uint32s.
+const largeHeaderSize = 8
+
+var errBadLarge = errors.New("malformed large block sequence")
+
+// CompressLarge compresses in, which can be larger than the 2,113,929,216
+// bytes lz4 can compress as one block, into a newly allocated slice. The input
+// is split into blocks of 1 GiB, each preceded by its uncompressed and
+// compressed sizes as 4-byte little endian integers. The result can be
+// decompressed with UncompressLarge.
+func CompressLarge(in []byte) ([]byte, error) {
+	return compressLarge(in, largeChunkSize)
+}
+
+func compressLarge(in []byte, chunkSize int) ([]byte, error) {
+	chunks := (len(in) + chunkSize - 1) / chunkSize
+	out := make([]byte, 0, len(in)+len(in)/255+chunks*(16+largeHeaderSize))
+	for len(in) > 0 {
+		chunk := in[:min(chunkSize, len(in))]
+		in = in[len(chunk):]
+
+		start := len(out)
+		bound := CompressBound(chunk)
+		out = out[:start+largeHeaderSize+bound]
+		n, err := Compress(out[start+largeHeaderSize:], chunk)
+		if err != nil {
+			return nil, err
+		}
+		binary.LittleEndian.PutUint32(out[start:], uint32(len(chunk)))
+		binary.LittleEndian.PutUint32(out[start+4:], uint32(n))
+		out = out[:start+largeHeaderSize+n]
+	}
+	return out, nil
+}
+
+// UncompressLarge decompresses the output of CompressLarge into a newly
+// allocated slice. The sizes in the block headers are validated against the
+// size of in before allocating, so it is safe to use on untrusted input.
+func UncompressLarge(in []byte) ([]byte, error) {
+	// validate the headers and compute the total size first
+	total := 0
+	for rest := in; len(rest) > 0; {
+		size, compressed, err := parseLargeHeader(rest)
+		if err != nil {
+			return nil, err
+		}
+		total += size
+		rest = rest[largeHeaderSize+compressed:]
+	}
+
+	out := make([]byte, total)
+	pos := 0
+	for len(in) > 0 {
+		size, compressed, _ := parseLargeHeader(in)
+		block := in[largeHeaderSize : largeHeaderSize+compressed]
+		n, err := Uncompress(out[pos:pos+size], block)
+		if err != nil {
+			return nil, err
+		}
+		if n != size {
+			return nil, errBadLarge
+		}
+		pos += size
+		in = in[largeHeaderSize+compressed:]
+	}
+	return out, nil
+}
+
+// parseLargeHeader returns the uncompressed and compressed sizes of the block
+// at the start of in, checking that the block is complete and that its
+// uncompressed size is possible for its compressed size.
+func parseLargeHeader(in []byte) (int, int, error) {
+	if len(in) < largeHeaderSize {
+		return 0, 0, errBadLarge
+	}
+	size := int(binary.LittleEndian.Uint32(in))
+	compressed := int(binary.LittleEndian.Uint32(in[4:]))
+	// lz4 cannot expand a byte of compressed data to more than 255 bytes
+	if compressed > len(in)-largeHeaderSize || size > maxInputSize || size > compressed*255 {
+		return 0, 0, errBadLarge
+	}
+	return size, compressed, nil
+}
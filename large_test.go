@@ -0,0 +1,48 @@
+package lz4
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCompressLargeRoundTrip(t *testing.T) {
+	input := bytes.Repeat([]byte("split into several blocks "), 10000)
+	for _, chunkSize := range []int{largeChunkSize, 100000, 7} {
+		compressed, err := compressLarge(input, chunkSize)
+		failOnError(t, "Failed compressing", err)
+		out, err := UncompressLarge(compressed)
+		failOnError(t, "Failed decompressing", err)
+		if !bytes.Equal(out, input) {
+			t.Fatalf("chunk size %d: decompressed output != input", chunkSize)
+		}
+	}
+
+	compressed, err := CompressLarge(nil)
+	failOnError(t, "Failed compressing empty input", err)
+	out, err := UncompressLarge(compressed)
+	if err != nil || len(out) != 0 {
+		t.Fatalf("empty input: got %d bytes, %v", len(out), err)
+	}
+}
+
+func TestUncompressLargeMalformed(t *testing.T) {
+	compressed, err := compressLarge(bytes.Repeat(plaintext0, 1000), 10000)
+	failOnError(t, "Failed compressing", err)
+
+	inflated := append([]byte(nil), compressed...)
+	// claim a huge uncompressed size
+	inflated[3] = 0x7f
+	wrongSize := append([]byte(nil), compressed...)
+	wrongSize[0]--
+
+	for name, in := range map[string][]byte{
+		"truncated":  compressed[:len(compressed)-1],
+		"header":     compressed[:5],
+		"inflated":   inflated,
+		"wrong size": wrongSize,
+	} {
+		if _, err := UncompressLarge(in); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package lz4
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// legacy.go reads and writes the legacy frame format of the lz4 command line
+// tool, written with lz4 -l: a magic number followed by independent blocks of
+// 8 MiB, each preceded by its compressed size.
+
+const (
+	legacyMagic     = 0x184C2102
+	legacyBlockSize = 8 << 20
+)
+
+// legacyReader decompresses the legacy frame format. Concatenated frames are
+// decompressed one after the other.
+type legacyReader struct {
+	r       io.Reader
+	out     blockEngine
+	started bool
+	header  [4]byte
+	in      []byte
+	buf     []byte
+}
+
+func newLegacyReader(r io.Reader) *legacyReader {
+	lr := &legacyReader{r: r}
+	lr.out.next = lr.nextBlock
+	return lr
+}
+
+func (r *legacyReader) Read(dst []byte) (int, error) {
+	return r.out.read(dst)
+}
+
+func (r *legacyReader) Close() error {
+	return nil
+}
+
+// nextBlock decodes the next block, skipping the magic numbers of the frames.
+func (r *legacyReader) nextBlock() ([]byte, error) {
+	for {
+		if _, err := io.ReadFull(r.r, r.header[:]); err != nil {
+			if err == io.EOF && !r.started {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		size := binary.LittleEndian.Uint32(r.header[:])
+		if size == legacyMagic {
+			r.started = true
+			continue
+		}
+		if !r.started {
+			return nil, errors.New("missing legacy frame magic number")
+		}
+		if int64(size) > int64(compressBound(legacyBlockSize)) {
+			return nil, fmt.Errorf("legacy block of %d bytes too large", size)
+		}
+		if cap(r.in) < int(size) {
+			r.in = make([]byte, size)
+		}
+		in := r.in[:size]
+		if _, err := io.ReadFull(r.r, in); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		if r.buf == nil {
+			r.buf = make([]byte, legacyBlockSize)
+		}
+		n, err := Uncompress(r.buf, in)
+		if err != nil {
+			return nil, err
+		}
+		return r.buf[:n], nil
+	}
+}
+
+// legacyWriter compresses its input into the legacy frame format, buffering
+// each block. The frame is complete once Close has been called.
+type legacyWriter struct {
+	w       io.Writer
+	started bool
+	buf     []byte
+	out     []byte
+}
+
+func newLegacyWriter(w io.Writer) *legacyWriter {
+	return &legacyWriter{w: w}
+}
+
+func (w *legacyWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), legacyBlockSize-len(w.buf))
+		w.buf = append(w.buf, p[:n]...)
+		p = p[n:]
+		written += n
+		if len(w.buf) == legacyBlockSize {
+			if err := w.flush(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// flush writes the magic number if needed, and the buffered block, if any.
+func (w *legacyWriter) flush() error {
+	if !w.started {
+		var magic [4]byte
+		binary.LittleEndian.PutUint32(magic[:], legacyMagic)
+		if _, err := w.w.Write(magic[:]); err != nil {
+			return err
+		}
+		w.started = true
+	}
+	if len(w.buf) == 0 {
+		return nil
+	}
+	if w.out == nil {
+		w.out = make([]byte, blockHeaderSize+compressBound(legacyBlockSize))
+	}
+	n, err := Compress(w.out[blockHeaderSize:], w.buf)
+	if err != nil {
+		return err
+	}
+	binary.LittleEndian.PutUint32(w.out, uint32(n))
+	w.buf = w.buf[:0]
+	_, err = w.w.Write(w.out[:blockHeaderSize+n])
+	return err
+}
+
+func (w *legacyWriter) Close() error {
+	return w.flush()
+}
@@ -0,0 +1,26 @@
+package lz4
+
+// levelSampleSize is the size of the sample of a block passed to the function
+// of WithLevelFunc.
+const levelSampleSize = 4 << 10
+
+// WithLevelFunc makes a Writer call fn before compressing each block, with a
+// sample of the block, its first 4 KiB, to choose its compression level, as
+// given to WithLevel, so that a single stream can compress text hard and skip
+// over already compressed media cheaply. The sample is only valid during the
+// call. fn overrides WithLevel and WithAdaptiveLevel. The output can be read
+// by any reader.
+func WithLevelFunc(fn func(sample []byte) int) Option {
+	return func(o *options) {
+		o.levelFunc = fn
+	}
+}
+
+// blockLevel returns the compression level chosen by the function of
+// WithLevelFunc for src.
+func (w *Writer) blockLevel(src []byte) int {
+	if len(src) > levelSampleSize {
+		src = src[:levelSampleSize]
+	}
+	return w.opts.levelFunc(src)
+}
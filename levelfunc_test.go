@@ -0,0 +1,61 @@
+package lz4
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestLevelFunc(t *testing.T) {
+	text, err := ioutil.ReadFile(sampleFilePath)
+	failOnError(t, "Failed reading sample", err)
+	text = bytes.Repeat(text, 4*streamingBlockSize/len(text)+1)[:4*streamingBlockSize]
+	media := make([]byte, 4*streamingBlockSize)
+	rand.New(rand.NewSource(1)).Read(media)
+
+	compress := func(opts ...Option) []byte {
+		var buf bytes.Buffer
+		w := NewWriter(&buf, opts...)
+		for _, part := range [][]byte{text, media, text} {
+			_, err := w.Write(part)
+			failOnError(t, "Failed writing", err)
+		}
+		failOnError(t, "Failed closing", w.Close())
+		return buf.Bytes()
+	}
+
+	var levels []int
+	compressed := compress(WithAdaptiveLevel(), WithLevelFunc(func(sample []byte) int {
+		if len(sample) > levelSampleSize {
+			t.Errorf("sample of %d bytes", len(sample))
+		}
+		level := -32
+		if utf8.Valid(sample[:len(sample)-utf8.UTFMax]) {
+			level = 9
+		}
+		levels = append(levels, level)
+		return level
+	}))
+	want := []int{9, 9, 9, 9, -32, -32, -32, -32, 9, 9, 9, 9}
+	if len(levels) != len(want) {
+		t.Fatalf("got levels %v, want %v", levels, want)
+	}
+	for i := range want {
+		if levels[i] != want[i] {
+			t.Fatalf("got levels %v, want %v", levels, want)
+		}
+	}
+
+	out, err := DecompressAll(compressed, 3*len(text))
+	failOnError(t, "Failed decompressing", err)
+	var input []byte
+	input = append(append(append(input, text...), media...), text...)
+	if !bytes.Equal(out, input) {
+		t.Fatal("decompressed output != input")
+	}
+	if fast := compress(); len(compressed) >= len(fast) {
+		t.Errorf("compressed to %d bytes, %d with the default level", len(compressed), len(fast))
+	}
+}
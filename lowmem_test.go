@@ -0,0 +1,105 @@
+package lz4
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestLowMemoryReader(t *testing.T) {
+	input, err := ioutil.ReadFile(sampleFilePath)
+	failOnError(t, "Failed reading sample", err)
+	for len(input) < 8*streamingBlockSize {
+		input = append(input, input...)
+	}
+
+	// vary the block sizes, so blocks wrap around the ring at different
+	// positions
+	var compressed bytes.Buffer
+	w := NewWriter(&compressed)
+	for i, size := 0, 1; i < len(input); size = size*7%streamingBlockSize + 1 {
+		end := min(i+size, len(input))
+		_, err := w.Write(input[i:end])
+		failOnError(t, "Failed writing to compress object", err)
+		i = end
+	}
+	failOnError(t, "Failed closing writer", w.Close())
+
+	r := NewDecompressReader(&compressed, WithLowMemory())
+	out, err := ioutil.ReadAll(r)
+	failOnError(t, "Failed decompressing", err)
+	failOnError(t, "Failed closing reader", r.Close())
+	if !bytes.Equal(out, input) {
+		t.Fatalf("Decompressed output != input")
+	}
+}
+
+func TestLowMemoryReaderLargeBlock(t *testing.T) {
+	input := bytes.Repeat([]byte("0123456789abcdef"), streamingBlockSize)
+	cr := NewCompressReader(bytes.NewReader(input))
+	defer cr.Close()
+	compressed, err := ioutil.ReadAll(cr)
+	failOnError(t, "Failed compressing", err)
+
+	r := NewDecompressReader(bytes.NewReader(compressed), WithLowMemory())
+	defer r.Close()
+	if _, err := ioutil.ReadAll(r); err == nil {
+		t.Fatalf("expected an error")
+	}
+}
+
+func TestIndependentBlocks(t *testing.T) {
+	input, err := ioutil.ReadFile(sampleFilePath)
+	failOnError(t, "Failed reading sample", err)
+	for len(input) < 4*streamingBlockSize {
+		input = append(input, input...)
+	}
+	store := NewDictionaryStore()
+	failOnError(t, "Failed registering", store.Register(7, input[:1000]))
+	failOnError(t, "Failed setting version", store.SetCurrent(7))
+
+	var linked, independent, withDict bytes.Buffer
+	for _, tc := range []struct {
+		buf  *bytes.Buffer
+		opts []Option
+	}{
+		{&linked, nil},
+		{&independent, []Option{WithIndependentBlocks()}},
+		{&withDict, []Option{WithIndependentBlocks(), WithDictionaryStore(store)}},
+	} {
+		w := NewWriter(tc.buf, tc.opts...)
+		_, err := w.Write(input)
+		failOnError(t, "Failed writing", err)
+		failOnError(t, "Failed closing writer", w.Close())
+	}
+	cr := NewCompressReader(bytes.NewReader(input), WithIndependentBlocks())
+	fromReader, err := ioutil.ReadAll(cr)
+	failOnError(t, "Failed compressing", err)
+	failOnError(t, "Failed closing", cr.Close())
+
+	for _, stream := range [][]byte{independent.Bytes(), withDict.Bytes(), fromReader} {
+		for _, opts := range [][]Option{
+			{WithDictionaryStore(store)},
+			{WithIndependentBlocks(), WithDictionaryStore(store)},
+			{WithIndependentBlocks(), WithLowMemory(), WithDictionaryStore(store)},
+		} {
+			if len(opts) == 3 && bytes.Equal(stream, fromReader) {
+				// CompressReader writes blocks too large for low-memory mode
+				continue
+			}
+			r := NewDecompressReader(bytes.NewReader(stream), opts...)
+			out, err := ioutil.ReadAll(r)
+			failOnError(t, "Failed decompressing", err)
+			failOnError(t, "Failed closing reader", r.Close())
+			if !bytes.Equal(out, input) {
+				t.Fatalf("Decompressed output != input")
+			}
+		}
+	}
+
+	r := NewDecompressReader(bytes.NewReader(linked.Bytes()), WithIndependentBlocks())
+	defer r.Close()
+	if _, err := ioutil.ReadAll(r); err == nil {
+		t.Fatal("linked blocks decoded as independent ones")
+	}
+}
@@ -2,15 +2,19 @@ package lz4
 
 // #cgo pkg-config: liblz4
 // #include <lz4.h>
+// #include <lz4hc.h>
 // #include <stdlib.h>
 import "C"
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"time"
 	"unsafe"
 )
 
@@ -21,8 +25,49 @@ const (
 
 	hugeStreamingBlockSize        = 1024 * 1024 * 5
 	boundedHugeStreamingBlockSize = hugeStreamingBlockSize + hugeStreamingBlockSize/255 + 16
+
+	// lowMemoryRingSize is LZ4_DECODER_RING_BUFFER_SIZE(streamingBlockSize),
+	// the size of a ring buffer that keeps the previous block intact while
+	// the next one is decoded, whatever the block sizes.
+	lowMemoryRingSize = 65536 + 14 + streamingBlockSize
+
+	// maxInputSize is LZ4_MAX_INPUT_SIZE, the largest input lz4 can
+	// compress as one block.
+	maxInputSize = C.LZ4_MAX_INPUT_SIZE
+
+	// bufferSeparation is the gap between the two input buffers of a Writer
+	// or CompressReader, allocated as one block; see newWriter.
+	bufferSeparation = 8
+)
+
+// ErrInputTooLarge is returned when the input of Compress or
+// CompressBoundChecked is larger than the 2,113,929,216 bytes lz4 can compress
+// as one block.
+var ErrInputTooLarge = errors.New("input too large for a single lz4 block")
+
+const (
+	// LargeBlockSize is the block size used by WithLargeBlocks.
+	LargeBlockSize = 4 * 1024 * 1024
+	// MaxBlockSize is the largest block size accepted by WithBlockSize, and
+	// the largest block DecompressReader can decode.
+	MaxBlockSize = hugeStreamingBlockSize
+	// MaxCompressedBlockSize is the largest compressed block size in a block
+	// stream, the compressed size of a block of MaxBlockSize in the worst
+	// case.
+	MaxCompressedBlockSize = boundedHugeStreamingBlockSize
+	// StreamBlockSize is the default uncompressed block size of Writer, and
+	// the size of the history window of the block stream.
+	StreamBlockSize = streamingBlockSize
+	// BlockHeaderSize is the size of the header preceding each record of a
+	// block stream.
+	BlockHeaderSize = blockHeaderSize
 )
 
+// compressBound returns the maximum compressed size of a block of n bytes.
+func compressBound(n int) int {
+	return n + n/255 + 16
+}
+
 // p gets a char pointer to the first byte of a []byte slice
 func p(in []byte) *C.char {
 	if len(in) == 0 {
@@ -48,7 +93,7 @@ func min(a, b int) int {
 func Uncompress(out, in []byte) (outSize int, err error) {
 	outSize = int(C.LZ4_decompress_safe(p(in), p(out), clen(in), clen(out)))
 	if outSize < 0 {
-		err = errors.New("Malformed compression stream")
+		err = newCError("LZ4_decompress_safe", outSize, "Malformed compression stream")
 	}
 	return
 }
@@ -58,17 +103,61 @@ func Uncompress(out, in []byte) (outSize int, err error) {
 //
 // #define LZ4_COMPRESSBOUND(isize)
 //      ((unsigned int)(isize) > (unsigned int)LZ4_MAX_INPUT_SIZE ? 0 : (isize) + ((isize)/255) + 16)
+//
+// Like the macro, it returns 0 for inputs too large to be compressed.
 func CompressBound(in []byte) int {
-	return len(in) + ((len(in) / 255) + 16)
+	n, _ := compressBoundLen(len(in))
+	return n
+}
+
+// CompressBoundChecked is like CompressBound, but returns ErrInputTooLarge
+// for inputs too large to be compressed.
+func CompressBoundChecked(in []byte) (int, error) {
+	return compressBoundLen(len(in))
+}
+
+// compressBoundLen returns the bound of CompressBoundChecked for an input of n
+// bytes.
+func compressBoundLen(n int) (int, error) {
+	if err := checkInputSize(n); err != nil {
+		return 0, err
+	}
+	return n + ((n / 255) + 16), nil
+}
+
+// checkInputSize returns ErrInputTooLarge if an input of n bytes is too large
+// to be compressed as one block.
+func checkInputSize(n int) error {
+	if n > maxInputSize {
+		return ErrInputTooLarge
+	}
+	return nil
 }
 
 // Compress compresses in and puts the content in out. len(out)
 // should have enough space for the compressed data (use CompressBound
 // to calculate). Returns the number of bytes in the out slice.
 func Compress(out, in []byte) (outSize int, err error) {
+	if err := checkInputSize(len(in)); err != nil {
+		return 0, err
+	}
 	outSize = int(C.LZ4_compress_default(p(in), p(out), clen(in), clen(out)))
 	if outSize == 0 {
-		err = errors.New("Insufficient space for compression")
+		err = newCError("LZ4_compress_default", outSize, "Insufficient space for compression")
+	}
+	return
+}
+
+// CompressFast is like Compress, but trades compression ratio for speed
+// according to acceleration: each increment speeds up compression by about 3%.
+// Values below 1 select the default acceleration of Compress.
+func CompressFast(out, in []byte, acceleration int) (outSize int, err error) {
+	if err := checkInputSize(len(in)); err != nil {
+		return 0, err
+	}
+	outSize = int(C.LZ4_compress_fast(p(in), p(out), clen(in), clen(out), C.int(acceleration)))
+	if outSize == 0 {
+		err = newCError("LZ4_compress_fast", outSize, "Insufficient space for compression")
 	}
 	return
 }
@@ -78,13 +167,69 @@ type Writer struct {
 	compressionBuffer [2]unsafe.Pointer
 	mallocBuffer      unsafe.Pointer
 	lz4Stream         *C.LZ4_stream_t
+	hcStream          *C.LZ4_streamHC_t
+	hcLevel           int
+	hcActive          bool
+	adaptive          *adaptiveLevel
+	limiter           *tokenBucket
+	verifyBuf         []byte
+	compressedBuf     []byte
+	blockSize         int
+	metadata          []byte
+	trailer           *trailerState
+	blockChecksum     Checksummer
+	blockHasher       Checksummer
 	underlyingWriter  io.Writer
+	closer            io.Closer
 	inpBufIndex       int
+	opts              options
+
+	// lastBlock is the last block compressed, the history of the next one.
+	// joined is set if the history of the stream extends before lastBlock,
+	// which happens when blocks follow each other in memory.
+	lastBlock []byte
+	joined    bool
+
+	// byteBuf holds the bytes written by WriteByte, compressed as a block
+	// once full or before anything else is written
+	byteBuf []byte
+
+	// dict is the dictionary of a DictionaryStore used by w, and
+	// dictPending is set when its record must precede the next block
+	dict        []byte
+	dictID      uint32
+	dictPending bool
+
+	// headerWidth is the width of the record headers, and preamblePending
+	// is set until the record announcing it is written
+	headerWidth     int
+	preamblePending bool
+
+	uncompressedWritten int64
+	compressedWritten   int64
+	lastSync            int64
+
+	// err is the WriteError failing w, and recordStart the offset of the
+	// records being written, where the stream is cut if they fail
+	err         error
+	recordStart int64
+	// reserved is the C memory accounted for by w
+	reserved int64
 }
 
 // NewWriter creates a new Writer. Writes to
 // the writer will be written in compressed form to w.
-func NewWriter(w io.Writer) *Writer {
+func NewWriter(w io.Writer, opts ...Option) *Writer {
+	return newWriter(w, newOptions(opts))
+}
+
+// newWriter creates a new Writer with the options o.
+func newWriter(w io.Writer, o options) *Writer {
+	blockSize := o.blockSize
+	if blockSize <= 0 {
+		blockSize = streamingBlockSize
+	}
+
 	// The input buffers MUST NOT be contiguous in memory. LZ4_compress_fast_continue has the
 	// following comment:
 	//
@@ -101,30 +246,105 @@ func NewWriter(w io.Writer) *Writer {
 
 	// Separate the buffers so LZ4 treats them as separate. Use 8 bytes to maintain 8 byte alignment,
 	// assuming malloc's result was aligned. This may permit optimizations on 64-bit CPUs.
-	const bufferSeparation = 8
-	mallocBuffer := C.malloc(2*streamingBlockSize + bufferSeparation)
+	reserved := int64(2*blockSize+bufferSeparation) + stateSize
+	if !reserveMemory(reserved) {
+		return &Writer{
+			lz4Stream:        C.LZ4_createStream(),
+			blockSize:        blockSize,
+			underlyingWriter: w,
+			closer:           underlyingCloser(w, o),
+			opts:             o,
+			err:              ErrMemoryBudgetExceeded,
+		}
+	}
+	mallocBuffer := C.malloc(C.size_t(2*blockSize + bufferSeparation))
 	buffer1 := mallocBuffer
-	buffer2 := unsafe.Pointer(uintptr(mallocBuffer) + streamingBlockSize + bufferSeparation)
+	buffer2 := unsafe.Pointer(uintptr(mallocBuffer) + uintptr(blockSize) + bufferSeparation)
 
-	return &Writer{
+	wr := &Writer{
 		compressionBuffer: [2]unsafe.Pointer{buffer1, buffer2},
 		mallocBuffer:      mallocBuffer,
 		lz4Stream:         C.LZ4_createStream(),
+		compressedBuf:     make([]byte, compressBound(blockSize)),
+		blockSize:         blockSize,
 		underlyingWriter:  w,
+		closer:            underlyingCloser(w, o),
+		opts:              o,
+		reserved:          reserved,
+		headerWidth:       blockHeaderSize,
+	}
+	if o.headerWidth > 0 && o.headerWidth != blockHeaderSize {
+		wr.headerWidth, wr.preamblePending = o.headerWidth, true
+	}
+	if o.discardOutput {
+		// control records are dropped too
+		wr.underlyingWriter = ioutil.Discard
 	}
+	if o.adaptive {
+		wr.adaptive = newAdaptiveLevel()
+	}
+	if o.rateLimit > 0 {
+		wr.limiter = newTokenBucket(o.rateLimit, blockSize)
+	}
+	if o.trailer {
+		wr.trailer = newTrailerState(o.newChecksummer(XXH64))
+	}
+	if o.blockChecksum {
+		wr.blockChecksum = o.newChecksummer(XXH32)
+	}
+	if o.dictionaries != nil {
+		if id, dict, ok := o.dictionaries.Current(); ok {
+			wr.dict, wr.dictID, wr.dictPending = dict, id, true
+		}
+	}
+	return wr
+}
+
+// CompressMultiWriter creates a new Writer that writes the same compressed
+// stream to all of ws, so the data is compressed only once. Writes stop at the
+// first writer returning an error, as with io.MultiWriter.
+func CompressMultiWriter(ws ...io.Writer) *Writer {
+	return NewWriter(io.MultiWriter(ws...))
 }
 
 // Write writes a compressed form of src to the underlying io.Writer.
+// If the underlying writer fails, Write returns the number of bytes of src
+// whose blocks were completely written along with a *WriteError.
 func (w *Writer) Write(src []byte) (int, error) {
+	return w.write(src, false)
+}
+
+// CompressFrom writes a compressed form of src to the underlying io.Writer,
+// like Write, but compresses src in place instead of copying it to an internal
+// buffer first. The caller guarantees that the memory of src stays in place
+// and unmodified until the next call writing to w, or Close, returns, since
+// the compression of the next block references it. It suits memory outside
+// of the Go heap, such as a memory-mapped file.
+func (w *Writer) CompressFrom(src []byte) (int, error) {
+	return w.write(src, true)
+}
+
+// write compresses src in blocks of the block size of w, in place if stable
+// is set.
+func (w *Writer) write(src []byte, stable bool) (int, error) {
+	if err := w.flushBytes(); err != nil {
+		return 0, err
+	}
+	if w.opts.independentWrites && len(src) > 0 {
+		w.ResetState()
+	}
+	if len(src) == 0 && w.opts.emptyWrites {
+		return w.writeFrame(src, stable)
+	}
 	remainingBytes := len(src)
 	totalWritten := 0
 
 	for remainingBytes > 0 {
-		endIdx := totalWritten + streamingBlockSize
+		endIdx := totalWritten + w.blockSize
 		if endIdx > len(src) {
 			endIdx = len(src)
 		}
-		written, err := w.writeFrame(src[totalWritten:endIdx])
+		written, err := w.writeFrame(src[totalWritten:endIdx], stable)
 		if err != nil {
 			return totalWritten, err
 		}
@@ -135,184 +355,273 @@ func (w *Writer) Write(src []byte) (int, error) {
 	return totalWritten, nil
 }
 
-func (w *Writer) writeFrame(src []byte) (int, error) {
-	var compressedBuf [boundedStreamingBlockSize]byte
-	inpPtr := w.nextInputBuffer()
+// WriteBlock compresses src as exactly one block and writes it to the
+// underlying io.Writer. It can be read back as a unit with
+// DecompressReader.ReadBlock, which suits protocols mapping one message to one
+// block. src must not be larger than the block size of w, 64 KiB by default.
+// An empty src is written as an empty block, which ReadBlock returns as an
+// empty slice.
+func (w *Writer) WriteBlock(src []byte) error {
+	if len(src) > w.blockSize {
+		return fmt.Errorf("block too large: %d bytes", len(src))
+	}
+	if err := w.flushBytes(); err != nil {
+		return err
+	}
+	if w.opts.independentWrites {
+		w.ResetState()
+	}
+	_, err := w.writeFrame(src, false)
+	return err
+}
+
+// CompressedBytesWritten returns the number of bytes written to the underlying
+// io.Writer so far, including block headers.
+func (w *Writer) CompressedBytesWritten() int64 {
+	return w.compressedWritten
+}
+
+// writeFrame compresses src as one block and writes it. Unless stable is set,
+// src is first copied to the next input buffer.
+func (w *Writer) writeFrame(src []byte, stable bool) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	// the records of the block start here
+	w.recordStart = w.compressedWritten
+	if w.opts.independentBlocks {
+		w.ResetState()
+	}
+	if w.opts.syncInterval > 0 && w.uncompressedWritten-w.lastSync >= w.opts.syncInterval {
+		if err := w.writeSync(); err != nil {
+			return 0, err
+		}
+	}
+	if w.dictPending {
+		if err := w.writeDictionary(); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := w.writeMetadata(); err != nil {
+		return 0, err
+	}
+	if w.blockChecksum != nil {
+		if err := w.writeBlockChecksum(src); err != nil {
+			return 0, err
+		}
+	}
 
-	copy(inpPtr, src)
+	compressedBuf := w.compressedBuf
+	input := src
+	if !stable {
+		input = w.nextInputBuffer()[:len(src)]
+		copy(input, src)
+	}
 
-	written := int(C.LZ4_compress_fast_continue(
-		w.lz4Stream,
-		p(inpPtr),
-		p(compressedBuf[:]),
-		C.int(len(src)),
-		C.int(len(compressedBuf)),
-		1))
+	level := w.opts.level
+	var start time.Time
+	if w.adaptive != nil {
+		level = w.adaptive.level()
+		start = time.Now()
+	}
+	if w.opts.levelFunc != nil {
+		level = w.blockLevel(src)
+	}
+	written := w.compressBlock(input, compressedBuf, level)
 	if written <= 0 {
-		return 0, errors.New("error compressing")
+		fn := "LZ4_compress_fast_continue"
+		if w.hcActive {
+			fn = "LZ4_compress_HC_continue"
+		}
+		return 0, newCError(fn, written, "error compressing")
+	}
+	if w.opts.verify {
+		if err := w.verifyBlock(src, compressedBuf[:written]); err != nil {
+			return 0, err
+		}
+	}
+	var compressTime time.Duration
+	if w.adaptive != nil {
+		compressTime = time.Since(start)
+		// the time spent waiting for the rate limit is counted as writing,
+		// as it also leaves time for stronger compression
+		start = time.Now()
+	}
+	if w.limiter != nil {
+		w.limiter.wait(blockHeaderSize + written)
 	}
 
 	// Write "header" to the buffer for decompression
 	var header [4]byte
-	binary.LittleEndian.PutUint32(header[:], uint32(written))
-	_, err := w.underlyingWriter.Write(header[:])
-	if err != nil {
-		return 0, err
-	}
+	if !w.opts.discardOutput {
+		binary.LittleEndian.PutUint32(header[:], uint32(written))
+		if err := w.writeRecord(header[:]); err != nil {
+			return 0, err
+		}
 
-	// Write to underlying buffer
-	_, err = w.underlyingWriter.Write(compressedBuf[:written])
-	if err != nil {
-		return 0, err
+		// Write to underlying buffer
+		if err := w.writeUnderlying(compressedBuf[:written]); err != nil {
+			return 0, err
+		}
 	}
 
+	if w.adaptive != nil {
+		w.adaptive.update(compressTime, time.Since(start))
+	}
+	if w.trailer != nil {
+		w.trailer.update(src)
+	}
+	if w.opts.blockHash != nil {
+		w.reportBlockHash(src)
+	}
+	w.lastBlock = input
+	w.uncompressedWritten += int64(len(src))
+	w.compressedWritten += int64(len(header) + written)
 	return len(src), nil
 }
 
+// compressBlock compresses src, which must stay in place until the next block
+// is compressed, into dst and returns the compressed size, or 0 on error.
+// Positive levels use HC compression at that level, negative levels use fast
+// compression with the opposite as acceleration, and 0 uses the default fast
+// compression.
+func (w *Writer) compressBlock(src, dst []byte, level int) int {
+	n := len(w.lastBlock)
+	contiguous := n > 0 && len(src) > 0 &&
+		uintptr(unsafe.Pointer(&w.lastBlock[0]))+uintptr(n) == uintptr(unsafe.Pointer(&src[0]))
+	if (contiguous || w.joined) && n > 0 && n < streamingBlockSize {
+		// lz4 treats input following the previous block in memory as one
+		// with it, and would reference the blocks before it, which the
+		// decoder does not keep: restrict the history to the previous block
+		C.LZ4_loadDict(w.lz4Stream, p(w.lastBlock), clen(w.lastBlock))
+		w.hcActive = false
+	}
+	w.joined = contiguous
+	if level > 0 && (w.hcStream != nil || w.reserveHC()) {
+		return w.compressHCBlock(src, dst, level)
+	}
+	if w.hcActive {
+		// the fast stream does not know about the blocks compressed with HC
+		C.LZ4_loadDict(w.lz4Stream, p(w.lastBlock), clen(w.lastBlock))
+		w.hcActive = false
+	}
+	acceleration := 1
+	if level < 0 {
+		acceleration = -level
+	}
+	return int(C.LZ4_compress_fast_continue(
+		w.lz4Stream,
+		p(src),
+		p(dst),
+		clen(src),
+		clen(dst),
+		C.int(acceleration)))
+}
+
+// writeSync writes a sync marker and resets the compression history, so the
+// next block can be decoded without the preceding ones.
+func (w *Writer) writeSync() error {
+	record := appendSyncRecord(nil, w.uncompressedWritten)
+	if err := w.writeRecord(record); err != nil {
+		return err
+	}
+	w.ResetState()
+	w.lastSync = w.uncompressedWritten
+	w.compressedWritten += int64(len(record))
+	return nil
+}
+
+// ResetState clears the compression history, keeping the lz4 state and
+// buffers, so the next block does not reference the data written before, for
+// example at the boundary between the records of different tenants. Unlike a
+// sync marker, nothing is written to the stream: readers decode it as usual,
+// but cannot start decoding at the boundary.
+func (w *Writer) ResetState() {
+	// an error is kept by w for the next write
+	w.flushBytes()
+	C.LZ4_resetStream_fast(w.lz4Stream)
+	// an HC stream is reset from the empty previous block when next used
+	w.hcActive = false
+	w.lastBlock = nil
+	w.joined = false
+	// the dictionary is lost with the history, so it is loaded again
+	w.dictPending = w.dict != nil
+}
+
 func (w *Writer) nextInputBuffer() []byte {
 	w.inpBufIndex = (w.inpBufIndex + 1) % 2
-	return unsafe.Slice((*byte)(w.compressionBuffer[w.inpBufIndex]), streamingBlockSize)
+	return unsafe.Slice((*byte)(w.compressionBuffer[w.inpBufIndex]), w.blockSize)
+}
+
+// loadDict uses dict as the history for the next block. dict must be part of
+// the last block seen by the decoder, since that is all the history it keeps.
+func (w *Writer) loadDict(dict []byte) {
+	if w.mallocBuffer == nil {
+		// w failed to allocate its buffers
+		return
+	}
+	if len(dict) > streamingBlockSize {
+		dict = dict[len(dict)-streamingBlockSize:]
+	}
+	if len(dict) > w.blockSize {
+		dict = dict[len(dict)-w.blockSize:]
+	}
+	// the dictionary is kept in the current input buffer, which stays
+	// untouched while the next block is compressed from the other one
+	buf := unsafe.Slice((*byte)(w.compressionBuffer[w.inpBufIndex]), w.blockSize)
+	n := copy(buf, dict)
+	C.LZ4_loadDict(w.lz4Stream, p(buf), C.int(n))
+	w.hcActive = false
+	w.lastBlock = buf[:n]
+	w.joined = false
 }
 
 // Close releases all the resources occupied by Writer.
 // w cannot be used after the release.
 func (w *Writer) Close() error {
 	if w.lz4Stream != nil {
+		err := w.flushBytes()
+		if err == nil {
+			err = w.writeTrailer()
+		}
+		if err == nil {
+			err = w.writePadding()
+		}
 		C.LZ4_freeStream(w.lz4Stream)
 		w.lz4Stream = nil
+		if w.hcStream != nil {
+			C.LZ4_freeStreamHC(w.hcStream)
+			w.hcStream = nil
+		}
+		if w.opts.zeroize {
+			w.zeroizeBuffers()
+		}
 		C.free(w.mallocBuffer)
 		w.mallocBuffer = nil
+		releaseMemory(w.reserved)
+		w.reserved = 0
+		if w.closer != nil {
+			if cerr := w.closer.Close(); err == nil {
+				err = cerr
+			}
+		}
+		return err
 	}
 	return nil
 }
 
-// reader is an io.ReadCloser that decompresses when read from.
-type reader struct {
-	lz4Stream        *C.LZ4_streamDecode_t
-	pending          []byte
-	left             unsafe.Pointer
-	right            unsafe.Pointer
-	underlyingReader io.Reader
-	isLeft           bool
-}
-
 // NewReader creates a new io.ReadCloser.  Reads from the returned ReadCloser
 // read and decompress data from r.  It is the caller's responsibility to call
 // Close on the ReadCloser when done.  If this is not done, underlying objects
 // in the lz4 library will not be freed.
 //
-// Deprecated: Use NewDecompressReader instead. It can decompress the output
-// of NewWriter, but uses fewer allocations.
+// It is equivalent to NewDecompressReader, which replaced its original
+// implementation: each Read returns data from at most one block.
+//
+// Deprecated: Use NewDecompressReader instead.
 func NewReader(r io.Reader) io.ReadCloser {
-	return &reader{
-		lz4Stream:        C.LZ4_createStreamDecode(),
-		underlyingReader: r,
-		isLeft:           true,
-		// As per lz4 docs:
-		//
-		//   *_continue() :
-		//     These decoding functions allow decompression of multiple blocks in "streaming" mode.
-		//     Previously decoded blocks must still be available at the memory position where they were decoded.
-		//
-		// double buffer needs to use C.malloc to make sure the same memory address
-		// allocate buffers in go memory will fail randomly since GC may move the memory
-		left:  C.malloc(boundedStreamingBlockSize),
-		right: C.malloc(boundedStreamingBlockSize),
-	}
-}
-
-// Close releases all the resources occupied by r.
-// r cannot be used after the release.
-func (r *reader) Close() error {
-	if r.lz4Stream != nil {
-		C.LZ4_freeStreamDecode(r.lz4Stream)
-		r.lz4Stream = nil
-	}
-
-	C.free(r.left)
-	C.free(r.right)
-	return nil
-}
-
-// Read decompresses `compressionBuffer` into `dst`.
-// dst buffer must of at least streamingBlockSize bytes large
-func (r *reader) Read(dst []byte) (int, error) {
-	if len(dst) == 0 {
-		return 0, nil
-	}
-	// Write data read from a previous call
-	if r.pending != nil {
-		return r.readFromPending(dst)
-	}
-
-	blockSize, err := r.readSize(r.underlyingReader)
-	if err != nil {
-		return 0, err
-	}
-
-	// read blockSize from r.underlyingReader --> readBuffer
-	var uncompressedBuf [boundedStreamingBlockSize]byte
-	_, err = io.ReadFull(r.underlyingReader, uncompressedBuf[:blockSize])
-	if err != nil {
-		return 0, err
-	}
-
-	var ptr unsafe.Pointer
-	if r.isLeft {
-		ptr = r.left
-		r.isLeft = false
-	} else {
-		ptr = r.right
-		r.isLeft = true
-	}
-
-	decompressed := int(C.LZ4_decompress_safe_continue(
-		r.lz4Stream,
-		(*C.char)(unsafe.Pointer(&uncompressedBuf[0])),
-		(*C.char)(ptr),
-		C.int(blockSize),
-		C.int(streamingBlockSize),
-	))
-
-	if decompressed < 0 {
-		// io.Reader requires Read to return a value in range [0, len(dst)]
-		return 0, fmt.Errorf("error decompressing; result=%d", decompressed)
-	}
-
-	mySlice := C.GoBytes(ptr, C.int(decompressed))
-	copySize := min(decompressed, len(dst))
-
-	copied := copy(dst, mySlice[:copySize])
-
-	if decompressed > len(dst) {
-		// Save data for future reads
-		r.pending = mySlice[copied:]
-	}
-
-	return copied, nil
-}
-
-// read the 4-byte little endian size from the head of each stream compressed block
-func (r *reader) readSize(rdr io.Reader) (int, error) {
-	var temp [4]byte
-	_, err := io.ReadFull(rdr, temp[:])
-	if err != nil {
-		return 0, err
-	}
-
-	return int(binary.LittleEndian.Uint32(temp[:])), nil
-}
-
-func (r *reader) readFromPending(dst []byte) (int, error) {
-	copySize := min(len(dst), len(r.pending))
-	copied := copy(dst, r.pending[:copySize])
-
-	if copied == len(r.pending) {
-		r.pending = nil
-	} else {
-		r.pending = r.pending[copied:]
-	}
-	return copied, nil
+	return NewDecompressReader(r)
 }
 
 // CompressReader reads input and creates an io.ReadCloser for reading
@@ -321,53 +630,96 @@ type CompressReader struct {
 	underlyingReader  io.Reader
 	compressionBuffer [2]unsafe.Pointer
 	mallocBuffer      unsafe.Pointer
-	outputBuffer      *bytes.Reader
+	out               blockEngine
 	lz4Stream         *C.LZ4_stream_t
 	inpBufIndex       int
 	compressedBuffer  unsafe.Pointer
+	closer            io.Closer
+	independent       bool
+	zeroize           bool
+	reserved          int64
+	// hcStream compresses the blocks instead of lz4Stream for positive
+	// levels, and acceleration is that of fast compression otherwise
+	hcStream     *C.LZ4_streamHC_t
+	level        int
+	acceleration int
 }
 
 // NewCompressReader creates a new io.ReadCloser.  Reads from the returned ReadCloser
 // read and compress data from r.  It is the caller's responsibility to call
 // Close on the ReadCloser when done.  If this is not done, underlying objects
 // in the lz4 library will not be freed. The compressed output must be decompressed
-// using NewDecompressReader.
-func NewCompressReader(r io.Reader) *CompressReader {
+// using NewDecompressReader. WithLevel selects the compression level as for
+// Writer: positive levels use HC compression, with LZ4_compress_HC_continue,
+// and negative levels fast compression with an acceleration.
+func NewCompressReader(r io.Reader, opts ...Option) *CompressReader {
 	// The input buffers MUST NOT be contiguous in memory so the two blocks are treated as separate.
 	// We had a bug in Writer when malloc decided to allocate buffers contiguously. This bug does
 	// not happen with CompressReader, because we only have "partial" blocks at EOF, and we need two
 	// calls to LZ4_compress_fast_continue with sizes < 64 kiB to trigger the problem. However, we
 	// should separate these buffers explicitly, to make this impossible. For details, see the
-	// comment in NewWriter.
-
-	// Separate the buffers so LZ4 treats them as separate. Use 8 bytes to maintain 8 byte alignment,
-	// assuming malloc's result was aligned. This may permit optimizations on 64-bit CPUs.
-	const bufferSeparation = 8
-	mallocBuffer := C.malloc(2*hugeStreamingBlockSize + bufferSeparation)
-	buffer1 := mallocBuffer
-	buffer2 := unsafe.Pointer(uintptr(mallocBuffer) + hugeStreamingBlockSize + bufferSeparation)
+	// comment in newWriter.
+	o := newOptions(opts)
+	reserved := int64(2*hugeStreamingBlockSize+bufferSeparation) + stateSize
+	if o.independentBlocks {
+		reserved = hugeStreamingBlockSize + stateSize
+	}
+	reserved += boundedHugeStreamingBlockSize + blockHeaderSize
+	if o.level > 0 {
+		reserved += stateHCSize
+	}
+	if !reserveMemory(reserved) {
+		cr := &CompressReader{
+			lz4Stream:        C.LZ4_createStream(),
+			underlyingReader: r,
+			closer:           underlyingCloser(r, o),
+		}
+		cr.out.next = failBudget
+		return cr
+	}
+	var mallocBuffer, buffer1, buffer2 unsafe.Pointer
+	if o.independentBlocks {
+		// blocks have no history, so a single buffer is enough
+		mallocBuffer = C.malloc(hugeStreamingBlockSize)
+		buffer1, buffer2 = mallocBuffer, mallocBuffer
+	} else {
+		mallocBuffer = C.malloc(2*hugeStreamingBlockSize + bufferSeparation)
+		buffer1 = mallocBuffer
+		buffer2 = unsafe.Pointer(uintptr(mallocBuffer) + hugeStreamingBlockSize + bufferSeparation)
+	}
 
-	return &CompressReader{
+	cr := &CompressReader{
 		compressionBuffer: [2]unsafe.Pointer{buffer1, buffer2},
 		mallocBuffer:      mallocBuffer,
 		lz4Stream:         C.LZ4_createStream(),
 		underlyingReader:  r,
-		outputBuffer:      bytes.NewReader(nil),
 		compressedBuffer:  C.malloc(boundedHugeStreamingBlockSize + blockHeaderSize),
+		closer:            underlyingCloser(r, o),
+		independent:       o.independentBlocks,
+		zeroize:           o.zeroize,
+		reserved:          reserved,
+		level:             o.level,
+		acceleration:      1,
+	}
+	if o.level > 0 {
+		cr.hcStream = C.LZ4_createStreamHC()
+		C.LZ4_resetStreamHC_fast(cr.hcStream, C.int(o.level))
+	} else if o.level < 0 {
+		cr.acceleration = -o.level
 	}
+	cr.out.next = cr.compressBlock
+	cr.out.earlyEOF = o.earlyEOF
+	return cr
 }
 
 // Read compresses data from the underlyingReader into dst.
 func (r *CompressReader) Read(dst []byte) (int, error) {
-	// try to consume from the buffer
-	n, _ := r.outputBuffer.Read(dst)
-	// ignoring err which can only be EOF in which case bytes read is 0
-	if n > 0 {
-		// if the buffer contains anything it's leftover from a previous call
-		return n, nil
-	}
+	return r.out.read(dst)
+}
 
-	// the buffer is empty, we are going to write into it so we reset it first
+// compressBlock reads the next block of input and returns it compressed,
+// preceded by its header.
+func (r *CompressReader) compressBlock() ([]byte, error) {
 	totalBlockSize := boundedHugeStreamingBlockSize + blockHeaderSize
 	inpPtr := r.nextInputBuffer()
 	outPtr := ptrToByteSlice(r.compressedBuffer, totalBlockSize, totalBlockSize)
@@ -375,36 +727,41 @@ func (r *CompressReader) Read(dst []byte) (int, error) {
 	bytesRead, err := io.ReadFull(r.underlyingReader, inpPtr)
 	if err == io.EOF {
 		// nothing left to read from the source
-		return 0, err
+		return nil, err
 	}
 	if err != nil && err != io.ErrUnexpectedEOF {
 		// ErrUnexpectedEOF occurs when some bytes are read but not all the bytes (n > 0)
-		return 0, fmt.Errorf("error reading source: %s", err)
+		return nil, fmt.Errorf("error reading source: %w", err)
 	}
 
 	// compress and write the data into compressedBuf, leaving space for the
 	// 4 byte header
-	written := int(C.LZ4_compress_fast_continue(
-		r.lz4Stream,
-		p(inpPtr),
-		p(outPtr[blockHeaderSize:]),
-		C.int(bytesRead),
-		C.int(boundedHugeStreamingBlockSize),
-		1))
+	var written int
+	if r.hcStream != nil {
+		written = r.compressHCBlock(inpPtr[:bytesRead], outPtr[blockHeaderSize:])
+	} else {
+		if r.independent {
+			C.LZ4_resetStream_fast(r.lz4Stream)
+		}
+		written = int(C.LZ4_compress_fast_continue(
+			r.lz4Stream,
+			p(inpPtr),
+			p(outPtr[blockHeaderSize:]),
+			C.int(bytesRead),
+			C.int(boundedHugeStreamingBlockSize),
+			C.int(r.acceleration)))
+	}
 	if written <= 0 {
-		return 0, errors.New("error compressing")
+		fn := "LZ4_compress_fast_continue"
+		if r.hcStream != nil {
+			fn = "LZ4_compress_HC_continue"
+		}
+		return nil, newCError(fn, written, "error compressing")
 	}
 
 	// write "header" to the buffer for decompression at the first 4 bytes
 	binary.LittleEndian.PutUint32(outPtr[:blockHeaderSize], uint32(written))
-
-	// populate the buffer with our internal slice and consume from it
-	r.outputBuffer = bytes.NewReader(outPtr[:written+blockHeaderSize])
-	n, _ = r.outputBuffer.Read(dst)
-	// here we ignore any EOF because the buffer contains partial data only
-	// EOF will be communicated on the next call if the underlying Reader is exhausted
-
-	return n, nil
+	return outPtr[:written+blockHeaderSize], nil
 }
 
 func (r *CompressReader) nextInputBuffer() []byte {
@@ -418,10 +775,22 @@ func (r *CompressReader) Close() error {
 	if r.lz4Stream != nil {
 		C.LZ4_freeStream(r.lz4Stream)
 		r.lz4Stream = nil
+		if r.hcStream != nil {
+			C.LZ4_freeStreamHC(r.hcStream)
+			r.hcStream = nil
+		}
+		if r.zeroize {
+			r.zeroizeBuffers()
+		}
 		C.free(r.mallocBuffer)
 		r.mallocBuffer = nil
 		C.free(r.compressedBuffer)
 		r.compressedBuffer = nil
+		releaseMemory(r.reserved)
+		r.reserved = 0
+		if r.closer != nil {
+			return r.closer.Close()
+		}
 	}
 
 	return nil
@@ -430,103 +799,521 @@ func (r *CompressReader) Close() error {
 // DecompressReader is an io.ReadCloser that decompresses when read from.
 type DecompressReader struct {
 	lz4Stream           *C.LZ4_streamDecode_t
-	outputBuffer        *bytes.Reader
+	out                 blockEngine
+	block               []byte
 	decompressionBuffer [2]unsafe.Pointer
 	underlyingReader    io.Reader
 	inpBufIndex         int
 	compressedBuffer    unsafe.Pointer
+	closer              io.Closer
+	opts                options
+
+	// maxBlockSize and maxCompressedSize bound the blocks that can be
+	// decoded. In low-memory mode, decompressionBuffer[0] is a ring buffer
+	// of ringSize bytes, where the next block is decoded at ringPos.
+	maxBlockSize      int
+	maxCompressedSize int
+	ringSize          int
+	ringPos           int
+	// external is set if the buffers were supplied by the caller, and
+	// reserved is the C memory accounted for otherwise
+	external bool
+	reserved int64
+	// independent is set if blocks are decoded without history, into the
+	// single buffer decompressionBuffer[0]; history is then the dictionary
+	// of the next block, if any
+	independent bool
+	history     []byte
+	// strictSize is the expected block size in strict mode, and
+	// shortBlockSeen is set once a block shorter than that was decoded
+	strictSize     int
+	shortBlockSeen bool
+
+	// record holds the bytes of the record being decoded, to rescan them
+	// if it turns out to be corrupt
+	record struct {
+		headerBuf [maxHeaderWidth]byte
+		header    []byte
+		payload   []byte
+	}
+	compressedRead   int64
+	uncompressedRead int64
+	blocksDecoded    int64
+	// headerWidth is the width of the record headers, set by the preamble,
+	// which fails the reader if defaultWidthOnly is set
+	headerWidth      int
+	defaultWidthOnly bool
+
+	readAhead *blockReadAhead
+	timeout   readTimeout
+	// armed is set while a call to Read or ReadBlock, which armed the
+	// deadline, is in progress
+	armed bool
+	trailer   *trailerState
+
+	// blockSum is the checksum of the next block, if hasBlockSum is set
+	blockChecksum Checksummer
+	blockSum      uint64
+	hasBlockSum   bool
+
+	// info describes the stream once the first block is decoded; sumSeen
+	// is set once a block checksum was read
+	info    *StreamInfo
+	sumSeen bool
+	dictID  uint32
 }
 
-// NewDecompressReader creates a new io.ReadCloser. This function mirrors the
-// behavior of NewReader but provides better performance.
+// NewDecompressReader creates a new io.ReadCloser. Reads from the returned
+// ReadCloser read and decompress data from r.
 // It is the caller's responsibility to call Close on the ReadCloser when done.
 // If this is not done, underlying objects in the lz4 library will not be freed.
-func NewDecompressReader(r io.Reader) io.ReadCloser {
-	return &DecompressReader{
-		lz4Stream:        C.LZ4_createStreamDecode(),
-		underlyingReader: r,
-		decompressionBuffer: [2]unsafe.Pointer{
-			// double buffer needs to use C.malloc to make sure the same memory address
-			// allocate buffers in go memory will fail randomly since GC may move the memory
+// The returned ReadCloser is a *DecompressReader.
+func NewDecompressReader(r io.Reader, opts ...Option) io.ReadCloser {
+	return newDecompressReader(r, newOptions(opts), nil)
+}
+
+// newDecompressReader creates a DecompressReader, decoding into buf in
+// low-memory mode if it is not nil.
+func newDecompressReader(r io.Reader, o options, buf []byte) *DecompressReader {
+	dr := &DecompressReader{
+		lz4Stream:         C.LZ4_createStreamDecode(),
+		underlyingReader:  r,
+		closer:            underlyingCloser(r, o),
+		opts:              o,
+		maxBlockSize:      hugeStreamingBlockSize,
+		maxCompressedSize: boundedHugeStreamingBlockSize,
+		strictSize:        o.strictBlockSize,
+		timeout:           newReadTimeout(r, o),
+		headerWidth:       blockHeaderSize,
+	}
+	if buf == nil {
+		dr.reserved = decompressMemory(o)
+		if !reserveMemory(dr.reserved) {
+			dr.reserved = 0
+			dr.out.next = failBudget
+			return dr
+		}
+	}
+	if o.lowMemory || buf != nil {
+		dr.maxBlockSize = lowMemoryBlockSize(o)
+		dr.maxCompressedSize = compressBound(dr.maxBlockSize)
+	}
+	if buf != nil {
+		dr.ringSize = decoderRingBufferSize(dr.maxBlockSize)
+		dr.decompressionBuffer[0] = unsafe.Pointer(&buf[0])
+		dr.compressedBuffer = unsafe.Pointer(&buf[dr.ringSize])
+		dr.external = true
+	} else if o.independentBlocks {
+		dr.decompressionBuffer[0] = C.malloc(C.size_t(dr.maxBlockSize))
+		if decodesAhead(o, buf) {
+			dr.decompressionBuffer[1] = C.malloc(C.size_t(dr.maxBlockSize))
+		}
+		dr.independent = true
+	} else if o.lowMemory {
+		dr.ringSize = decoderRingBufferSize(dr.maxBlockSize)
+		dr.decompressionBuffer[0] = C.malloc(C.size_t(dr.ringSize))
+	} else {
+		// double buffer needs to use C.malloc to make sure the same memory address
+		// allocate buffers in go memory will fail randomly since GC may move the memory
+		dr.decompressionBuffer = [2]unsafe.Pointer{
 			C.malloc(hugeStreamingBlockSize),
 			C.malloc(hugeStreamingBlockSize),
-		},
-		outputBuffer:     bytes.NewReader(nil),
-		compressedBuffer: C.malloc(boundedHugeStreamingBlockSize),
+		}
 	}
+	if !dr.external {
+		dr.compressedBuffer = C.malloc(C.size_t(dr.maxCompressedSize))
+	}
+	if o.trailer {
+		dr.trailer = newTrailerState(o.newChecksummer(XXH64))
+	}
+	if o.blockChecksum {
+		dr.blockChecksum = o.newChecksummer(XXH32)
+	}
+	dr.out.next = dr.nextArmed
+	dr.out.fill = o.fillBuffer
+	dr.out.earlyEOF = o.earlyEOF
+	if decodesAhead(o, buf) {
+		dr.readAhead = newBlockReadAhead(dr.decodeAhead, dr.timeout)
+		dr.out.next = dr.readAhead.next
+		dr.timeout.timeout = 0
+	}
+	return dr
 }
 
 // Read decompresses data from the underlying reader into `dst`.
 func (r *DecompressReader) Read(dst []byte) (int, error) {
-	// write data read from a previous call
-	n, _ := r.outputBuffer.Read(dst)
-	// ignoring err which can only be EOF in which case bytes read is 0
-	if n > 0 {
-		// if the buffer contains anything it's leftover from a previous call
-		return n, nil
+	if err := r.timeout.arm(); err != nil {
+		return 0, err
+	}
+	r.armed = true
+	n, err := r.out.read(dst)
+	r.armed = false
+	return n, err
+}
+
+// ReadBlock decompresses the next block from the underlying reader and returns
+// its content, exactly as passed to one call to Writer.WriteBlock, or one
+// block of a Writer.Write. The returned slice is only valid until the next
+// call to r. If Read left part of a block unread, ReadBlock returns the rest of
+// that block.
+func (r *DecompressReader) ReadBlock() ([]byte, error) {
+	if err := r.timeout.arm(); err != nil {
+		return nil, err
+	}
+	r.armed = true
+	block, err := r.out.readBlock()
+	r.armed = false
+	return block, err
+}
+
+// nextBlock decodes and returns the next block, skipping corrupt data in
+// recovery mode.
+func (r *DecompressReader) nextBlock() ([]byte, error) {
+	for {
+		err := r.decodeBlock()
+		if err == nil {
+			return r.block, nil
+		}
+		if err == io.EOF && r.trailer != nil && !r.trailer.seen {
+			return nil, ErrMissingTrailer
+		}
+		var ce *corruptionError
+		if r.opts.recovery == nil || !errors.As(err, &ce) {
+			return nil, err
+		}
+		if err := r.resync(ce); err != nil {
+			return nil, err
+		}
 	}
+}
 
-	compressedBlockSize, err := r.readSize(r.underlyingReader)
+// CompressedBytesRead returns the number of bytes consumed from the underlying
+// reader by the blocks decoded so far, including block headers.
+func (r *DecompressReader) CompressedBytesRead() int64 {
+	if r.readAhead != nil {
+		return r.readAhead.last.compressedRead
+	}
+	return r.compressedRead
+}
+
+// UncompressedBytesRead returns the number of uncompressed bytes returned by
+// Read so far.
+func (r *DecompressReader) UncompressedBytesRead() int64 {
+	if r.readAhead != nil {
+		return r.readAhead.last.uncompressedRead - int64(len(r.out.pending))
+	}
+	return r.uncompressedRead - int64(len(r.out.pending))
+}
+
+// decodeBlock reads the next block from the underlying reader, handling any
+// control records preceding it, and decompresses it into r.block.
+func (r *DecompressReader) decodeBlock() error {
+	compressedBlockSize, err := r.readBlockSize()
 	if err != nil {
-		return 0, err
+		return err
 	}
 
-	inPtr := ptrToByteSlice(r.compressedBuffer, boundedHugeStreamingBlockSize, boundedHugeStreamingBlockSize)
+	inPtr := ptrToByteSlice(r.compressedBuffer, r.maxCompressedSize, r.maxCompressedSize)
 	outPtr := r.nextDecompressionBuffer()
 
 	// read the compressed blockSize from r.underlyingReader
-	_, err = io.ReadFull(r.underlyingReader, inPtr[:compressedBlockSize])
+	n, err := io.ReadFull(r.underlyingReader, inPtr[:compressedBlockSize])
+	r.record.payload = inPtr[:n]
 	if err != nil {
-		return 0, err
+		err = unexpectedEOF(err)
+		if r.opts.recovery != nil && err == io.ErrUnexpectedEOF {
+			// a truncated block may come from a corrupted header
+			return &corruptionError{err}
+		}
+		return err
 	}
 
-	decompressed := int(C.LZ4_decompress_safe_continue(
-		r.lz4Stream,
-		p(inPtr),
-		p(outPtr),
-		C.int(compressedBlockSize),
-		C.int(hugeStreamingBlockSize),
-	))
+	var decompressed int
+	if r.independent {
+		decompressed = int(C.LZ4_decompress_safe_usingDict(
+			p(inPtr),
+			p(outPtr),
+			C.int(compressedBlockSize),
+			C.int(r.maxBlockSize),
+			p(r.history),
+			clen(r.history),
+		))
+		r.history = nil
+	} else {
+		decompressed = int(C.LZ4_decompress_safe_continue(
+			r.lz4Stream,
+			p(inPtr),
+			p(outPtr),
+			C.int(compressedBlockSize),
+			C.int(r.maxBlockSize),
+		))
+	}
 
 	if decompressed < 0 {
-		return decompressed, errors.New("error decompressing")
+		fn := "LZ4_decompress_safe_continue"
+		if r.independent {
+			fn = "LZ4_decompress_safe_usingDict"
+		}
+		return &corruptionError{newCError(fn, decompressed, "error decompressing")}
+	}
+	if err := r.checkBlock(outPtr[:decompressed]); err != nil {
+		return err
+	}
+	if err := r.checkBlockSize(decompressed); err != nil {
+		return err
+	}
+	if r.info == nil {
+		r.info = &StreamInfo{
+			Format:        FormatCustomStream,
+			BlockSize:     decompressed,
+			BlockChecksum: r.sumSeen,
+			ContentSize:   -1,
+			DictID:        r.dictID,
+		}
 	}
+	if r.ringSize > 0 {
+		r.ringPos += decompressed
+	}
+
+	r.reportBlock(r.compressedRead, compressedBlockSize, decompressed)
+	r.compressedRead += int64(r.headerWidth + compressedBlockSize)
+	r.uncompressedRead += int64(decompressed)
+	r.block = outPtr[:decompressed]
+	if r.trailer != nil {
+		r.trailer.update(r.block)
+	}
+	return nil
+}
 
-	// write the decompressed data to the output buffer
-	r.outputBuffer = bytes.NewReader(outPtr[:decompressed])
-	// read as much as we can into dst, ignoring any EOF
-	n, _ = r.outputBuffer.Read(dst)
+// corruptionError is returned for invalid data in the compressed stream, as
+// opposed to errors from the underlying reader.
+type corruptionError struct {
+	err error
+}
 
-	return n, nil
+func (e *corruptionError) Error() string {
+	return e.err.Error()
+}
+
+func (e *corruptionError) Unwrap() error {
+	return e.err
+}
+
+// resync skips the stream ahead to the next sync marker after the corrupt
+// record that caused ce, and reports the skipped range to the recovery
+// callback.
+func (r *DecompressReader) resync(ce *corruptionError) error {
+	start := r.compressedRead
+
+	// rescan the record from its second byte, since a corrupted header may
+	// have swallowed the marker
+	consumed := make([]byte, 0, len(r.record.header)+len(r.record.payload))
+	consumed = append(consumed, r.record.header...)
+	consumed = append(consumed, r.record.payload...)
+	br := bufio.NewReader(io.MultiReader(bytes.NewReader(consumed[1:]), r.underlyingReader))
+	skipped, err := skipToSync(br, r.headerWidth)
+	buffered, _ := br.Peek(br.Buffered())
+	r.underlyingReader = io.MultiReader(bytes.NewReader(append([]byte(nil), buffered...)), r.underlyingReader)
+
+	r.compressedRead = start + 1 + skipped
+	// the data lost can no longer be verified
+	r.trailer = nil
+	r.hasBlockSum = false
+	r.shortBlockSeen = false
+	r.opts.recovery(SkippedRange{
+		Offset:             start,
+		Length:             1 + skipped,
+		UncompressedOffset: r.uncompressedRead,
+		Err:                ce.err,
+	})
+	return err
 }
 
 // Close releases all the resources occupied by r.
 // r cannot be used after the release.
 func (r *DecompressReader) Close() error {
 	if r.lz4Stream != nil {
+		if r.readAhead != nil {
+			r.readAhead.close()
+		}
 		C.LZ4_freeStreamDecode(r.lz4Stream)
 		r.lz4Stream = nil
+		if r.opts.zeroize {
+			r.zeroizeBuffers()
+		}
+		if !r.external {
+			C.free(r.decompressionBuffer[0])
+			C.free(r.decompressionBuffer[1])
+			C.free(r.compressedBuffer)
+			releaseMemory(r.reserved)
+			r.reserved = 0
+		}
+		if r.closer != nil {
+			return r.closer.Close()
+		}
 	}
-
-	C.free(r.decompressionBuffer[0])
-	C.free(r.decompressionBuffer[1])
-	C.free(r.compressedBuffer)
 	return nil
 }
 
 func (r *DecompressReader) nextDecompressionBuffer() []byte {
+	if r.independent && r.decompressionBuffer[1] == nil {
+		return ptrToByteSlice(r.decompressionBuffer[0], r.maxBlockSize, r.maxBlockSize)
+	}
+	if r.ringSize > 0 {
+		if r.ringPos+r.maxBlockSize > r.ringSize {
+			r.ringPos = 0
+		}
+		ring := ptrToByteSlice(r.decompressionBuffer[0], r.ringSize, r.ringSize)
+		return ring[r.ringPos : r.ringPos+r.maxBlockSize]
+	}
 	r.inpBufIndex = (r.inpBufIndex + 1) % 2
 	return ptrToByteSlice(r.decompressionBuffer[r.inpBufIndex], hugeStreamingBlockSize, hugeStreamingBlockSize)
 }
 
-// read the 4-byte little endian size from the head of each stream compressed block
-func (r *DecompressReader) readSize(rdr io.Reader) (int, error) {
-	var temp [blockHeaderSize]byte
-	_, err := io.ReadFull(rdr, temp[:])
+// resetWithDict discards any buffered output and continues decoding from rdr,
+// using dict as the history that preceded the next block. Only the last 64 KiB
+// of dict are used, since lz4 never references anything further back.
+func (r *DecompressReader) resetWithDict(rdr io.Reader, dict []byte) error {
+	if err := r.loadHistory(dict); err != nil {
+		return err
+	}
+	r.underlyingReader = rdr
+	r.out.reset()
+	return nil
+}
+
+// loadHistory uses dict as the history that preceded the next block.
+func (r *DecompressReader) loadHistory(dict []byte) error {
+	if len(dict) > streamingBlockSize {
+		dict = dict[len(dict)-streamingBlockSize:]
+	}
+	if r.independent {
+		r.history = append(r.history[:0], dict...)
+		return nil
+	}
+	// keep the dictionary in the buffer that was decoded last, so the next
+	// block is decoded into the other one and the history stays in place
+	var buf []byte
+	if r.ringSize > 0 {
+		// the next block is decoded right after the dictionary
+		buf = ptrToByteSlice(r.decompressionBuffer[0], r.ringSize, r.ringSize)
+	} else {
+		buf = ptrToByteSlice(r.decompressionBuffer[r.inpBufIndex], hugeStreamingBlockSize, hugeStreamingBlockSize)
+	}
+	n := copy(buf, dict)
+	r.ringPos = n
+	if code := C.LZ4_setStreamDecode(r.lz4Stream, p(buf), C.int(n)); code != 1 {
+		return newCError("LZ4_setStreamDecode", int(code), "error resetting decoder")
+	}
+	return nil
+}
+
+// readBlockSize reads block headers, handling any control records, until it
+// finds a compressed block and returns its size.
+func (r *DecompressReader) readBlockSize() (int, error) {
+	for {
+		r.record.payload = nil
+		header, err := r.readHeader(r.underlyingReader)
+		if err != nil {
+			if r.opts.recovery != nil && err == io.ErrUnexpectedEOF {
+				return 0, &corruptionError{err}
+			}
+			return 0, err
+		}
+		if header&controlFlag == 0 {
+			if header > boundedHugeStreamingBlockSize {
+				return 0, &corruptionError{fmt.Errorf("invalid block size %d", header)}
+			}
+			if header > uint32(r.maxCompressedSize) {
+				return 0, fmt.Errorf("block size %d too large for low-memory mode", header)
+			}
+			return int(header), nil
+		}
+		if err := r.readControl(header); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// readControl reads the payload of the control record with the given header
+// and applies it.
+func (r *DecompressReader) readControl(header uint32) error {
+	typ, length := parseControlHeader(header)
+	if length > maxControlLength {
+		return &corruptionError{fmt.Errorf("invalid control record length %d", length)}
+	}
+	payload := make([]byte, length)
+	n, err := io.ReadFull(r.underlyingReader, payload)
+	r.record.payload = payload[:n]
+	if err != nil {
+		err = unexpectedEOF(err)
+		if r.opts.recovery != nil && err == io.ErrUnexpectedEOF {
+			return &corruptionError{err}
+		}
+		return err
+	}
+
+	switch typ {
+	case recordSync:
+		if _, err := parseSyncPayload(payload); err != nil {
+			return &corruptionError{err}
+		}
+		if code := C.LZ4_setStreamDecode(r.lz4Stream, nil, 0); code != 1 {
+			return newCError("LZ4_setStreamDecode", int(code), "error resetting decoder")
+		}
+	case recordMetadata:
+		m, err := parseMetadataPayload(payload)
+		if err != nil {
+			return &corruptionError{err}
+		}
+		if r.opts.metadata != nil {
+			m.UncompressedOffset = r.uncompressedRead
+			r.opts.metadata(m)
+		}
+	case recordBlockChecksum:
+		if len(payload) != blockChecksumPayloadSize {
+			return &corruptionError{errors.New("malformed block checksum")}
+		}
+		r.blockSum = binary.LittleEndian.Uint64(payload)
+		r.hasBlockSum = r.blockChecksum != nil
+		r.sumSeen = true
+	case recordDictionary:
+		if err := r.readDictionary(payload); err != nil {
+			return err
+		}
+	case recordTrailer:
+		if r.trailer != nil {
+			if err := r.trailer.check(payload); err != nil {
+				return err
+			}
+		}
+	case recordHeaderWidth:
+		// the record is counted with the width it was read with
+		r.compressedRead += int64(r.headerWidth + length)
+		return r.readHeaderWidth(payload)
+	}
+	r.compressedRead += int64(r.headerWidth + length)
+	return nil
+}
+
+// read the little endian header from the head of each stream compressed block,
+// returned in the 4-byte format whatever the width of the stream
+func (r *DecompressReader) readHeader(rdr io.Reader) (uint32, error) {
+	n, err := io.ReadFull(rdr, r.record.headerBuf[:r.headerWidth])
+	r.record.header = r.record.headerBuf[:n]
 	if err != nil {
 		return 0, err
 	}
-	return int(binary.LittleEndian.Uint32(temp[:])), nil
+	return parseHeader(r.record.headerBuf[:], r.headerWidth)
+}
+
+// unexpectedEOF returns io.ErrUnexpectedEOF for io.EOF, and err otherwise. It
+// is used when the stream ends in the middle of a record.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
 }
 
 func ptrToByteSlice(dataPtr unsafe.Pointer, _len, _cap int) []byte {
@@ -4,10 +4,6 @@ package lz4
 // #include <lz4hc.h>
 import "C"
 
-import (
-	"fmt"
-)
-
 // CompressHC compresses in and puts the content in out. len(out)
 // should have enough space for the compressed data (use CompressBound
 // to calculate). Returns the number of bytes in the out slice. Determines
@@ -28,10 +24,39 @@ func CompressHCLevel(out, in []byte, level int) (outSize int, err error) {
 	if len(in) == 0 || len(out) == 0 {
 		return Compress(out, in)
 	}
+	if err := checkInputSize(len(in)); err != nil {
+		return 0, err
+	}
 
 	outSize = int(C.LZ4_compress_HC(p(in), p(out), clen(in), clen(out), C.int(level)))
 	if outSize == 0 {
-		err = fmt.Errorf("insufficient space for compression")
+		err = newCError("LZ4_compress_HC", outSize, "insufficient space for compression")
 	}
 	return
 }
+
+// compressHCBlock compresses src, which must be the current input buffer of w,
+// into dst with the HC stream of w at the given level.
+func (w *Writer) compressHCBlock(src, dst []byte, level int) int {
+	if w.hcStream == nil {
+		w.hcStream = C.LZ4_createStreamHC()
+	}
+	if !w.hcActive || w.hcLevel != level {
+		// the HC stream does not know about the blocks compressed since it
+		// was last used, so it starts again from the previous block
+		C.LZ4_resetStreamHC_fast(w.hcStream, C.int(level))
+		C.LZ4_loadDictHC(w.hcStream, p(w.lastBlock), clen(w.lastBlock))
+		w.hcActive = true
+		w.hcLevel = level
+	}
+	return int(C.LZ4_compress_HC_continue(w.hcStream, p(src), p(dst), clen(src), clen(dst)))
+}
+
+// compressHCBlock compresses src, which must be the current input buffer of r,
+// into dst with the HC stream of r.
+func (r *CompressReader) compressHCBlock(src, dst []byte) int {
+	if r.independent {
+		C.LZ4_resetStreamHC_fast(r.hcStream, C.int(r.level))
+	}
+	return int(C.LZ4_compress_HC_continue(r.hcStream, p(src), p(dst), clen(src), C.int(boundedHugeStreamingBlockSize)))
+}
@@ -6,6 +6,7 @@
 package lz4
 
 import (
+	"bytes"
 	"io/ioutil"
 	"strings"
 	"testing"
@@ -202,3 +203,35 @@ func TestFuzzHC(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestCompressReaderHC(t *testing.T) {
+	input, err := ioutil.ReadFile(sampleFilePath)
+	failOnError(t, "Failed reading sample", err)
+	// several blocks of CompressReader
+	input = bytes.Repeat(input, 3*hugeStreamingBlockSize/len(input)+1)
+
+	sizes := map[int]int{}
+	for _, level := range []int{0, -8, 9} {
+		for _, independent := range []bool{false, true} {
+			opts := []Option{WithLevel(level)}
+			if independent {
+				opts = append(opts, WithIndependentBlocks())
+			}
+			cr := NewCompressReader(bytes.NewReader(input), opts...)
+			compressed, err := ioutil.ReadAll(cr)
+			failOnError(t, "Failed compressing", err)
+			failOnError(t, "Failed closing", cr.Close())
+			out, err := DecompressAll(compressed, len(input))
+			failOnError(t, "Failed decompressing", err)
+			if !bytes.Equal(out, input) {
+				t.Fatalf("level %d, independent %v: decompressed output != input", level, independent)
+			}
+			if !independent {
+				sizes[level] = len(compressed)
+			}
+		}
+	}
+	if !(sizes[9] < sizes[0] && sizes[0] < sizes[-8]) {
+		t.Errorf("compressed sizes by level: %v", sizes)
+	}
+}
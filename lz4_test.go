@@ -7,6 +7,7 @@ package lz4
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -182,6 +183,21 @@ func TestCompressBound(t *testing.T) {
 
 	input = make([]byte, 510)
 	assert(t, CompressBound(input) == 528)
+
+	n, err := CompressBoundChecked(input)
+	assert(t, n == 528 && err == nil)
+}
+
+func TestCompressBoundTooLarge(t *testing.T) {
+	// the checks only look at the length of the input, which would take
+	// gigabytes to back with memory
+	n, err := compressBoundLen(maxInputSize + 1)
+	assert(t, n == 0 && err == ErrInputTooLarge)
+	assert(t, checkInputSize(maxInputSize+1) == ErrInputTooLarge)
+
+	n, err = compressBoundLen(maxInputSize)
+	assert(t, n == maxInputSize+maxInputSize/255+16 && err == nil)
+	assert(t, checkInputSize(maxInputSize) == nil)
 }
 
 func TestFuzz(t *testing.T) {
@@ -624,6 +640,115 @@ func TestReaderBadData(t *testing.T) {
 	}
 }
 
+func TestReaderHostileSizes(t *testing.T) {
+	for _, header := range [][]byte{
+		{0xff, 0xff, 0xff, 0xff},
+		{0x12, 0x01, 0x01, 0x00}, // boundedStreamingBlockSize + 1
+		{0xff, 0xff, 0xff, 0x81}, // a control record
+	} {
+		input := append(header, make([]byte, 100)...)
+		r := NewReader(bytes.NewReader(input))
+		if _, err := io.Copy(ioutil.Discard, r); err == nil {
+			t.Errorf("header %x: expected an error", header)
+		}
+		r.Close()
+	}
+}
+
+func TestReaderCorruptStreams(t *testing.T) {
+	var compressed bytes.Buffer
+	w := NewWriter(&compressed)
+	for i := 0; i < 3; i++ {
+		_, err := w.Write(bytes.Repeat(plaintext0, 2000+i))
+		failOnError(t, "Failed writing to compress object", err)
+	}
+	failOnError(t, "Failed closing writer", w.Close())
+	valid := compressed.Bytes()
+
+	// any corruption of the stream, including of the block headers, must
+	// result in an error or garbage, never a panic
+	f := func(positions []uint32, values []byte) bool {
+		input := append([]byte(nil), valid...)
+		for i, pos := range positions {
+			if i < len(values) {
+				input[int(pos)%len(input)] = values[i]
+			}
+		}
+		r := NewReader(bytes.NewReader(input))
+		io.Copy(ioutil.Discard, r)
+		r.Close()
+		return true
+	}
+	if err := quick.Check(f, &quick.Config{MaxCount: 500}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWriterCompressedBytesWritten(t *testing.T) {
+	var compressed bytes.Buffer
+	w := NewWriter(&compressed, WithSyncInterval(streamingBlockSize))
+	for i := 0; i < 5; i++ {
+		_, err := w.Write(make([]byte, streamingBlockSize/2+i))
+		failOnError(t, "Failed writing to compress object", err)
+		if w.CompressedBytesWritten() != int64(compressed.Len()) {
+			t.Fatalf("CompressedBytesWritten = %d, expected %d", w.CompressedBytesWritten(), compressed.Len())
+		}
+	}
+	failOnError(t, "Failed closing writer", w.Close())
+}
+
+func TestDecompressReaderByteCounts(t *testing.T) {
+	input, err := ioutil.ReadFile(sampleFilePath)
+	failOnError(t, "Failed reading sample", err)
+
+	var compressed bytes.Buffer
+	w := NewWriter(&compressed)
+	_, err = w.Write(input)
+	failOnError(t, "Failed writing to compress object", err)
+	failOnError(t, "Failed closing writer", w.Close())
+	compressedLen := compressed.Len()
+
+	r := NewDecompressReader(&compressed).(*DecompressReader)
+	defer r.Close()
+	dst := make([]byte, 1000)
+	n, err := r.Read(dst)
+	failOnError(t, "Failed reading", err)
+	if r.UncompressedBytesRead() != int64(n) {
+		t.Fatalf("UncompressedBytesRead = %d, expected %d", r.UncompressedBytesRead(), n)
+	}
+
+	_, err = io.Copy(ioutil.Discard, r)
+	failOnError(t, "Failed reading", err)
+	if r.UncompressedBytesRead() != int64(len(input)) {
+		t.Fatalf("UncompressedBytesRead = %d, expected %d", r.UncompressedBytesRead(), len(input))
+	}
+	if r.CompressedBytesRead() != int64(compressedLen) {
+		t.Fatalf("CompressedBytesRead = %d, expected %d", r.CompressedBytesRead(), compressedLen)
+	}
+}
+
+func TestCompressMultiWriter(t *testing.T) {
+	input, err := ioutil.ReadFile(sampleFilePath)
+	failOnError(t, "Failed reading sample", err)
+
+	var local, remote bytes.Buffer
+	w := CompressMultiWriter(&local, &remote)
+	_, err = w.Write(input)
+	failOnError(t, "Failed writing to compress object", err)
+	failOnError(t, "Failed closing writer", w.Close())
+
+	if !bytes.Equal(local.Bytes(), remote.Bytes()) {
+		t.Fatalf("sinks received different streams")
+	}
+	r := NewDecompressReader(&remote)
+	out, err := ioutil.ReadAll(r)
+	failOnError(t, "Failed decompressing", err)
+	failOnError(t, "Failed closing reader", r.Close())
+	if !bytes.Equal(out, input) {
+		t.Fatalf("Decompressed output != input")
+	}
+}
+
 func BenchmarkCompress(b *testing.B) {
 	b.ReportAllocs()
 	dst := make([]byte, CompressBound(plaintext0))
@@ -681,6 +806,40 @@ func BenchmarkStreamCompress(b *testing.B) {
 	}
 }
 
+func BenchmarkStreamCompressDiscard(b *testing.B) {
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		w := NewWriter(nil, WithDiscardOutput())
+		if _, err := io.Copy(w, io.LimitReader(Null, 10*1024*1024)); err != nil {
+			b.Fatalf("Failed writing to compress object: %s", err)
+		}
+		b.SetBytes(10 * 1024 * 1024)
+		err := w.Close()
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestWriterDiscardOutput(t *testing.T) {
+	input, err := ioutil.ReadFile(sampleFilePath)
+	failOnError(t, "Failed reading sample", err)
+	var buf bytes.Buffer
+	w := NewWriter(&buf, WithTrailer(), WithBlockChecksum())
+	_, err = w.Write(input)
+	failOnError(t, "Failed writing", err)
+	d := NewWriter(nil, WithDiscardOutput(), WithTrailer(), WithBlockChecksum())
+	_, err = d.Write(input)
+	failOnError(t, "Failed writing", err)
+	if w.CompressedBytesWritten() != d.CompressedBytesWritten() {
+		t.Errorf("got %d compressed bytes, want %d", d.CompressedBytesWritten(), w.CompressedBytesWritten())
+	}
+	failOnError(t, "Failed closing", w.Close())
+	failOnError(t, "Failed closing", d.Close())
+}
+
 func BenchmarkStreamCompressReader(b *testing.B) {
 	b.ReportAllocs()
 	b.ResetTimer()
@@ -749,3 +908,86 @@ func BenchmarkStreamDecompressReader(b *testing.B) {
 		}
 	}
 }
+
+func TestWriterResetState(t *testing.T) {
+	record := []byte(`{"tenant":"a","event":"checkout","items":[1,2,3],"total":42}`)
+	for _, level := range []int{0, 9} {
+		var buf bytes.Buffer
+		w := NewWriter(&buf, WithLevel(level))
+		_, err := w.Write(record)
+		failOnError(t, "Failed writing", err)
+		first := w.CompressedBytesWritten()
+		_, err = w.Write(record)
+		failOnError(t, "Failed writing", err)
+		linked := w.CompressedBytesWritten() - first
+		w.ResetState()
+		_, err = w.Write(record)
+		failOnError(t, "Failed writing", err)
+		reset := w.CompressedBytesWritten() - first - linked
+		failOnError(t, "Failed closing", w.Close())
+
+		if linked >= first || reset != first {
+			t.Errorf("level %d: record sizes %d, %d after the same record, %d after a reset", level, first, linked, reset)
+		}
+		out, err := ioutil.ReadAll(NewDecompressReader(&buf))
+		failOnError(t, "Failed decompressing", err)
+		if !bytes.Equal(out, bytes.Repeat(record, 3)) {
+			t.Fatalf("level %d: decompressed output != input", level)
+		}
+	}
+}
+
+func TestWriterIndependentWrites(t *testing.T) {
+	records := [][]byte{
+		[]byte(`{"user":"alice","action":"delete","object":"invoice/1"}`),
+		bytes.Repeat([]byte(`{"user":"alice","action":"delete","object":"invoice/2"}`), 3000),
+		[]byte(`{"user":"alice","action":"delete","object":"invoice/3"}`),
+	}
+	var buf bytes.Buffer
+	w := NewWriter(&buf, WithIndependentWrites(), WithBlockChecksum())
+	offsets := []int64{0}
+	for _, record := range records {
+		_, err := w.Write(record)
+		failOnError(t, "Failed writing", err)
+		offsets = append(offsets, w.CompressedBytesWritten())
+	}
+	failOnError(t, "Failed closing", w.Close())
+
+	for i := len(records) - 1; i >= 0; i-- {
+		part := buf.Bytes()[offsets[i]:offsets[i+1]]
+		out, err := ioutil.ReadAll(NewDecompressReader(bytes.NewReader(part), WithBlockChecksum()))
+		failOnError(t, "Failed decompressing record", err)
+		if !bytes.Equal(out, records[i]) {
+			t.Fatalf("record %d: decompressed output != input", i)
+		}
+	}
+}
+
+func TestBlockStreamConstants(t *testing.T) {
+	input := bytes.Repeat([]byte("constants "), StreamBlockSize/5+1)[:2*StreamBlockSize]
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	_, err := w.Write(input)
+	failOnError(t, "Failed writing", err)
+	failOnError(t, "Failed closing", w.Close())
+
+	// the stream is made of two blocks of StreamBlockSize, each preceded by
+	// its size
+	stream := buf.Bytes()
+	for i := 0; i < 2; i++ {
+		size := int(binary.LittleEndian.Uint32(stream))
+		if size > MaxCompressedBlockSize {
+			t.Fatalf("block %d: compressed size %d", i, size)
+		}
+		block := make([]byte, StreamBlockSize)
+		n, err := UncompressWithPrevious(block, stream[BlockHeaderSize:BlockHeaderSize+size], input[:i*StreamBlockSize])
+		failOnError(t, "Failed decompressing block", err)
+		if n != StreamBlockSize || !bytes.Equal(block, input[i*StreamBlockSize:(i+1)*StreamBlockSize]) {
+			t.Fatalf("block %d: decompressed output != input", i)
+		}
+		stream = stream[BlockHeaderSize+size:]
+	}
+	if len(stream) != 0 {
+		t.Fatalf("%d bytes after the blocks", len(stream))
+	}
+}
@@ -0,0 +1,66 @@
+package lz4
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// MaxMetadataLength is the maximum length of the data in a BlockMetadata.
+const MaxMetadataLength = maxControlLength - 1
+
+// BlockMetadata is a small application-defined record attached to a block,
+// such as a timestamp or a sequence number.
+type BlockMetadata struct {
+	// Flags is free for use by the application.
+	Flags byte
+	// Data is the content of the record, at most MaxMetadataLength bytes.
+	Data []byte
+	// UncompressedOffset is the offset in the uncompressed data of the block
+	// the metadata is attached to. It is set by readers and ignored by
+	// SetBlockMetadata.
+	UncompressedOffset int64
+}
+
+// SetBlockMetadata attaches m to the next block written by w. The metadata is
+// stored in a control record preceding the block, which readers created
+// without WithMetadata skip. Calling SetBlockMetadata again before the next
+// block replaces m.
+func (w *Writer) SetBlockMetadata(m BlockMetadata) error {
+	if len(m.Data) > MaxMetadataLength {
+		return fmt.Errorf("metadata too long: %d bytes", len(m.Data))
+	}
+	w.metadata = appendMetadataRecord(w.metadata[:0], m)
+	return nil
+}
+
+// writeMetadata writes the pending metadata record, if any.
+func (w *Writer) writeMetadata() error {
+	if len(w.metadata) == 0 {
+		return nil
+	}
+	if err := w.writeRecord(w.metadata); err != nil {
+		return err
+	}
+	w.compressedWritten += int64(len(w.metadata))
+	w.metadata = w.metadata[:0]
+	return nil
+}
+
+// appendMetadataRecord appends a metadata record holding m to b. The payload is
+// the flags followed by the data.
+func appendMetadataRecord(b []byte, m BlockMetadata) []byte {
+	var header [blockHeaderSize]byte
+	binary.LittleEndian.PutUint32(header[:], controlHeader(recordMetadata, 1+len(m.Data)))
+	b = append(b, header[:]...)
+	b = append(b, m.Flags)
+	return append(b, m.Data...)
+}
+
+// parseMetadataPayload returns the metadata stored in a metadata payload. The
+// data aliases payload.
+func parseMetadataPayload(payload []byte) (BlockMetadata, error) {
+	if len(payload) == 0 {
+		return BlockMetadata{}, errBadMetadata
+	}
+	return BlockMetadata{Flags: payload[0], Data: payload[1:]}, nil
+}
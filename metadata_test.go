@@ -0,0 +1,61 @@
+package lz4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"testing"
+)
+
+func TestBlockMetadata(t *testing.T) {
+	var compressed bytes.Buffer
+	w := NewWriter(&compressed, WithSyncInterval(1))
+	for i := 0; i < 4; i++ {
+		var seq [8]byte
+		binary.LittleEndian.PutUint64(seq[:], uint64(i))
+		failOnError(t, "Failed setting metadata", w.SetBlockMetadata(BlockMetadata{Flags: byte(i), Data: seq[:]}))
+		_, err := w.Write(plaintext0)
+		failOnError(t, "Failed writing to compress object", err)
+	}
+	_, err := w.Write(plaintext0)
+	failOnError(t, "Failed writing to compress object", err)
+	failOnError(t, "Failed closing writer", w.Close())
+	if w.CompressedBytesWritten() != int64(compressed.Len()) {
+		t.Errorf("CompressedBytesWritten = %d, want %d", w.CompressedBytesWritten(), compressed.Len())
+	}
+
+	// readers without a callback skip the metadata
+	out, err := ioutil.ReadAll(NewDecompressReader(bytes.NewReader(compressed.Bytes())))
+	failOnError(t, "Failed decompressing", err)
+	if !bytes.Equal(out, bytes.Repeat(plaintext0, 5)) {
+		t.Fatalf("Decompressed output != input")
+	}
+
+	var got []BlockMetadata
+	r := NewDecompressReader(&compressed, WithMetadata(func(m BlockMetadata) {
+		m.Data = append([]byte(nil), m.Data...)
+		got = append(got, m)
+	}))
+	defer r.Close()
+	_, err = ioutil.ReadAll(r)
+	failOnError(t, "Failed decompressing", err)
+	if len(got) != 4 {
+		t.Fatalf("got %d metadata records, want 4", len(got))
+	}
+	for i, m := range got {
+		if m.Flags != byte(i) || binary.LittleEndian.Uint64(m.Data) != uint64(i) {
+			t.Errorf("metadata %d = %+v", i, m)
+		}
+		if want := int64(i * len(plaintext0)); m.UncompressedOffset != want {
+			t.Errorf("metadata %d offset = %d, want %d", i, m.UncompressedOffset, want)
+		}
+	}
+}
+
+func TestBlockMetadataTooLong(t *testing.T) {
+	w := NewWriter(ioutil.Discard)
+	defer w.Close()
+	if err := w.SetBlockMetadata(BlockMetadata{Data: make([]byte, MaxMetadataLength+1)}); err == nil {
+		t.Fatalf("expected an error")
+	}
+}
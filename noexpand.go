@@ -0,0 +1,61 @@
+package lz4
+
+// #cgo pkg-config: liblz4
+// #include <lz4.h>
+import "C"
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// ErrIncompressible is returned by CompressNoExpand and CompressHdrNoExpand
+// when the compressed output would be larger than the input, in which case
+// the caller can store the input uncompressed instead.
+var ErrIncompressible = errors.New("input is incompressible")
+
+// CompressNoExpand is like Compress, but returns ErrIncompressible if the
+// compressed data would be larger than in. Compression stops as soon as the
+// output exceeds the size of in, rather than paying for the whole compression
+// to find out. out needs len(in) bytes rather than CompressBound(in).
+func CompressNoExpand(out, in []byte) (int, error) {
+	if len(in) > maxInputSize {
+		return 0, ErrInputTooLarge
+	}
+	return compressNoExpand(out, in, len(in))
+}
+
+// CompressHdrNoExpand is like CompressHdr, but returns ErrIncompressible if
+// the output, including its 4-byte header, would be larger than in. out needs
+// len(in) bytes.
+func CompressHdrNoExpand(out, in []byte) (int, error) {
+	if len(in) > maxInputSize {
+		return 0, ErrInputTooLarge
+	}
+	if len(in) < 4 {
+		return 0, ErrIncompressible
+	}
+	if len(out) < 4 {
+		return 0, errors.New("Insufficient space for compression")
+	}
+	n, err := compressNoExpand(out[4:], in, len(in)-4)
+	if err != nil {
+		return 0, err
+	}
+	binary.LittleEndian.PutUint32(out, uint32(len(in)))
+	return n + 4, nil
+}
+
+// compressNoExpand compresses in into out, and returns ErrIncompressible if the
+// compressed size exceeds limit.
+func compressNoExpand(out, in []byte, limit int) (int, error) {
+	capacity := min(len(out), limit)
+	n := int(C.LZ4_compress_default(p(in), p(out), clen(in), C.int(capacity)))
+	if n > 0 {
+		return n, nil
+	}
+	if capacity == limit {
+		return 0, ErrIncompressible
+	}
+	return 0, newCError("LZ4_compress_default", n, "Insufficient space for compression")
+}
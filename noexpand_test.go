@@ -0,0 +1,42 @@
+package lz4
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestCompressNoExpand(t *testing.T) {
+	random := make([]byte, 10000)
+	rand.New(rand.NewSource(1)).Read(random)
+	text := bytes.Repeat([]byte("compressible "), 1000)
+
+	for name, compress := range map[string]func(out, in []byte) (int, error){
+		"CompressNoExpand":    CompressNoExpand,
+		"CompressHdrNoExpand": CompressHdrNoExpand,
+	} {
+		out := make([]byte, len(text))
+		n, err := compress(out, text)
+		failOnError(t, name, err)
+		var got []byte
+		if name == "CompressHdrNoExpand" {
+			got, err = UncompressAllocHdr(nil, out[:n])
+		} else {
+			got = make([]byte, len(text))
+			_, err = Uncompress(got, out[:n])
+		}
+		failOnError(t, name+": failed decompressing", err)
+		if !bytes.Equal(got, text) {
+			t.Fatalf("%s: decompressed output != input", name)
+		}
+
+		for _, in := range [][]byte{random, {}, []byte("ab")} {
+			if _, err := compress(make([]byte, len(in)), in); err != ErrIncompressible {
+				t.Errorf("%s of %d bytes: got %v, want ErrIncompressible", name, len(in), err)
+			}
+		}
+		if _, err := compress(make([]byte, 10), text); err == nil || err == ErrIncompressible {
+			t.Errorf("%s with a small out: got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package lz4
+
+// nohdr.go contains routines for blocks without a length header, as written
+// by lz4.block.compress of the python lz4 library with store_size=False, whose
+// size must be passed along separately.
+
+// CompressAllocNoHdr is like CompressAllocHdr, but writes no length header, as
+// with store_size=False in python. The size of in, or an upper bound, must be
+// given to UncompressAllocNoHdr to decompress the block.
+func CompressAllocNoHdr(in []byte) ([]byte, error) {
+	bound, err := CompressBoundChecked(in)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, bound)
+	n, err := Compress(out, in)
+	if err != nil {
+		return out, err
+	}
+	return out[:n], nil
+}
+
+// UncompressAllocNoHdr uncompresses in, a block without length header, which
+// must decompress to at most size bytes, as with the uncompressed_size
+// argument of lz4.block.decompress in python. out is used if it is large
+// enough, otherwise a new slice of size bytes is allocated. The returned slice
+// has the decompressed length.
+func UncompressAllocNoHdr(out, in []byte, size int) ([]byte, error) {
+	if size < 0 || size > maxInputSize {
+		return out, ErrInputTooLarge
+	}
+	if size > len(out) {
+		out = make([]byte, size)
+	}
+	n, err := Uncompress(out[:size], in)
+	if err != nil {
+		return out, err
+	}
+	return out[:n], nil
+}
@@ -0,0 +1,78 @@
+package lz4
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestNoHdr(t *testing.T) {
+	input, err := ioutil.ReadFile(sampleFilePath)
+	failOnError(t, "Failed reading sample", err)
+
+	compressed, err := CompressAllocNoHdr(input)
+	failOnError(t, "Failed compressing", err)
+	withHdr, err := CompressAllocHdr(input)
+	failOnError(t, "Failed compressing with header", err)
+	if !bytes.Equal(compressed, withHdr[4:]) {
+		t.Fatal("block differs from the one after a length header")
+	}
+
+	out, err := UncompressAllocNoHdr(nil, compressed, len(input))
+	failOnError(t, "Failed decompressing", err)
+	if !bytes.Equal(out, input) {
+		t.Fatal("decompressed output != input")
+	}
+	buf := make([]byte, 2*len(input))
+	out, err = UncompressAllocNoHdr(buf, compressed, len(input))
+	failOnError(t, "Failed decompressing into buffer", err)
+	if &out[0] != &buf[0] || !bytes.Equal(out, input) {
+		t.Error("buffer not reused")
+	}
+
+	// the size is an upper bound
+	out, err = UncompressAllocNoHdr(nil, compressed, len(input)+100)
+	failOnError(t, "Failed decompressing with a larger size", err)
+	if !bytes.Equal(out, input) {
+		t.Error("decompressed output != input with a larger size")
+	}
+	if _, err := UncompressAllocNoHdr(nil, compressed, len(input)-1); err == nil {
+		t.Error("no error for a smaller size")
+	}
+	if _, err := UncompressAllocNoHdr(nil, compressed, -1); err == nil {
+		t.Error("no error for a negative size")
+	}
+}
+
+func TestNoHdrPythonInterop(t *testing.T) {
+	if !pymod("lz4.block") {
+		t.Skip("not testing python module compat: no module lz4 found")
+	}
+	input, err := ioutil.ReadFile(sampleFilePath)
+	failOnError(t, "Failed reading sample", err)
+	dir := t.TempDir()
+
+	compressed, err := CompressAllocNoHdr(input)
+	failOnError(t, "Failed compressing", err)
+	goPath := filepath.Join(dir, "go.lz4")
+	failOnError(t, "Failed writing", ioutil.WriteFile(goPath, compressed, 0644))
+	pyPath := filepath.Join(dir, "py.lz4")
+	script := fmt.Sprintf(`import lz4.block
+src = open(%q, "rb").read()
+assert lz4.block.decompress(open(%q, "rb").read(), uncompressed_size=len(src)) == src
+open(%q, "wb").write(lz4.block.compress(src, store_size=False))`, sampleFilePath, goPath, pyPath)
+	if out, err := exec.Command("python3", "-c", script).CombinedOutput(); err != nil {
+		t.Fatalf("python failed: %v\n%s", err, out)
+	}
+
+	compressed, err = ioutil.ReadFile(pyPath)
+	failOnError(t, "Failed reading", err)
+	out, err := UncompressAllocNoHdr(nil, compressed, len(input))
+	failOnError(t, "Failed decompressing", err)
+	if !bytes.Equal(out, input) {
+		t.Error("decompressed output != input")
+	}
+}
@@ -0,0 +1,101 @@
+package lz4
+
+// oneshot.go contains helpers converting between byte slices and the block
+// stream format used by Writer and the readers.
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+)
+
+// ErrTooLarge is returned when decompressed data would exceed the size limit
+// given by the caller.
+var ErrTooLarge = errors.New("decompressed size exceeds limit")
+
+var errNegativeLimit = errors.New("negative size limit")
+
+// DecompressAll decompresses the whole block stream in in and returns the
+// uncompressed data. It fails with ErrTooLarge as soon as the uncompressed data
+// would exceed maxSize bytes, so it is safe to use on untrusted input. A
+// truncated stream is an error, and so is a negative maxSize. Pass
+// math.MaxInt for no limit.
+func DecompressAll(in []byte, maxSize int) ([]byte, error) {
+	if maxSize < 0 {
+		return nil, errNegativeLimit
+	}
+	r := newDecompressReader(bytes.NewReader(in), explicitOptions(), nil)
+	defer r.Close()
+
+	var out bytes.Buffer
+	// reading one byte more than allowed tells whether the limit is exceeded
+	limit := int64(maxSize)
+	if limit < math.MaxInt64 {
+		limit++
+	}
+	_, err := io.Copy(&out, io.LimitReader(r, limit))
+	if err != nil {
+		return nil, err
+	}
+	if out.Len() > maxSize {
+		return nil, ErrTooLarge
+	}
+	return out.Bytes(), nil
+}
+
+// CompressBytesToStream compresses in into a block stream, as written by
+// Writer, which can be read by any of the streaming readers.
+func CompressBytesToStream(in []byte) ([]byte, error) {
+	var out bytes.Buffer
+	blocks := len(in)/streamingBlockSize + 1
+	out.Grow(CompressBound(in) + blocks*blockHeaderSize)
+
+	w := newWriter(&out, explicitOptions())
+	if _, err := w.Write(in); err != nil {
+		w.Close()
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// maxSizeHint bounds the preallocation of CompressFromReader, so that a size
+// hint coming from an untrusted peer cannot allocate more than this upfront.
+const maxSizeHint = 64 << 20
+
+// CompressFromReader compresses everything read from r into a block stream,
+// as CompressBytesToStream does, without first reading it all into memory.
+// sizeHint, such as the Content-Length of an HTTP request, is the expected
+// size of the input: when positive, the output is preallocated for it, up to
+// 64 MiB, so that it does not grow while compressing. The hint may be wrong;
+// it only affects allocations.
+func CompressFromReader(r io.Reader, sizeHint int64) ([]byte, error) {
+	var out bytes.Buffer
+	if sizeHint > 0 {
+		size := maxSizeHint
+		if sizeHint < maxSizeHint {
+			size = int(sizeHint)
+		}
+		blocks := size/streamingBlockSize + 1
+		out.Grow(compressBound(size) + blocks*blockHeaderSize)
+	}
+
+	w := newWriter(&out, explicitOptions())
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// DecompressStreamToBytes decompresses a block stream produced by
+// CompressBytesToStream or Writer. It is equivalent to DecompressAll.
+func DecompressStreamToBytes(in []byte, maxSize int) ([]byte, error) {
+	return DecompressAll(in, maxSize)
+}
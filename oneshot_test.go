@@ -0,0 +1,116 @@
+package lz4
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math"
+	"testing"
+	"testing/iotest"
+)
+
+func compressStream(t *testing.T, input []byte) []byte {
+	t.Helper()
+	var compressed bytes.Buffer
+	w := NewWriter(&compressed)
+	_, err := w.Write(input)
+	failOnError(t, "Failed writing to compress object", err)
+	failOnError(t, "Failed closing writer", w.Close())
+	return compressed.Bytes()
+}
+
+func TestDecompressAll(t *testing.T) {
+	input, err := ioutil.ReadFile(sampleFilePath)
+	failOnError(t, "Failed reading sample", err)
+	compressed := compressStream(t, input)
+
+	out, err := DecompressAll(compressed, len(input))
+	failOnError(t, "Failed decompressing", err)
+	if !bytes.Equal(out, input) {
+		t.Fatalf("Decompressed output != input")
+	}
+
+	if _, err := DecompressAll(compressed, len(input)-1); err != ErrTooLarge {
+		t.Fatalf("expected ErrTooLarge, got %v", err)
+	}
+
+	// cut in the middle of the last block
+	if _, err := DecompressAll(compressed[:len(compressed)-1], len(input)); err == nil {
+		t.Fatalf("expected an error for a truncated stream")
+	}
+	// cut right after the header of a block
+	if _, err := DecompressAll(compressed[:4], len(input)); err != io.ErrUnexpectedEOF {
+		t.Fatalf("expected io.ErrUnexpectedEOF, got %v", err)
+	}
+
+	// math.MaxInt means no limit
+	out, err = DecompressAll(compressed, math.MaxInt)
+	failOnError(t, "Failed decompressing without limit", err)
+	if !bytes.Equal(out, input) {
+		t.Fatalf("Decompressed output != input without limit")
+	}
+	if _, err := DecompressAll(compressed, -1); err != errNegativeLimit {
+		t.Fatalf("expected errNegativeLimit, got %v", err)
+	}
+
+	out, err = DecompressAll(nil, 0)
+	failOnError(t, "Failed decompressing empty stream", err)
+	if len(out) != 0 {
+		t.Fatalf("expected empty output, got %q", out)
+	}
+}
+
+func TestStreamBytesRoundTrip(t *testing.T) {
+	input, err := ioutil.ReadFile(sampleFilePath)
+	failOnError(t, "Failed reading sample", err)
+	for len(input) < 3*streamingBlockSize {
+		input = append(input, input...)
+	}
+
+	compressed, err := CompressBytesToStream(input)
+	failOnError(t, "Failed compressing", err)
+	if !bytes.Equal(compressed, compressStream(t, input)) {
+		t.Fatalf("CompressBytesToStream output differs from Writer output")
+	}
+
+	out, err := DecompressStreamToBytes(compressed, len(input))
+	failOnError(t, "Failed decompressing", err)
+	if !bytes.Equal(out, input) {
+		t.Fatalf("Decompressed output != input")
+	}
+	out, err = DecompressStreamToBytes(compressed, math.MaxInt)
+	failOnError(t, "Failed decompressing without limit", err)
+	if !bytes.Equal(out, input) {
+		t.Fatalf("Decompressed output != input without limit")
+	}
+
+	empty, err := CompressBytesToStream(nil)
+	failOnError(t, "Failed compressing empty input", err)
+	out, err = DecompressStreamToBytes(empty, 0)
+	failOnError(t, "Failed decompressing empty stream", err)
+	if len(out) != 0 {
+		t.Fatalf("expected empty output, got %q", out)
+	}
+}
+
+func TestCompressFromReader(t *testing.T) {
+	input, err := ioutil.ReadFile(sampleFilePath)
+	failOnError(t, "Failed reading sample", err)
+
+	for _, hint := range []int64{0, -1, int64(len(input)), 10, 1 << 40} {
+		out, err := CompressFromReader(bytes.NewReader(input), hint)
+		failOnError(t, "Failed compressing", err)
+		dec, err := DecompressAll(out, len(input))
+		failOnError(t, "Failed decompressing", err)
+		if !bytes.Equal(dec, input) {
+			t.Fatalf("hint %d: decompressed output != input", hint)
+		}
+	}
+
+	errRead := errors.New("read failed")
+	r := io.MultiReader(bytes.NewReader(input), iotest.ErrReader(errRead))
+	if _, err := CompressFromReader(r, 0); err != errRead {
+		t.Errorf("got error %v instead of %v", err, errRead)
+	}
+}
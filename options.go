@@ -0,0 +1,393 @@
+package lz4
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// Option configures a Writer or a reader. Options that do not apply to the
+// type being created are ignored.
+type Option func(*options)
+
+type options struct {
+	syncInterval   int64
+	recovery       func(SkippedRange)
+	ownsUnderlying bool
+	adaptive       bool
+	rateLimit      int64
+	verify         bool
+	metadata       func(BlockMetadata)
+	level          int
+	lowMemory      bool
+	blockSize      int
+	fillBuffer     bool
+	readAhead      bool
+	trailer        bool
+	blockChecksum  bool
+	checksum       func() Checksummer
+	fileSync       bool
+	dictionaries   *DictionaryStore
+
+	independentBlocks bool
+	noContentChecksum bool
+	favorDecSpeed     bool
+	independentWrites bool
+	emptyWrites       bool
+	earlyEOF          bool
+	strict            bool
+	strictBlockSize   int
+	discardOutput     bool
+	readTimeout       time.Duration
+	maxRetained       int64
+	zeroize           bool
+	levelFunc         func([]byte) int
+	blockReport       func(BlockReport)
+	headerWidth       int
+	ringBlockSize     int
+	padding           int
+	blockHash         func(BlockHash)
+}
+
+var (
+	defaultsMu sync.RWMutex
+	defaults   []Option
+)
+
+// SetDefaultOptions sets options applied by every constructor and function
+// taking options, before the options given to it, so that settings such as
+// WithBlockSize, WithLevel or WithBlockChecksum can be tuned for a whole
+// program from a single call in an init function. Options given to a
+// constructor override the defaults they conflict with, but cannot turn off a
+// boolean option enabled by default. Each call replaces the previous defaults;
+// calling it with no options clears them. It is safe to call concurrently with
+// the constructors, which use the defaults set at the time of the call. The
+// defaults apply to the exported constructors of readers and writers and to
+// the types built on them, such as SharedWriter, ChunkWriter, the archive
+// package and httplz4. They do not apply to the functions creating readers and
+// writers internally, such as CompressParallel, DecompressParallel,
+// CompressDir, ExtractDir, Transcode, Inspect, DecodeUntrustedStream and the
+// one-shot helpers such as DecompressAll, which only use the options given to
+// them.
+func SetDefaultOptions(opts ...Option) {
+	opts = append([]Option(nil), opts...)
+	defaultsMu.Lock()
+	defaults = opts
+	defaultsMu.Unlock()
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	defaultsMu.RLock()
+	for _, opt := range defaults {
+		opt(&o)
+	}
+	defaultsMu.RUnlock()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// explicitOptions returns the options opts alone, without the defaults of
+// SetDefaultOptions. The readers and writers the package uses internally are
+// built from them, so that their behaviour does not depend on the defaults.
+func explicitOptions(opts ...Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithOwnsUnderlying makes Close also close the underlying io.Reader or
+// io.Writer, if it implements io.Closer. Close is idempotent, so the underlying
+// value is closed only once.
+func WithOwnsUnderlying() Option {
+	return func(o *options) {
+		o.ownsUnderlying = true
+	}
+}
+
+// underlyingCloser returns the io.Closer to close along with a Writer or
+// reader wrapping v, if any.
+func underlyingCloser(v interface{}, o options) io.Closer {
+	if c, ok := v.(io.Closer); ok && o.ownsUnderlying {
+		return c
+	}
+	return nil
+}
+
+// WithSyncInterval makes a Writer emit a sync marker before the first block
+// that starts at least n uncompressed bytes after the previous marker. The
+// compression history is reset at each marker, so decoding can start there
+// without the preceding data. See NextSyncPoint. A larger n gives better
+// compression, since fewer blocks start without history.
+func WithSyncInterval(n int64) Option {
+	return func(o *options) {
+		o.syncInterval = n
+	}
+}
+
+// SkippedRange describes compressed data discarded by a reader in recovery
+// mode.
+type SkippedRange struct {
+	// Offset is the offset of the first skipped byte in the compressed
+	// stream.
+	Offset int64
+	// Length is the number of skipped compressed bytes.
+	Length int64
+	// UncompressedOffset is the offset in the uncompressed output where the
+	// data of the skipped range is missing.
+	UncompressedOffset int64
+	// Err is the corruption that caused the range to be skipped.
+	Err error
+}
+
+// WithRecovery makes a DecompressReader recover from corrupt data instead of
+// failing: it scans forward to the next sync marker and continues decoding
+// from there, calling fn with the range that was skipped. Data between the
+// corruption and the marker is lost, and if there is no further marker the
+// reader returns io.EOF after reporting the rest of the stream as skipped.
+// Only streams written with WithSyncInterval can be recovered before their
+// end. Errors from the underlying reader are returned as usual.
+func WithRecovery(fn func(SkippedRange)) Option {
+	return func(o *options) {
+		o.recovery = fn
+	}
+}
+
+// WithAdaptiveLevel makes a Writer adjust its compression level to the speed of
+// the underlying writer, similar to zstd --adapt. When writes to the underlying
+// writer take longer than compressing, the Writer has time to spare and moves
+// towards HC compression; when compressing takes longer, it moves towards fast
+// compression with higher acceleration. The output can be read by any reader.
+func WithAdaptiveLevel() Option {
+	return func(o *options) {
+		o.adaptive = true
+	}
+}
+
+// WithRateLimit limits the rate at which a Writer writes compressed data to the
+// underlying writer to bytesPerSecond on average. Writes are delayed between
+// blocks, so each block is still written in one piece.
+func WithRateLimit(bytesPerSecond int64) Option {
+	return func(o *options) {
+		o.rateLimit = bytesPerSecond
+	}
+}
+
+// WithVerify makes a Writer decompress every block right after compressing it
+// and compare the result with its input before writing it. Write returns
+// ErrVerifyMismatch if they differ. This roughly doubles the CPU cost of
+// compression.
+func WithVerify() Option {
+	return func(o *options) {
+		o.verify = true
+	}
+}
+
+// WithMetadata makes a DecompressReader call fn with the metadata attached to
+// a block by Writer.SetBlockMetadata, before any of the block's data is
+// returned by Read. m.Data is only valid during the call.
+func WithMetadata(fn func(m BlockMetadata)) Option {
+	return func(o *options) {
+		o.metadata = fn
+	}
+}
+
+// WithLevel sets the compression level of a Writer or CompressReader.
+// Positive levels use HC compression at that level, from 1 to 12; negative
+// levels use fast compression with the opposite of level as acceleration,
+// trading ratio for speed; 0 uses the default fast compression.
+// WithAdaptiveLevel overrides it for a Writer.
+func WithLevel(level int) Option {
+	return func(o *options) {
+		o.level = level
+	}
+}
+
+// WithLowMemory makes a DecompressReader decode into a single ring buffer sized
+// for 64 KiB blocks, as written by Writer, instead of two buffers sized for the
+// 5 MiB blocks of CompressReader. This reduces the memory used by each reader
+// from about 15 MiB to under 200 KiB. Reading a stream with larger blocks
+// returns an error. WithRingBuffer sizes the ring for other block sizes.
+func WithLowMemory() Option {
+	return func(o *options) {
+		o.lowMemory = true
+	}
+}
+
+// WithBlockSize sets the maximum uncompressed size of the blocks written by a
+// Writer. Larger blocks give a better ratio at the cost of memory: a Writer
+// uses about three times the block size. Sizes up to MaxBlockSize can be read
+// by DecompressReader, except in low-memory mode, which is limited to the
+// default of 64 KiB. Sizes out of range are clamped.
+func WithBlockSize(size int) Option {
+	return func(o *options) {
+		if size > MaxBlockSize {
+			size = MaxBlockSize
+		}
+		o.blockSize = size
+	}
+}
+
+// WithLargeBlocks makes a Writer use blocks of LargeBlockSize, for bulk
+// transfers where the ratio matters more than memory.
+func WithLargeBlocks() Option {
+	return WithBlockSize(LargeBlockSize)
+}
+
+// WithFillBuffer makes DecompressReader.Read decode as many blocks as needed to
+// fill its argument, instead of returning after the rest of one block. This
+// reduces the number of calls to Read with large buffers, such as in
+// io.CopyBuffer. Read still returns less data at the end of the stream.
+func WithFillBuffer() Option {
+	return func(o *options) {
+		o.fillBuffer = true
+	}
+}
+
+// WithDiscardOutput makes a Writer compress its input without writing anything
+// to the underlying writer, which may be nil, so that benchmarks measure the
+// compression alone. CompressedBytesWritten still counts the bytes that would
+// have been written.
+func WithDiscardOutput() Option {
+	return func(o *options) {
+		o.discardOutput = true
+	}
+}
+
+// WithEarlyEOF makes DecompressReader, CompressReader and FrameReader return
+// io.EOF along with the last data of the stream, as many parsers expect,
+// instead of on the next call to Read. To find out whether the stream ends,
+// Read then decodes the next block, or reads the underlying reader, before
+// returning, which delays returning data that is available when following a
+// stream that is still being written.
+func WithEarlyEOF() Option {
+	return func(o *options) {
+		o.earlyEOF = true
+	}
+}
+
+// WithReadAhead makes a DecompressReader read and decode the next block in a
+// background goroutine while the current one is consumed, using a second
+// block buffer with independent blocks. Callbacks such as those of
+// WithRecovery and WithBlockReports are then called from the goroutine. It is
+// ignored in low-memory mode, whose ring buffer cannot hold two blocks. A
+// FrameReader, which liblz4 decodes straight into the buffer passed to Read,
+// instead reads its input in the background, up to 512 KiB ahead. It helps
+// when reading and decoding both take significant time, as with fast
+// storage. Close stops the goroutine; a DecompressReader waits for the block
+// being decoded, so Close blocks until a read in progress returns.
+func WithReadAhead() Option {
+	return func(o *options) {
+		o.readAhead = true
+	}
+}
+
+// WithTrailer makes a Writer end the stream with a trailer holding the length
+// and checksum of the uncompressed data, written by Close. The checksum is
+// XXH64 unless set with WithChecksum. It makes a
+// DecompressReader verify the trailer, returning ErrTrailerMismatch if the
+// data does not match and ErrMissingTrailer if the stream ends without one.
+// The trailer covers the data written since the Writer was created, so a
+// stream extended with NewAppendWriter has a trailer for each part, and
+// NewWriterFromState returns an error with it. Readers skip trailers without
+// this option, and stop verifying after recovering from corrupt data.
+func WithTrailer() Option {
+	return func(o *options) {
+		o.trailer = true
+	}
+}
+
+// WithBlockChecksum makes a Writer precede each block with the checksum of its
+// uncompressed data, and makes a DecompressReader verify it, returning an
+// error matching ErrChecksumMismatch for a corrupt block. The checksum is XXH32
+// unless set with WithChecksum. In recovery mode, a corrupt block is skipped
+// like any other corruption. Readers skip block checksums without this option.
+func WithBlockChecksum() Option {
+	return func(o *options) {
+		o.blockChecksum = true
+	}
+}
+
+// WithChecksum sets the checksum used by WithTrailer and WithBlockChecksum.
+// newChecksummer is called for each checksum needed. The stream does not
+// record the checksum used, so readers must be given the same one.
+func WithChecksum(newChecksummer func() Checksummer) Option {
+	return func(o *options) {
+		o.checksum = newChecksummer
+	}
+}
+
+// WithFileSync makes CompressFile and DecompressFile flush the output file and
+// its directory to stable storage before returning.
+func WithFileSync() Option {
+	return func(o *options) {
+		o.fileSync = true
+	}
+}
+
+// WithIndependentWrites makes a Writer reset the compression history at the
+// start of each call to Write, CompressFrom or WriteBlock, as ResetState does,
+// so the compressed bytes written by each call can be decoded on their own by
+// a new DecompressReader, for example to extract a single record from a log.
+// CompressedBytesWritten gives the boundaries between calls. The ratio is
+// lower, since records cannot reference each other.
+func WithIndependentWrites() Option {
+	return func(o *options) {
+		o.independentWrites = true
+	}
+}
+
+// WithEmptyWrites makes a Writer write an empty block for a call to Write or
+// CompressFrom with an empty slice, instead of nothing, so that empty messages
+// round-trip through DecompressReader.ReadBlock, which returns an empty block
+// as an empty slice. Read skips empty blocks, since it never returns 0 bytes
+// without an error, so an empty stream and a stream of empty blocks both read
+// as no data followed by io.EOF.
+func WithEmptyWrites() Option {
+	return func(o *options) {
+		o.emptyWrites = true
+	}
+}
+
+// WithIndependentBlocks makes a FrameWriter, Writer or CompressReader compress
+// each block independently, instead of referencing the previous blocks. The
+// ratio is lower, but each block can be decoded on its own. Since no history
+// needs to be kept, a CompressReader uses a single input buffer, and a
+// DecompressReader given the option decodes into a single buffer, halving
+// their memory; the DecompressReader then fails on blocks of streams written
+// without it.
+func WithIndependentBlocks() Option {
+	return func(o *options) {
+		o.independentBlocks = true
+	}
+}
+
+// WithContentChecksum sets whether a FrameWriter ends the frame with an XXH32
+// checksum of its content, which it does by default like the lz4 command line
+// tool.
+func WithContentChecksum(enabled bool) Option {
+	return func(o *options) {
+		o.noContentChecksum = !enabled
+	}
+}
+
+// WithFavorDecSpeed makes a FrameWriter using HC compression at level 10 or
+// above trade some ratio for faster decompression.
+func WithFavorDecSpeed() Option {
+	return func(o *options) {
+		o.favorDecSpeed = true
+	}
+}
+
+// newChecksummer returns the checksum set with WithChecksum, or the result of
+// def if none was.
+func (o options) newChecksummer(def func() Checksummer) Checksummer {
+	if o.checksum != nil {
+		return o.checksum()
+	}
+	return def()
+}
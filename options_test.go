@@ -0,0 +1,120 @@
+package lz4
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+type closeCounter struct {
+	bytes.Buffer
+	closed int
+}
+
+func (c *closeCounter) Close() error {
+	c.closed++
+	return nil
+}
+
+func TestWithOwnsUnderlying(t *testing.T) {
+	var compressed closeCounter
+	w := NewWriter(&compressed, WithOwnsUnderlying())
+	_, err := w.Write(plaintext0)
+	failOnError(t, "Failed writing to compress object", err)
+	failOnError(t, "Failed closing writer", w.Close())
+	failOnError(t, "Failed closing writer twice", w.Close())
+	if compressed.closed != 1 {
+		t.Fatalf("underlying writer closed %d times, expected 1", compressed.closed)
+	}
+
+	src := &closeCounter{Buffer: *bytes.NewBuffer(compressed.Bytes())}
+	r := NewDecompressReader(src, WithOwnsUnderlying())
+	_, err = io.Copy(io.Discard, r)
+	failOnError(t, "Failed decompressing", err)
+	failOnError(t, "Failed closing reader", r.Close())
+	failOnError(t, "Failed closing reader twice", r.Close())
+	if src.closed != 1 {
+		t.Fatalf("underlying reader closed %d times, expected 1", src.closed)
+	}
+
+	src = &closeCounter{Buffer: *bytes.NewBuffer(plaintext0)}
+	cr := NewCompressReader(src, WithOwnsUnderlying())
+	_, err = io.Copy(io.Discard, cr)
+	failOnError(t, "Failed compressing", err)
+	failOnError(t, "Failed closing reader", cr.Close())
+	failOnError(t, "Failed closing reader twice", cr.Close())
+	if src.closed != 1 {
+		t.Fatalf("underlying reader closed %d times, expected 1", src.closed)
+	}
+
+	// without the option, the underlying value is left open
+	var plain closeCounter
+	w = NewWriter(&plain)
+	failOnError(t, "Failed closing writer", w.Close())
+	if plain.closed != 0 {
+		t.Fatalf("underlying writer closed without WithOwnsUnderlying")
+	}
+}
+
+func TestSetDefaultOptions(t *testing.T) {
+	SetDefaultOptions(WithBlockSize(4096), WithBlockChecksum())
+	defer SetDefaultOptions()
+	input := bytes.Repeat([]byte("default options "), 1000)
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	_, err := w.Write(input)
+	failOnError(t, "Failed writing", err)
+	failOnError(t, "Failed closing", w.Close())
+	report, err := Inspect(bytes.NewReader(buf.Bytes()))
+	failOnError(t, "Failed inspecting", err)
+	if len(report.Blocks) != 4 || !report.BlockChecksums {
+		t.Fatalf("got %d blocks, checksums %v, want 4 blocks with checksums", len(report.Blocks), report.BlockChecksums)
+	}
+
+	// options given to the constructor take precedence
+	buf.Reset()
+	w = NewWriter(&buf, WithBlockSize(8192))
+	_, err = w.Write(input)
+	failOnError(t, "Failed writing", err)
+	failOnError(t, "Failed closing", w.Close())
+	report, err = Inspect(bytes.NewReader(buf.Bytes()))
+	failOnError(t, "Failed inspecting", err)
+	if len(report.Blocks) != 2 {
+		t.Fatalf("got %d blocks, want 2", len(report.Blocks))
+	}
+
+	SetDefaultOptions()
+	buf.Reset()
+	w = NewWriter(&buf)
+	_, err = w.Write(input)
+	failOnError(t, "Failed writing", err)
+	failOnError(t, "Failed closing", w.Close())
+	report, err = Inspect(bytes.NewReader(buf.Bytes()))
+	failOnError(t, "Failed inspecting", err)
+	if len(report.Blocks) != 1 || report.BlockChecksums {
+		t.Fatalf("got %d blocks, checksums %v after clearing the defaults", len(report.Blocks), report.BlockChecksums)
+	}
+}
+
+func TestDefaultOptionsInternal(t *testing.T) {
+	SetDefaultOptions(WithReadAhead(), WithTrailer(), WithRecovery(func(SkippedRange) {}))
+	defer SetDefaultOptions()
+	input := bytes.Repeat([]byte("internal readers "), 10000)
+
+	var compressed bytes.Buffer
+	idx, err := CompressParallel(&compressed, bytes.NewReader(input), 16384)
+	failOnError(t, "Failed compressing", err)
+	var out bytes.Buffer
+	failOnError(t, "Failed decompressing", DecompressParallel(&out, bytes.NewReader(compressed.Bytes()), idx))
+	if !bytes.Equal(out.Bytes(), input) {
+		t.Fatalf("Decompressed output != input")
+	}
+
+	// recovery would skip the corrupted block
+	stream := compressStream(t, input)
+	stream[len(stream)/2] ^= 0xff
+	if _, err := DecodeUntrustedStream(stream, Limits{}); err == nil {
+		t.Errorf("no error decoding a corrupted stream")
+	}
+}
@@ -0,0 +1,216 @@
+package lz4
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// packet.go contains a codec for unreliable, unordered transports such as UDP.
+// Each packet is compressed on its own, but with a dictionary made of the
+// payloads of recent packets the receiver has acknowledged, so it can be
+// decoded whatever packets are lost or reordered in between.
+//
+// A packet is made of uvarints giving its sequence number, the generation of
+// its dictionary, the number of packets in the dictionary and, for each of
+// them from oldest to newest, the difference between the packet's sequence
+// number and its own, followed by the uncompressed size and the compressed
+// payload. The dictionary is the concatenation of the payloads of the listed
+// packets, of which the last 64 KiB are used.
+
+// MaxPacketSize is the maximum payload size of a packet.
+const MaxPacketSize = streamingBlockSize
+
+// packetWindow is the number of recent packets whose payloads are kept by a
+// PacketEncoder until acknowledged, and by a PacketDecoder for use in
+// dictionaries.
+const packetWindow = 1024
+
+// ErrMissingDictionary is returned by PacketDecoder.Decode when a packet was
+// compressed with a dictionary including packets the decoder has not
+// received, or no longer remembers.
+var ErrMissingDictionary = errors.New("packet dictionary references unknown packets")
+
+var errBadPacket = errors.New("malformed packet")
+
+type packetPayload struct {
+	seq     uint32
+	payload []byte
+	valid   bool
+}
+
+// seqBefore reports whether sequence number a comes before b, allowing for
+// wraparound.
+func seqBefore(a, b uint32) bool {
+	return int32(a-b) < 0
+}
+
+// PacketEncoder compresses packets for a PacketDecoder. The sender must call
+// Ack for the packets the receiver reports as decoded, which then become part
+// of the dictionary of the following packets. A PacketEncoder is not safe for
+// concurrent use.
+type PacketEncoder struct {
+	history int
+	seq     uint32
+	sent    [packetWindow]packetPayload
+	acked   []packetPayload
+	gen     uint64
+	dict    []byte
+}
+
+// NewPacketEncoder returns a PacketEncoder whose dictionaries are made of the
+// last history acknowledged packets.
+func NewPacketEncoder(history int) *PacketEncoder {
+	return &PacketEncoder{history: history}
+}
+
+// Encode compresses payload into a new packet and returns it, along with the
+// sequence number assigned to it. payload must be at most MaxPacketSize bytes.
+func (e *PacketEncoder) Encode(payload []byte) ([]byte, uint32, error) {
+	if len(payload) > MaxPacketSize {
+		return nil, 0, fmt.Errorf("packet too large: %d bytes", len(payload))
+	}
+	seq := e.seq
+	e.seq++
+
+	var tmp [binary.MaxVarintLen64]byte
+	packet := make([]byte, 0, (4+len(e.acked))*binary.MaxVarintLen64+CompressBound(payload))
+	putUvarint := func(v uint64) {
+		packet = append(packet, tmp[:binary.PutUvarint(tmp[:], v)]...)
+	}
+	putUvarint(uint64(seq))
+	putUvarint(e.gen)
+	putUvarint(uint64(len(e.acked)))
+	for _, a := range e.acked {
+		putUvarint(uint64(seq - a.seq))
+	}
+	putUvarint(uint64(len(payload)))
+
+	header := len(packet)
+	packet = packet[:cap(packet)]
+	n, err := compressWithDict(packet[header:], payload, e.dict)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	e.sent[seq%packetWindow] = packetPayload{seq: seq, payload: append([]byte(nil), payload...), valid: true}
+	return packet[:header+n], seq, nil
+}
+
+// Ack records that the packet with sequence number seq was received, so it can
+// be used in the dictionary of the next packets. Acknowledgements for packets
+// that were not sent recently, or that are older than the current dictionary,
+// are ignored.
+func (e *PacketEncoder) Ack(seq uint32) {
+	sent := &e.sent[seq%packetWindow]
+	if !sent.valid || sent.seq != seq {
+		return
+	}
+	if len(e.acked) == e.history && (e.history == 0 || seqBefore(seq, e.acked[0].seq)) {
+		return
+	}
+	for _, a := range e.acked {
+		if a.seq == seq {
+			return
+		}
+	}
+
+	// keep acked sorted by sequence number
+	i := len(e.acked)
+	for i > 0 && seqBefore(seq, e.acked[i-1].seq) {
+		i--
+	}
+	e.acked = append(e.acked, packetPayload{})
+	copy(e.acked[i+1:], e.acked[i:])
+	e.acked[i] = *sent
+	if len(e.acked) > e.history {
+		e.acked = e.acked[1:]
+	}
+
+	e.dict = e.dict[:0]
+	for _, a := range e.acked {
+		e.dict = append(e.dict, a.payload...)
+	}
+	e.gen++
+}
+
+// PacketDecoder decompresses packets produced by a PacketEncoder, in any
+// order. Each PacketEncoder needs its own PacketDecoder. A PacketDecoder is
+// not safe for concurrent use.
+type PacketDecoder struct {
+	received [packetWindow]packetPayload
+	gen      uint64
+	dict     []byte
+	hasDict  bool
+}
+
+// NewPacketDecoder returns a new PacketDecoder.
+func NewPacketDecoder() *PacketDecoder {
+	return &PacketDecoder{}
+}
+
+// Decode decompresses packet and returns its payload and sequence number. The
+// sender should then acknowledge the sequence number. The payload is kept for
+// use in dictionaries and must not be modified.
+func (d *PacketDecoder) Decode(packet []byte) ([]byte, uint32, error) {
+	readUvarint := func() (uint64, error) {
+		v, n := binary.Uvarint(packet)
+		if n <= 0 {
+			return 0, errBadPacket
+		}
+		packet = packet[n:]
+		return v, nil
+	}
+
+	var fields [3]uint64
+	for i := range fields {
+		v, err := readUvarint()
+		if err != nil {
+			return nil, 0, err
+		}
+		fields[i] = v
+	}
+	seq, gen, count := uint32(fields[0]), fields[1], fields[2]
+	if fields[0] > 1<<32-1 || count > packetWindow {
+		return nil, 0, errBadPacket
+	}
+	dictSeqs := make([]uint32, count)
+	for i := range dictSeqs {
+		delta, err := readUvarint()
+		if err != nil {
+			return nil, 0, err
+		}
+		dictSeqs[i] = seq - uint32(delta)
+	}
+	size, err := readUvarint()
+	if err != nil {
+		return nil, 0, err
+	}
+	if size > MaxPacketSize {
+		return nil, 0, errBadPacket
+	}
+
+	if !d.hasDict || d.gen != gen {
+		dict := d.dict[:0]
+		for _, s := range dictSeqs {
+			r := &d.received[s%packetWindow]
+			if !r.valid || r.seq != s {
+				d.hasDict = false
+				return nil, 0, ErrMissingDictionary
+			}
+			dict = append(dict, r.payload...)
+		}
+		d.dict, d.gen, d.hasDict = dict, gen, true
+	}
+
+	payload := make([]byte, size)
+	n, err := uncompressWithDict(payload, packet, d.dict)
+	if err != nil {
+		return nil, 0, err
+	}
+	if n != len(payload) {
+		return nil, 0, errBadPacket
+	}
+	d.received[seq%packetWindow] = packetPayload{seq: seq, payload: payload, valid: true}
+	return payload, seq, nil
+}
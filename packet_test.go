@@ -0,0 +1,90 @@
+package lz4
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func snapshot(i int) []byte {
+	var b bytes.Buffer
+	for e := 0; e < 50; e++ {
+		fmt.Fprintf(&b, "entity %d pos=%d,%d hp=100 state=idle\n", e, e*10, (i/10+e)%7)
+	}
+	return b.Bytes()
+}
+
+func TestPacketCodec(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	enc := NewPacketEncoder(4)
+	dec := NewPacketDecoder()
+
+	type inflight struct {
+		packet  []byte
+		payload []byte
+	}
+	var queue []inflight
+	var compressed, uncompressed int
+	for i := 0; i < 500; i++ {
+		payload := snapshot(i)
+		packet, _, err := enc.Encode(payload)
+		failOnError(t, "Failed encoding", err)
+		compressed += len(packet)
+		uncompressed += len(payload)
+		queue = append(queue, inflight{packet, payload})
+
+		// deliver a random queued packet, dropping some
+		j := rng.Intn(len(queue))
+		p := queue[j]
+		queue = append(queue[:j], queue[j+1:]...)
+		if rng.Intn(5) == 0 {
+			continue
+		}
+		out, seq, err := dec.Decode(p.packet)
+		failOnError(t, "Failed decoding", err)
+		if !bytes.Equal(out, p.payload) {
+			t.Fatalf("packet %d: decoded payload != input", seq)
+		}
+		if rng.Intn(3) != 0 {
+			enc.Ack(seq)
+		}
+	}
+
+	if compressed*4 > uncompressed {
+		t.Errorf("compressed %d bytes to %d", uncompressed, compressed)
+	}
+}
+
+func TestPacketMissingDictionary(t *testing.T) {
+	enc := NewPacketEncoder(2)
+	first, seq, err := enc.Encode(snapshot(0))
+	failOnError(t, "Failed encoding", err)
+	enc.Ack(seq)
+	second, _, err := enc.Encode(snapshot(1))
+	failOnError(t, "Failed encoding", err)
+
+	dec := NewPacketDecoder()
+	if _, _, err := dec.Decode(second); err != ErrMissingDictionary {
+		t.Fatalf("expected ErrMissingDictionary, got %v", err)
+	}
+	_, _, err = dec.Decode(first)
+	failOnError(t, "Failed decoding", err)
+	out, _, err := dec.Decode(second)
+	failOnError(t, "Failed decoding", err)
+	if !bytes.Equal(out, snapshot(1)) {
+		t.Fatalf("decoded payload != input")
+	}
+}
+
+func TestPacketTruncated(t *testing.T) {
+	enc := NewPacketEncoder(2)
+	packet, _, err := enc.Encode(snapshot(0))
+	failOnError(t, "Failed encoding", err)
+	dec := NewPacketDecoder()
+	for i := 0; i < len(packet); i++ {
+		if _, _, err := dec.Decode(packet[:i]); err == nil {
+			t.Fatalf("truncated packet of %d bytes decoded", i)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package lz4
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// skippableFrameMagic is the magic number of the skippable frames written by
+// FrameWriter for padding. Readers accept the 16 values from it.
+const (
+	skippableFrameMagic      = 0x184D2A50
+	skippableFrameHeaderSize = 8
+)
+
+// WithPadding makes a Writer or FrameWriter pad its output when closed so its
+// total size is a multiple of multiple bytes, for backends requiring
+// fixed-size writes. Writer pads with padding control records, which
+// DecompressReader skips, and FrameWriter with a skippable frame, which
+// FrameReader and the lz4 command line tool skip. Since the padding needs
+// room for a header, BlockHeaderSize bytes for a record and 8 for a skippable
+// frame, it may extend the output by more than multiple bytes. Multiples
+// below 2 disable padding, the default.
+func WithPadding(multiple int) Option {
+	return func(o *options) {
+		o.padding = multiple
+	}
+}
+
+// paddingSize returns the size of the padding extending size bytes to a
+// multiple of multiple, which is 0 or at least min.
+func paddingSize(size int64, multiple, min int) int {
+	if multiple < 2 {
+		return 0
+	}
+	n := int((int64(multiple) - size%int64(multiple)) % int64(multiple))
+	for n > 0 && n < min {
+		n += multiple
+	}
+	return n
+}
+
+// writePadding writes the padding records of WithPadding, if enabled.
+func (w *Writer) writePadding() error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.opts.padding < 2 || w.opts.discardOutput {
+		return nil
+	}
+	if err := w.writePreamble(); err != nil {
+		return err
+	}
+	w.recordStart = w.compressedWritten
+	n := paddingSize(w.compressedWritten, w.opts.padding, w.headerWidth)
+	if n == 0 {
+		return nil
+	}
+	// the records are built with the width of w, so they are written as is
+	padding := appendPadding(nil, n, w.headerWidth)
+	if err := w.writeUnderlying(padding); err != nil {
+		return err
+	}
+	w.compressedWritten += int64(len(padding))
+	return nil
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// ReadFrom keeps the optimizations of the underlying writer, such as
+// sendfile, for FrameWriter.StoreFrom.
+func (c *countingWriter) ReadFrom(r io.Reader) (int64, error) {
+	n, err := io.Copy(c.w, r)
+	c.n += n
+	return n, err
+}
+
+// writePadding writes the skippable frame of WithPadding, if enabled.
+func (w *FrameWriter) writePadding() error {
+	if w.counter == nil {
+		return nil
+	}
+	n := paddingSize(w.counter.n, w.padding, skippableFrameHeaderSize)
+	if n == 0 {
+		return nil
+	}
+	frame := make([]byte, n)
+	binary.LittleEndian.PutUint32(frame, skippableFrameMagic)
+	binary.LittleEndian.PutUint32(frame[4:], uint32(n-skippableFrameHeaderSize))
+	_, err := w.counter.Write(frame)
+	return err
+}
@@ -0,0 +1,88 @@
+package lz4
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestWriterPadding(t *testing.T) {
+	input := bytes.Repeat(plaintext0, 500)
+	for _, c := range []struct {
+		multiple int
+		opts     []Option
+	}{
+		{512, nil},
+		{3, nil},
+		{1 << 20, []Option{WithTrailer()}},
+		{4096, []Option{WithHeaderWidth(HeaderWidth3)}},
+		{7, []Option{WithHeaderWidth(HeaderWidth8)}},
+	} {
+		var buf bytes.Buffer
+		w := NewWriter(&buf, append(c.opts, WithPadding(c.multiple))...)
+		_, err := w.Write(input)
+		failOnError(t, "Failed writing", err)
+		failOnError(t, "Failed closing", w.Close())
+		if buf.Len()%c.multiple != 0 {
+			t.Errorf("padding to %d: got %d bytes", c.multiple, buf.Len())
+		}
+		if w.CompressedBytesWritten() != int64(buf.Len()) {
+			t.Errorf("padding to %d: CompressedBytesWritten = %d, want %d", c.multiple, w.CompressedBytesWritten(), buf.Len())
+		}
+		r := NewDecompressReader(&buf, c.opts...)
+		out, err := ioutil.ReadAll(r)
+		failOnError(t, "Failed decompressing", err)
+		failOnError(t, "Failed closing reader", r.Close())
+		if !bytes.Equal(out, input) {
+			t.Fatalf("padding to %d: decompressed output != input", c.multiple)
+		}
+	}
+
+	// an empty stream is already a multiple
+	var buf bytes.Buffer
+	w := NewWriter(&buf, WithPadding(100))
+	failOnError(t, "Failed closing", w.Close())
+	if buf.Len() != 0 {
+		t.Errorf("padded empty stream to %d bytes, want 0", buf.Len())
+	}
+}
+
+func TestFrameWriterPadding(t *testing.T) {
+	input := bytes.Repeat(plaintext0, 500)
+	for _, multiple := range []int{4096, 5} {
+		var buf bytes.Buffer
+		w := NewFrameWriter(&buf, WithPadding(multiple))
+		_, err := w.Write(input)
+		failOnError(t, "Failed writing", err)
+		failOnError(t, "Failed closing", w.Close())
+		if buf.Len()%multiple != 0 {
+			t.Errorf("padding to %d: got %d bytes", multiple, buf.Len())
+		}
+		r := NewFrameReader(&buf)
+		out, err := ioutil.ReadAll(r)
+		failOnError(t, "Failed decompressing", err)
+		failOnError(t, "Failed closing reader", r.Close())
+		if !bytes.Equal(out, input) {
+			t.Fatalf("padding to %d: decompressed output != input", multiple)
+		}
+	}
+}
+
+func TestPaddingSize(t *testing.T) {
+	for _, c := range []struct {
+		size          int64
+		multiple, min int
+		want          int
+	}{
+		{100, 0, 4, 0},
+		{100, 1, 4, 0},
+		{100, 10, 4, 0},
+		{95, 10, 4, 5},
+		{98, 10, 4, 12},
+		{99, 2, 8, 9},
+	} {
+		if got := paddingSize(c.size, c.multiple, c.min); got != c.want {
+			t.Errorf("paddingSize(%d, %d, %d) = %d, want %d", c.size, c.multiple, c.min, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package lz4
+
+// #cgo pkg-config: liblz4
+// #include <lz4.h>
+import "C"
+
+// CompressPage compresses src, typically a fixed-size database page, into at
+// most len(dst) bytes of dst. fit reports whether the whole page fits, in
+// which case n is the compressed size; otherwise n is 0 and the content of dst
+// is undefined, and the page should be stored uncompressed. The page can be
+// decompressed with Uncompress into a buffer of len(src) bytes.
+//
+// Unlike Compress, dst does not need to be CompressBound(src) bytes large:
+// compression stops as soon as the budget is exceeded, rather than
+// compressing the whole page only to find out that it does not fit.
+func CompressPage(dst, src []byte) (fit bool, n int) {
+	if len(src) == 0 || len(dst) == 0 || len(src) > maxInputSize {
+		return false, 0
+	}
+	n, consumed := compressDestSize(dst, src)
+	if n <= 0 || consumed != len(src) {
+		return false, 0
+	}
+	return true, n
+}
+
+// CompressTruncate compresses as much of the start of src as fits in dst, and
+// returns the compressed size n and the number of bytes of src consumed, for
+// example to store the head of a large log line in a fixed-size slot. The
+// compressed data decompresses to src[:consumed], with Uncompress into a
+// buffer of consumed bytes. consumed is 0 if dst is empty.
+func CompressTruncate(dst, src []byte) (n, consumed int) {
+	if len(src) > maxInputSize {
+		src = src[:maxInputSize]
+	}
+	if len(src) == 0 || len(dst) == 0 {
+		return 0, 0
+	}
+	return compressDestSize(dst, src)
+}
+
+// compressDestSize compresses as much of src as fits in dst with
+// LZ4_compress_destSize.
+func compressDestSize(dst, src []byte) (n, consumed int) {
+	srcSize := clen(src)
+	n = int(C.LZ4_compress_destSize(p(src), p(dst), &srcSize, clen(dst)))
+	if n <= 0 {
+		return 0, 0
+	}
+	return n, int(srcSize)
+}
@@ -0,0 +1,68 @@
+package lz4
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestCompressPage(t *testing.T) {
+	page := bytes.Repeat([]byte("row data 0123456789 "), 8192/20+1)[:8192]
+	dst := make([]byte, 4096)
+	fit, n := CompressPage(dst, page)
+	if !fit || n <= 0 || n > len(dst) {
+		t.Fatalf("compressible page: got fit=%v, n=%d", fit, n)
+	}
+	out := make([]byte, len(page))
+	m, err := Uncompress(out, dst[:n])
+	failOnError(t, "Failed decompressing page", err)
+	if m != len(page) || !bytes.Equal(out, page) {
+		t.Fatal("decompressed page != page")
+	}
+
+	random := make([]byte, 8192)
+	rand.New(rand.NewSource(1)).Read(random)
+	if fit, n := CompressPage(dst, random); fit || n != 0 {
+		t.Fatalf("random page: got fit=%v, n=%d", fit, n)
+	}
+
+	// a budget just large enough, and one byte too small
+	if fit, m := CompressPage(make([]byte, n), page); !fit || m != n {
+		t.Fatalf("exact budget: got fit=%v, n=%d, want %d", fit, m, n)
+	}
+	if fit, _ := CompressPage(make([]byte, n-1), page); fit {
+		t.Fatal("page fits in a budget smaller than its compressed size")
+	}
+
+	if fit, _ := CompressPage(dst, nil); fit {
+		t.Fatal("empty page reported as fitting")
+	}
+}
+
+func TestCompressTruncate(t *testing.T) {
+	var line []byte
+	for i := 0; len(line) < 100000; i++ {
+		line = append(line, fmt.Sprintf("key%d=value%d ", i%50, i%7)...)
+	}
+	slot := make([]byte, 512)
+	n, consumed := CompressTruncate(slot, line)
+	if n <= 0 || n > len(slot) || consumed <= len(slot) || consumed >= len(line) {
+		t.Fatalf("got n=%d, consumed=%d", n, consumed)
+	}
+	out := make([]byte, consumed)
+	m, err := Uncompress(out, slot[:n])
+	failOnError(t, "Failed decompressing", err)
+	if m != consumed || !bytes.Equal(out, line[:consumed]) {
+		t.Fatal("decompressed output != head of the input")
+	}
+
+	// a short line fits entirely
+	n, consumed = CompressTruncate(slot, line[:100])
+	if consumed != 100 || n <= 0 {
+		t.Fatalf("short line: got n=%d, consumed=%d", n, consumed)
+	}
+	if n, consumed := CompressTruncate(nil, line); n != 0 || consumed != 0 {
+		t.Fatalf("empty dst: got n=%d, consumed=%d", n, consumed)
+	}
+}
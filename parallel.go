@@ -0,0 +1,251 @@
+package lz4
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+)
+
+// DefaultParallelChunkSize is the chunk size used by CompressParallel when
+// none is given.
+const DefaultParallelChunkSize = 4 << 20
+
+// CompressParallel compresses src into a block stream written to dst, using
+// all cores. The input is cut into chunks of chunkSize bytes, or
+// DefaultParallelChunkSize if chunkSize is not positive, each compressed as an
+// independent block stream configured by opts, and the streams are
+// concatenated, which is itself a valid block stream. opts are not suitable
+// for the options adding records covering the whole stream, such as
+// WithTrailer.
+//
+// It returns an index with a point at the start of each chunk, to decompress
+// the stream in parallel with DecompressParallel or to seek in it with
+// NewSeekReader. Independent chunks compress a little worse than a single
+// stream.
+func CompressParallel(dst io.Writer, src io.Reader, chunkSize int, opts ...Option) (*Index, error) {
+	if chunkSize <= 0 {
+		chunkSize = DefaultParallelChunkSize
+	}
+	if err := checkDefaultWidth(explicitOptions(opts...)); err != nil {
+		return nil, err
+	}
+	next := readChunks(src, chunkSize, func(chunk []byte) ([]byte, error) {
+		return compressChunk(chunk, opts)
+	})
+
+	idx := &Index{}
+	err := runOrdered(runtime.GOMAXPROCS(0), next, func(res chunkResult) error {
+		idx.Points = append(idx.Points, IndexPoint{
+			CompressedOffset:   idx.CompressedSize,
+			UncompressedOffset: idx.UncompressedSize,
+		})
+		if _, err := dst.Write(res.data); err != nil {
+			return err
+		}
+		idx.CompressedSize += int64(len(res.data))
+		idx.UncompressedSize += int64(res.n)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// readChunks returns a task source for runOrdered, reading chunks of chunkSize
+// bytes from src, the last one possibly shorter, and passing them to
+// transform.
+func readChunks(src io.Reader, chunkSize int, transform func([]byte) ([]byte, error)) func() (func() chunkResult, error) {
+	eof := false
+	return func() (func() chunkResult, error) {
+		if eof {
+			return nil, nil
+		}
+		chunk := make([]byte, chunkSize)
+		n, err := io.ReadFull(src, chunk)
+		switch err {
+		case nil:
+		case io.EOF, io.ErrUnexpectedEOF:
+			eof = true
+			if n == 0 {
+				return nil, nil
+			}
+		default:
+			return nil, err
+		}
+		return func() chunkResult {
+			data, err := transform(chunk[:n])
+			return chunkResult{data: data, n: n, err: err}
+		}, nil
+	}
+}
+
+// compressChunk compresses chunk into an independent block stream.
+func compressChunk(chunk []byte, opts []Option) ([]byte, error) {
+	var out bytesWriter
+	w := newWriter(&out, explicitOptions(opts...))
+	_, err := w.Write(chunk)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	return out, err
+}
+
+// bytesWriter is an io.Writer appending to a byte slice.
+type bytesWriter []byte
+
+func (b *bytesWriter) Write(p []byte) (int, error) {
+	*b = append(*b, p...)
+	return len(p), nil
+}
+
+// DecompressParallel decompresses the block stream in src, described by idx,
+// to dst, using all cores. The segments between the points of idx are decoded
+// concurrently, each starting from the window of its point, and written in
+// order. idx can come from CompressParallel or BuildIndex, though an index
+// with few points gives little parallelism.
+func DecompressParallel(dst io.Writer, src io.ReaderAt, idx *Index) error {
+	i := 0
+	next := func() (func() chunkResult, error) {
+		if i == len(idx.Points) {
+			return nil, nil
+		}
+		i++
+		seg := i - 1
+		return func() chunkResult {
+			data, err := decompressSegment(src, idx, seg)
+			return chunkResult{data: data, n: len(data), err: err}
+		}, nil
+	}
+	return runOrdered(runtime.GOMAXPROCS(0), next, func(res chunkResult) error {
+		_, err := dst.Write(res.data)
+		return err
+	})
+}
+
+// decompressSegment decodes the data between the point i of idx and the next
+// one.
+func decompressSegment(src io.ReaderAt, idx *Index, i int) ([]byte, error) {
+	pt := idx.Points[i]
+	end := idx.CompressedSize
+	size := idx.UncompressedSize - pt.UncompressedOffset
+	if i+1 < len(idx.Points) {
+		end = idx.Points[i+1].CompressedOffset
+		size = idx.Points[i+1].UncompressedOffset - pt.UncompressedOffset
+	}
+	if end < pt.CompressedOffset || size < 0 {
+		return nil, errBadIndex
+	}
+
+	dr := newDecompressReader(nil, explicitOptions(), nil)
+	dr.defaultWidthOnly = true
+	defer dr.Close()
+	if err := dr.resetWithDict(io.NewSectionReader(src, pt.CompressedOffset, end-pt.CompressedOffset), pt.Window); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(io.LimitReader(dr, size+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) != size {
+		return nil, fmt.Errorf("segment at offset %d decodes to %d bytes, expected %d", pt.CompressedOffset, len(data), size)
+	}
+	return data, nil
+}
+
+type chunkResult struct {
+	data []byte
+	n    int
+	err  error
+}
+
+// runOrdered runs the tasks returned by next, until it returns a nil task,
+// on up to workers goroutines, and passes their results to consume in the
+// order of the tasks. It stops at the first error.
+func runOrdered(workers int, next func() (func() chunkResult, error), consume func(chunkResult) error) error {
+	// the task whose result is awaited is no longer pending
+	pending := make(chan chan chunkResult, workers-1)
+	done := make(chan struct{})
+	nextErr := make(chan error, 1)
+
+	go func() {
+		defer close(pending)
+		for {
+			task, err := next()
+			if err != nil || task == nil {
+				nextErr <- err
+				return
+			}
+			res := make(chan chunkResult, 1)
+			select {
+			case pending <- res:
+			case <-done:
+				nextErr <- nil
+				return
+			}
+			go func() {
+				res <- task()
+			}()
+		}
+	}()
+
+	var err error
+	for res := range pending {
+		r := <-res
+		if err != nil {
+			continue
+		}
+		if err = r.err; err == nil {
+			err = consume(r)
+		}
+		if err != nil {
+			close(done)
+		}
+	}
+	if nerr := <-nextErr; err == nil {
+		err = nerr
+	}
+	return err
+}
+
+// DecompressToWriterAt decompresses the block stream in src, described by idx,
+// writing each segment between the points of idx at its offset in dst. The
+// segments are decoded and written concurrently, in no particular order, using
+// all cores, so dst is typically a preallocated file. It returns the first
+// error encountered, after the segments in progress complete.
+func DecompressToWriterAt(dst io.WriterAt, src io.ReaderAt, idx *Index) error {
+	segments := make(chan int)
+	errs := make(chan error)
+	for w := 0; w < runtime.GOMAXPROCS(0); w++ {
+		go func() {
+			for i := range segments {
+				data, err := decompressSegment(src, idx, i)
+				if err == nil {
+					_, err = dst.WriteAt(data, idx.Points[i].UncompressedOffset)
+				}
+				errs <- err
+			}
+		}()
+	}
+
+	var err error
+	sent, received := 0, 0
+	for received < sent || (err == nil && sent < len(idx.Points)) {
+		// stop sending segments after an error
+		var feed chan<- int
+		if err == nil && sent < len(idx.Points) {
+			feed = segments
+		}
+		select {
+		case feed <- sent:
+			sent++
+		case e := <-errs:
+			received++
+			if err == nil {
+				err = e
+			}
+		}
+	}
+	close(segments)
+	return err
+}
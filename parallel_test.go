@@ -0,0 +1,105 @@
+package lz4
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCompressParallelRoundTrip(t *testing.T) {
+	plain := []byte(strings.Repeat("parallel compression of many chunks ", 200000))
+	for _, chunkSize := range []int{0, 100000, len(plain), len(plain) + 1} {
+		var compressed bytes.Buffer
+		idx, err := CompressParallel(&compressed, bytes.NewReader(plain), chunkSize)
+		failOnError(t, "Failed compressing", err)
+		if idx.CompressedSize != int64(compressed.Len()) || idx.UncompressedSize != int64(len(plain)) {
+			t.Fatalf("chunk size %d: got sizes %d and %d", chunkSize, idx.CompressedSize, idx.UncompressedSize)
+		}
+
+		// the output is a single valid block stream
+		got, err := io.ReadAll(NewDecompressReader(bytes.NewReader(compressed.Bytes())))
+		failOnError(t, "Failed decompressing", err)
+		if !bytes.Equal(got, plain) {
+			t.Fatalf("chunk size %d: sequential decompression mismatch", chunkSize)
+		}
+
+		var out bytes.Buffer
+		failOnError(t, "Failed decompressing in parallel", DecompressParallel(&out, bytes.NewReader(compressed.Bytes()), idx))
+		if !bytes.Equal(out.Bytes(), plain) {
+			t.Fatalf("chunk size %d: parallel decompression mismatch", chunkSize)
+		}
+	}
+}
+
+func TestDecompressParallelWithBuiltIndex(t *testing.T) {
+	plain := []byte(strings.Repeat("dependent blocks need their window ", 100000))
+	var compressed bytes.Buffer
+	w := NewWriter(&compressed)
+	_, err := w.Write(plain)
+	failOnError(t, "Failed writing", err)
+	failOnError(t, "Failed closing", w.Close())
+
+	idx, err := BuildIndex(bytes.NewReader(compressed.Bytes()), 256*1024)
+	failOnError(t, "Failed building index", err)
+	var out bytes.Buffer
+	failOnError(t, "Failed decompressing in parallel", DecompressParallel(&out, bytes.NewReader(compressed.Bytes()), idx))
+	if !bytes.Equal(out.Bytes(), plain) {
+		t.Fatal("parallel decompression mismatch")
+	}
+}
+
+func TestCompressParallelEmpty(t *testing.T) {
+	var compressed bytes.Buffer
+	idx, err := CompressParallel(&compressed, bytes.NewReader(nil), 0)
+	failOnError(t, "Failed compressing", err)
+	if len(idx.Points) != 0 || compressed.Len() != 0 {
+		t.Fatalf("got %d points and %d bytes for empty input", len(idx.Points), compressed.Len())
+	}
+}
+
+func TestDecompressParallelCorrupt(t *testing.T) {
+	plain := []byte(strings.Repeat("corrupt ", 100000))
+	var compressed bytes.Buffer
+	idx, err := CompressParallel(&compressed, bytes.NewReader(plain), 64*1024)
+	failOnError(t, "Failed compressing", err)
+	data := compressed.Bytes()
+	data[idx.Points[3].CompressedOffset+2] ^= 0xff
+	if err := DecompressParallel(io.Discard, bytes.NewReader(data), idx); err == nil {
+		t.Fatal("no error for a corrupt segment")
+	}
+}
+
+type writerAtBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (w *writerAtBuffer) WriteAt(p []byte, off int64) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if end := int(off) + len(p); end > len(w.buf) {
+		w.buf = append(w.buf, make([]byte, end-len(w.buf))...)
+	}
+	return copy(w.buf[off:], p), nil
+}
+
+func TestDecompressToWriterAt(t *testing.T) {
+	plain := []byte(strings.Repeat("restored at their final offsets ", 100000))
+	var compressed bytes.Buffer
+	idx, err := CompressParallel(&compressed, bytes.NewReader(plain), 128*1024)
+	failOnError(t, "Failed compressing", err)
+
+	var out writerAtBuffer
+	failOnError(t, "Failed decompressing", DecompressToWriterAt(&out, bytes.NewReader(compressed.Bytes()), idx))
+	if !bytes.Equal(out.buf, plain) {
+		t.Fatal("decompressed content does not match")
+	}
+
+	data := append([]byte(nil), compressed.Bytes()...)
+	data[idx.Points[len(idx.Points)-1].CompressedOffset+2] ^= 0xff
+	if err := DecompressToWriterAt(&out, bytes.NewReader(data), idx); err == nil {
+		t.Fatal("no error for a corrupt segment")
+	}
+}
@@ -0,0 +1,51 @@
+package lz4
+
+import (
+	"io"
+	"runtime"
+)
+
+// Pipeline reads its input in chunks, transforms the chunks concurrently and
+// writes the results in order. It is the engine of CompressParallel, for
+// custom formats built from independently compressed chunks. The zero value
+// compresses each chunk into an independent block stream, so the output is a
+// valid block stream, with DefaultParallelChunkSize chunks on GOMAXPROCS
+// workers.
+type Pipeline struct {
+	// ChunkSize is the size of the chunks read from the source, except for
+	// the last one, which may be shorter. DefaultParallelChunkSize is used
+	// if it is not positive.
+	ChunkSize int
+	// Workers is the maximum number of chunks transformed concurrently.
+	// GOMAXPROCS is used if it is not positive.
+	Workers int
+	// Transform returns the data written to the sink for a chunk. The
+	// chunk is not used by the Pipeline afterwards. It is called
+	// concurrently, and the first error stops the pipeline.
+	Transform func(chunk []byte) ([]byte, error)
+}
+
+// Run reads src until io.EOF, and writes the transformed chunks to dst. Up to
+// Workers chunks and their results are held in memory at once. It returns the
+// first error from src, dst or Transform, after the chunks in progress
+// complete.
+func (pl *Pipeline) Run(dst io.Writer, src io.Reader) error {
+	chunkSize := pl.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = DefaultParallelChunkSize
+	}
+	workers := pl.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	transform := pl.Transform
+	if transform == nil {
+		transform = func(chunk []byte) ([]byte, error) {
+			return compressChunk(chunk, nil)
+		}
+	}
+	return runOrdered(workers, readChunks(src, chunkSize, transform), func(res chunkResult) error {
+		_, err := dst.Write(res.data)
+		return err
+	})
+}
@@ -0,0 +1,73 @@
+package lz4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"sync/atomic"
+	"testing"
+	"testing/iotest"
+)
+
+func TestPipeline(t *testing.T) {
+	input, err := ioutil.ReadFile(sampleFilePath)
+	failOnError(t, "Failed reading sample", err)
+	for len(input) < 1<<20 {
+		input = append(input, input...)
+	}
+
+	// the default transform writes a block stream
+	var compressed bytes.Buffer
+	pl := &Pipeline{ChunkSize: 100 << 10}
+	failOnError(t, "Failed running pipeline", pl.Run(&compressed, bytes.NewReader(input)))
+	out, err := ioutil.ReadAll(NewDecompressReader(&compressed))
+	failOnError(t, "Failed decompressing", err)
+	if !bytes.Equal(out, input) {
+		t.Fatal("Decompressed output != input")
+	}
+
+	// a custom format of CompressAllocHdr chunks preceded by their size, on
+	// 3 workers
+	var running, maxRunning int32
+	pl = &Pipeline{ChunkSize: 64 << 10, Workers: 3, Transform: func(chunk []byte) ([]byte, error) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		c, err := CompressAllocHdr(chunk)
+		var size [4]byte
+		binary.LittleEndian.PutUint32(size[:], uint32(len(c)))
+		return append(size[:], c...), err
+	}}
+	var hdr bytes.Buffer
+	failOnError(t, "Failed running pipeline", pl.Run(&hdr, bytes.NewReader(input)))
+	if maxRunning > 3 {
+		t.Errorf("%d chunks transformed concurrently, want at most 3", maxRunning)
+	}
+	var got []byte
+	for rest := hdr.Bytes(); len(rest) > 0; {
+		size := 4 + int(binary.LittleEndian.Uint32(rest))
+		chunk, err := UncompressAllocHdr(nil, rest[4:size])
+		failOnError(t, "Failed decompressing chunk", err)
+		got = append(got, chunk...)
+		rest = rest[size:]
+	}
+	if !bytes.Equal(got, input) {
+		t.Fatal("Decompressed output != input")
+	}
+
+	// errors from the source and from Transform
+	errTest := errors.New("test error")
+	if err := (&Pipeline{}).Run(ioutil.Discard, iotest.ErrReader(errTest)); err != errTest {
+		t.Errorf("got %v, want the source error", err)
+	}
+	pl = &Pipeline{ChunkSize: 1000, Transform: func([]byte) ([]byte, error) { return nil, errTest }}
+	if err := pl.Run(ioutil.Discard, bytes.NewReader(input)); err != errTest {
+		t.Errorf("got %v, want the Transform error", err)
+	}
+}
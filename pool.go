@@ -0,0 +1,137 @@
+package lz4
+
+// #cgo pkg-config: liblz4
+// #include <stdlib.h>
+// #include <lz4.h>
+// #include <lz4hc.h>
+import "C"
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+	"unsafe"
+)
+
+var errPoolClosed = errors.New("compressor pool is closed")
+
+type poolJob struct {
+	in     []byte
+	result chan poolResult
+}
+
+type poolResult struct {
+	out []byte
+	err error
+}
+
+// CompressorPool compresses independent buffers on a fixed set of worker
+// goroutines, each keeping its lz4 state from one buffer to the next, and
+// sharing a pool of output buffers, so services compressing many buffers
+// concurrently do not pay for their allocation each time. WithMaxRetainedBytes
+// bounds the output buffers kept, and Trim releases them. Submit may be called
+// concurrently, and returns an error once Close was called.
+type CompressorPool struct {
+	jobs  chan poolJob
+	level int
+	wg    sync.WaitGroup
+
+	buffers bufferPool
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewCompressorPool starts a CompressorPool with the given number of workers,
+// at least 1. WithLevel sets the compression level, as for Writer. It is the
+// caller's responsibility to call Close when done, to stop the workers and
+// free their state.
+func NewCompressorPool(workers int, opts ...Option) *CompressorPool {
+	if workers < 1 {
+		workers = 1
+	}
+	o := newOptions(opts)
+	cp := &CompressorPool{
+		jobs:  make(chan poolJob),
+		level: o.level,
+	}
+	cp.buffers.max = o.maxRetained
+	cp.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go cp.work()
+	}
+	return cp
+}
+
+// work compresses jobs until the pool is closed.
+func (cp *CompressorPool) work() {
+	defer cp.wg.Done()
+	var state unsafe.Pointer
+	if cp.level > 0 {
+		state = C.malloc(C.size_t(C.LZ4_sizeofStateHC()))
+	} else {
+		state = C.malloc(C.size_t(C.LZ4_sizeofState()))
+	}
+	defer C.free(state)
+	for job := range cp.jobs {
+		buf := cp.buffers.get(CompressBoundHdr(job.in))
+		n := cp.compress(state, buf[4:], job.in)
+		if n <= 0 {
+			cp.buffers.put(buf)
+			fn := "LZ4_compress_fast_extState"
+			if cp.level > 0 {
+				fn = "LZ4_compress_HC_extStateHC"
+			}
+			job.result <- poolResult{err: newCError(fn, n, "error compressing")}
+			continue
+		}
+		binary.LittleEndian.PutUint32(buf, uint32(len(job.in)))
+		out := make([]byte, 4+n)
+		copy(out, buf)
+		cp.buffers.put(buf)
+		job.result <- poolResult{out: out}
+	}
+}
+
+// compress compresses in into out with the extState functions, which reuse
+// state instead of allocating one.
+func (cp *CompressorPool) compress(state unsafe.Pointer, out, in []byte) int {
+	if cp.level > 0 {
+		return int(C.LZ4_compress_HC_extStateHC(state, p(in), p(out), clen(in), clen(out), C.int(cp.level)))
+	}
+	acceleration := 1
+	if cp.level < 0 {
+		acceleration = -cp.level
+	}
+	return int(C.LZ4_compress_fast_extState(state, p(in), p(out), clen(in), clen(out), C.int(acceleration)))
+}
+
+// Submit compresses in on one of the workers and returns the result, in the
+// format of CompressAllocHdr, so it can be decompressed with
+// UncompressAllocHdr. It blocks until a worker is available and done.
+func (cp *CompressorPool) Submit(in []byte) (out []byte, err error) {
+	if len(in) > maxInputSize {
+		return nil, ErrInputTooLarge
+	}
+	cp.mu.RLock()
+	defer cp.mu.RUnlock()
+	if cp.closed {
+		return nil, errPoolClosed
+	}
+	result := make(chan poolResult, 1)
+	cp.jobs <- poolJob{in: in, result: result}
+	r := <-result
+	return r.out, r.err
+}
+
+// Close stops the workers and frees their state.
+func (cp *CompressorPool) Close() error {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	if !cp.closed {
+		cp.closed = true
+		close(cp.jobs)
+		cp.wg.Wait()
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package lz4
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestCompressorPool(t *testing.T) {
+	for _, level := range []int{0, -4, 9} {
+		t.Run(fmt.Sprint("level ", level), func(t *testing.T) {
+			cp := NewCompressorPool(4, WithLevel(level))
+			var wg sync.WaitGroup
+			for i := 0; i < 32; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					in := bytes.Repeat([]byte(fmt.Sprintf("buffer %d ", i)), 100*i)
+					out, err := cp.Submit(in)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					dec, err := UncompressAllocHdr(nil, out)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					if !bytes.Equal(dec, in) {
+						t.Errorf("buffer %d: decompressed output != input", i)
+					}
+				}(i)
+			}
+			wg.Wait()
+			failOnError(t, "Failed closing", cp.Close())
+			failOnError(t, "Failed closing twice", cp.Close())
+			if _, err := cp.Submit([]byte("late")); err == nil {
+				t.Error("no error submitting to a closed pool")
+			}
+		})
+	}
+}
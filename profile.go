@@ -0,0 +1,50 @@
+package lz4
+
+// Profile is a ready-made set of options for a common use case. Use
+// WithProfile to apply it. Options passed after WithProfile override the
+// choices of the profile.
+type Profile int
+
+const (
+	// ProfileBalanced uses the default fast compression. It is what a
+	// Writer does without options.
+	ProfileBalanced Profile = iota
+	// ProfileRealtime favors speed over ratio, using fast compression with
+	// a high acceleration. Use it for latency-sensitive streams.
+	ProfileRealtime
+	// ProfileArchive favors ratio over speed, using HC compression, and
+	// checks every block after compressing it. Use it for data that is
+	// written once and kept for a long time.
+	ProfileArchive
+	// ProfileLowMemory avoids the extra memory of HC compression, and makes
+	// readers use WithLowMemory. Use it for many concurrent streams or
+	// memory-constrained processes.
+	ProfileLowMemory
+)
+
+// profileRealtimeLevel is the level used by ProfileRealtime, an acceleration
+// of 8.
+const profileRealtimeLevel = -8
+
+// profileArchiveLevel is the level used by ProfileArchive, the default level
+// of lz4hc.
+const profileArchiveLevel = 9
+
+// WithProfile applies the options bundled in p.
+func WithProfile(p Profile) Option {
+	return func(o *options) {
+		switch p {
+		case ProfileBalanced:
+			o.level = 0
+		case ProfileRealtime:
+			o.level = profileRealtimeLevel
+		case ProfileArchive:
+			o.level = profileArchiveLevel
+			o.verify = true
+		case ProfileLowMemory:
+			o.level = 0
+			o.adaptive = false
+			o.lowMemory = true
+		}
+	}
+}
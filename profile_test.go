@@ -0,0 +1,57 @@
+package lz4
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestProfiles(t *testing.T) {
+	input, err := ioutil.ReadFile(sampleFilePath)
+	failOnError(t, "Failed reading sample", err)
+
+	sizes := map[Profile]int{}
+	for _, p := range []Profile{ProfileBalanced, ProfileRealtime, ProfileArchive, ProfileLowMemory} {
+		var compressed bytes.Buffer
+		w := NewWriter(&compressed, WithProfile(p))
+		_, err := w.Write(input)
+		failOnError(t, "Failed writing to compress object", err)
+		failOnError(t, "Failed closing writer", w.Close())
+		sizes[p] = compressed.Len()
+
+		r := NewDecompressReader(&compressed)
+		out, err := ioutil.ReadAll(r)
+		failOnError(t, "Failed decompressing", err)
+		failOnError(t, "Failed closing reader", r.Close())
+		if !bytes.Equal(out, input) {
+			t.Fatalf("profile %d: decompressed output != input", p)
+		}
+	}
+	if !(sizes[ProfileArchive] < sizes[ProfileBalanced] && sizes[ProfileBalanced] < sizes[ProfileRealtime]) {
+		t.Errorf("unexpected compressed sizes: %v", sizes)
+	}
+}
+
+func TestWithLevel(t *testing.T) {
+	input, err := ioutil.ReadFile(sampleFilePath)
+	failOnError(t, "Failed reading sample", err)
+
+	var fast, hc bytes.Buffer
+	for _, c := range []struct {
+		buf   *bytes.Buffer
+		level int
+	}{{&fast, 0}, {&hc, 12}} {
+		w := NewWriter(c.buf, WithLevel(c.level))
+		_, err := w.Write(input)
+		failOnError(t, "Failed writing to compress object", err)
+		failOnError(t, "Failed closing writer", w.Close())
+	}
+	if hc.Len() >= fast.Len() {
+		t.Errorf("HC output %d bytes, fast output %d bytes", hc.Len(), fast.Len())
+	}
+	out, err := ioutil.ReadAll(NewDecompressReader(&hc))
+	failOnError(t, "Failed decompressing", err)
+	if !bytes.Equal(out, input) {
+		t.Fatalf("Decompressed output != input")
+	}
+}
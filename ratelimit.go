@@ -0,0 +1,41 @@
+package lz4
+
+import (
+	"time"
+)
+
+// tokenBucket limits the rate of the bytes written by a Writer. Tokens are
+// bytes; the bucket holds at most one block, so the output is paced block by
+// block rather than in bursts.
+type tokenBucket struct {
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(bytesPerSecond int64, blockSize int) *tokenBucket {
+	burst := float64(blockHeaderSize + compressBound(blockSize))
+	return &tokenBucket{
+		rate:   float64(bytesPerSecond),
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+// wait blocks until n bytes can be written. n may exceed the size of the
+// bucket, in which case the caller waits for the missing tokens.
+func (b *tokenBucket) wait(n int) {
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	b.tokens -= float64(n)
+	if b.tokens < 0 {
+		time.Sleep(time.Duration(-b.tokens / b.rate * float64(time.Second)))
+	}
+}
@@ -0,0 +1,38 @@
+package lz4
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestWithRateLimit(t *testing.T) {
+	// random data does not compress, so the output is as large as the input
+	input := make([]byte, 4*streamingBlockSize)
+	rand.New(rand.NewSource(1)).Read(input)
+
+	const rate = 1024 * 1024
+	var compressed bytes.Buffer
+	w := NewWriter(&compressed, WithRateLimit(rate))
+	start := time.Now()
+	_, err := w.Write(input)
+	failOnError(t, "Failed writing to compress object", err)
+	elapsed := time.Since(start)
+	failOnError(t, "Failed closing writer", w.Close())
+
+	// the first block is allowed as a burst
+	minimum := time.Duration(float64(compressed.Len()-boundedStreamingBlockSize-blockHeaderSize) / rate * float64(time.Second))
+	if elapsed < minimum {
+		t.Fatalf("wrote %d bytes in %s, expected at least %s", compressed.Len(), elapsed, minimum)
+	}
+
+	r := NewDecompressReader(&compressed)
+	out, err := ioutil.ReadAll(r)
+	failOnError(t, "Failed decompressing", err)
+	failOnError(t, "Failed closing reader", r.Close())
+	if !bytes.Equal(out, input) {
+		t.Fatalf("Decompressed output != input")
+	}
+}
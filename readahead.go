@@ -0,0 +1,206 @@
+package lz4
+
+import (
+	"io"
+	"sync"
+)
+
+const (
+	// readAheadChunkSize is the size of the reads made by a readAhead.
+	readAheadChunkSize = 256 * 1024
+	// readAheadChunks is the number of chunks a readAhead buffers.
+	readAheadChunks = 2
+)
+
+type readAheadChunk struct {
+	buf []byte
+	err error
+}
+
+// readAhead reads from an io.Reader in a background goroutine, so the reads
+// from the underlying reader of a FrameReader overlap with the decompression
+// of the data already read. liblz4 decodes frames straight into the buffer
+// passed to Read, so they cannot be decoded ahead like block streams.
+type readAhead struct {
+	// timeout is armed before each read, instead of by the reader
+	timeout   readTimeout
+	full      chan readAheadChunk
+	free      chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+
+	chunk readAheadChunk
+	cur   []byte
+}
+
+func newReadAhead(r io.Reader, timeout readTimeout) *readAhead {
+	ra := &readAhead{
+		timeout: timeout,
+		full:    make(chan readAheadChunk, readAheadChunks),
+		free:    make(chan []byte, readAheadChunks),
+		done:    make(chan struct{}),
+	}
+	for i := 0; i < readAheadChunks; i++ {
+		ra.free <- make([]byte, readAheadChunkSize)
+	}
+	go ra.run(r)
+	return ra
+}
+
+func (ra *readAhead) run(r io.Reader) {
+	for {
+		var buf []byte
+		select {
+		case buf = <-ra.free:
+		case <-ra.done:
+			return
+		}
+		n, err := 0, ra.timeout.arm()
+		if err == nil {
+			n, err = r.Read(buf)
+		}
+		select {
+		case ra.full <- readAheadChunk{buf[:n], err}:
+		case <-ra.done:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (ra *readAhead) Read(p []byte) (int, error) {
+	for len(ra.cur) == 0 {
+		if ra.chunk.err != nil {
+			return 0, ra.chunk.err
+		}
+		if ra.chunk.buf != nil {
+			ra.free <- ra.chunk.buf[:cap(ra.chunk.buf)]
+		}
+		ra.chunk = <-ra.full
+		ra.cur = ra.chunk.buf
+	}
+	n := copy(p, ra.cur)
+	ra.cur = ra.cur[n:]
+	return n, nil
+}
+
+// close stops the background goroutine. A read from the underlying reader in
+// progress is not interrupted, but its result is discarded.
+func (ra *readAhead) close() {
+	ra.closeOnce.Do(func() {
+		close(ra.done)
+	})
+}
+
+// decodedBlock is a block decoded ahead by a blockReadAhead, along with the
+// state of the DecompressReader after decoding it.
+type decodedBlock struct {
+	block []byte
+	err   error
+
+	compressedRead   int64
+	uncompressedRead int64
+	info             *StreamInfo
+}
+
+// blockReadAhead decodes the blocks of a DecompressReader one block ahead in a
+// background goroutine, so reading and decoding the next block overlaps with
+// the consumption of the current one. The block returned last and the one
+// being decoded are in use at the same time, so the reader must decode into
+// two alternating buffers. The goroutine owns the state of the reader; last
+// holds the state after the block returned last, for the accessors.
+type blockReadAhead struct {
+	// timeout is armed before decoding each block, instead of by the reader
+	timeout readTimeout
+	decode  func() decodedBlock
+	request chan struct{}
+	decoded chan decodedBlock
+	done    chan struct{}
+	exited  chan struct{}
+
+	// requested is set while a block is being decoded ahead
+	requested bool
+	last      decodedBlock
+	closeOnce sync.Once
+}
+
+func newBlockReadAhead(decode func() decodedBlock, timeout readTimeout) *blockReadAhead {
+	ra := &blockReadAhead{
+		timeout: timeout,
+		decode:  decode,
+		request: make(chan struct{}, 1),
+		decoded: make(chan decodedBlock),
+		done:    make(chan struct{}),
+		exited:  make(chan struct{}),
+	}
+	go ra.run()
+	return ra
+}
+
+func (ra *blockReadAhead) run() {
+	defer close(ra.exited)
+	for {
+		select {
+		case <-ra.request:
+		case <-ra.done:
+			return
+		}
+		b := ra.decode()
+		select {
+		case ra.decoded <- b:
+		case <-ra.done:
+			return
+		}
+	}
+}
+
+// next returns the block decoded ahead, waiting for it if needed, and starts
+// decoding the following one. It is the next function of the block engine of
+// the reader. After an error, which may be io.EOF on a growing stream, the
+// next block is only decoded when asked for.
+func (ra *blockReadAhead) next() ([]byte, error) {
+	if !ra.requested {
+		ra.request <- struct{}{}
+	}
+	ra.last = <-ra.decoded
+	ra.requested = ra.last.err == nil
+	if ra.requested {
+		ra.request <- struct{}{}
+	}
+	return ra.last.block, ra.last.err
+}
+
+// close stops the background goroutine, waiting for the block being decoded,
+// if any, since it uses the buffers of the reader.
+func (ra *blockReadAhead) close() {
+	ra.closeOnce.Do(func() {
+		close(ra.done)
+	})
+	<-ra.exited
+}
+
+// decodesAhead reports whether a DecompressReader with the options o, decoding
+// into buf in low-memory mode if it is not nil, decodes blocks ahead. A ring
+// buffer only keeps the last 64 KiB of the block returned last, so WithReadAhead
+// is ignored in low-memory mode.
+func decodesAhead(o options, buf []byte) bool {
+	return o.readAhead && buf == nil && !o.lowMemory
+}
+
+// decodeAhead decodes the next block for a blockReadAhead.
+func (r *DecompressReader) decodeAhead() decodedBlock {
+	var block []byte
+	err := r.readAhead.timeout.arm()
+	if err == nil {
+		block, err = r.nextBlock()
+	}
+	return decodedBlock{
+		block:            block,
+		err:              err,
+		compressedRead:   r.compressedRead,
+		uncompressedRead: r.uncompressedRead,
+		info:             r.info,
+	}
+}
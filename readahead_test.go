@@ -0,0 +1,100 @@
+package lz4
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestWithReadAhead(t *testing.T) {
+	input, err := ioutil.ReadFile(sampleFilePath)
+	failOnError(t, "Failed reading sample", err)
+	for len(input) < 4*readAheadChunkSize {
+		input = append(input, input...)
+	}
+	compressed, err := CompressBytesToStream(input)
+	failOnError(t, "Failed compressing", err)
+
+	r := NewDecompressReader(iotest.HalfReader(bytes.NewReader(compressed)), WithReadAhead())
+	out, err := ioutil.ReadAll(r)
+	failOnError(t, "Failed decompressing", err)
+	failOnError(t, "Failed closing reader", r.Close())
+	if !bytes.Equal(out, input) {
+		t.Fatalf("Decompressed output != input")
+	}
+
+	var frame bytes.Buffer
+	failOnError(t, "Failed transcoding", Transcode(&frame, bytes.NewReader(compressed), FormatCustomStream, FormatFrame))
+	fr := NewFrameReader(&frame, WithReadAhead())
+	out, err = ioutil.ReadAll(fr)
+	failOnError(t, "Failed decompressing", err)
+	failOnError(t, "Failed closing reader", fr.Close())
+	if !bytes.Equal(out, input) {
+		t.Fatalf("Decompressed frame output != input")
+	}
+}
+
+func TestReadAheadError(t *testing.T) {
+	errBroken := errors.New("broken")
+	ra := newReadAhead(io.MultiReader(bytes.NewReader([]byte("abc")), iotest.ErrReader(errBroken)), readTimeout{})
+	defer ra.close()
+	out, err := ioutil.ReadAll(ra)
+	if string(out) != "abc" || err != errBroken {
+		t.Fatalf("got %q, %v", out, err)
+	}
+}
+
+func TestReadAheadCloseEarly(t *testing.T) {
+	compressed, err := CompressBytesToStream(bytes.Repeat(plaintext0, 100000))
+	failOnError(t, "Failed compressing", err)
+	r := NewDecompressReader(bytes.NewReader(compressed), WithReadAhead())
+	_, err = r.Read(make([]byte, 10))
+	failOnError(t, "Failed reading", err)
+	failOnError(t, "Failed closing reader", r.Close())
+}
+
+func TestReadAheadDecodesNextBlock(t *testing.T) {
+	input := bytes.Repeat(plaintext0, 50000)
+	for _, opts := range [][]Option{nil, {WithIndependentBlocks()}} {
+		var buf bytes.Buffer
+		w := NewWriter(&buf, opts...)
+		_, err := w.Write(input)
+		failOnError(t, "Failed writing", err)
+		failOnError(t, "Failed closing", w.Close())
+
+		size := int64(buf.Len())
+		reports := make(chan BlockReport, 100)
+		r := NewDecompressReader(&buf, append(opts, WithReadAhead(), WithBlockReports(func(b BlockReport) {
+			reports <- b
+		}))...).(*DecompressReader)
+		first, err := r.ReadBlock()
+		failOnError(t, "Failed reading block", err)
+		first = append([]byte(nil), first...)
+		<-reports
+		// the second block is decoded without reading it
+		select {
+		case b := <-reports:
+			if b.Index != 1 {
+				t.Fatalf("decoded block %d ahead, want 1", b.Index)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("the next block was not decoded ahead")
+		}
+		if got := r.UncompressedBytesRead(); got != int64(len(first)) {
+			t.Errorf("UncompressedBytesRead = %d, want %d", got, len(first))
+		}
+		rest, err := ioutil.ReadAll(r)
+		failOnError(t, "Failed decompressing", err)
+		failOnError(t, "Failed closing reader", r.Close())
+		if !bytes.Equal(append(first, rest...), input) {
+			t.Fatalf("Decompressed output != input")
+		}
+		if got := r.CompressedBytesRead(); got != size {
+			t.Errorf("CompressedBytesRead = %d, want %d", got, size)
+		}
+	}
+}
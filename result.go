@@ -0,0 +1,71 @@
+package lz4
+
+// Result describes the outcome of a one-shot compression, for call sites
+// feeding metrics or storage headers.
+type Result struct {
+	// CompressedSize is the number of bytes written to out.
+	CompressedSize int
+	// OriginalSize is the size of the input.
+	OriginalSize int
+	// Ratio is OriginalSize divided by CompressedSize, or 1 if both are 0.
+	Ratio float64
+	// Stored is set if the input was copied to out uncompressed.
+	Stored bool
+}
+
+func newResult(compressed, original int, stored bool) Result {
+	ratio := 1.0
+	if compressed > 0 {
+		ratio = float64(original) / float64(compressed)
+	}
+	return Result{
+		CompressedSize: compressed,
+		OriginalSize:   original,
+		Ratio:          ratio,
+		Stored:         stored,
+	}
+}
+
+// CompressResult is like Compress, but describes the output with a Result.
+func CompressResult(out, in []byte) (Result, error) {
+	n, err := Compress(out, in)
+	if err != nil {
+		return Result{}, err
+	}
+	return newResult(n, len(in), false), nil
+}
+
+// CompressFastResult is like CompressFast, but describes the output with a
+// Result.
+func CompressFastResult(out, in []byte, acceleration int) (Result, error) {
+	n, err := CompressFast(out, in, acceleration)
+	if err != nil {
+		return Result{}, err
+	}
+	return newResult(n, len(in), false), nil
+}
+
+// CompressHCLevelResult is like CompressHCLevel, but describes the output with
+// a Result.
+func CompressHCLevelResult(out, in []byte, level int) (Result, error) {
+	n, err := CompressHCLevel(out, in, level)
+	if err != nil {
+		return Result{}, err
+	}
+	return newResult(n, len(in), false), nil
+}
+
+// CompressOrStore compresses in into out like CompressNoExpand, but copies in
+// to out instead when it is incompressible, setting Result.Stored, which the
+// caller must record to know how to read the data back. out needs len(in)
+// bytes.
+func CompressOrStore(out, in []byte) (Result, error) {
+	n, err := CompressNoExpand(out, in)
+	if err == ErrIncompressible && len(out) >= len(in) {
+		return newResult(copy(out, in), len(in), true), nil
+	}
+	if err != nil {
+		return Result{}, err
+	}
+	return newResult(n, len(in), false), nil
+}
@@ -0,0 +1,41 @@
+package lz4
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+func TestCompressResult(t *testing.T) {
+	input := bytes.Repeat([]byte("result "), 1000)
+	out := make([]byte, CompressBound(input))
+	for name, compress := range map[string]func() (Result, error){
+		"default": func() (Result, error) { return CompressResult(out, input) },
+		"fast":    func() (Result, error) { return CompressFastResult(out, input, 8) },
+		"hc":      func() (Result, error) { return CompressHCLevelResult(out, input, 9) },
+		"store":   func() (Result, error) { return CompressOrStore(out, input) },
+	} {
+		res, err := compress()
+		failOnError(t, "Failed compressing", err)
+		if res.OriginalSize != len(input) || res.Stored || res.CompressedSize <= 0 ||
+			res.Ratio != float64(len(input))/float64(res.CompressedSize) {
+			t.Errorf("%s: got %+v", name, res)
+		}
+		decompressed := make([]byte, len(input))
+		if _, err := Uncompress(decompressed, out[:res.CompressedSize]); err != nil || !bytes.Equal(decompressed, input) {
+			t.Errorf("%s: decompressed output != input (%v)", name, err)
+		}
+	}
+
+	random := make([]byte, 1000)
+	_, err := rand.Read(random)
+	failOnError(t, "Failed generating input", err)
+	res, err := CompressOrStore(out, random)
+	failOnError(t, "Failed storing", err)
+	if !res.Stored || res.CompressedSize != len(random) || res.Ratio != 1 || !bytes.Equal(out[:len(random)], random) {
+		t.Errorf("got %+v for random input", res)
+	}
+	if res, err := CompressOrStore(nil, nil); err != nil || res.Ratio <= 0 {
+		t.Errorf("got %+v, %v for empty input", res, err)
+	}
+}
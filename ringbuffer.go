@@ -0,0 +1,59 @@
+package lz4
+
+// #cgo pkg-config: liblz4
+// #include <lz4.h>
+import "C"
+
+// WithRingBuffer makes a DecompressReader decode into a ring buffer sized by
+// LZ4_decoderRingBufferSize for blocks of at most maxBlockSize bytes, as
+// written by a Writer created with WithBlockSize(maxBlockSize) or less, like
+// WithLowMemory does for the default of 64 KiB. The ring keeps the 64 KiB
+// history window with room for one block, so small blocks bring the memory
+// of a reader down to about 66 KiB plus twice the block size, for
+// constrained devices. With WithIndependentBlocks, the reader only needs
+// room for one block. Reading a stream with larger blocks returns an error.
+// Sizes out of range are clamped.
+func WithRingBuffer(maxBlockSize int) Option {
+	return func(o *options) {
+		if maxBlockSize < 1 {
+			maxBlockSize = 1
+		}
+		if maxBlockSize > MaxBlockSize {
+			maxBlockSize = MaxBlockSize
+		}
+		o.lowMemory = true
+		o.ringBlockSize = maxBlockSize
+	}
+}
+
+// DecompressBufferSizeFor returns the size of the buffer passed to
+// NewDecompressReaderBuffer with WithRingBuffer(maxBlockSize): the ring
+// buffer and the space for a compressed block. It is DecompressBufferSize for
+// blocks of 64 KiB.
+func DecompressBufferSizeFor(maxBlockSize int) int {
+	var o options
+	WithRingBuffer(maxBlockSize)(&o)
+	return lowMemorySize(o)
+}
+
+// lowMemoryBlockSize returns the maximum block size of a reader in low-memory
+// mode with the options o.
+func lowMemoryBlockSize(o options) int {
+	if o.ringBlockSize > 0 {
+		return o.ringBlockSize
+	}
+	return streamingBlockSize
+}
+
+// decoderRingBufferSize returns the size of a ring buffer decoding blocks of
+// at most maxBlockSize bytes.
+func decoderRingBufferSize(maxBlockSize int) int {
+	return int(C.LZ4_decoderRingBufferSize(C.int(maxBlockSize)))
+}
+
+// lowMemorySize returns the memory used by a reader in low-memory mode with
+// the options o, decoding linked blocks.
+func lowMemorySize(o options) int {
+	blockSize := lowMemoryBlockSize(o)
+	return decoderRingBufferSize(blockSize) + compressBound(blockSize)
+}
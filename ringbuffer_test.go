@@ -0,0 +1,59 @@
+package lz4
+
+import (
+	"bytes"
+	"io/ioutil"
+	"syscall"
+	"testing"
+)
+
+func TestRingBuffer(t *testing.T) {
+	if got := DecompressBufferSizeFor(streamingBlockSize); got != DecompressBufferSize {
+		t.Errorf("DecompressBufferSizeFor(64 KiB) = %d, want %d", got, DecompressBufferSize)
+	}
+
+	input, err := ioutil.ReadFile(sampleFilePath)
+	failOnError(t, "Failed reading sample", err)
+	for len(input) < 4*streamingBlockSize {
+		input = append(input, input...)
+	}
+	const blockSize = 4096
+	var compressed bytes.Buffer
+	w := NewWriter(&compressed, WithBlockSize(blockSize))
+	_, err = w.Write(input)
+	failOnError(t, "Failed writing", err)
+	failOnError(t, "Failed closing writer", w.Close())
+
+	r := NewDecompressReader(bytes.NewReader(compressed.Bytes()), WithRingBuffer(blockSize)).(*DecompressReader)
+	if r.reserved >= 2*streamingBlockSize {
+		t.Errorf("reader uses %d bytes", r.reserved)
+	}
+	out, err := ioutil.ReadAll(r)
+	failOnError(t, "Failed decompressing", err)
+	failOnError(t, "Failed closing reader", r.Close())
+	if !bytes.Equal(out, input) {
+		t.Fatalf("Decompressed output != input")
+	}
+
+	size := DecompressBufferSizeFor(blockSize)
+	arena, err := syscall.Mmap(-1, 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	if err != nil {
+		t.Skip("mmap not available:", err)
+	}
+	defer syscall.Munmap(arena)
+	br, err := NewDecompressReaderBuffer(bytes.NewReader(compressed.Bytes()), arena, WithRingBuffer(blockSize))
+	failOnError(t, "Failed creating reader", err)
+	out, err = ioutil.ReadAll(br)
+	failOnError(t, "Failed decompressing", err)
+	failOnError(t, "Failed closing reader", br.Close())
+	if !bytes.Equal(out, input) {
+		t.Fatalf("Decompressed output != input with a buffer")
+	}
+
+	// larger blocks do not fit
+	r = NewDecompressReader(bytes.NewReader(compressStream(t, input)), WithRingBuffer(blockSize)).(*DecompressReader)
+	defer r.Close()
+	if _, err := ioutil.ReadAll(r); err == nil {
+		t.Errorf("no error for blocks larger than the ring buffer allows")
+	}
+}
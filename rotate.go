@@ -0,0 +1,86 @@
+package lz4
+
+import (
+	"io"
+	"time"
+)
+
+// RotatingWriter writes a sequence of independent compressed streams, starting
+// a new one when the current stream reaches a size or an age. Each stream is
+// written to a new io.WriteCloser returned by a factory, such as a new log
+// file, and can be decompressed on its own. A Write is never split between
+// streams. A RotatingWriter is not safe for concurrent use.
+type RotatingWriter struct {
+	newOutput func() (io.WriteCloser, error)
+	maxBytes  int64
+	maxAge    time.Duration
+	opts      []Option
+
+	w       *Writer
+	started time.Time
+}
+
+// NewRotatingWriter creates a new RotatingWriter calling newOutput for each
+// stream. A stream is ended after the Write that makes its compressed size
+// reach maxBytes, or before the first Write after it is maxAge old. A value of
+// 0 disables the corresponding limit. opts configure the Writer of each
+// stream. It is the caller's responsibility to call Close when done.
+func NewRotatingWriter(newOutput func() (io.WriteCloser, error), maxBytes int64, maxAge time.Duration, opts ...Option) *RotatingWriter {
+	return &RotatingWriter{
+		newOutput: newOutput,
+		maxBytes:  maxBytes,
+		maxAge:    maxAge,
+		// each Writer closes its output, after writing the end of its
+		// stream
+		opts: append(append([]Option(nil), opts...), WithOwnsUnderlying()),
+	}
+}
+
+// Write compresses p into the current stream, starting a new one if needed.
+func (r *RotatingWriter) Write(p []byte) (int, error) {
+	if r.w != nil && r.maxAge > 0 && time.Since(r.started) >= r.maxAge {
+		if err := r.Rotate(); err != nil {
+			return 0, err
+		}
+	}
+	if r.w == nil {
+		out, err := r.newOutput()
+		if err != nil {
+			return 0, err
+		}
+		r.w = NewWriter(out, r.opts...)
+		r.started = time.Now()
+	}
+	n, err := r.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+	if r.maxBytes > 0 && r.w.CompressedBytesWritten() >= r.maxBytes {
+		return n, r.Rotate()
+	}
+	return n, nil
+}
+
+// Flush flushes the output of the current stream, as Writer.Flush does.
+func (r *RotatingWriter) Flush() error {
+	if r.w == nil {
+		return nil
+	}
+	return r.w.Flush()
+}
+
+// Rotate ends the current stream, if any, and closes its output. The next
+// Write starts a new stream.
+func (r *RotatingWriter) Rotate() error {
+	if r.w == nil {
+		return nil
+	}
+	w := r.w
+	r.w = nil
+	return w.Close()
+}
+
+// Close ends the current stream and closes its output.
+func (r *RotatingWriter) Close() error {
+	return r.Rotate()
+}
@@ -0,0 +1,83 @@
+package lz4
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+type closingBuffer struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (c *closingBuffer) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestRotatingWriterSize(t *testing.T) {
+	var outputs []*closingBuffer
+	newOutput := func() (io.WriteCloser, error) {
+		outputs = append(outputs, &closingBuffer{})
+		return outputs[len(outputs)-1], nil
+	}
+
+	// random letters barely compress, so the streams fill up quickly
+	rng := rand.New(rand.NewSource(1))
+	record := make([]byte, 1000)
+	w := NewRotatingWriter(newOutput, 4096, 0, WithTrailer())
+	var written []byte
+	for i := 0; i < 20; i++ {
+		for j := range record {
+			record[j] = 'a' + byte(rng.Intn(26))
+		}
+		_, err := w.Write(record)
+		failOnError(t, "Failed writing", err)
+		written = append(written, record...)
+	}
+	failOnError(t, "Failed closing", w.Close())
+
+	if len(outputs) < 3 {
+		t.Fatalf("got %d streams", len(outputs))
+	}
+	var out []byte
+	for i, o := range outputs {
+		if !o.closed {
+			t.Errorf("stream %d not closed", i)
+		}
+		// each stream is complete on its own
+		r := NewDecompressReader(&o.Buffer, WithTrailer())
+		data, err := ioutil.ReadAll(r)
+		failOnError(t, "Failed decompressing", err)
+		out = append(out, data...)
+	}
+	if !bytes.Equal(out, written) {
+		t.Fatalf("Decompressed output != input")
+	}
+}
+
+func TestRotatingWriterAge(t *testing.T) {
+	var outputs []*closingBuffer
+	newOutput := func() (io.WriteCloser, error) {
+		outputs = append(outputs, &closingBuffer{})
+		return outputs[len(outputs)-1], nil
+	}
+	w := NewRotatingWriter(newOutput, 0, 10*time.Millisecond)
+	_, err := w.Write(plaintext0)
+	failOnError(t, "Failed writing", err)
+	_, err = w.Write(plaintext0)
+	failOnError(t, "Failed writing", err)
+	time.Sleep(20 * time.Millisecond)
+	_, err = w.Write(plaintext0)
+	failOnError(t, "Failed writing", err)
+	failOnError(t, "Failed closing", w.Close())
+	failOnError(t, "Failed closing twice", w.Close())
+
+	if len(outputs) != 2 || !outputs[0].closed || !outputs[1].closed {
+		t.Fatalf("got %d streams", len(outputs))
+	}
+}
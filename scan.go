@@ -0,0 +1,69 @@
+package lz4
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// ScanBlocks is a bufio.SplitFunc that splits a block stream, as written by
+// Writer or CompressReader, into records without decompressing them. Each
+// token is a complete record: a compressed block or a control record, with its
+// 4-byte header. Concatenating the tokens gives back the stream. Use
+// IsControlRecord to tell the records apart. Streams written with
+// WithHeaderWidth and another width than the default are not supported.
+//
+// Records can be larger than the default maximum token size of a
+// bufio.Scanner; NewBlockScanner returns a Scanner with a large enough buffer.
+func ScanBlocks(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if len(data) < blockHeaderSize {
+		if atEOF && len(data) > 0 {
+			return 0, nil, io.ErrUnexpectedEOF
+		}
+		return 0, nil, nil
+	}
+	header := binary.LittleEndian.Uint32(data)
+	size := int(header)
+	typ := 0
+	if header&controlFlag != 0 {
+		typ, size = parseControlHeader(header)
+	} else if header > boundedHugeStreamingBlockSize {
+		return 0, nil, fmt.Errorf("invalid block size %d", header)
+	}
+	if len(data) < blockHeaderSize+size {
+		if atEOF {
+			return 0, nil, io.ErrUnexpectedEOF
+		}
+		return 0, nil, nil
+	}
+	if typ == recordHeaderWidth {
+		if size != headerWidthPayload {
+			return 0, nil, errBadHeaderWidth
+		}
+		if data[blockHeaderSize] != blockHeaderSize {
+			// the records that follow have headers of another width
+			return 0, nil, errDefaultWidthOnly
+		}
+	}
+	return blockHeaderSize + size, data[:blockHeaderSize+size], nil
+}
+
+// maxRecordSize is the size of the largest record in a block stream.
+const maxRecordSize = blockHeaderSize + controlLengthMask
+
+// NewBlockScanner returns a bufio.Scanner splitting the block stream read from
+// r with ScanBlocks.
+func NewBlockScanner(r io.Reader) *bufio.Scanner {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, blockHeaderSize+boundedStreamingBlockSize), maxRecordSize)
+	s.Split(ScanBlocks)
+	return s
+}
+
+// IsControlRecord reports whether record, a token returned by ScanBlocks,
+// is a control record, such as a sync marker or block metadata, rather than a
+// compressed block.
+func IsControlRecord(record []byte) bool {
+	return len(record) >= blockHeaderSize && binary.LittleEndian.Uint32(record)&controlFlag != 0
+}
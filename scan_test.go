@@ -0,0 +1,88 @@
+package lz4
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestScanBlocks(t *testing.T) {
+	input, err := ioutil.ReadFile(sampleFilePath)
+	failOnError(t, "Failed reading sample", err)
+
+	var compressed bytes.Buffer
+	w := NewWriter(&compressed, WithSyncInterval(streamingBlockSize))
+	_, err = w.Write(input)
+	failOnError(t, "Failed writing to compress object", err)
+	failOnError(t, "Failed closing writer", w.Close())
+
+	s := NewBlockScanner(bytes.NewReader(compressed.Bytes()))
+	var joined []byte
+	var blocks, controls int
+	for s.Scan() {
+		if IsControlRecord(s.Bytes()) {
+			controls++
+		} else {
+			blocks++
+		}
+		joined = append(joined, s.Bytes()...)
+	}
+	failOnError(t, "Failed scanning", s.Err())
+	if !bytes.Equal(joined, compressed.Bytes()) {
+		t.Fatalf("joined tokens != stream")
+	}
+	if want := (len(input) + streamingBlockSize - 1) / streamingBlockSize; blocks != want || controls != want-1 {
+		t.Fatalf("got %d blocks and %d control records, want %d and %d", blocks, controls, want, want-1)
+	}
+
+	s = NewBlockScanner(bytes.NewReader(compressed.Bytes()[:compressed.Len()-1]))
+	for s.Scan() {
+	}
+	if s.Err() != io.ErrUnexpectedEOF {
+		t.Fatalf("expected io.ErrUnexpectedEOF, got %v", s.Err())
+	}
+}
+
+func ExampleNewBlockScanner() {
+	var compressed bytes.Buffer
+	w := NewWriter(&compressed, WithSyncInterval(1))
+	w.WriteBlock([]byte(strings.Repeat("first message ", 10)))
+	w.WriteBlock([]byte("second message"))
+	w.Close()
+
+	s := NewBlockScanner(&compressed)
+	for s.Scan() {
+		if IsControlRecord(s.Bytes()) {
+			fmt.Println("control record")
+		} else {
+			fmt.Println("block")
+		}
+	}
+	if err := s.Err(); err != nil {
+		fmt.Println(err)
+	}
+	// Output:
+	// block
+	// control record
+	// block
+}
+
+func TestScanBlocksHeaderWidth(t *testing.T) {
+	for _, width := range []int{HeaderWidth3, HeaderWidth8} {
+		var buf bytes.Buffer
+		w := NewWriter(&buf, WithHeaderWidth(width))
+		_, err := w.Write(plaintext0)
+		failOnError(t, "Failed writing", err)
+		failOnError(t, "Failed closing", w.Close())
+
+		s := NewBlockScanner(&buf)
+		for s.Scan() {
+		}
+		if s.Err() != errDefaultWidthOnly {
+			t.Errorf("width %d: got %v, want errDefaultWidthOnly", width, s.Err())
+		}
+	}
+}
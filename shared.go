@@ -0,0 +1,103 @@
+package lz4
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"sync"
+)
+
+const (
+	// recordHeaderSize is the size of the little endian length preceding
+	// each record in the uncompressed data written by SharedWriter.
+	recordHeaderSize = 4
+	// recordChunkSize is the size of the reads of a RecordReader, which
+	// grows a record as its data arrives rather than trusting its length.
+	recordChunkSize = 1 << 20
+)
+
+// SharedWriter is a Writer safe for concurrent use by many producers writing
+// records. Each record is written with a 4-byte little endian length prefix
+// and compressed as a unit under an internal lock, so records from different
+// goroutines never interleave. Records can be read back with RecordReader.
+type SharedWriter struct {
+	mu  sync.Mutex
+	w   *Writer
+	buf []byte
+}
+
+// NewSharedWriter creates a new SharedWriter writing a compressed stream to w.
+// It is the caller's responsibility to call Close when done.
+func NewSharedWriter(w io.Writer, opts ...Option) *SharedWriter {
+	return &SharedWriter{w: NewWriter(w, opts...)}
+}
+
+// WriteRecord compresses p as a single record.
+func (s *SharedWriter) WriteRecord(p []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// write the prefix and the record in one call, so they are compressed
+	// into the same blocks
+	s.buf = append(s.buf[:0], 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(s.buf, uint32(len(p)))
+	s.buf = append(s.buf, p...)
+	_, err := s.w.Write(s.buf)
+	return err
+}
+
+// Write compresses p as a single record. It allows a SharedWriter to be used
+// as an io.Writer where each call is a record, as with log.Logger.
+func (s *SharedWriter) Write(p []byte) (int, error) {
+	if err := s.WriteRecord(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close releases the resources of the underlying Writer.
+func (s *SharedWriter) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Close()
+}
+
+// RecordReader reads the records written by a SharedWriter.
+type RecordReader struct {
+	r  io.ReadCloser
+	br *bufio.Reader
+}
+
+// NewRecordReader creates a new RecordReader decompressing the stream from r.
+// It is the caller's responsibility to call Close when done.
+func NewRecordReader(r io.Reader, opts ...Option) *RecordReader {
+	dr := NewDecompressReader(r, opts...)
+	return &RecordReader{r: dr, br: bufio.NewReader(dr)}
+}
+
+// ReadRecord returns the next record. It returns io.EOF when there are no more
+// records, and io.ErrUnexpectedEOF if the stream ends within a record.
+func (r *RecordReader) ReadRecord() ([]byte, error) {
+	var header [recordHeaderSize]byte
+	if _, err := io.ReadFull(r.br, header[:]); err != nil {
+		return nil, err
+	}
+	size := int(binary.LittleEndian.Uint32(header[:]))
+	record := make([]byte, 0, min(size, recordChunkSize))
+	for len(record) < size {
+		n := min(size-len(record), recordChunkSize)
+		record = append(record, make([]byte, n)...)
+		if _, err := io.ReadFull(r.br, record[len(record)-n:]); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+	}
+	return record, nil
+}
+
+// Close releases the resources of the underlying reader.
+func (r *RecordReader) Close() error {
+	return r.r.Close()
+}
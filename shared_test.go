@@ -0,0 +1,96 @@
+package lz4
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSharedWriter(t *testing.T) {
+	var compressed bytes.Buffer
+	w := NewSharedWriter(&compressed)
+
+	const producers = 8
+	const records = 200
+	var wg sync.WaitGroup
+	for i := 0; i < producers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < records; j++ {
+				// some records span several blocks
+				record := strings.Repeat(fmt.Sprintf("producer %d record %d;", i, j), j*50+1)
+				if err := w.WriteRecord([]byte(record)); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	failOnError(t, "Failed closing writer", w.Close())
+
+	r := NewRecordReader(&compressed)
+	defer r.Close()
+	next := make([]int, producers)
+	for n := 0; n < producers*records; n++ {
+		record, err := r.ReadRecord()
+		failOnError(t, "Failed reading record", err)
+		var i, j int
+		_, err = fmt.Sscanf(string(record), "producer %d record %d;", &i, &j)
+		failOnError(t, "Failed parsing record", err)
+		if j != next[i] {
+			t.Fatalf("producer %d: got record %d, expected %d", i, j, next[i])
+		}
+		if expected := strings.Repeat(fmt.Sprintf("producer %d record %d;", i, j), j*50+1); string(record) != expected {
+			t.Fatalf("producer %d record %d is corrupted", i, j)
+		}
+		next[i]++
+	}
+	if _, err := r.ReadRecord(); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+}
+
+func TestSharedWriterEmptyRecord(t *testing.T) {
+	var compressed bytes.Buffer
+	w := NewSharedWriter(&compressed)
+	failOnError(t, "Failed writing record", w.WriteRecord(nil))
+	_, err := w.Write([]byte("after"))
+	failOnError(t, "Failed writing record", err)
+	failOnError(t, "Failed closing writer", w.Close())
+
+	r := NewRecordReader(&compressed)
+	defer r.Close()
+	record, err := r.ReadRecord()
+	failOnError(t, "Failed reading record", err)
+	if len(record) != 0 {
+		t.Fatalf("expected empty record, got %q", record)
+	}
+	record, err = r.ReadRecord()
+	failOnError(t, "Failed reading record", err)
+	if string(record) != "after" {
+		t.Fatalf("unexpected record %q", record)
+	}
+}
+
+func TestRecordReaderHugeLength(t *testing.T) {
+	// a record claiming 4 GiB in a short stream
+	compressed, err := CompressBytesToStream([]byte{0xff, 0xff, 0xff, 0xff, 'x'})
+	failOnError(t, "Failed compressing", err)
+	r := NewRecordReader(bytes.NewReader(compressed))
+	defer r.Close()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if _, err := r.ReadRecord(); err != io.ErrUnexpectedEOF {
+		t.Fatalf("expected io.ErrUnexpectedEOF, got %v", err)
+	}
+	runtime.ReadMemStats(&after)
+	if n := after.TotalAlloc - before.TotalAlloc; n > 4*recordChunkSize {
+		t.Errorf("allocated %d bytes for a truncated record", n)
+	}
+}
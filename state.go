@@ -0,0 +1,90 @@
+package lz4
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+var stateMagic = [4]byte{'L', 'Z', '4', 'S'}
+
+const stateVersion = 1
+
+var (
+	errBadState     = errors.New("malformed writer state")
+	errStateTrailer = errors.New("a writer resumed from a state cannot write a trailer")
+)
+
+// MarshalState returns a snapshot of the stream state of w: the history used
+// to compress the next block and the byte counters. It can be passed to
+// NewWriterFromState to continue the same stream later, possibly in another
+// process. The compressed data written before the snapshot, whose size is
+// given by CompressedBytesWritten, must be kept exactly as written, and
+// anything written after it discarded.
+func (w *Writer) MarshalState() ([]byte, error) {
+	if w.lz4Stream == nil {
+		return nil, errors.New("writer is closed")
+	}
+	if err := w.flushBytes(); err != nil {
+		return nil, err
+	}
+	window := w.lastBlock
+	if len(window) > streamingBlockSize {
+		// lz4 never references data further back
+		window = window[len(window)-streamingBlockSize:]
+	}
+
+	var tmp [binary.MaxVarintLen64]byte
+	state := make([]byte, 0, len(stateMagic)+1+3*binary.MaxVarintLen64+len(window))
+	putUvarint := func(v uint64) {
+		state = append(state, tmp[:binary.PutUvarint(tmp[:], v)]...)
+	}
+
+	state = append(state, stateMagic[:]...)
+	state = append(state, stateVersion)
+	putUvarint(uint64(w.uncompressedWritten))
+	putUvarint(uint64(w.compressedWritten))
+	putUvarint(uint64(len(window)))
+	state = append(state, window...)
+	return state, nil
+}
+
+// NewWriterFromState creates a new Writer that continues the stream described
+// by state, as returned by MarshalState. Data written to the new Writer must be
+// appended to the compressed data covered by the snapshot. WithTrailer,
+// including through SetDefaultOptions, is an error.
+func NewWriterFromState(w io.Writer, state []byte, opts ...Option) (*Writer, error) {
+	if len(state) < len(stateMagic)+1 ||
+		string(state[:len(stateMagic)]) != string(stateMagic[:]) ||
+		state[len(stateMagic)] != stateVersion {
+		return nil, errBadState
+	}
+	state = state[len(stateMagic)+1:]
+
+	var fields [3]uint64
+	for i := range fields {
+		v, n := binary.Uvarint(state)
+		if n <= 0 {
+			return nil, errBadState
+		}
+		fields[i] = v
+		state = state[n:]
+	}
+	if fields[2] > streamingBlockSize || fields[2] != uint64(len(state)) {
+		return nil, errBadState
+	}
+
+	o := newOptions(opts)
+	if o.trailer {
+		// the trailer would only cover the data written after the snapshot
+		return nil, errStateTrailer
+	}
+	wr := newWriter(w, o)
+	wr.loadDict(state)
+	wr.uncompressedWritten = int64(fields[0])
+	wr.compressedWritten = int64(fields[1])
+	// the preamble was written with the start of the stream
+	wr.preamblePending = wr.preamblePending && wr.compressedWritten == 0
+	wr.lastSync = wr.uncompressedWritten
+	return wr, nil
+}
@@ -0,0 +1,72 @@
+package lz4
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestWriterStateResume(t *testing.T) {
+	input, err := ioutil.ReadFile(sampleFilePath)
+	failOnError(t, "Failed reading sample", err)
+	for len(input) < 3*streamingBlockSize {
+		input = append(input, input...)
+	}
+	split := len(input)/2 + 123
+
+	var compressed bytes.Buffer
+	w := NewWriter(&compressed)
+	_, err = w.Write(input[:split])
+	failOnError(t, "Failed writing to compress object", err)
+	state, err := w.MarshalState()
+	failOnError(t, "Failed marshaling state", err)
+	snapshotLen := compressed.Len()
+	if w.CompressedBytesWritten() != int64(snapshotLen) {
+		t.Fatalf("CompressedBytesWritten = %d, expected %d", w.CompressedBytesWritten(), snapshotLen)
+	}
+
+	// anything written after the snapshot is lost
+	_, err = w.Write([]byte("lost in the crash"))
+	failOnError(t, "Failed writing to compress object", err)
+	failOnError(t, "Failed closing writer", w.Close())
+	compressed.Truncate(snapshotLen)
+
+	w, err = NewWriterFromState(&compressed, state)
+	failOnError(t, "Failed restoring state", err)
+	if w.uncompressedWritten != int64(split) || w.CompressedBytesWritten() != int64(snapshotLen) {
+		t.Fatalf("wrong counters after restore: %d, %d", w.uncompressedWritten, w.CompressedBytesWritten())
+	}
+	_, err = w.Write(input[split:])
+	failOnError(t, "Failed writing to compress object", err)
+	failOnError(t, "Failed closing writer", w.Close())
+
+	r := NewDecompressReader(&compressed)
+	out, err := ioutil.ReadAll(r)
+	failOnError(t, "Failed decompressing", err)
+	failOnError(t, "Failed closing reader", r.Close())
+	if !bytes.Equal(out, input) {
+		t.Fatalf("Decompressed output != input (lengths: %d, %d)", len(out), len(input))
+	}
+}
+
+func TestWriterStateBadData(t *testing.T) {
+	_, err := NewWriterFromState(ioutil.Discard, []byte("LZ4S\x01\x00\x00\x05abc"))
+	if err != errBadState {
+		t.Fatalf("expected errBadState, got %v", err)
+	}
+}
+
+func TestWriterStateTrailer(t *testing.T) {
+	w := NewWriter(ioutil.Discard)
+	state, err := w.MarshalState()
+	failOnError(t, "Failed marshaling state", err)
+	failOnError(t, "Failed closing", w.Close())
+	if _, err := NewWriterFromState(ioutil.Discard, state, WithTrailer()); err != errStateTrailer {
+		t.Fatalf("expected errStateTrailer, got %v", err)
+	}
+	SetDefaultOptions(WithTrailer())
+	defer SetDefaultOptions()
+	if _, err := NewWriterFromState(ioutil.Discard, state); err != errStateTrailer {
+		t.Fatalf("expected errStateTrailer with the default options, got %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package lz4
+
+// #cgo pkg-config: liblz4
+// #include <lz4frame.h>
+import "C"
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"unsafe"
+)
+
+// StoreFrom writes the next n bytes of r to the frame without compressing
+// them, for data known to be incompressible, such as media files in an
+// archive. It returns the number of bytes of r written.
+//
+// If the frame has independent blocks and no checksums, as with
+// WithIndependentBlocks and WithContentChecksum(false), the data does not
+// have to be read by w, so it is written as blocks stored uncompressed, each
+// copied from r with the ReadFrom method of the underlying writer if it has
+// one. When both are files or network connections, as with *os.File and
+// *net.TCPConn, the data is then copied by the kernel, with sendfile or
+// copy_file_range, without passing through user space. Since each block
+// header announces its size before its data is copied, r must hold n bytes:
+// a shorter r leaves the frame corrupt. Otherwise, StoreFrom is equivalent to
+// copying n bytes from r to w, which stores the incompressible blocks
+// uncompressed anyway.
+func (w *FrameWriter) StoreFrom(r io.Reader, n int64) (int64, error) {
+	if w.ctx == nil {
+		return 0, errors.New("writer is closed")
+	}
+	fi := &w.prefs.frameInfo
+	if w.enc != nil || fi.blockMode != C.LZ4F_blockIndependent ||
+		fi.contentChecksumFlag != C.LZ4F_noContentChecksum || fi.blockChecksumFlag != C.LZ4F_noBlockChecksum {
+		return io.CopyN(w, r, n)
+	}
+	if err := w.begin(); err != nil {
+		return 0, err
+	}
+	// the data buffered by liblz4 goes first
+	flushed := C.LZ4F_flush(w.ctx, unsafe.Pointer(&w.buf[0]), C.size_t(len(w.buf)), nil)
+	if err := frameError("LZ4F_flush", flushed); err != nil {
+		return 0, err
+	}
+	if flushed > 0 {
+		if _, err := w.underlyingWriter.Write(w.buf[:flushed]); err != nil {
+			return 0, err
+		}
+	}
+	blockSize := int64(frameBlockSize(fi.blockSizeID))
+	var header [blockHeaderSize]byte
+	var written int64
+	for written < n {
+		size := n - written
+		if size > blockSize {
+			size = blockSize
+		}
+		binary.LittleEndian.PutUint32(header[:], uint32(size)|frameUncompressedBit)
+		if _, err := w.underlyingWriter.Write(header[:]); err != nil {
+			return written, err
+		}
+		m, err := io.CopyN(w.underlyingWriter, r, size)
+		written += m
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
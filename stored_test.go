@@ -0,0 +1,74 @@
+package lz4
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestStoreFrom(t *testing.T) {
+	dir := t.TempDir()
+	media := make([]byte, 300<<10+17)
+	rand.New(rand.NewSource(1)).Read(media)
+	mediaPath := filepath.Join(dir, "media")
+	failOnError(t, "Failed writing media", os.WriteFile(mediaPath, media, 0o644))
+	text := bytes.Repeat([]byte("compressible text "), 5000)
+	var want []byte
+	want = append(want, text...)
+	want = append(want, media...)
+	want = append(want, text...)
+
+	for _, tc := range []struct {
+		name string
+		opts []Option
+	}{
+		{"stored blocks", []Option{WithIndependentBlocks(), WithContentChecksum(false)}},
+		{"copy", nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			outPath := filepath.Join(dir, "out.lz4")
+			out, err := os.Create(outPath)
+			failOnError(t, "Failed creating output", err)
+			in, err := os.Open(mediaPath)
+			failOnError(t, "Failed opening media", err)
+			defer in.Close()
+
+			w := NewFrameWriter(out, append(tc.opts, WithOwnsUnderlying())...)
+			_, err = w.Write(text)
+			failOnError(t, "Failed writing", err)
+			n, err := w.StoreFrom(in, int64(len(media)))
+			failOnError(t, "Failed storing", err)
+			if n != int64(len(media)) {
+				t.Fatalf("stored %d bytes of %d", n, len(media))
+			}
+			_, err = w.Write(text)
+			failOnError(t, "Failed writing", err)
+			failOnError(t, "Failed closing", w.Close())
+
+			compressed, err := os.ReadFile(outPath)
+			failOnError(t, "Failed reading output", err)
+			got, err := io.ReadAll(NewFrameReader(bytes.NewReader(compressed)))
+			failOnError(t, "Failed decompressing", err)
+			if !bytes.Equal(got, want) {
+				t.Fatal("decompressed output != input")
+			}
+			if tc.opts != nil && !bytes.Contains(compressed, media[:64<<10]) {
+				t.Error("media not stored uncompressed")
+			}
+
+			lz4, err := exec.LookPath("lz4")
+			if err != nil {
+				return
+			}
+			got, err = exec.Command(lz4, "-d", "-c", outPath).Output()
+			failOnError(t, "lz4 failed decompressing", err)
+			if !bytes.Equal(got, want) {
+				t.Error("lz4 output != input")
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package lz4
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrBlockSizeMismatch is returned by a DecompressReader created with
+// WithStrictBlockSize for a block inconsistent with the block size of the
+// stream.
+var ErrBlockSizeMismatch = errors.New("block size mismatch")
+
+// WithStrictBlockSize makes a DecompressReader check that every block but the
+// last decodes to exactly size bytes, and the last one to at most size bytes,
+// returning an error matching ErrBlockSizeMismatch otherwise, to catch
+// corruption that decodes to valid but truncated data. A size of 0 uses the
+// size of the first block. It suits streams written with full blocks, such as
+// those of CompressReader, whose blocks are MaxBlockSize bytes, or of a Writer
+// whose writes are all multiples of its block size; other streams may
+// legitimately have shorter blocks. Since a block can only be known to be the
+// last one once the stream ends, a short block is detected as such when the
+// next one is read.
+func WithStrictBlockSize(size int) Option {
+	return func(o *options) {
+		o.strictBlockSize = size
+		o.strict = true
+	}
+}
+
+// checkBlockSize checks the size n of the block just decoded in strict mode.
+func (r *DecompressReader) checkBlockSize(n int) error {
+	if !r.opts.strict {
+		return nil
+	}
+	if r.strictSize == 0 {
+		r.strictSize = n
+	}
+	switch {
+	case r.shortBlockSeen:
+		return &corruptionError{fmt.Errorf("%w: block follows a block shorter than %d bytes", ErrBlockSizeMismatch, r.strictSize)}
+	case n > r.strictSize:
+		return &corruptionError{fmt.Errorf("%w: block of %d bytes, larger than %d bytes", ErrBlockSizeMismatch, n, r.strictSize)}
+	case n < r.strictSize:
+		r.shortBlockSeen = true
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package lz4
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"testing"
+)
+
+func TestStrictBlockSize(t *testing.T) {
+	input := bytes.Repeat([]byte("strict block size "), 20000)
+	write := func(sizes ...int) []byte {
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		rest := input
+		for _, size := range sizes {
+			_, err := w.Write(rest[:size])
+			failOnError(t, "Failed writing", err)
+			rest = rest[size:]
+		}
+		failOnError(t, "Failed closing", w.Close())
+		return buf.Bytes()
+	}
+	full := write(len(input))
+	cr, err := ioutil.ReadAll(NewCompressReader(bytes.NewReader(input)))
+	failOnError(t, "Failed compressing", err)
+
+	for _, tc := range []struct {
+		name   string
+		stream []byte
+		opts   []Option
+	}{
+		{"full blocks", full, []Option{WithStrictBlockSize(0)}},
+		{"explicit size", full, []Option{WithStrictBlockSize(streamingBlockSize)}},
+		{"CompressReader", cr, []Option{WithStrictBlockSize(MaxBlockSize)}},
+	} {
+		out, err := ioutil.ReadAll(NewDecompressReader(bytes.NewReader(tc.stream), tc.opts...))
+		failOnError(t, tc.name, err)
+		if !bytes.Equal(out, input) {
+			t.Fatalf("%s: decompressed output != input", tc.name)
+		}
+	}
+
+	for _, tc := range []struct {
+		name   string
+		stream []byte
+		size   int
+	}{
+		{"short block in the middle", write(1000, len(input)-1000), 0},
+		{"block larger than the size", full, 1000},
+	} {
+		// without the option, the stream decodes fine
+		_, err := ioutil.ReadAll(NewDecompressReader(bytes.NewReader(tc.stream)))
+		failOnError(t, tc.name, err)
+		_, err = ioutil.ReadAll(NewDecompressReader(bytes.NewReader(tc.stream), WithStrictBlockSize(tc.size)))
+		if !errors.Is(err, ErrBlockSizeMismatch) {
+			t.Errorf("%s: got %v, want ErrBlockSizeMismatch", tc.name, err)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package lz4
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf8"
+	"unsafe"
+)
+
+// stringBytes returns the bytes of s without copying them. They must not be
+// modified.
+func stringBytes(s string) []byte {
+	if len(s) == 0 {
+		return nil
+	}
+	// the data pointer is the first word of a string
+	return unsafe.Slice(*(**byte)(unsafe.Pointer(&s)), len(s))
+}
+
+// WriteString is like Write, but takes a string, which it compresses without
+// converting it to a byte slice first. It makes Writer an io.StringWriter, as
+// used by io.WriteString and fmt.Fprint.
+func (w *Writer) WriteString(s string) (int, error) {
+	return w.write(stringBytes(s), false)
+}
+
+// ReadString reads until the first occurrence of delim in the decompressed
+// data, and returns a string holding the data up to and including delim, as
+// bufio.Reader.ReadString does, but without buffering the data again. If it
+// meets an error before finding delim, it returns the data read before the
+// error and the error itself, often io.EOF.
+func (r *DecompressReader) ReadString(delim byte) (string, error) {
+	return r.out.readString(delim)
+}
+
+// readString implements ReadString on the output of e.
+func (e *blockEngine) readString(delim byte) (string, error) {
+	var b strings.Builder
+	for {
+		if i := bytes.IndexByte(e.pending, delim); i >= 0 {
+			line := e.pending[:i+1]
+			e.pending = e.pending[i+1:]
+			if b.Len() == 0 {
+				return string(line), nil
+			}
+			b.Write(line)
+			return b.String(), nil
+		}
+		b.Write(e.pending)
+		e.pending = nil
+		block, err := e.nextBlock()
+		if err != nil {
+			return b.String(), err
+		}
+		e.pending = block
+	}
+}
+
+// ReadRune reads and returns the next UTF-8 encoded character of the
+// decompressed data and its size in bytes, making DecompressReader an
+// io.RuneReader, as used by regexp.MatchReader and text scanners. Invalid
+// encodings are returned as utf8.RuneError of size 1, as by
+// bufio.Reader.ReadRune.
+func (r *DecompressReader) ReadRune() (rune, int, error) {
+	return r.out.readRune()
+}
+
+// readRune implements ReadRune on the output of e.
+func (e *blockEngine) readRune() (rune, int, error) {
+	if utf8.FullRune(e.pending) {
+		c, size := utf8.DecodeRune(e.pending)
+		e.pending = e.pending[size:]
+		return c, size, nil
+	}
+	// the character continues in the next blocks
+	var buf [utf8.UTFMax]byte
+	n := copy(buf[:], e.pending)
+	var rest []byte
+	e.pending = nil
+	for !utf8.FullRune(buf[:n]) {
+		block, err := e.nextBlock()
+		if err != nil {
+			if n == 0 {
+				return 0, 0, err
+			}
+			// the error is met again by the next call
+			break
+		}
+		m := copy(buf[n:], block)
+		n += m
+		rest = block[m:]
+	}
+	c, size := utf8.DecodeRune(buf[:n])
+	e.pending = rest
+	if size < n {
+		// bytes of an invalid encoding are read again
+		e.pending = append(append([]byte(nil), buf[size:n]...), rest...)
+	}
+	return c, size, nil
+}
@@ -0,0 +1,99 @@
+package lz4
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+)
+
+func TestStrings(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, WithBlockSize(1000))
+	var want []string
+	for i := 0; i < 2000; i++ {
+		line := fmt.Sprintf("%d: log line\n", i)
+		want = append(want, line)
+		n, err := w.WriteString(line)
+		failOnError(t, "Failed writing", err)
+		if n != len(line) {
+			t.Fatalf("wrote %d bytes of %d", n, len(line))
+		}
+	}
+	_, err := io.WriteString(w, "no newline")
+	failOnError(t, "Failed writing", err)
+	want = append(want, "no newline")
+	failOnError(t, "Failed closing", w.Close())
+
+	r := NewDecompressReader(&buf).(*DecompressReader)
+	defer r.Close()
+	for i, line := range want {
+		got, err := r.ReadString('\n')
+		if i == len(want)-1 {
+			if err != io.EOF {
+				t.Errorf("got error %v at the end", err)
+			}
+		} else {
+			failOnError(t, "Failed reading", err)
+		}
+		if got != line {
+			t.Fatalf("line %d: got %q, want %q", i, got, line)
+		}
+	}
+	if got, err := r.ReadString('\n'); got != "" || err != io.EOF {
+		t.Errorf("got %q, %v after the end", got, err)
+	}
+}
+
+func TestWriteStringAllocations(t *testing.T) {
+	w := NewWriter(io.Discard)
+	defer w.Close()
+	b := bytes.Repeat([]byte("no copy "), 1000)
+	s := string(b)
+	// as many as Write, which does not convert its argument
+	want := testing.AllocsPerRun(100, func() { w.Write(b) })
+	if allocs := testing.AllocsPerRun(100, func() { w.WriteString(s) }); allocs > want {
+		t.Errorf("WriteString made %.0f allocations, Write %.0f", allocs, want)
+	}
+}
+
+func TestReadRune(t *testing.T) {
+	text := "héllo, 世界! \xff🙂 ascii"
+	var buf bytes.Buffer
+	// blocks of 1 byte split every multi-byte character
+	w := NewWriter(&buf, WithBlockSize(1))
+	_, err := w.WriteString(text)
+	failOnError(t, "Failed writing", err)
+	_, err = w.Write([]byte("\xe4\xb8"))
+	failOnError(t, "Failed writing", err)
+	failOnError(t, "Failed closing", w.Close())
+	want := []rune(text + "\xe4\xb8")
+
+	for _, blockSize := range []int{1, 1000} {
+		stream := buf.Bytes()
+		if blockSize > 1 {
+			var whole bytes.Buffer
+			w := NewWriter(&whole, WithBlockSize(blockSize))
+			_, err := w.WriteString(text + "\xe4\xb8")
+			failOnError(t, "Failed writing", err)
+			failOnError(t, "Failed closing", w.Close())
+			stream = whole.Bytes()
+		}
+		r := NewDecompressReader(bytes.NewReader(stream)).(*DecompressReader)
+		var got []rune
+		total := 0
+		for {
+			c, size, err := r.ReadRune()
+			if err == io.EOF {
+				break
+			}
+			failOnError(t, "Failed reading rune", err)
+			got = append(got, c)
+			total += size
+		}
+		r.Close()
+		if string(got) != string(want) || total != len(text)+2 {
+			t.Errorf("block size %d: got %q in %d bytes, want %q", blockSize, string(got), total, string(want))
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package lz4
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// trailerPayloadSize is the size of the payload of a trailer record: the
+// number of uncompressed bytes it covers and their XXH64, both as little
+// endian uint64.
+const trailerPayloadSize = 16
+
+var (
+	// ErrTrailerMismatch is returned by a reader created with WithTrailer
+	// when the data read does not match the trailer of the stream.
+	ErrTrailerMismatch = errors.New("data does not match the stream trailer")
+	// ErrMissingTrailer is returned by a reader created with WithTrailer
+	// when the stream does not end with a trailer.
+	ErrMissingTrailer = errors.New("stream does not end with a trailer")
+
+	errBadTrailer = errors.New("malformed trailer")
+)
+
+// trailerState is the running checksum of the data covered by the next
+// trailer.
+type trailerState struct {
+	hash   Checksummer
+	length int64
+	// seen is set when the last record read was a trailer
+	seen bool
+}
+
+func newTrailerState(hash Checksummer) *trailerState {
+	return &trailerState{hash: hash}
+}
+
+func (t *trailerState) update(p []byte) {
+	t.hash.Write(p)
+	t.length += int64(len(p))
+	t.seen = false
+}
+
+// appendRecord appends a trailer record for the data so far to b, and starts
+// a new segment.
+func (t *trailerState) appendRecord(b []byte) []byte {
+	var record [blockHeaderSize + trailerPayloadSize]byte
+	binary.LittleEndian.PutUint32(record[:], controlHeader(recordTrailer, trailerPayloadSize))
+	binary.LittleEndian.PutUint64(record[blockHeaderSize:], uint64(t.length))
+	binary.LittleEndian.PutUint64(record[blockHeaderSize+8:], t.hash.Sum64())
+	t.reset()
+	return append(b, record[:]...)
+}
+
+// check verifies a trailer payload against the data so far, and starts a new
+// segment.
+func (t *trailerState) check(payload []byte) error {
+	if len(payload) != trailerPayloadSize {
+		return errBadTrailer
+	}
+	length := binary.LittleEndian.Uint64(payload)
+	sum := binary.LittleEndian.Uint64(payload[8:])
+	ok := length == uint64(t.length) && sum == t.hash.Sum64()
+	t.reset()
+	t.seen = true
+	if !ok {
+		return ErrTrailerMismatch
+	}
+	return nil
+}
+
+func (t *trailerState) reset() {
+	t.hash.Reset()
+	t.length = 0
+}
+
+// writeTrailer writes the trailer record of w, if enabled.
+func (w *Writer) writeTrailer() error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.trailer == nil {
+		return nil
+	}
+	w.recordStart = w.compressedWritten
+	record := w.trailer.appendRecord(nil)
+	if err := w.writeRecord(record); err != nil {
+		return err
+	}
+	w.compressedWritten += int64(len(record))
+	return nil
+}
@@ -0,0 +1,81 @@
+package lz4
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func compressWithTrailer(t *testing.T, input []byte) []byte {
+	var compressed bytes.Buffer
+	w := NewWriter(&compressed, WithTrailer())
+	_, err := w.Write(input)
+	failOnError(t, "Failed writing to compress object", err)
+	failOnError(t, "Failed closing writer", w.Close())
+	if w.CompressedBytesWritten() != int64(compressed.Len()) {
+		t.Fatalf("CompressedBytesWritten = %d, want %d", w.CompressedBytesWritten(), compressed.Len())
+	}
+	return compressed.Bytes()
+}
+
+func TestTrailer(t *testing.T) {
+	input, err := ioutil.ReadFile(sampleFilePath)
+	failOnError(t, "Failed reading sample", err)
+	compressed := compressWithTrailer(t, input)
+
+	for _, opts := range [][]Option{nil, {WithTrailer()}} {
+		r := NewDecompressReader(bytes.NewReader(compressed), opts...)
+		out, err := ioutil.ReadAll(r)
+		failOnError(t, "Failed decompressing", err)
+		failOnError(t, "Failed closing reader", r.Close())
+		if !bytes.Equal(out, input) {
+			t.Fatalf("Decompressed output != input")
+		}
+	}
+
+	empty := compressWithTrailer(t, nil)
+	_, err = ioutil.ReadAll(NewDecompressReader(bytes.NewReader(empty), WithTrailer()))
+	failOnError(t, "Failed decompressing empty stream", err)
+}
+
+func TestTrailerMismatch(t *testing.T) {
+	compressed := compressWithTrailer(t, plaintext0)
+	// corrupt the checksum
+	compressed[len(compressed)-1] ^= 1
+	_, err := ioutil.ReadAll(NewDecompressReader(bytes.NewReader(compressed), WithTrailer()))
+	if err != ErrTrailerMismatch {
+		t.Fatalf("expected ErrTrailerMismatch, got %v", err)
+	}
+}
+
+func TestMissingTrailer(t *testing.T) {
+	compressed, err := CompressBytesToStream(plaintext0)
+	failOnError(t, "Failed compressing", err)
+	_, err = ioutil.ReadAll(NewDecompressReader(bytes.NewReader(compressed), WithTrailer()))
+	if err != ErrMissingTrailer {
+		t.Fatalf("expected ErrMissingTrailer, got %v", err)
+	}
+}
+
+func TestTrailerAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stream.lz4")
+	failOnError(t, "Failed writing file", ioutil.WriteFile(path, compressWithTrailer(t, plaintext0), 0o644))
+
+	w, err := OpenAppend(path, true, WithTrailer())
+	failOnError(t, "Failed opening for append", err)
+	_, err = w.Write([]byte("appended data"))
+	failOnError(t, "Failed writing to compress object", err)
+	failOnError(t, "Failed closing writer", w.Close())
+
+	f, err := os.Open(path)
+	failOnError(t, "Failed opening file", err)
+	r := NewDecompressReader(f, WithTrailer(), WithOwnsUnderlying())
+	out, err := ioutil.ReadAll(r)
+	failOnError(t, "Failed decompressing", err)
+	failOnError(t, "Failed closing reader", r.Close())
+	if !bytes.Equal(out, append(append([]byte(nil), plaintext0...), []byte("appended data")...)) {
+		t.Fatalf("Decompressed output != input")
+	}
+}
@@ -0,0 +1,96 @@
+package lz4
+
+// untrusted.go contains decoders for data from untrusted sources, which check
+// sizes against Limits before allocating. They never panic on any input, so
+// they can serve both as fuzzing targets and as production parsers.
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DefaultMaxDecodedSize is the maximum decoded size used when Limits.MaxSize
+// is 0.
+const DefaultMaxDecodedSize = 64 << 20
+
+// maxBlockRatio bounds the ratio of an lz4 block, since each byte of match
+// length encodes at most 255 bytes of output.
+const maxBlockRatio = 255
+
+// Limits bounds the resources used by DecodeUntrustedBlockHdr and
+// DecodeUntrustedStream.
+type Limits struct {
+	// MaxSize is the maximum decoded size, DefaultMaxDecodedSize if 0.
+	MaxSize int
+	// MaxRatio is the maximum ratio of the decoded size to the size of the
+	// input, or 0 for no limit beyond what lz4 can encode.
+	MaxRatio int
+}
+
+func (l Limits) maxSize(compressed int) int {
+	max := l.MaxSize
+	if max <= 0 {
+		max = DefaultMaxDecodedSize
+	}
+	if l.MaxRatio > 0 && compressed*l.MaxRatio < max {
+		max = compressed * l.MaxRatio
+	}
+	return max
+}
+
+// DecodeUntrustedBlockHdr decodes a block with a length header, as written by
+// CompressHdr, from an untrusted source. Unlike UncompressAllocHdr, it checks
+// the length header against the limits and against the size in can decode
+// to, before allocating the output, and fails unless the block decodes to
+// exactly the length of the header. It fails with ErrTooLarge if the length
+// header exceeds the limits.
+func DecodeUntrustedBlockHdr(in []byte, limits Limits) ([]byte, error) {
+	if len(in) < 4 {
+		return nil, errTooShort
+	}
+	size := int64(binary.LittleEndian.Uint32(in))
+	payload := in[4:]
+	if size > int64(limits.maxSize(len(payload))) {
+		return nil, fmt.Errorf("%w: %d bytes", ErrTooLarge, size)
+	}
+	if size > int64(len(payload))*maxBlockRatio || size > maxInputSize {
+		return nil, fmt.Errorf("length header of %d bytes too large for %d bytes of input", size, len(payload))
+	}
+	out := make([]byte, size)
+	n, err := Uncompress(out, payload)
+	if err != nil {
+		return nil, err
+	}
+	if n != len(out) {
+		return nil, fmt.Errorf("block decoded to %d bytes, length header says %d", n, size)
+	}
+	return out, nil
+}
+
+// DecodeUntrustedStream decodes in, a block stream as written by Writer or
+// CompressReader, from an untrusted source, within the limits, failing with
+// ErrTooLarge if they are exceeded. Unlike DecompressAll, it verifies the
+// block checksums and the trailer of the stream, if present, as Inspect does.
+// Decoding stops at the first block exceeding the limits.
+func DecodeUntrustedStream(in []byte, limits Limits) ([]byte, error) {
+	max := limits.maxSize(len(in))
+	r := newDecompressReader(bytes.NewReader(in), explicitOptions(WithBlockChecksum(), WithTrailer()), nil)
+	defer r.Close()
+	var out []byte
+	for {
+		block, err := r.ReadBlock()
+		if err == io.EOF || errors.Is(err, ErrMissingTrailer) {
+			return out, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(out)+len(block) > max {
+			return nil, fmt.Errorf("%w: more than %d bytes", ErrTooLarge, max)
+		}
+		out = append(out, block...)
+	}
+}
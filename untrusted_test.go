@@ -0,0 +1,84 @@
+package lz4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+func TestDecodeUntrustedBlockHdr(t *testing.T) {
+	input := bytes.Repeat([]byte("untrusted "), 1000)
+	block, err := CompressAllocHdr(input)
+	failOnError(t, "Failed compressing", err)
+	out, err := DecodeUntrustedBlockHdr(block, Limits{})
+	failOnError(t, "Failed decoding", err)
+	if !bytes.Equal(out, input) {
+		t.Fatal("decoded output != input")
+	}
+
+	if _, err := DecodeUntrustedBlockHdr(block, Limits{MaxSize: len(input) - 1}); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("got %v with a smaller MaxSize, want ErrTooLarge", err)
+	}
+	if _, err := DecodeUntrustedBlockHdr(block, Limits{MaxRatio: 1}); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("got %v with a MaxRatio of 1, want ErrTooLarge", err)
+	}
+	for _, size := range []uint32{uint32(len(input)) - 1, uint32(len(input)) + 1, 1 << 31} {
+		bad := append([]byte(nil), block...)
+		binary.LittleEndian.PutUint32(bad, size)
+		if _, err := DecodeUntrustedBlockHdr(bad, Limits{MaxSize: 1 << 31}); err == nil {
+			t.Errorf("no error for a length header of %d", size)
+		}
+	}
+	if _, err := DecodeUntrustedBlockHdr(block[:3], Limits{}); err == nil {
+		t.Error("no error for a short input")
+	}
+}
+
+func TestDecodeUntrustedStream(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, WithBlockChecksum(), WithTrailer(), WithBlockSize(1024))
+	_, err := w.Write(plaintext0)
+	failOnError(t, "Failed writing", err)
+	failOnError(t, "Failed closing", w.Close())
+	stream := buf.Bytes()
+
+	out, err := DecodeUntrustedStream(stream, Limits{})
+	failOnError(t, "Failed decoding", err)
+	if !bytes.Equal(out, plaintext0) {
+		t.Fatal("decoded output != input")
+	}
+	if _, err := DecodeUntrustedStream(stream, Limits{MaxSize: len(plaintext0) / 2}); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("got %v with a smaller MaxSize, want ErrTooLarge", err)
+	}
+	if _, err := DecodeUntrustedStream(stream[:len(stream)-3], Limits{}); err == nil {
+		t.Error("no error for a truncated stream")
+	}
+}
+
+// TestDecodeUntrustedCorrupt decodes random corruptions of valid input, which
+// must fail or succeed without panicking.
+func TestDecodeUntrustedCorrupt(t *testing.T) {
+	block, err := CompressAllocHdr(plaintext0)
+	failOnError(t, "Failed compressing", err)
+	var buf bytes.Buffer
+	w := NewWriter(&buf, WithBlockChecksum(), WithBlockSize(1024))
+	_, err = w.Write(plaintext0)
+	failOnError(t, "Failed writing", err)
+	failOnError(t, "Failed closing", w.Close())
+	stream := buf.Bytes()
+
+	rng := rand.New(rand.NewSource(1))
+	limits := Limits{MaxSize: 1 << 20}
+	for i := 0; i < 2000; i++ {
+		for _, valid := range [][]byte{block, stream} {
+			data := append([]byte(nil), valid[:rng.Intn(len(valid)+1)]...)
+			for j := rng.Intn(4); j >= 0 && len(data) > 0; j-- {
+				data[rng.Intn(len(data))] = byte(rng.Intn(256))
+			}
+			DecodeUntrustedBlockHdr(data, limits)
+			DecodeUntrustedStream(data, limits)
+		}
+	}
+}
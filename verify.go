@@ -0,0 +1,35 @@
+package lz4
+
+// #cgo pkg-config: liblz4
+// #include <lz4.h>
+import "C"
+
+import (
+	"bytes"
+	"errors"
+)
+
+// ErrVerifyMismatch is returned by a Writer created with WithVerify when a
+// compressed block does not decompress to its input.
+var ErrVerifyMismatch = errors.New("compressed block does not decompress to its input")
+
+// verifyBlock decompresses compressed, the block just compressed from src,
+// with the previous block as history, as a reader would, and checks that it
+// matches src.
+func (w *Writer) verifyBlock(src, compressed []byte) error {
+	if w.verifyBuf == nil {
+		w.verifyBuf = make([]byte, w.blockSize)
+	}
+	prev := w.lastBlock
+	n := int(C.LZ4_decompress_safe_usingDict(
+		p(compressed),
+		p(w.verifyBuf),
+		clen(compressed),
+		clen(w.verifyBuf),
+		p(prev),
+		clen(prev)))
+	if n < 0 || !bytes.Equal(w.verifyBuf[:n], src) {
+		return ErrVerifyMismatch
+	}
+	return nil
+}
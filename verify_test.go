@@ -0,0 +1,47 @@
+package lz4
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestWithVerify(t *testing.T) {
+	input, err := ioutil.ReadFile(sampleFilePath)
+	failOnError(t, "Failed reading sample", err)
+	for len(input) < 4*streamingBlockSize {
+		input = append(input, input...)
+	}
+
+	var compressed bytes.Buffer
+	w := NewWriter(&compressed, WithVerify(), WithSyncInterval(2*streamingBlockSize), WithAdaptiveLevel())
+	_, err = w.Write(input)
+	failOnError(t, "Failed writing to compress object", err)
+	failOnError(t, "Failed closing writer", w.Close())
+
+	r := NewDecompressReader(&compressed)
+	out, err := ioutil.ReadAll(r)
+	failOnError(t, "Failed decompressing", err)
+	failOnError(t, "Failed closing reader", r.Close())
+	if !bytes.Equal(out, input) {
+		t.Fatalf("Decompressed output != input")
+	}
+}
+
+func TestVerifyBlockMismatch(t *testing.T) {
+	w := NewWriter(ioutil.Discard, WithVerify())
+	defer w.Close()
+	src := w.nextInputBuffer()[:len(plaintext0)]
+	copy(src, plaintext0)
+	compressed := make([]byte, CompressBound(src))
+	n := w.compressBlock(src, compressed, 0)
+	if n <= 0 {
+		t.Fatalf("compression failed")
+	}
+	failOnError(t, "Failed verifying block", w.verifyBlock(src, compressed[:n]))
+
+	compressed[n-1] ^= 0xff
+	if err := w.verifyBlock(src, compressed[:n]); err != ErrVerifyMismatch {
+		t.Fatalf("expected ErrVerifyMismatch, got %v", err)
+	}
+}
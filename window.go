@@ -0,0 +1,29 @@
+package lz4
+
+import (
+	"fmt"
+	"io"
+)
+
+// DecompressBufferSize is the size of the buffer passed to
+// NewDecompressReaderBuffer: the 64 KiB history window, the space to decode a
+// block after it, and the space for a compressed block.
+const DecompressBufferSize = lowMemoryRingSize + boundedStreamingBlockSize
+
+// NewDecompressReaderBuffer is like NewDecompressReader with WithLowMemory, but
+// decodes into buf instead of allocating its own buffers, so the memory of many
+// concurrent streams can be allocated from an arena and accounted for
+// precisely. buf must be at least DecompressBufferSize bytes, or
+// DecompressBufferSizeFor the block size given with WithRingBuffer. Beyond
+// buf, the reader uses little memory. liblz4 keeps a pointer to buf between
+// calls, so buf should be memory outside of the Go heap, such as memory
+// allocated with mmap, and must not be used by the caller until the reader is
+// closed. Close does not release buf.
+func NewDecompressReaderBuffer(r io.Reader, buf []byte, opts ...Option) (*DecompressReader, error) {
+	o := newOptions(opts)
+	size := lowMemorySize(o)
+	if len(buf) < size {
+		return nil, fmt.Errorf("buffer too small: %d bytes, need %d", len(buf), size)
+	}
+	return newDecompressReader(r, o, buf[:size]), nil
+}
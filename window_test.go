@@ -0,0 +1,42 @@
+package lz4
+
+import (
+	"bytes"
+	"io/ioutil"
+	"syscall"
+	"testing"
+)
+
+func TestNewDecompressReaderBuffer(t *testing.T) {
+	input, err := ioutil.ReadFile(sampleFilePath)
+	failOnError(t, "Failed reading sample", err)
+	for len(input) < 4*streamingBlockSize {
+		input = append(input, input...)
+	}
+	var compressed bytes.Buffer
+	w := NewWriter(&compressed)
+	_, err = w.Write(input)
+	failOnError(t, "Failed writing", err)
+	failOnError(t, "Failed closing writer", w.Close())
+
+	// two readers sharing one arena
+	arena, err := syscall.Mmap(-1, 0, 2*DecompressBufferSize, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	if err != nil {
+		t.Skip("mmap not available:", err)
+	}
+	defer syscall.Munmap(arena)
+	for i := 0; i < 2; i++ {
+		r, err := NewDecompressReaderBuffer(bytes.NewReader(compressed.Bytes()), arena[i*DecompressBufferSize:])
+		failOnError(t, "Failed creating reader", err)
+		out, err := ioutil.ReadAll(r)
+		failOnError(t, "Failed decompressing", err)
+		failOnError(t, "Failed closing reader", r.Close())
+		if !bytes.Equal(out, input) {
+			t.Fatalf("Decompressed output != input")
+		}
+	}
+
+	if _, err := NewDecompressReaderBuffer(bytes.NewReader(nil), make([]byte, DecompressBufferSize-1)); err == nil {
+		t.Fatal("no error for a buffer too small")
+	}
+}
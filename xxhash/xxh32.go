@@ -0,0 +1,130 @@
+// Package xxhash implements the XXH32 and XXH64 hashes used by the lz4 frame
+// format, as in liblz4. liblz4 builds xxHash into the library without
+// exporting it under stable names, so this package implements the same
+// algorithms in Go rather than binding to them. The results are identical.
+package xxhash
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+)
+
+const (
+	xxhPrime32_1 uint32 = 2654435761
+	xxhPrime32_2 uint32 = 2246822519
+	xxhPrime32_3 uint32 = 3266489917
+	xxhPrime32_4 uint32 = 668265263
+	xxhPrime32_5 uint32 = 374761393
+)
+
+// xxh32 is a streaming XXH32 hash.
+type xxh32 struct {
+	seed  uint32
+	v     [4]uint32
+	total uint64
+	mem   [16]byte
+	n     int // bytes in mem
+}
+
+// New32 returns a new hash.Hash32 computing XXH32 with a seed of 0.
+func New32() hash.Hash32 {
+	return NewWithSeed32(0)
+}
+
+// NewWithSeed32 returns a new hash.Hash32 computing XXH32 with the given seed.
+func NewWithSeed32(seed uint32) hash.Hash32 {
+	h := &xxh32{seed: seed}
+	h.Reset()
+	return h
+}
+
+// Sum32 returns the XXH32 of b with a seed of 0.
+func Sum32(b []byte) uint32 {
+	var h xxh32
+	h.Reset()
+	h.Write(b)
+	return h.Sum32()
+}
+
+func (h *xxh32) Reset() {
+	h.v = [4]uint32{
+		h.seed + xxhPrime32_1 + xxhPrime32_2,
+		h.seed + xxhPrime32_2,
+		h.seed,
+		h.seed - xxhPrime32_1,
+	}
+	h.total = 0
+	h.n = 0
+}
+
+func (h *xxh32) Size() int      { return 4 }
+func (h *xxh32) BlockSize() int { return 16 }
+
+func xxh32Round(acc, input uint32) uint32 {
+	acc += input * xxhPrime32_2
+	acc = bits.RotateLeft32(acc, 13)
+	return acc * xxhPrime32_1
+}
+
+func (h *xxh32) Write(p []byte) (int, error) {
+	n := len(p)
+	h.total += uint64(n)
+	if h.n+len(p) < 16 {
+		h.n += copy(h.mem[h.n:], p)
+		return n, nil
+	}
+	if h.n > 0 {
+		c := copy(h.mem[h.n:], p)
+		h.stripe(h.mem[:])
+		p = p[c:]
+		h.n = 0
+	}
+	for len(p) >= 16 {
+		h.stripe(p)
+		p = p[16:]
+	}
+	h.n = copy(h.mem[:], p)
+	return n, nil
+}
+
+func (h *xxh32) stripe(b []byte) {
+	h.v[0] = xxh32Round(h.v[0], binary.LittleEndian.Uint32(b))
+	h.v[1] = xxh32Round(h.v[1], binary.LittleEndian.Uint32(b[4:]))
+	h.v[2] = xxh32Round(h.v[2], binary.LittleEndian.Uint32(b[8:]))
+	h.v[3] = xxh32Round(h.v[3], binary.LittleEndian.Uint32(b[12:]))
+}
+
+func (h *xxh32) Sum32() uint32 {
+	var acc uint32
+	if h.total >= 16 {
+		acc = bits.RotateLeft32(h.v[0], 1) + bits.RotateLeft32(h.v[1], 7) +
+			bits.RotateLeft32(h.v[2], 12) + bits.RotateLeft32(h.v[3], 18)
+	} else {
+		acc = h.seed + xxhPrime32_5
+	}
+	acc += uint32(h.total)
+
+	b := h.mem[:h.n]
+	for ; len(b) >= 4; b = b[4:] {
+		acc += binary.LittleEndian.Uint32(b) * xxhPrime32_3
+		acc = bits.RotateLeft32(acc, 17) * xxhPrime32_4
+	}
+	for _, c := range b {
+		acc += uint32(c) * xxhPrime32_5
+		acc = bits.RotateLeft32(acc, 11) * xxhPrime32_1
+	}
+
+	acc ^= acc >> 15
+	acc *= xxhPrime32_2
+	acc ^= acc >> 13
+	acc *= xxhPrime32_3
+	acc ^= acc >> 16
+	return acc
+}
+
+func (h *xxh32) Sum(b []byte) []byte {
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], h.Sum32())
+	return append(b, sum[:]...)
+}
@@ -0,0 +1,56 @@
+package xxhash
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestXXH32(t *testing.T) {
+	for _, c := range []struct {
+		in   string
+		want uint32
+	}{
+		{"", 0x02cc5d05},
+		{"a", 0x550d7456},
+		{"abc", 0x32d153ff},
+		{"Nobody inspects the spammish repetition", 0xe2293b2f},
+	} {
+		h := New32()
+		h.Write([]byte(c.in))
+		if got := h.Sum32(); got != c.want {
+			t.Errorf("XXH32(%q) = %#x, want %#x", c.in, got, c.want)
+		}
+		if got := Sum32([]byte(c.in)); got != c.want {
+			t.Errorf("Sum32(%q) = %#x, want %#x", c.in, got, c.want)
+		}
+	}
+
+	// the result does not depend on how the input is split
+	long := strings.Repeat("Nobody inspects the spammish repetition", 10)
+	for _, step := range []int{1, 7, 15, 16, 17} {
+		h := New32()
+		for i := 0; i < len(long); i += step {
+			end := i + step
+			if end > len(long) {
+				end = len(long)
+			}
+			h.Write([]byte(long[i:end]))
+		}
+		if h.Sum32() != Sum32([]byte(long)) {
+			t.Errorf("step %d: got %#x, want %#x", step, h.Sum32(), Sum32([]byte(long)))
+		}
+	}
+}
+
+func TestXXH32Seed(t *testing.T) {
+	h := NewWithSeed32(1)
+	h.Write([]byte("abc"))
+	if h.Sum32() == Sum32([]byte("abc")) {
+		t.Fatalf("seed ignored")
+	}
+	h.Reset()
+	h.Write([]byte("abc"))
+	if got := h.Sum(nil); len(got) != 4 {
+		t.Fatalf("Sum returned %d bytes", len(got))
+	}
+}
@@ -0,0 +1,139 @@
+package xxhash
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+)
+
+const (
+	xxhPrime64_1 uint64 = 11400714785074694791
+	xxhPrime64_2 uint64 = 14029467366897019727
+	xxhPrime64_3 uint64 = 1609587929392839161
+	xxhPrime64_4 uint64 = 9650029242287828579
+	xxhPrime64_5 uint64 = 2870177450012600261
+)
+
+// xxh64 is a streaming XXH64 hash.
+type xxh64 struct {
+	seed  uint64
+	v     [4]uint64
+	total uint64
+	mem   [32]byte
+	n     int // bytes in mem
+}
+
+// New64 returns a new hash.Hash64 computing XXH64 with a seed of 0.
+func New64() hash.Hash64 {
+	return NewWithSeed64(0)
+}
+
+// NewWithSeed64 returns a new hash.Hash64 computing XXH64 with the given seed.
+func NewWithSeed64(seed uint64) hash.Hash64 {
+	h := &xxh64{seed: seed}
+	h.Reset()
+	return h
+}
+
+// Sum64 returns the XXH64 of b with a seed of 0.
+func Sum64(b []byte) uint64 {
+	var h xxh64
+	h.Reset()
+	h.Write(b)
+	return h.Sum64()
+}
+
+func (h *xxh64) Reset() {
+	h.v = [4]uint64{
+		h.seed + xxhPrime64_1 + xxhPrime64_2,
+		h.seed + xxhPrime64_2,
+		h.seed,
+		h.seed - xxhPrime64_1,
+	}
+	h.total = 0
+	h.n = 0
+}
+
+func (h *xxh64) Size() int      { return 8 }
+func (h *xxh64) BlockSize() int { return 32 }
+
+func xxh64Round(acc, input uint64) uint64 {
+	acc += input * xxhPrime64_2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * xxhPrime64_1
+}
+
+func xxh64MergeRound(acc, val uint64) uint64 {
+	acc ^= xxh64Round(0, val)
+	return acc*xxhPrime64_1 + xxhPrime64_4
+}
+
+func (h *xxh64) Write(p []byte) (int, error) {
+	n := len(p)
+	h.total += uint64(n)
+	if h.n+len(p) < 32 {
+		h.n += copy(h.mem[h.n:], p)
+		return n, nil
+	}
+	if h.n > 0 {
+		c := copy(h.mem[h.n:], p)
+		h.stripe(h.mem[:])
+		p = p[c:]
+		h.n = 0
+	}
+	for len(p) >= 32 {
+		h.stripe(p)
+		p = p[32:]
+	}
+	h.n = copy(h.mem[:], p)
+	return n, nil
+}
+
+func (h *xxh64) stripe(b []byte) {
+	h.v[0] = xxh64Round(h.v[0], binary.LittleEndian.Uint64(b))
+	h.v[1] = xxh64Round(h.v[1], binary.LittleEndian.Uint64(b[8:]))
+	h.v[2] = xxh64Round(h.v[2], binary.LittleEndian.Uint64(b[16:]))
+	h.v[3] = xxh64Round(h.v[3], binary.LittleEndian.Uint64(b[24:]))
+}
+
+func (h *xxh64) Sum64() uint64 {
+	var acc uint64
+	if h.total >= 32 {
+		acc = bits.RotateLeft64(h.v[0], 1) + bits.RotateLeft64(h.v[1], 7) +
+			bits.RotateLeft64(h.v[2], 12) + bits.RotateLeft64(h.v[3], 18)
+		for _, v := range h.v {
+			acc = xxh64MergeRound(acc, v)
+		}
+	} else {
+		acc = h.seed + xxhPrime64_5
+	}
+	acc += h.total
+
+	b := h.mem[:h.n]
+	for ; len(b) >= 8; b = b[8:] {
+		acc ^= xxh64Round(0, binary.LittleEndian.Uint64(b))
+		acc = bits.RotateLeft64(acc, 27)*xxhPrime64_1 + xxhPrime64_4
+	}
+	if len(b) >= 4 {
+		acc ^= uint64(binary.LittleEndian.Uint32(b)) * xxhPrime64_1
+		acc = bits.RotateLeft64(acc, 23)*xxhPrime64_2 + xxhPrime64_3
+		b = b[4:]
+	}
+	for _, c := range b {
+		acc ^= uint64(c) * xxhPrime64_5
+		acc = bits.RotateLeft64(acc, 11) * xxhPrime64_1
+	}
+
+	acc ^= acc >> 33
+	acc *= xxhPrime64_2
+	acc ^= acc >> 29
+	acc *= xxhPrime64_3
+	acc ^= acc >> 32
+	return acc
+}
+
+func (h *xxh64) Sum(b []byte) []byte {
+	var sum [8]byte
+	binary.BigEndian.PutUint64(sum[:], h.Sum64())
+	return append(b, sum[:]...)
+}
@@ -0,0 +1,43 @@
+package xxhash
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestXXH64(t *testing.T) {
+	for _, c := range []struct {
+		in   string
+		want uint64
+	}{
+		{"", 0xef46db3751d8e999},
+		{"a", 0xd24ec4f1a98c6e5b},
+		{"abc", 0x44bc2cf5ad770999},
+		{"Nobody inspects the spammish repetition", 0xfbcea83c8a378bf1},
+	} {
+		h := New64()
+		h.Write([]byte(c.in))
+		if got := h.Sum64(); got != c.want {
+			t.Errorf("XXH64(%q) = %#x, want %#x", c.in, got, c.want)
+		}
+		if got := Sum64([]byte(c.in)); got != c.want {
+			t.Errorf("Sum64(%q) = %#x, want %#x", c.in, got, c.want)
+		}
+	}
+
+	// the result does not depend on how the input is split
+	long := strings.Repeat("Nobody inspects the spammish repetition", 10)
+	for _, step := range []int{1, 7, 31, 32, 33} {
+		h := New64()
+		for i := 0; i < len(long); i += step {
+			end := i + step
+			if end > len(long) {
+				end = len(long)
+			}
+			h.Write([]byte(long[i:end]))
+		}
+		if h.Sum64() != Sum64([]byte(long)) {
+			t.Errorf("step %d: got %#x, want %#x", step, h.Sum64(), Sum64([]byte(long)))
+		}
+	}
+}
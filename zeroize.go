@@ -0,0 +1,68 @@
+package lz4
+
+import "unsafe"
+
+// WithZeroize makes Close of a Writer, CompressReader or DecompressReader
+// overwrite with zeros the buffers holding the data of the stream before
+// freeing them: the input and history buffers in C memory, which keep up to
+// the last 64 KiB of plaintext after the stream is done, and the output
+// buffers. Use it for streams carrying secrets. Buffers supplied by the
+// caller, such as those of an Arena, and data already returned to the caller
+// are not wiped. Frames are not covered, since liblz4 keeps their buffers
+// internally.
+func WithZeroize() Option {
+	return func(o *options) {
+		o.zeroize = true
+	}
+}
+
+// zeroize overwrites b with zeros.
+func zeroize(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// zeroizeC overwrites the n bytes of C memory at ptr with zeros, if ptr is not
+// nil.
+func zeroizeC(ptr unsafe.Pointer, n int) {
+	if ptr != nil {
+		zeroize(unsafe.Slice((*byte)(ptr), n))
+	}
+}
+
+// zeroizeBuffers wipes the buffers of w.
+func (w *Writer) zeroizeBuffers() {
+	zeroizeC(w.mallocBuffer, 2*w.blockSize+bufferSeparation)
+	zeroize(w.compressedBuf)
+	zeroize(w.byteBuf[:cap(w.byteBuf)])
+	zeroize(w.verifyBuf)
+}
+
+// zeroizeBuffers wipes the buffers of r.
+func (r *CompressReader) zeroizeBuffers() {
+	if r.independent {
+		zeroizeC(r.mallocBuffer, hugeStreamingBlockSize)
+	} else {
+		zeroizeC(r.mallocBuffer, 2*hugeStreamingBlockSize+bufferSeparation)
+	}
+	zeroizeC(r.compressedBuffer, boundedHugeStreamingBlockSize+blockHeaderSize)
+}
+
+// zeroizeBuffers wipes the buffers of r, unless supplied by the caller.
+func (r *DecompressReader) zeroizeBuffers() {
+	if r.external {
+		return
+	}
+	switch {
+	case r.independent:
+		zeroizeC(r.decompressionBuffer[0], r.maxBlockSize)
+		zeroizeC(r.decompressionBuffer[1], r.maxBlockSize)
+	case r.ringSize > 0:
+		zeroizeC(r.decompressionBuffer[0], r.ringSize)
+	default:
+		zeroizeC(r.decompressionBuffer[0], hugeStreamingBlockSize)
+		zeroizeC(r.decompressionBuffer[1], hugeStreamingBlockSize)
+	}
+	zeroizeC(r.compressedBuffer, r.maxCompressedSize)
+}
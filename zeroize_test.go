@@ -0,0 +1,62 @@
+package lz4
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"unsafe"
+)
+
+func TestZeroize(t *testing.T) {
+	secret := bytes.Repeat([]byte("secret key material "), 100)
+	contains := func(ptr unsafe.Pointer, n int) bool {
+		return ptr != nil && bytes.Contains(unsafe.Slice((*byte)(ptr), n), secret[:40])
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf, WithZeroize())
+	_, err := w.Write(secret)
+	failOnError(t, "Failed writing", err)
+	size := 2*w.blockSize + 8
+	if !contains(w.mallocBuffer, size) {
+		t.Fatal("secret not found in the input buffers")
+	}
+	w.zeroizeBuffers()
+	if contains(w.mallocBuffer, size) {
+		t.Error("secret left in the input buffers of Writer")
+	}
+	failOnError(t, "Failed closing writer", w.Close())
+	stream := buf.Bytes()
+
+	r := NewDecompressReader(bytes.NewReader(stream), WithZeroize()).(*DecompressReader)
+	out, err := io.ReadAll(r)
+	failOnError(t, "Failed reading", err)
+	if !bytes.Equal(out, secret) {
+		t.Fatal("decompressed output != input")
+	}
+	found := func() bool {
+		return contains(r.decompressionBuffer[0], hugeStreamingBlockSize) ||
+			contains(r.decompressionBuffer[1], hugeStreamingBlockSize)
+	}
+	if !found() {
+		t.Fatal("secret not found in the decompression buffers")
+	}
+	r.zeroizeBuffers()
+	if found() {
+		t.Error("secret left in the buffers of DecompressReader")
+	}
+	failOnError(t, "Failed closing reader", r.Close())
+
+	cr := NewCompressReader(bytes.NewReader(secret), WithZeroize())
+	_, err = io.ReadAll(cr)
+	failOnError(t, "Failed compressing", err)
+	size = 2*hugeStreamingBlockSize + 8
+	if !contains(cr.mallocBuffer, size) {
+		t.Fatal("secret not found in the input buffers")
+	}
+	cr.zeroizeBuffers()
+	if contains(cr.mallocBuffer, size) {
+		t.Error("secret left in the input buffers of CompressReader")
+	}
+	failOnError(t, "Failed closing compress reader", cr.Close())
+}